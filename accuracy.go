@@ -0,0 +1,364 @@
+// accuracy.go: prediction error of the model over the keys it was built on.
+// Errors are measured once per version of the model, by the build and
+// otherwise on first use, by routing every key and comparing GetIndex
+// against its true index; they bound the search window of every key.
+
+package rmi
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"sync"
+)
+
+// fewest keys per goroutine when measuring the errors of a model
+const minErrorChunk = 4096
+
+/*
+Prediction error of a single leaf over the keys routed to it
+count: number of keys routed to the leaf
+minResidual, maxResidual: extremes of (true index - predicted index)
+sumAbs, sumSq: sum of the absolute and of the squared residuals
+first, last: smallest and largest true index of the keys routed to the leaf
+*/
+type leafError struct {
+	count                    int
+	minResidual, maxResidual int
+	sumAbs, sumSq            float64
+	first, last              int
+}
+
+// maximum absolute residual of the leaf
+func (e leafError) maxAbs() int {
+	return int(math.Max(math.Abs(float64(e.minResidual)), math.Abs(float64(e.maxResidual))))
+}
+
+// binary search steps over the search window of the leaf (see GetIndexWithBounds)
+func (e leafError) searchSteps() float64 {
+	return math.Log2(float64(e.maxResidual - e.minResidual + 1))
+}
+
+// mean absolute residual of the leaf (0 if no key is routed to it)
+func (e leafError) mean() float64 {
+	if e.count == 0 {
+		return 0
+	}
+
+	return e.sumAbs / float64(e.count)
+}
+
+// distribution of the absolute residuals: number of keys at each distance
+type errorHistogram []int
+
+// add records a key at distance d from its true index
+func (h *errorHistogram) add(d int) {
+	for len(*h) <= d {
+		*h = append(*h, 0)
+	}
+	(*h)[d]++
+}
+
+// merge adds the keys of other to h
+func (h *errorHistogram) merge(other errorHistogram) {
+	for len(*h) < len(other) {
+		*h = append(*h, 0)
+	}
+	for d, count := range other {
+		(*h)[d] += count
+	}
+}
+
+// percentile returns the smallest distance of at least a fraction p
+// of the keys (nearest rank); 0 if there are no keys
+func (h errorHistogram) percentile(p float64) int {
+	total := 0
+	for _, count := range h {
+		total += count
+	}
+
+	rank := int(math.Ceil(p * float64(total)))
+	seen := 0
+	for d, count := range h {
+		if seen += count; seen >= rank && seen > 0 {
+			return d
+		}
+	}
+
+	return 0
+}
+
+// error statistics of a version, computed at most once (and the key
+// boundaries of its leaves, see membership.go, and whether its indices
+// are monotonic, see contract.go)
+type lazyErrors struct {
+	once   sync.Once
+	leaves []leafError
+
+	histOnce sync.Once
+	hist     errorHistogram
+
+	boundsOnce sync.Once
+	bounds     []keyBounds
+
+	monoOnce  sync.Once
+	monotonic bool
+}
+
+// errorsOf returns the per-leaf errors of version v
+func (rmi *RMI) errorsOf(v *version) []leafError {
+	v.errs.once.Do(func() {
+		v.errs.leaves, v.errs.hist = rmi.measureErrors(v, nil)
+	})
+
+	return v.errs.leaves
+}
+
+// histogramOf returns the distribution of the absolute errors of version v;
+// versions whose error bounds were loaded (see Load) are measured on first use
+func (rmi *RMI) histogramOf(v *version) errorHistogram {
+	rmi.errorsOf(v)
+	v.errs.histOnce.Do(func() {
+		if v.errs.hist == nil {
+			_, v.errs.hist = rmi.measureErrors(v, nil)
+		}
+	})
+
+	return v.errs.hist
+}
+
+// measureErrors routes every key through version v and records the residuals
+// per leaf and their distribution (and in keys, if not nil, the leaf and
+// residual of every key); consecutive chunks of keys are measured
+// concurrently and merged
+func (rmi *RMI) measureErrors(v *version, keys *keyResiduals) ([]leafError, errorHistogram) {
+
+	workers := rmi.conf.workers(len(rmi.values) / minErrorChunk)
+	if workers <= 1 {
+		return rmi.measureRange(v, 0, len(rmi.values), keys)
+	}
+
+	chunks := make([][]leafError, workers)
+	hists := make([]errorHistogram, workers)
+	var wg sync.WaitGroup
+	for w := range chunks {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			chunks[w], hists[w] = rmi.measureRange(v, w*len(rmi.values)/workers, (w+1)*len(rmi.values)/workers, keys)
+		}(w)
+	}
+	wg.Wait()
+
+	leaves, hist := chunks[0], hists[0]
+	for w, chunk := range chunks[1:] {
+		for loc := range leaves {
+			leaves[loc].merge(chunk[loc])
+		}
+		hist.merge(hists[w+1])
+	}
+
+	return leaves, hist
+}
+
+// measureRange records the residuals of the keys in [lo, hi) per leaf
+func (rmi *RMI) measureRange(v *version, lo, hi int, keys *keyResiduals) ([]leafError, errorHistogram) {
+
+	p := rmi.conf.pacer()
+	leaves := make([]leafError, len(v.nodes[rmi.depth-1]))
+	hist := errorHistogram{}
+	for i := lo; i < hi; i++ {
+		if (i-lo)%pacingChunk == pacingChunk-1 {
+			p.pause()
+		}
+
+		value := rmi.values[i]
+		_, loc := rmi.leaf(v, value)
+		residual := i - rmi.getIndex(v, value)
+
+		leaves[loc].add(i, residual)
+		hist.add(int(math.Abs(float64(residual))))
+		if keys != nil {
+			keys.loc[i], keys.residual[i] = loc, residual
+		}
+	}
+
+	return leaves, hist
+}
+
+// add records the residual of the key at index i, after the earlier keys of the leaf
+func (e *leafError) add(i, residual int) {
+	if e.count == 0 {
+		e.first = i
+	}
+	e.last = i
+
+	if e.count == 0 || residual < e.minResidual {
+		e.minResidual = residual
+	}
+	if e.count == 0 || residual > e.maxResidual {
+		e.maxResidual = residual
+	}
+	e.sumAbs += math.Abs(float64(residual))
+	e.sumSq += float64(residual) * float64(residual)
+	e.count++
+}
+
+// merge adds the residuals of other, measured over later keys, to e
+func (e *leafError) merge(other leafError) {
+	if other.count == 0 {
+		return
+	} else if e.count == 0 {
+		*e = other
+		return
+	}
+
+	e.minResidual = int(math.Min(float64(e.minResidual), float64(other.minResidual)))
+	e.maxResidual = int(math.Max(float64(e.maxResidual), float64(other.maxResidual)))
+	e.sumAbs += other.sumAbs
+	e.sumSq += other.sumSq
+	e.last = other.last
+	e.count += other.count
+}
+
+/*
+ErrorStats is the absolute error of the model over the keys it was built on
+Keys: number of keys measured
+Max, Mean: maximum and mean distance between GetIndex and the true index
+Median, P99: 50th and 99th percentile of the distance (nearest rank)
+*/
+type ErrorStats struct {
+	Keys        int
+	Max         int
+	Mean        float64
+	Median, P99 int
+}
+
+// ErrorStats returns the error statistics of the current version over all
+// keys, measured by the build and otherwise on first use (see accuracy.go)
+func (rmi *RMI) ErrorStats() ErrorStats {
+	v := rmi.current.Load()
+	hist := rmi.histogramOf(v)
+
+	stats := ErrorStats{Median: hist.percentile(0.5), P99: hist.percentile(0.99)}
+	sum := 0.0
+	for _, e := range rmi.errorsOf(v) {
+		stats.Keys += e.count
+		stats.Max = int(math.Max(float64(stats.Max), float64(e.maxAbs())))
+		sum += e.sumAbs
+	}
+
+	if stats.Keys > 0 {
+		stats.Mean = sum / float64(stats.Keys)
+	}
+
+	return stats
+}
+
+// NumLeaves returns the number of leaf models
+func (rmi *RMI) NumLeaves() int {
+	return len(rmi.current.Load().nodes[rmi.depth-1])
+}
+
+// MaxError returns the maximum distance between GetIndex and the true index over all keys
+// (the MaxError of Contract)
+func (rmi *RMI) MaxError() int {
+	maxErr := 0
+	for _, e := range rmi.errorsOf(rmi.current.Load()) {
+		maxErr = int(math.Max(float64(maxErr), float64(e.maxAbs())))
+	}
+
+	return maxErr
+}
+
+// MeanError returns the mean distance between GetIndex and the true index over all keys
+func (rmi *RMI) MeanError() float64 {
+	sum := 0.0
+	count := 0
+	for _, e := range rmi.errorsOf(rmi.current.Load()) {
+		sum += e.sumAbs
+		count += e.count
+	}
+
+	if count == 0 {
+		return 0
+	}
+
+	return sum / float64(count)
+}
+
+// ExpectedSearchSteps returns the mean over all keys of log2 of the search
+// window of the key's leaf, the expected number of binary search steps of a
+// lookup after the prediction; lookup cost follows it more closely than the
+// mean error, as wide windows cost only logarithmically more to search
+func (rmi *RMI) ExpectedSearchSteps() float64 {
+	sum := 0.0
+	count := 0
+	for _, e := range rmi.errorsOf(rmi.current.Load()) {
+		if e.count > 0 {
+			sum += float64(e.count) * e.searchSteps()
+			count += e.count
+		}
+	}
+
+	if count == 0 {
+		return 0
+	}
+
+	return sum / float64(count)
+}
+
+// LeafMaxError returns the maximum error over the keys routed to the leaf
+func (rmi *RMI) LeafMaxError(leaf int) (int, error) {
+	leaves := rmi.errorsOf(rmi.current.Load())
+	if leaf < 0 || leaf >= len(leaves) {
+		return 0, errors.New("leaf index out of range")
+	}
+
+	return leaves[leaf].maxAbs(), nil
+}
+
+// LeafMeanError returns the mean error over the keys routed to the leaf
+func (rmi *RMI) LeafMeanError(leaf int) (float64, error) {
+	leaves := rmi.errorsOf(rmi.current.Load())
+	if leaf < 0 || leaf >= len(leaves) {
+		return 0, errors.New("leaf index out of range")
+	}
+
+	return leaves[leaf].mean(), nil
+}
+
+// meanStdError returns the mean and standard deviation of the
+// absolute error over all keys for version v
+func (rmi *RMI) meanStdError(v *version) (float64, float64) {
+	sumAbs, sumSq := 0.0, 0.0
+	count := 0
+	for _, e := range rmi.errorsOf(v) {
+		sumAbs += e.sumAbs
+		sumSq += e.sumSq
+		count += e.count
+	}
+
+	if count == 0 {
+		return 0, 0
+	}
+
+	mean := sumAbs / float64(count)
+	return mean, math.Sqrt(math.Max(0, sumSq/float64(count)-mean*mean))
+}
+
+// GetIndexWithBounds returns the window [lo, hi] guaranteed to contain the
+// index of value if it is one of the keys, from the minimum and maximum
+// residual of the keys routed to its leaf; a binary search over the window
+// finds the key exactly
+func (rmi *RMI) GetIndexWithBounds(value *big.Int) (int, int) {
+	v := rmi.current.Load()
+	_, loc := rmi.leaf(v, value)
+	prediction := rmi.getIndex(v, value)
+
+	e := rmi.errorsOf(v)[loc]
+	lo := int(math.Max(0, float64(prediction+e.minResidual)))
+	hi := int(math.Min(float64(v.maxIndex), float64(prediction+e.maxResidual)))
+
+	return lo, hi
+}
@@ -0,0 +1,135 @@
+package rmi
+
+import (
+	"bytes"
+	"math"
+	"sort"
+	"testing"
+)
+
+func TestErrorAccessors(t *testing.T) {
+	rmi, values, _ := generateTestRMI()
+
+	maxErr := 0
+	sum := 0.0
+	for i, value := range values {
+		err := int(math.Abs(float64(rmi.GetIndex(value) - i)))
+		maxErr = int(math.Max(float64(maxErr), float64(err)))
+		sum += float64(err)
+	}
+
+	if rmi.MaxError() != maxErr {
+		t.Fatalf("MaxError %v does not match measured %v", rmi.MaxError(), maxErr)
+	}
+
+	if math.Abs(rmi.MeanError()-sum/float64(len(values))) > 1e-9 {
+		t.Fatalf("MeanError %v does not match measured %v", rmi.MeanError(), sum/float64(len(values)))
+	}
+
+	leafMax := 0
+	for leaf := 0; leaf < rmi.NumLeaves(); leaf++ {
+		e, err := rmi.LeafMaxError(leaf)
+		if err != nil {
+			t.Fatalf("failed to get leaf error %v", err)
+		}
+		leafMax = int(math.Max(float64(leafMax), float64(e)))
+	}
+
+	if leafMax != maxErr {
+		t.Fatalf("largest leaf error %v does not match index error %v", leafMax, maxErr)
+	}
+
+	if _, err := rmi.LeafMeanError(rmi.NumLeaves()); err == nil {
+		t.Fatalf("expected out of range leaf to be rejected")
+	}
+}
+
+func TestGetIndexWithBounds(t *testing.T) {
+	values := sortedTestData(10000)
+	rmi, _ := NewRMI(values, RMIWidthParameter, 3)
+
+	// the bounds were measured by the build
+	if rmi.current.Load().errs.leaves == nil {
+		t.Fatalf("expected the leaf error bounds to be tracked at build time")
+	}
+
+	width := 0
+	for i, value := range values {
+		lo, hi := rmi.GetIndexWithBounds(value)
+		if i < lo || i > hi {
+			t.Fatalf("index %v of key outside its bounds [%v, %v]", i, lo, hi)
+		}
+
+		index, ok := searchRange(values, value, lo, hi)
+		if !ok || values[index].Cmp(value) != 0 {
+			t.Fatalf("binary search within the bounds failed for key %v", i)
+		}
+		width = int(math.Max(float64(width), float64(hi-lo)))
+	}
+
+	if width > 2*rmi.MaxError() {
+		t.Fatalf("window %v wider than twice the max error %v", width, rmi.MaxError())
+	}
+}
+
+func TestExpectedSearchSteps(t *testing.T) {
+
+	rmi, values, err := generateTestRMI()
+	if err != nil {
+		t.Fatalf("Failed to build RMI %v\n", err)
+	}
+
+	steps := 0.0
+	for _, value := range values {
+		lo, hi := rmi.GetIndexWithBounds(value)
+		steps += math.Log2(float64(hi - lo + 1))
+	}
+	steps /= float64(len(values))
+
+	// windows clamped at either end of the keys are narrower
+	if expected := rmi.ExpectedSearchSteps(); expected < steps-1e-9 || expected > steps+0.01 {
+		t.Fatalf("expected search steps %v, measured %v", expected, steps)
+	}
+}
+
+func TestErrorStats(t *testing.T) {
+	values := sortedTestData(10000)
+	rmi, _ := NewRMI(values, 8, 2, WithParallelism(4))
+
+	distances := make([]int, len(values))
+	sum := 0
+	for i, value := range values {
+		distances[i] = abs(rmi.GetIndex(value) - i)
+		sum += distances[i]
+	}
+	sort.Ints(distances)
+
+	stats := rmi.ErrorStats()
+	if stats.Keys != len(values) || stats.Max != distances[len(values)-1] || stats.Max != rmi.MaxError() {
+		t.Fatalf("unexpected key count or max error %+v", stats)
+	}
+
+	if math.Abs(stats.Mean-float64(sum)/float64(len(values))) > 1e-9 {
+		t.Fatalf("mean error %v, expected %v", stats.Mean, float64(sum)/float64(len(values)))
+	}
+
+	if stats.Median != distances[len(values)/2-1] || stats.P99 != distances[len(values)*99/100-1] {
+		t.Fatalf("percentiles %v, %v, expected %v, %v", stats.Median, stats.P99, distances[len(values)/2-1], distances[len(values)*99/100-1])
+	}
+
+	if stats.Median > stats.P99 || stats.P99 > stats.Max {
+		t.Fatalf("percentiles out of order %+v", stats)
+	}
+
+	// loaded models measure the distribution on first use
+	var buf bytes.Buffer
+	rmi.Save(&buf)
+	loaded, err := Load(&buf, values, rmi.Metadata())
+	if err != nil {
+		t.Fatalf("Failed to load RMI %v\n", err)
+	}
+
+	if loaded.ErrorStats() != stats {
+		t.Fatalf("loaded stats %+v, expected %+v", loaded.ErrorStats(), stats)
+	}
+}
@@ -0,0 +1,282 @@
+// audit.go: commitments to the trained coefficients and per-query proofs.
+// The commitment is the root of a Merkle tree over the encoded nodes (layer
+// by layer) bound to the shape of the model; a proof carries the nodes used
+// by a query with their Merkle paths, so a client of an outsourced index can
+// check that a response was computed from the committed coefficients.
+
+package rmi
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"sync"
+)
+
+// domain separation of the hashes in the commitment
+const (
+	auditLeafTag   = 0
+	auditInnerTag  = 1
+	auditCommitTag = 2
+)
+
+/*
+Merkle tree over the nodes of a version
+levels: hashes of every level, levels[0] are the node hashes and the
+last level is the root (an odd hash at the end of a level is promoted)
+*/
+type merkleTree struct {
+	levels [][][32]byte
+}
+
+// Merkle tree of a version, computed at most once
+type lazyTree struct {
+	once sync.Once
+	tree *merkleTree
+}
+
+/*
+AuditProof proves which committed nodes served a query
+Width, Depth, MaxIndex: shape of the committed model
+Positions: position of the node used at each layer
+Nodes: encoding of the node used at each layer (see Node.MarshalBinary)
+Paths: Merkle siblings of each node from the bottom level up
+*/
+type AuditProof struct {
+	Width, Depth, MaxIndex int
+	Positions              []int
+	Nodes                  [][]byte
+	Paths                  [][][32]byte
+}
+
+// hashes a node at position pos of layer
+func hashNode(layer, pos int, encoded []byte) [32]byte {
+	buf := []byte{auditLeafTag}
+	buf = binary.BigEndian.AppendUint32(buf, uint32(layer))
+	buf = binary.BigEndian.AppendUint32(buf, uint32(pos))
+	return sha256.Sum256(append(buf, encoded...))
+}
+
+// hashes two children in the Merkle tree
+func hashInner(left, right [32]byte) [32]byte {
+	buf := append([]byte{auditInnerTag}, left[:]...)
+	return sha256.Sum256(append(buf, right[:]...))
+}
+
+// commitment binding the Merkle root to the shape of the model
+func commitment(width, depth, maxIndex int, root [32]byte) [32]byte {
+	buf := []byte{auditCommitTag}
+	buf = binary.BigEndian.AppendUint32(buf, uint32(width))
+	buf = binary.BigEndian.AppendUint32(buf, uint32(depth))
+	buf = binary.BigEndian.AppendUint64(buf, uint64(int64(maxIndex)))
+	return sha256.Sum256(append(buf, root[:]...))
+}
+
+// treeOf returns the Merkle tree of version v
+func (v *version) treeOf() *merkleTree {
+	v.tree.once.Do(func() {
+		hashes := make([][32]byte, 0)
+		for layer, nodes := range v.nodes {
+			for pos, node := range nodes {
+				hashes = append(hashes, hashNode(layer, pos, appendNode(nil, node)))
+			}
+		}
+
+		tree := &merkleTree{[][][32]byte{hashes}}
+		for len(hashes) > 1 {
+			next := make([][32]byte, 0, (len(hashes)+1)/2)
+			for i := 0; i < len(hashes); i += 2 {
+				if i+1 == len(hashes) {
+					next = append(next, hashes[i])
+				} else {
+					next = append(next, hashInner(hashes[i], hashes[i+1]))
+				}
+			}
+			tree.levels = append(tree.levels, next)
+			hashes = next
+		}
+
+		v.tree.tree = tree
+	})
+
+	return v.tree.tree
+}
+
+// path returns the Merkle siblings of the hash at index i of the bottom level
+func (t *merkleTree) path(i int) [][32]byte {
+	path := make([][32]byte, 0)
+	for _, level := range t.levels[:len(t.levels)-1] {
+		if sibling := i ^ 1; sibling < len(level) {
+			path = append(path, level[sibling])
+		}
+		i /= 2
+	}
+
+	return path
+}
+
+// Commitment returns the commitment to the coefficients and shape
+// of the current version of the model
+func (rmi *RMI) Commitment() [32]byte {
+	v := rmi.current.Load()
+	levels := v.treeOf().levels
+
+	return commitment(rmi.width, rmi.depth, v.maxIndex, levels[len(levels)-1][0])
+}
+
+// GetIndexWithProof returns GetIndex together with a proof of the nodes that
+// computed it; calibrated models, models with a neighbor clamp and leaves
+// with last-mile tables are not covered by the commitment and cannot be audited
+func (rmi *RMI) GetIndexWithProof(value *big.Int) (int, *AuditProof, error) {
+
+	v := rmi.current.Load()
+	if v.calib != nil {
+		return 0, nil, errors.New("calibrated models cannot be audited")
+	} else if rmi.conf.neighborClamp {
+		return 0, nil, errors.New("models with a neighbor clamp cannot be audited")
+	}
+
+	proof := &AuditProof{Width: rmi.width, Depth: rmi.depth, MaxIndex: v.maxIndex}
+	tree := v.treeOf()
+
+	s := getScratch(value)
+	defer putScratch(s)
+
+	positions := route(s, rmi.width, v.maxIndex, len(v.nodes), func(layer, pos int) *Node {
+		return v.nodes[layer][pos]
+	})
+
+	leaf := v.nodes[rmi.depth-1][positions[rmi.depth-1]]
+	if leaf.exact != nil {
+		return 0, nil, errors.New("leaves with last-mile tables cannot be audited")
+	}
+
+	offset := 0
+	for layer, pos := range positions {
+		proof.Positions = append(proof.Positions, pos)
+		proof.Nodes = append(proof.Nodes, appendNode(nil, v.nodes[layer][pos]))
+		proof.Paths = append(proof.Paths, tree.path(offset+pos))
+		offset += len(v.nodes[layer])
+	}
+
+	return clampIndex(s.eval(leaf.modelFor(value)), v.maxIndex), proof, nil
+}
+
+// route returns the position of the node used at each of the depth layers
+// by the query held by s, with the same arithmetic as leafWith; node returns
+// the node at a position of a layer
+func route(s *scratch, width, maxIndex, depth int, node func(layer, pos int) *Node) []int {
+
+	s.width.SetFloat64(float64(width))
+	s.maxIndex.SetFloat64(routingMaxIndex(maxIndex))
+
+	positions := make([]int, depth)
+	size := 1
+	for layer := 1; layer < depth; layer++ {
+		res := s.eval(node(layer-1, positions[layer-1]))
+		res.Quo(res, s.maxIndex)
+		res.Mul(res, s.width)
+
+		size *= width
+		positions[layer] = clampIndex(res, size-1)
+		s.width.Mul(s.width, s.factor.SetFloat64(float64(width)))
+	}
+
+	return positions
+}
+
+// VerifyAudit checks that index is the response of the committed model to
+// value: every node of the proof is in the commitment, the nodes form the
+// route of value from the root to a leaf and the leaf predicts index
+func VerifyAudit(committed [32]byte, value *big.Int, index int, proof *AuditProof) error {
+
+	if proof.Width < 1 || proof.Depth < 1 || len(proof.Positions) != proof.Depth ||
+		len(proof.Nodes) != proof.Depth || len(proof.Paths) != proof.Depth {
+		return errors.New("malformed audit proof")
+	}
+
+	// number of nodes per layer and in total
+	sizes := make([]int, proof.Depth)
+	total := 0
+	for layer := range sizes {
+		sizes[layer] = int(numLeaves(proof.Width, layer+1))
+		total += sizes[layer]
+	}
+
+	nodes := make([]*Node, proof.Depth)
+	offset := 0
+	var root [32]byte
+	for layer := range nodes {
+		pos := proof.Positions[layer]
+		if pos < 0 || pos >= sizes[layer] {
+			return errors.New("node position out of range")
+		}
+
+		node := &Node{}
+		if err := node.UnmarshalBinary(proof.Nodes[layer]); err != nil {
+			return err
+		}
+		nodes[layer] = node
+
+		hash, err := merkleRoot(hashNode(layer, pos, proof.Nodes[layer]), offset+pos, total, proof.Paths[layer])
+		if err != nil {
+			return err
+		}
+		if layer > 0 && hash != root {
+			return errors.New("nodes of the proof belong to different trees")
+		}
+		root = hash
+		offset += sizes[layer]
+	}
+
+	if commitment(proof.Width, proof.Depth, proof.MaxIndex, root) != committed {
+		return errors.New("proof does not match the commitment")
+	}
+
+	// replay the route of the query over the nodes of the proof
+	s := getScratch(value)
+	defer putScratch(s)
+
+	positions := route(s, proof.Width, proof.MaxIndex, proof.Depth, func(layer, pos int) *Node {
+		return nodes[layer]
+	})
+	for layer, pos := range positions {
+		if pos != proof.Positions[layer] {
+			return errors.New("nodes of the proof are not the route of the query")
+		}
+	}
+
+	if clampIndex(s.eval(nodes[proof.Depth-1].modelFor(value)), proof.MaxIndex) != index {
+		return errors.New("leaf of the proof does not predict the index")
+	}
+
+	return nil
+}
+
+// merkleRoot recomputes the root from the hash at index i of the
+// bottom level of a tree over n hashes and its Merkle siblings
+func merkleRoot(hash [32]byte, i, n int, path [][32]byte) ([32]byte, error) {
+	for n > 1 {
+		if sibling := i ^ 1; sibling < n {
+			if len(path) == 0 {
+				return hash, errors.New("merkle path too short")
+			}
+
+			if i%2 == 0 {
+				hash = hashInner(hash, path[0])
+			} else {
+				hash = hashInner(path[0], hash)
+			}
+			path = path[1:]
+		}
+		i /= 2
+		n = (n + 1) / 2
+	}
+
+	if len(path) != 0 {
+		return hash, errors.New("merkle path too long")
+	}
+
+	return hash, nil
+}
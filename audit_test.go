@@ -0,0 +1,55 @@
+package rmi
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestAuditProofs(t *testing.T) {
+	values := sortedTestData(3000)
+
+	for _, opts := range [][]Option{nil, {WithLeafEnsemble()}} {
+		rmi, _ := NewRMI(values, 10, 3, opts...)
+		committed := rmi.Commitment()
+
+		for i := 0; i < len(values); i += 11 {
+			index, proof, err := rmi.GetIndexWithProof(values[i])
+			if err != nil {
+				t.Fatalf("Failed to prove query %v\n", err)
+			}
+			if index != rmi.GetIndex(values[i]) {
+				t.Fatalf("proved index %v differs from GetIndex %v", index, rmi.GetIndex(values[i]))
+			}
+			if err := VerifyAudit(committed, values[i], index, proof); err != nil {
+				t.Fatalf("Failed to verify the proof of key %v: %v\n", i, err)
+			}
+		}
+	}
+
+	rmi, _ := NewRMI(values, 10, 3)
+	committed := rmi.Commitment()
+	index, proof, _ := rmi.GetIndexWithProof(values[100])
+
+	// a wrong response, another query or tampered coefficients are rejected
+	if VerifyAudit(committed, values[100], index+5, proof) == nil {
+		t.Fatalf("expected a wrong index to be rejected")
+	}
+	if VerifyAudit(committed, new(big.Int).Add(values[len(values)-1], values[len(values)-1]), index, proof) == nil {
+		t.Fatalf("expected a proof for another query to be rejected")
+	}
+
+	tampered := *proof
+	tampered.Nodes = append([][]byte{}, proof.Nodes...)
+	leaf := &Node{}
+	leaf.UnmarshalBinary(proof.Nodes[2])
+	tampered.Nodes[2] = appendNode(nil, newLinearNode(leaf.m, new(big.Float).Add(leaf.b, big.NewFloat(5))))
+	if VerifyAudit(committed, values[100], index+5, &tampered) == nil {
+		t.Fatalf("expected tampered coefficients to be rejected")
+	}
+
+	// retraining changes the commitment
+	rmi.RetrainLeaf(0, values[:10], indicesOf(values)[:10])
+	if rmi.Commitment() == committed {
+		t.Fatalf("expected a new commitment after retraining a leaf")
+	}
+}
@@ -0,0 +1,100 @@
+// autofit.go: automatic model selection per node. In the ModelAuto mode
+// every node is trained as each candidate model type (linear regression,
+// log-linear regression, the line through its first and last key and a
+// constant), as a layer of that type would train it, and keeps the
+// candidate with the smallest max error over its keys, as the optimizer of
+// the reference RMI does per layer; ModelTypes reports the chosen types.
+
+package rmi
+
+import (
+	"math"
+	"math/big"
+)
+
+// candidate model types of ModelAuto, ties go to the earlier one
+var autoCandidates = []ModelType{ModelLinear, ModelLogLinear, ModelEndpoint, ModelConstant}
+
+// trains the constant model of a single node at the middle index of its
+// keys, the constant with the smallest max error
+func trainConstant(task buildTask) *Node {
+
+	if len(task.indices) < 2 {
+		return trainNode(task)
+	}
+
+	b := new(big.Float).SetInt(task.indices[0])
+	b.Add(b, new(big.Float).SetInt(task.indices[len(task.indices)-1]))
+	b.Quo(b, big.NewFloat(2))
+
+	return &Node{m: big.NewFloat(0), b: b, w: big.NewFloat(0), fit: ModelConstant}
+}
+
+// auto returns the training function of ModelAuto nodes, which trains every
+// candidate model on the task (at the configured precision and with the
+// ill-conditioning guard, see fitOf) and keeps the one with the smallest
+// max error over the keys of the task
+func (c *config) auto() func(buildTask) *Node {
+
+	candidates := make([]func(buildTask) *Node, len(autoCandidates))
+	for i, t := range autoCandidates {
+		candidates[i] = c.fitOf(t)
+	}
+
+	return func(task buildTask) *Node {
+
+		if len(task.indices) < 2 {
+			return candidates[0](task)
+		}
+
+		var best *Node
+		bestErr := math.Inf(1)
+		for _, train := range candidates {
+			node := train(task)
+			if err := maxFitError(node, task); err < bestErr {
+				best, bestErr = node, err
+			}
+		}
+
+		return best
+	}
+}
+
+// maxFitError returns the max distance between the output of node and the
+// index of the keys of task
+func maxFitError(node *Node, task buildTask) float64 {
+
+	maxErr := 0.0
+	for i, value := range task.values {
+		prediction, _ := node.predict(value).Float64()
+		index, _ := new(big.Float).SetInt(task.indices[i]).Float64()
+		maxErr = math.Max(maxErr, math.Abs(index-prediction))
+	}
+
+	return maxErr
+}
+
+// modelType returns the model type the node was trained as
+func (node *Node) modelType() ModelType {
+	if node.log {
+		return ModelLogLinear
+	}
+
+	return node.fit
+}
+
+// ModelTypes returns the model type of every node of the current version,
+// layer by layer (ensemble leaves report their first model)
+func (rmi *RMI) ModelTypes() [][]ModelType {
+
+	v := rmi.current.Load()
+	types := make([][]ModelType, len(v.nodes))
+	for layer, nodes := range v.nodes {
+		types[layer] = make([]ModelType, len(nodes))
+		for i, node := range nodes {
+			types[layer][i] = node.modelType()
+		}
+	}
+
+	return types
+}
@@ -0,0 +1,85 @@
+// batch.go: training of a whole layer of linear regressions from sufficient
+// statistics. The tasks of a layer are consecutive slices of the keys, so a
+// single pass over the keys accumulates the exact sums of every node into
+// one array (consecutive chunks of nodes in parallel) and every node is
+// then solved from its sums, instead of fitting each node with separate
+// passes for its means and its centred sums.
+
+package rmi
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/sachaservan/rmi/regress"
+)
+
+// trainBatch trains the nodes of a layer with the linear regression of
+// their tasks (see trainNode), ill-conditioned tasks with the fallback;
+// with WithLazySortCheck the keys of every task are checked to be sorted
+func (rmi *RMI) trainBatch(nodes []*Node, tasks []buildTask) error {
+
+	sums := make([]regress.Sums, len(tasks))
+
+	workers := rmi.conf.workers(len(tasks))
+	errs := make([]error, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			errs[w] = rmi.accumulate(sums, tasks, w*len(tasks)/workers, (w+1)*len(tasks)/workers)
+		}(w)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	rmi.solveBatch(nodes, tasks, sums)
+	return nil
+}
+
+// solveBatch trains the nodes of a layer from the sums of their tasks; the
+// fallbacks only read the first, median and last key of a task
+func (rmi *RMI) solveBatch(nodes []*Node, tasks []buildTask, sums []regress.Sums) {
+
+	guarded := rmi.conf.guarded(trainNode)
+	for i, task := range tasks {
+		switch {
+		case len(task.indices) < 2:
+			nodes[i] = trainNode(task)
+		case rmi.conf.illConditioned != FallbackNone && illConditioned(task.values):
+			nodes[i] = guarded(task)
+		default:
+			b, m, w := sumsCoefficients(&sums[i], rmi.conf.precision, task.values, task.indices)
+			nodes[i] = &Node{m: m, b: b, w: w}
+		}
+	}
+}
+
+// accumulate adds the keys of the tasks in [lo, hi) to their sums
+func (rmi *RMI) accumulate(sums []regress.Sums, tasks []buildTask, lo, hi int) error {
+
+	p := rmi.conf.pacer()
+	keys := 0
+	for i := lo; i < hi; i++ {
+		values := tasks[i].values
+		for j, value := range values {
+			if rmi.conf.lazySort && j > 0 && values[j-1].Cmp(value) == 1 {
+				return fmt.Errorf("values must be in sorted order (key %v)", tasks[i].indices[j])
+			}
+			sums[i].Add(value, tasks[i].indices[j])
+		}
+
+		if keys += len(tasks[i].values); keys >= pacingChunk {
+			p.pause()
+			keys = 0
+		}
+	}
+
+	return nil
+}
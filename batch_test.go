@@ -0,0 +1,64 @@
+package rmi
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestTrainBatch(t *testing.T) {
+	values := sortedTestData(20000)
+	indices := make([]*big.Int, len(values))
+	for i := range indices {
+		indices[i] = big.NewInt(int64(i))
+	}
+
+	// a layer of tasks of all sizes, including empty and single-key tasks
+	rmi := &RMI{width: 64, depth: 2, conf: config{parallelism: 4}}
+	tasks := rmi.splitTask(buildTask{values[:2000], indices[:2000], big.NewInt(0)}, nil)
+	tasks = append(tasks, buildTask{values[2000:2001], indices[2000:2001], indices[2000]})
+	tasks = append(tasks, buildTask{values[2001:], indices[2001:], indices[2001]})
+
+	batch := make([]*Node, len(tasks))
+	rmi.trainBatch(batch, tasks)
+
+	for i, task := range tasks {
+		node := trainNode(task)
+		if batch[i].m.Cmp(node.m) != 0 || batch[i].b.Cmp(node.b) != 0 {
+			t.Fatalf("task %v: batch trained %v x + %v, expected %v x + %v", i, batch[i].m, batch[i].b, node.m, node.b)
+		}
+	}
+
+	// ill-conditioned tasks fall back as in the per-node build
+	prefixed := prefixedKeys(1000)
+	batch = batch[:1]
+	rmi.trainBatch(batch, []buildTask{{prefixed, indices[:1000], big.NewInt(0)}})
+	if !batch[0].fallback {
+		t.Fatalf("expected the ill-conditioned task to fall back")
+	}
+}
+
+func TestLazySortCheck(t *testing.T) {
+	values := sortedTestData(20000)
+
+	eager, _ := NewRMI(values, 16, 3)
+	lazy, err := NewRMI(values, 16, 3, WithLazySortCheck())
+	if err != nil {
+		t.Fatalf("Failed to build RMI %v\n", err)
+	}
+	for i, value := range values {
+		if lazy.GetIndex(value) != eager.GetIndex(value) {
+			t.Fatalf("lazily checked index of key %v differs", i)
+		}
+	}
+
+	// the last key swapped out of order, also for roots that are no regression
+	unsorted := append([]*big.Int{}, values...)
+	last := len(unsorted) - 1
+	unsorted[last-1], unsorted[last] = unsorted[last], unsorted[last-1]
+	for _, opts := range [][]Option{{WithLazySortCheck()}, {WithLazySortCheck(), WithModelType(ModelEndpoint)},
+		{WithLazySortCheck(), WithRadixRoot()}, {WithLazySortCheck(), WithParallelism(4)}} {
+		if _, err := NewRMI(unsorted, 16, 3, opts...); err == nil {
+			t.Fatalf("expected a lazy sort check error")
+		}
+	}
+}
@@ -0,0 +1,93 @@
+// blind.go: evaluation of the model over affinely blinded keys.
+// A caller holding the secret blinding y = a*x + c folds it into the
+// coefficients of every node, m*x + b = (m/a)*y + (b - (m/a)*c), so an
+// untrusted server can evaluate the blinded model on blinded queries and
+// return the index without ever seeing a raw key.
+
+package rmi
+
+import (
+	"errors"
+	"math/big"
+)
+
+// extra bits of precision kept by blinded coefficients so that the blinded
+// model routes and predicts like the model it was derived from (up to the
+// truncation of predictions that are within rounding of an integer)
+const blindPrecision = 128
+
+// Blinded is a model that answers queries on keys blinded with y = a*x + c
+type Blinded struct {
+	rmi *RMI
+}
+
+// Blind returns the current version of the model with the blinding
+// y = a*x + c folded into its coefficients; a must be non-zero, leaves
+// with last-mile tables (raw keys) and models with a neighbor clamp cannot
+// be blinded and ensemble leaves (breakpoint keys) only for a > 0
+func (rmi *RMI) Blind(a, c *big.Int) (*Blinded, error) {
+
+	if a.Sign() == 0 {
+		return nil, errors.New("blinding factor must be non-zero")
+	}
+
+	if rmi.conf.neighborClamp {
+		return nil, errors.New("models with a neighbor clamp cannot be blinded")
+	}
+
+	v := rmi.current.Load()
+
+	prec := uint(blindPrecision + a.BitLen() + c.BitLen())
+	fa := new(big.Float).SetInt(a)
+	fc := new(big.Float).SetInt(c)
+
+	for _, layer := range v.nodes {
+		for _, node := range layer {
+			if node.log {
+				return nil, errors.New("log-linear models cannot be blinded")
+			}
+		}
+	}
+
+	blindNode := func(node *Node) *Node {
+		m := new(big.Float).SetPrec(prec).Quo(node.m, fa)
+		b := new(big.Float).SetPrec(prec).Mul(m, fc)
+		b.Sub(node.b, b)
+		return &Node{m: m, b: b, w: xIntercept(m, b)}
+	}
+
+	nodes := make([][]*Node, len(v.nodes))
+	for i, layer := range v.nodes {
+		nodes[i] = make([]*Node, len(layer))
+		for j, node := range layer {
+			if node.exact != nil {
+				return nil, errors.New("leaves with last-mile tables cannot be blinded")
+			}
+
+			nodes[i][j] = blindNode(node)
+			if node.alt == nil {
+				continue
+			}
+
+			if a.Sign() == -1 {
+				return nil, errors.New("ensemble leaves can only be blinded with a positive factor")
+			}
+			nodes[i][j].alt = blindNode(node.alt)
+			nodes[i][j].breakpoint = new(big.Int).Mul(a, node.breakpoint)
+			nodes[i][j].breakpoint.Add(nodes[i][j].breakpoint, c)
+		}
+	}
+
+	blinded := &RMI{width: rmi.width, depth: rmi.depth}
+	next := newVersion(v.epoch, nodes, v.maxIndex)
+	next.calib = v.calib
+	blinded.current.Store(next.pack(0))
+
+	return &Blinded{blinded}, nil
+}
+
+// GetIndex returns the approximate index of the key x for the blinded
+// query y = a*x + c
+func (b *Blinded) GetIndex(blindedValue *big.Int) int {
+	return b.rmi.getIndex(b.rmi.current.Load(), blindedValue)
+}
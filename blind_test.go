@@ -0,0 +1,55 @@
+package rmi
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestBlinded(t *testing.T) {
+	values := sortedTestData(5000)
+
+	a, _ := new(big.Int).SetString("982451653000000000000017", 10)
+	c, _ := new(big.Int).SetString("-77777777777777777777777777", 10)
+	blind := func(x *big.Int) *big.Int {
+		y := new(big.Int).Mul(a, x)
+		return y.Add(y, c)
+	}
+
+	for _, opts := range [][]Option{nil, {WithLeafEnsemble()}, {WithCalibration()}} {
+		rmi, _ := NewRMI(values, RMIWidthParameter, 3, opts...)
+		blinded, err := rmi.Blind(a, c)
+		if err != nil {
+			t.Fatalf("Failed to blind the model %v\n", err)
+		}
+
+		// the blinded coefficients are rounded differently, so a
+		// prediction that is (almost) an integer may truncate differently
+		mismatches := 0
+		for i, value := range values {
+			if diff := abs(blinded.GetIndex(blind(value)) - rmi.GetIndex(value)); diff > 1 {
+				t.Fatalf("blinded query of key %v returned %v instead of %v",
+					i, blinded.GetIndex(blind(value)), rmi.GetIndex(value))
+			} else if diff == 1 {
+				mismatches++
+			}
+		}
+		if mismatches > len(values)/100 {
+			t.Fatalf("%v blinded queries were off by one", mismatches)
+		}
+	}
+
+	// a negative factor reverses the key order but not the index
+	rmi, _ := NewRMI(values, RMIWidthParameter, 3)
+	neg := new(big.Int).Neg(a)
+	blinded, _ := rmi.Blind(neg, c)
+	for i := 0; i < len(values); i += 7 {
+		y := new(big.Int).Mul(neg, values[i])
+		if abs(blinded.GetIndex(y.Add(y, c))-rmi.GetIndex(values[i])) > 1 {
+			t.Fatalf("negatively blinded query of key %v differs", i)
+		}
+	}
+
+	if _, err := rmi.Blind(big.NewInt(0), c); err == nil {
+		t.Fatalf("expected an error for a zero blinding factor")
+	}
+}
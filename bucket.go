@@ -0,0 +1,52 @@
+// bucket.go: fixed-size bucket mode; the leaves predict the buckets
+// of the data array that may hold a key rather than an exact index
+
+package rmi
+
+import (
+	"math/big"
+)
+
+// BucketSize returns the number of keys per bucket (1 if WithBucketSize was not used)
+func (rmi *RMI) BucketSize() int {
+	if rmi.conf.bucketSize <= 0 {
+		return 1
+	}
+
+	return rmi.conf.bucketSize
+}
+
+// NumBuckets returns the number of buckets the keys are split into
+func (rmi *RMI) NumBuckets() int {
+	size := rmi.BucketSize()
+	return (len(rmi.values) + size - 1) / size
+}
+
+// BucketBounds returns the slice bounds [start, end) of the given bucket
+func (rmi *RMI) BucketBounds(bucket int) (int, int) {
+	size := rmi.BucketSize()
+
+	start := bucket * size
+	end := start + size
+	if end > len(rmi.values) {
+		end = len(rmi.values)
+	}
+
+	return start, end
+}
+
+// GetBucket returns the buckets [first, last] covering the window of
+// GetIndexWithBounds for value and their slice bounds [start, end) in the
+// data array, which hold value if it is one of the keys; as the window is
+// not aligned to the buckets a key may span two buckets (or more if the
+// error exceeds the bucket size)
+func (rmi *RMI) GetBucket(value *big.Int) (int, int, int, int) {
+	size := rmi.BucketSize()
+	lo, hi := rmi.GetIndexWithBounds(value)
+
+	first, last := lo/size, hi/size
+	start, _ := rmi.BucketBounds(first)
+	_, end := rmi.BucketBounds(last)
+
+	return first, last, start, end
+}
@@ -0,0 +1,35 @@
+package rmi
+
+import (
+	"testing"
+)
+
+func TestGetBucket(t *testing.T) {
+	values := sortedTestData(1000)
+	rmi, _ := NewRMI(values, RMIWidthParameter, RMIDepthParameter, WithBucketSize(64))
+
+	if rmi.NumBuckets() != 16 {
+		t.Fatalf("expected 16 buckets, got %v", rmi.NumBuckets())
+	}
+
+	if start, end := rmi.BucketBounds(15); start != 960 || end != 1000 {
+		t.Fatalf("unexpected bounds of the last bucket [%v, %v)", start, end)
+	}
+
+	// every key is in the predicted buckets, not just its predicted index
+	spanned := 0
+	for i, value := range values {
+		first, last, start, end := rmi.GetBucket(value)
+		if i < start || i >= end || start != first*64 || end != min(len(values), (last+1)*64) {
+			t.Fatalf("key %v is not in predicted buckets [%v, %v] at [%v, %v)", i, first, last, start, end)
+		}
+
+		if last > first {
+			spanned++
+		}
+	}
+
+	if spanned == 0 {
+		t.Fatalf("expected some keys to span more than one bucket")
+	}
+}
@@ -0,0 +1,243 @@
+// bundle.go: single-file learned index artifacts. A bundle holds a saved
+// model together with the sorted keys it was built over, so that one read
+// (or one mmap, see OpenBundle) yields a self-contained read-only index.
+// Keys that all fit in a uint64 are packed as a little-endian array aligned
+// to 8 bytes and used in place when the bytes are suitably aligned on a
+// little-endian platform; other keys are stored as gob encoded big.Ints.
+//
+// Layout: magic, key kind, number of keys, length of the model, the model
+// (see Save), padding to a multiple of 8 bytes and the keys.
+
+package rmi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"unsafe"
+)
+
+// magic bytes at the start of every bundle
+var bundleMagic = []byte("RMIB")
+
+// encodings of the keys of a bundle
+const (
+	bundleBigInt byte = iota
+	bundleUint64
+)
+
+// size of the fixed bundle header: magic, kind, padding, key count and model length
+const bundleHeaderSize = 24
+
+/*
+Bundle is a read-only index loaded from a single file
+rmi: the model
+keys: the packed keys (nil unless every key fits in a uint64)
+data: the bytes of the bundle (which keys may alias)
+release: unmaps data (nil unless the bundle was mapped, see OpenBundle)
+*/
+type Bundle struct {
+	rmi     *RMI
+	keys    []uint64
+	data    []byte
+	release func() error
+}
+
+// WriteBundle writes the current version of the model and its keys to w
+// as a single bundle; the keys must be retained (see Load)
+func (rmi *RMI) WriteBundle(w io.Writer) error {
+
+	if rmi.values == nil {
+		return errors.New("a bundle needs the keys of the model")
+	}
+
+	model := rmi.encode()
+
+	kind := bundleUint64
+	for _, value := range rmi.values {
+		if value.Sign() < 0 || value.BitLen() > 64 {
+			kind = bundleBigInt
+			break
+		}
+	}
+
+	buf := append(make([]byte, 0, bundleHeaderSize+len(model)), bundleMagic...)
+	buf = append(buf, kind, 0, 0, 0)
+	buf = binary.BigEndian.AppendUint64(buf, uint64(len(rmi.values)))
+	buf = binary.BigEndian.AppendUint64(buf, uint64(len(model)))
+	buf = append(buf, model...)
+	buf = append(buf, make([]byte, paddingTo8(len(buf)))...)
+
+	for _, value := range rmi.values {
+		if kind == bundleUint64 {
+			buf = binary.LittleEndian.AppendUint64(buf, value.Uint64())
+			continue
+		}
+
+		encoded, err := value.GobEncode()
+		if err != nil {
+			return err
+		}
+		buf = appendString(buf, string(encoded))
+	}
+
+	_, err := w.Write(buf)
+	return err
+}
+
+// DecodeBundle reads a bundle written by WriteBundle; packed keys alias data
+// when possible, so data must not be modified while the bundle is in use
+func DecodeBundle(data []byte) (*Bundle, error) {
+
+	if !bytes.HasPrefix(data, bundleMagic) {
+		return nil, errors.New("data is not an RMI bundle")
+	}
+
+	if len(data) < bundleHeaderSize {
+		return nil, errShortBuffer
+	}
+
+	kind := data[4]
+	count := binary.BigEndian.Uint64(data[8:16])
+	modelSize := binary.BigEndian.Uint64(data[16:24])
+	if modelSize > uint64(len(data)-bundleHeaderSize) {
+		return nil, errShortBuffer
+	}
+
+	model := data[bundleHeaderSize : bundleHeaderSize+int(modelSize)]
+	rmi, err := decode(model, Metadata{})
+	if err != nil {
+		return nil, err
+	}
+
+	if count != uint64(rmi.current.Load().maxIndex+1) {
+		return nil, fmt.Errorf("model was built over %v keys but the bundle holds %v", rmi.current.Load().maxIndex+1, count)
+	}
+
+	start := bundleHeaderSize + len(model)
+	start += paddingTo8(start)
+	if start > len(data) {
+		return nil, errShortBuffer
+	}
+	keys := data[start:]
+
+	b := &Bundle{rmi: rmi, data: data}
+	switch kind {
+	case bundleUint64:
+		if uint64(len(keys))/8 < count {
+			return nil, errShortBuffer
+		}
+		b.keys = packedKeys(keys[:8*count], int(count))
+	case bundleBigInt:
+		rmi.values = make([]*big.Int, count)
+		for i := range rmi.values {
+			var encoded string
+			if encoded, keys, err = readString(keys); err != nil {
+				return nil, err
+			}
+			rmi.values[i] = new(big.Int)
+			if err := rmi.values[i].GobDecode([]byte(encoded)); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown bundle key encoding %v", kind)
+	}
+
+	return b, nil
+}
+
+// packedKeys returns the little-endian uint64 array in data, in place if
+// data is aligned and the platform is little-endian (a copy otherwise)
+func packedKeys(data []byte, count int) []uint64 {
+
+	if count == 0 {
+		return []uint64{}
+	}
+
+	littleEndian := binary.NativeEndian.Uint16([]byte{1, 0}) == 1
+	if littleEndian && uintptr(unsafe.Pointer(&data[0]))%8 == 0 {
+		return unsafe.Slice((*uint64)(unsafe.Pointer(&data[0])), count)
+	}
+
+	keys := make([]uint64, count)
+	for i := range keys {
+		keys[i] = binary.LittleEndian.Uint64(data[8*i:])
+	}
+
+	return keys
+}
+
+// paddingTo8 returns the number of bytes padding n to a multiple of 8
+func paddingTo8(n int) int {
+	return (8 - n%8) % 8
+}
+
+// RMI returns the model of the bundle; for packed keys the model is
+// loaded without its keys (see Load), use the lookups of the bundle
+func (b *Bundle) RMI() *RMI {
+	return b.rmi
+}
+
+// Len returns the number of keys of the bundle
+func (b *Bundle) Len() int {
+	return b.rmi.current.Load().maxIndex + 1
+}
+
+// Keys returns the packed keys (nil if the keys do not all fit in a uint64)
+func (b *Bundle) Keys() []uint64 {
+	return b.keys
+}
+
+// Lookup64 returns the index of the first occurrence of key (or false if absent)
+func (b *Bundle) Lookup64(key uint64) (int, bool) {
+
+	if b.keys == nil {
+		return b.rmi.Lookup(new(big.Int).SetUint64(key))
+	}
+
+	if len(b.keys) == 0 {
+		return 0, false
+	}
+
+	lo, hi := b.rmi.GetIndexWithBounds(new(big.Int).SetUint64(key))
+	index := lo + sort.Search(hi-lo+1, func(i int) bool {
+		return b.keys[lo+i] >= key
+	})
+
+	if index <= hi && b.keys[index] == key {
+		return index, true
+	}
+
+	return 0, false
+}
+
+// Lookup returns the index of the first occurrence of value (or false if absent)
+func (b *Bundle) Lookup(value *big.Int) (int, bool) {
+
+	if b.keys == nil {
+		return b.rmi.Lookup(value)
+	}
+
+	if value.Sign() < 0 || value.BitLen() > 64 {
+		return 0, false
+	}
+
+	return b.Lookup64(value.Uint64())
+}
+
+// Close releases the mapping of a bundle opened with OpenBundle; the
+// bundle (and its keys) must not be used afterwards
+func (b *Bundle) Close() error {
+	if b.release == nil {
+		return nil
+	}
+
+	release := b.release
+	b.release, b.keys, b.data = nil, nil, nil
+	return release()
+}
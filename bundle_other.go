@@ -0,0 +1,19 @@
+//go:build !unix
+
+package rmi
+
+import (
+	"os"
+)
+
+// OpenBundle reads the bundle file at path and decodes it
+// (memory mapping is only supported on unix platforms)
+func OpenBundle(path string) (*Bundle, error) {
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return DecodeBundle(data)
+}
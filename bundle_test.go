@@ -0,0 +1,89 @@
+package rmi
+
+import (
+	"bytes"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBundle(t *testing.T) {
+
+	rmi, values, err := generateTestRMI()
+	if err != nil {
+		t.Fatalf("Failed to build RMI %v\n", err)
+	}
+
+	var buf bytes.Buffer
+	if err := rmi.WriteBundle(&buf); err != nil {
+		t.Fatalf("Failed to write bundle %v\n", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "index.rmib")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatalf("Failed to write bundle file %v\n", err)
+	}
+
+	b, err := OpenBundle(path)
+	if err != nil {
+		t.Fatalf("Failed to open bundle %v\n", err)
+	}
+	defer b.Close()
+
+	if b.Len() != len(values) || len(b.Keys()) != len(values) {
+		t.Fatalf("bundle holds %v keys (%v packed), expected %v", b.Len(), len(b.Keys()), len(values))
+	}
+
+	for i, value := range values {
+		index, ok := b.Lookup64(value.Uint64())
+		if !ok || values[index].Cmp(value) != 0 {
+			t.Fatalf("Lookup of key %v in the bundle failed", i)
+		}
+
+		if b.RMI().GetIndex(value) != rmi.GetIndex(value) {
+			t.Fatalf("bundled model predicts key %v differently", i)
+		}
+	}
+
+	if _, ok := b.Lookup(new(big.Int).Lsh(big.NewInt(1), 70)); ok {
+		t.Fatalf("found a key beyond the packed keys")
+	}
+
+	if _, err := DecodeBundle(buf.Bytes()[:buf.Len()-1]); err == nil {
+		t.Fatalf("expected a truncated bundle to be rejected")
+	}
+}
+
+func TestBundleBigKeys(t *testing.T) {
+
+	values := make([]*big.Int, 1000)
+	for i := range values {
+		values[i] = new(big.Int).Lsh(big.NewInt(int64(3*i+1)), 80)
+	}
+
+	rmi, err := NewRMI(values, 8, 2)
+	if err != nil {
+		t.Fatalf("Failed to build RMI %v\n", err)
+	}
+
+	var buf bytes.Buffer
+	if err := rmi.WriteBundle(&buf); err != nil {
+		t.Fatalf("Failed to write bundle %v\n", err)
+	}
+
+	b, err := DecodeBundle(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Failed to decode bundle %v\n", err)
+	}
+
+	if b.Keys() != nil {
+		t.Fatalf("keys beyond 64 bits were packed")
+	}
+
+	for i, value := range values {
+		if index, ok := b.Lookup(value); !ok || index != i {
+			t.Fatalf("Lookup of key %v in the bundle returned %v, %v", i, index, ok)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+//go:build unix
+
+package rmi
+
+import (
+	"os"
+	"syscall"
+)
+
+// OpenBundle maps the bundle file at path read-only and decodes it; packed
+// keys are used directly from the mapping until the bundle is closed
+func OpenBundle(path string) (*Bundle, error) {
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	if info.Size() == 0 {
+		return DecodeBundle(nil)
+	}
+
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(info.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := DecodeBundle(data)
+	if err != nil {
+		syscall.Munmap(data)
+		return nil, err
+	}
+
+	b.release = func() error { return syscall.Munmap(data) }
+	return b, nil
+}
@@ -0,0 +1,124 @@
+// calibration.go: isotonic regression calibration of the leaf predictions.
+// Leaf models are linear and so make systematic errors on CDFs with
+// curvature; the calibration learns a monotone mapping from the raw
+// prediction to the true index using pool adjacent violators (PAV).
+
+package rmi
+
+import (
+	"math/big"
+	"sort"
+)
+
+/*
+Monotone piecewise linear mapping from raw predictions to indices
+x: raw predictions at the knots (increasing)
+y: calibrated index at the knots (non-decreasing)
+*/
+type calibration struct {
+	x, y []float64
+}
+
+// one block of the PAV algorithm
+type pavBlock struct {
+	sumX, sumY float64
+	count      float64
+}
+
+// fits an isotonic regression of y on x; the pairs must be sorted by x
+func fitIsotonic(x, y []float64) *calibration {
+
+	blocks := make([]pavBlock, 0)
+	for i := range x {
+		blocks = append(blocks, pavBlock{x[i], y[i], 1})
+
+		// merge blocks while they violate monotonicity
+		for len(blocks) > 1 {
+			last := blocks[len(blocks)-1]
+			prev := blocks[len(blocks)-2]
+			if prev.sumY/prev.count <= last.sumY/last.count {
+				break
+			}
+
+			blocks = blocks[:len(blocks)-1]
+			blocks[len(blocks)-1] = pavBlock{
+				prev.sumX + last.sumX,
+				prev.sumY + last.sumY,
+				prev.count + last.count,
+			}
+		}
+	}
+
+	calib := &calibration{make([]float64, len(blocks)), make([]float64, len(blocks))}
+	for i, block := range blocks {
+		calib.x[i] = block.sumX / block.count
+		calib.y[i] = block.sumY / block.count
+	}
+
+	return calib
+}
+
+// maps a raw prediction to the calibrated index by interpolating
+// between the knots (constant beyond the first and last knot)
+func (c *calibration) apply(prediction *big.Float) *big.Float {
+
+	if len(c.x) == 0 {
+		return prediction
+	}
+
+	p, _ := prediction.Float64()
+	return big.NewFloat(c.applyFloat64(p))
+}
+
+// apply for a raw prediction that is already a float64
+func (c *calibration) applyFloat64(p float64) float64 {
+
+	if len(c.x) == 0 {
+		return p
+	}
+
+	i := sort.SearchFloat64s(c.x, p)
+	if i == 0 {
+		return c.y[0]
+	} else if i == len(c.x) {
+		return c.y[len(c.y)-1]
+	}
+
+	t := (p - c.x[i-1]) / (c.x[i] - c.x[i-1])
+	return c.y[i-1] + t*(c.y[i]-c.y[i-1])
+}
+
+// Calibrate trains an isotonic calibration of the leaf predictions on the
+// keys the RMI was built over and publishes it as a new epoch;
+// GetIndex applies the calibration after the leaf model prediction
+func (rmi *RMI) Calibrate() uint64 {
+
+	v := rmi.current.Load()
+
+	type pair struct{ x, y float64 }
+	pairs := make([]pair, len(rmi.values))
+	for i, value := range rmi.values {
+		leaf, _ := rmi.leaf(v, value)
+		prediction, _ := leaf.predict(value).Float64()
+		pairs[i] = pair{prediction, float64(i)}
+	}
+
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return pairs[i].x < pairs[j].x
+	})
+
+	x := make([]float64, len(pairs))
+	y := make([]float64, len(pairs))
+	for i := range pairs {
+		x[i] = pairs[i].x
+		y[i] = pairs[i].y
+	}
+
+	calib := fitIsotonic(x, y)
+
+	return rmi.publish(func(v *version) *version {
+		next := v.next()
+		next.calib = calib
+		return next
+	})
+}
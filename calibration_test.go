@@ -0,0 +1,66 @@
+package rmi
+
+import (
+	"math"
+	"math/big"
+	"testing"
+)
+
+// mean absolute distance between predicted and true index
+func meanIndexError(rmi *RMI, values []*big.Int) float64 {
+	total := 0.0
+	for i, value := range values {
+		total += math.Abs(float64(rmi.GetIndex(value) - i))
+	}
+	return total / float64(len(values))
+}
+
+func TestCalibration(t *testing.T) {
+
+	// strongly curved CDF (cubic keys) that linear leaves fit poorly
+	values := make([]*big.Int, 5000)
+	for i := range values {
+		values[i] = big.NewInt(int64(i) * int64(i) * int64(i))
+	}
+
+	plain, _ := NewRMI(values, 4, 2)
+	calibrated, err := NewRMI(values, 4, 2, WithCalibration())
+	if err != nil {
+		t.Fatalf("Failed to build RMI %v\n", err)
+	}
+
+	plainErr := meanIndexError(plain, values)
+	calibratedErr := meanIndexError(calibrated, values)
+	t.Logf("mean error without calibration %v, with calibration %v", plainErr, calibratedErr)
+
+	if calibratedErr >= plainErr {
+		t.Fatalf("calibration did not reduce the error: %v >= %v", calibratedErr, plainErr)
+	}
+	// batches apply the same calibration, also to queries between the keys
+	queries := make([]*big.Int, 0, 2*len(values))
+	for _, value := range values {
+		queries = append(queries, value, new(big.Int).Add(value, big.NewInt(1)))
+	}
+
+	batch := calibrated.GetIndexBatch(queries)
+	for q, query := range queries {
+		if index := calibrated.GetIndex(query); batch[q] != index {
+			t.Fatalf("batch index %v of query %v differs from GetIndex %v", batch[q], q, index)
+		}
+	}
+}
+
+func TestFitIsotonic(t *testing.T) {
+	calib := fitIsotonic([]float64{0, 1, 2, 3}, []float64{0, 2, 1, 3})
+
+	// the violating pair (2, 1) is pooled into a single block
+	if len(calib.x) != 3 || calib.y[1] != 1.5 {
+		t.Fatalf("unexpected calibration %v", calib)
+	}
+
+	for i := 1; i < len(calib.y); i++ {
+		if calib.y[i] < calib.y[i-1] {
+			t.Fatalf("calibration is not monotone %v", calib.y)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+// circuit.go: export of the root-to-leaf evaluation as an arithmetic circuit.
+// Child selection is data-oblivious: every layer compares the (fixed-point)
+// prediction against the boundaries of all children and selects the child's
+// coefficients with a one-hot sum, so the circuit's gates do not depend on
+// the query and it can be evaluated by MPC and zero-knowledge toolchains.
+
+package rmi
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+)
+
+// fractional bits of the fixed-point values in an exported circuit
+const circuitFracBits = 128
+
+// gate operations of an exported circuit
+const (
+	GateInput = "input" // the query key
+	GateConst = "const" // integer constant Const
+	GateAdd   = "add"   // In[0] + In[1]
+	GateSub   = "sub"   // In[0] - In[1]
+	GateMul   = "mul"   // In[0] * In[1]
+	GateGE    = "ge"    // 1 if In[0] >= In[1] else 0
+)
+
+/*
+Gate of an arithmetic circuit; its output is the wire with the gate's index
+Op: one of the Gate* operations
+In: input wires of the gate
+Const: decimal value of a constant gate
+*/
+type Gate struct {
+	Op    string `json:"op"`
+	In    []int  `json:"in,omitempty"`
+	Const string `json:"const,omitempty"`
+}
+
+/*
+Circuit is an arithmetic circuit over the integers as a gate list
+FracBits: number of fractional bits of the fixed-point output
+Output: wire holding the fixed-point leaf prediction (the caller
+truncates it and clamps it to [0, MaxIndex] as GetIndex does)
+MaxIndex: maximum index in the data structure
+Gates: the gates in topological order
+*/
+type Circuit struct {
+	FracBits int    `json:"frac_bits"`
+	Output   int    `json:"output"`
+	MaxIndex int    `json:"max_index"`
+	Gates    []Gate `json:"gates"`
+}
+
+// adds a gate and returns its output wire
+func (c *Circuit) gate(op string, in ...int) int {
+	c.Gates = append(c.Gates, Gate{Op: op, In: in})
+	return len(c.Gates) - 1
+}
+
+// adds a constant gate and returns its output wire
+func (c *Circuit) constant(value *big.Int) int {
+	c.Gates = append(c.Gates, Gate{Op: GateConst, Const: value.String()})
+	return len(c.Gates) - 1
+}
+
+// Circuit exports the evaluation of the current version of the model as an
+// arithmetic circuit; ensemble leaves, last-mile tables, calibrations and
+// the neighbor clamp are not exported
+func (rmi *RMI) Circuit() (*Circuit, error) {
+
+	v := rmi.current.Load()
+	if v.calib != nil {
+		return nil, errors.New("calibrated models cannot be exported as a circuit")
+	} else if rmi.conf.neighborClamp {
+		return nil, errors.New("models with a neighbor clamp cannot be exported as a circuit")
+	}
+
+	c := &Circuit{FracBits: circuitFracBits, MaxIndex: v.maxIndex}
+	x := c.gate(GateInput)
+	one := c.constant(big.NewInt(1))
+
+	// one-hot selection of the nodes of the current layer
+	selected := []int{one}
+	width := big.NewInt(int64(rmi.width))
+	maxIndex := big.NewInt(int64(routingMaxIndex(v.maxIndex)))
+
+	for layer, nodes := range v.nodes {
+
+		// coefficients of the selected node
+		m, b := -1, -1
+		for j, node := range nodes {
+			if node.alt != nil || node.exact != nil {
+				return nil, errors.New("ensemble leaves and last-mile tables cannot be exported as a circuit")
+			} else if node.log {
+				return nil, errors.New("log-linear models cannot be exported as a circuit")
+			}
+
+			mj := c.gate(GateMul, selected[j], c.constant(fixedPoint(node.m, circuitFracBits)))
+			bj := c.gate(GateMul, selected[j], c.constant(fixedPoint(node.b, circuitFracBits)))
+			if j == 0 {
+				m, b = mj, bj
+			} else {
+				m, b = c.gate(GateAdd, m, mj), c.gate(GateAdd, b, bj)
+			}
+		}
+		prediction := c.gate(GateAdd, c.gate(GateMul, m, x), b)
+
+		if layer == len(v.nodes)-1 {
+			c.Output = prediction
+			break
+		}
+
+		// child j is selected when prediction / maxIndex * width >= j and
+		// < j + 1 (the first and last child also take the clamped predictions)
+		children := len(v.nodes[layer+1])
+		ge := make([]int, children+1)
+		for j := 1; j < children; j++ {
+			threshold := new(big.Int).Mul(big.NewInt(int64(j)), maxIndex)
+			threshold.Lsh(threshold, circuitFracBits)
+			threshold.Quo(threshold, width)
+			ge[j] = c.gate(GateGE, prediction, c.constant(threshold))
+		}
+
+		next := make([]int, children)
+		for j := range next {
+			switch {
+			case children == 1:
+				next[j] = one
+			case j == 0:
+				next[j] = c.gate(GateSub, one, ge[1])
+			case j == children-1:
+				next[j] = ge[j]
+			default:
+				next[j] = c.gate(GateSub, ge[j], ge[j+1])
+			}
+		}
+
+		selected = next
+		width.Mul(width, big.NewInt(int64(rmi.width)))
+	}
+
+	return c, nil
+}
+
+// WriteJSON writes the circuit as a JSON gate list
+func (c *Circuit) WriteJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(c)
+}
+
+// Eval evaluates the circuit on the query key and returns the index
+// (the output truncated and clamped to [0, MaxIndex])
+func (c *Circuit) Eval(value *big.Int) (int, error) {
+
+	wires := make([]*big.Int, len(c.Gates))
+	for i, g := range c.Gates {
+		for _, in := range g.In {
+			if in < 0 || in >= i {
+				return 0, errors.New("gate input is not a previous wire")
+			}
+		}
+		if g.Op != GateInput && g.Op != GateConst && len(g.In) != 2 {
+			return 0, errors.New("gate " + g.Op + " needs two inputs")
+		}
+
+		res := new(big.Int)
+		switch g.Op {
+		case GateInput:
+			res.Set(value)
+		case GateConst:
+			if _, ok := res.SetString(g.Const, 10); !ok {
+				return 0, errors.New("invalid constant " + g.Const)
+			}
+		case GateAdd:
+			res.Add(wires[g.In[0]], wires[g.In[1]])
+		case GateSub:
+			res.Sub(wires[g.In[0]], wires[g.In[1]])
+		case GateMul:
+			res.Mul(wires[g.In[0]], wires[g.In[1]])
+		case GateGE:
+			if wires[g.In[0]].Cmp(wires[g.In[1]]) >= 0 {
+				res.SetInt64(1)
+			}
+		default:
+			return 0, errors.New("unknown gate operation " + g.Op)
+		}
+		wires[i] = res
+	}
+
+	if c.Output < 0 || c.Output >= len(wires) {
+		return 0, errors.New("circuit output is not a wire")
+	}
+
+	prediction := new(big.Float).SetInt(wires[c.Output])
+	return clampIndex(prediction.SetMantExp(prediction, -c.FracBits), c.MaxIndex), nil
+}
@@ -0,0 +1,53 @@
+package rmi
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestCircuit(t *testing.T) {
+	values := sortedTestData(2000)
+	rmi, _ := NewRMI(values, 10, 3)
+
+	circuit, err := rmi.Circuit()
+	if err != nil {
+		t.Fatalf("Failed to export the circuit %v\n", err)
+	}
+
+	// round trip through the JSON gate list
+	var buf bytes.Buffer
+	if err := circuit.WriteJSON(&buf); err != nil {
+		t.Fatalf("Failed to write the circuit %v\n", err)
+	}
+	decoded := &Circuit{}
+	if err := json.Unmarshal(buf.Bytes(), decoded); err != nil {
+		t.Fatalf("Failed to decode the circuit %v\n", err)
+	}
+	t.Logf("circuit with %v gates (%v bytes)", len(decoded.Gates), buf.Len())
+
+	// the fixed-point circuit is exact while GetIndex rounds, so a
+	// prediction that is (almost) an integer may truncate differently
+	mismatches := 0
+	for i := 0; i < len(values); i += 3 {
+		index, err := decoded.Eval(values[i])
+		if err != nil {
+			t.Fatalf("Failed to evaluate the circuit %v\n", err)
+		}
+
+		if diff := abs(index - rmi.GetIndex(values[i])); diff > 1 {
+			t.Fatalf("circuit evaluation of key %v returned %v instead of %v", i, index, rmi.GetIndex(values[i]))
+		} else if diff == 1 {
+			mismatches++
+		}
+	}
+	if mismatches > len(values)/100 {
+		t.Fatalf("%v circuit evaluations were off by one", mismatches)
+	}
+
+	// every gate may only read previous wires
+	decoded.Gates[1].In = []int{5}
+	if _, err := decoded.Eval(values[0]); err == nil {
+		t.Fatalf("expected an error for a gate reading a later wire")
+	}
+}
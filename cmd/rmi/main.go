@@ -0,0 +1,200 @@
+// main.go: command line tool training an RMI over a file of keys. The keys
+// are read as decimal integers (one per line), as little-endian uint64s or
+// in the SOSD benchmark format (see package sosd); the tool trains a model
+// with the given shape and options, prints its size and error statistics
+// and optionally writes the serialized model (see Save).
+//
+// Usage:
+//
+//	rmi -keys keys.txt                             train the default shape and report it
+//	rmi -keys books_200M_uint64 -format sosd -width 100000 -depth 2 -out books.rmi
+//	rmi -keys keys.bin -format uint64 -model auto -precision 32 -sort
+
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sachaservan/rmi"
+	"github.com/sachaservan/rmi/sosd"
+)
+
+// readKeys reads the keys of the file at path in the given format
+func readKeys(path, format string) ([]*big.Int, error) {
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReaderSize(f, 1<<20)
+	switch format {
+	case "text":
+		return readText(r)
+	case "uint64":
+		return readUint64s(r)
+	case "sosd":
+		keys, err := sosd.ReadUint64(r)
+		return sosd.BigInts(keys), err
+	}
+
+	return nil, fmt.Errorf("unknown key format %q (text, uint64 or sosd)", format)
+}
+
+// readText reads one decimal integer per line, skipping blank lines
+func readText(r io.Reader) ([]*big.Int, error) {
+
+	keys := make([]*big.Int, 0)
+	lines := bufio.NewScanner(r)
+	for line := 1; lines.Scan(); line++ {
+		text := strings.TrimSpace(lines.Text())
+		if text == "" {
+			continue
+		}
+
+		key, ok := new(big.Int).SetString(text, 10)
+		if !ok {
+			return nil, fmt.Errorf("line %v: %q is not an integer", line, text)
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, lines.Err()
+}
+
+// readUint64s reads little-endian uint64s up to the end of r
+func readUint64s(r io.Reader) ([]*big.Int, error) {
+
+	keys := make([]*big.Int, 0)
+	word := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, word); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("key %v: %w", len(keys), err)
+		}
+		keys = append(keys, new(big.Int).SetUint64(binary.LittleEndian.Uint64(word)))
+	}
+
+	return keys, nil
+}
+
+// parseModel returns the model type named as by ModelType.String
+func parseModel(name string) (rmi.ModelType, error) {
+
+	for _, t := range []rmi.ModelType{rmi.ModelLinear, rmi.ModelEndpoint, rmi.ModelLogLinear, rmi.ModelConstant, rmi.ModelAuto} {
+		if t.String() == name {
+			return t, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown model type %q", name)
+}
+
+// report prints the shape, size and error statistics of the model
+func report(index *rmi.RMI, keys int, elapsed time.Duration) error {
+
+	encoded, err := index.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	stats := index.ErrorStats()
+	fmt.Printf("keys            %v\n", keys)
+	fmt.Printf("build time      %v\n", elapsed)
+	fmt.Printf("leaves          %v\n", index.NumLeaves())
+	fmt.Printf("serialized size %v bytes (%.2f bytes/key)\n", len(encoded), float64(len(encoded))/float64(max(1, keys)))
+	fmt.Printf("max error       %v\n", stats.Max)
+	fmt.Printf("mean error      %.2f\n", stats.Mean)
+	fmt.Printf("median error    %v\n", stats.Median)
+	fmt.Printf("p99 error       %v\n", stats.P99)
+	fmt.Printf("search steps    %.2f\n", index.ExpectedSearchSteps())
+	fmt.Printf("fallbacks       %v\n", index.Fallbacks())
+
+	return nil
+}
+
+// run executes the command line and returns the error to exit with
+func run() error {
+
+	path := flag.String("keys", "", "file of keys to train on")
+	format := flag.String("format", "text", "format of the keys: text, uint64 or sosd")
+	sorted := flag.Bool("sort", false, "sort the keys instead of requiring them to be sorted")
+	width := flag.Int("width", 0, "width of the model (0 picks the default)")
+	depth := flag.Int("depth", 0, "depth of the model (0 picks the default)")
+	model := flag.String("model", "linear", "model type: linear, endpoint, log-linear, constant or auto")
+	precision := flag.Uint("precision", 0, "bits the coefficients are rounded to (0 keeps them)")
+	parallelism := flag.Int("parallelism", 0, "goroutines training each layer (0 uses GOMAXPROCS)")
+	clamp := flag.Bool("clamp", false, "use the suggested shape instead of failing on pathological ones")
+	out := flag.String("out", "", "file to write the serialized model to")
+	flag.Parse()
+
+	if *path == "" || flag.NArg() > 0 {
+		flag.Usage()
+		return errors.New("usage: rmi -keys FILE [flags]")
+	}
+
+	t, err := parseModel(*model)
+	if err != nil {
+		return err
+	}
+
+	keys, err := readKeys(*path, *format)
+	if err != nil {
+		return fmt.Errorf("%v: %w", *path, err)
+	}
+
+	if *sorted {
+		sort.Slice(keys, func(i, j int) bool { return keys[i].Cmp(keys[j]) == -1 })
+	}
+
+	opts := []rmi.Option{rmi.WithWidth(*width), rmi.WithDepth(*depth), rmi.WithModelType(t),
+		rmi.WithPrecision(*precision), rmi.WithParallelism(*parallelism)}
+	if *clamp {
+		opts = append(opts, rmi.WithClampShape())
+	}
+
+	start := time.Now()
+	index, err := rmi.NewRMIWithOptions(keys, opts...)
+	if err != nil {
+		return err
+	}
+
+	if err := report(index, len(keys), time.Since(start)); err != nil {
+		return err
+	}
+
+	if *out == "" {
+		return nil
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+
+	if err := index.Save(f); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
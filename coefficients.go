@@ -0,0 +1,290 @@
+// coefficients.go: struct-of-arrays storage of the coefficients. Every
+// published version keeps the slopes, intercepts and x intercepts of each
+// layer in parallel contiguous slices and its nodes point into them, so bulk
+// operations (precision conversion, export, batched evaluation) walk flat
+// arrays instead of chasing one heap allocation per node. The nodes
+// themselves live in one arena per version, layer by layer, so a query
+// finds the node of a layer by arithmetic on its position in the arena.
+
+package rmi
+
+import (
+	"math/big"
+	"sort"
+)
+
+/*
+Coefficients of all nodes of a layer
+m, b, w: slope, intercept and x intercept of node i at index i
+*/
+type layerCoefficients struct {
+	m, b, w []big.Float
+}
+
+// packLayer returns copies of the nodes of a layer whose coefficients are
+// stored in a single layerCoefficients, rounded to prec bits (0 keeps the
+// precision); the nodes themselves are not modified as they may be shared
+// with published versions
+func packLayer(nodes []*Node, prec uint) ([]*Node, *layerCoefficients) {
+
+	c := &layerCoefficients{
+		m: make([]big.Float, len(nodes)),
+		b: make([]big.Float, len(nodes)),
+		w: make([]big.Float, len(nodes)),
+	}
+
+	shells := make([]Node, len(nodes))
+	packed := make([]*Node, len(nodes))
+	for i, node := range nodes {
+		shells[i] = *node
+		for _, f := range []struct{ dst, src *big.Float }{{&c.m[i], node.m}, {&c.b[i], node.b}, {&c.w[i], node.w}} {
+			if f.src == nil {
+				continue
+			}
+			f.dst.Copy(f.src)
+			if prec > 0 {
+				f.dst.SetPrec(prec)
+			}
+		}
+
+		shells[i].m, shells[i].b, shells[i].w = &c.m[i], &c.b[i], &c.w[i]
+		packed[i] = &shells[i]
+	}
+
+	return packed, c
+}
+
+// pack stores the coefficients of every layer of v (which must not be
+// published yet) in struct-of-arrays form, rounded to prec bits (0 keeps
+// the precision), and returns v
+func (v *version) pack(prec uint) *version {
+
+	v.coeffs = make([]*layerCoefficients, len(v.nodes))
+	nodes := make([][]*Node, len(v.nodes))
+	for layer := range v.nodes {
+		nodes[layer], v.coeffs[layer] = packLayer(v.nodes[layer], prec)
+	}
+	v.nodes = nodes
+	v.root = nodes[0][0]
+
+	return v.flatten()
+}
+
+// flatten copies the nodes of all layers of v (which must not be published
+// yet) into one arena and points the layers of v into it, and returns v;
+// versions whose layers all point into their arena already are kept
+func (v *version) flatten() *version {
+
+	if v.flattened() {
+		return v
+	}
+
+	starts := make([]int, len(v.nodes)+1)
+	for layer, nodes := range v.nodes {
+		starts[layer+1] = starts[layer] + len(nodes)
+	}
+
+	// the layers are views of a single slice of pointers into the arena
+	flat := make([]Node, starts[len(v.nodes)])
+	views := make([]*Node, len(flat))
+	layers := make([][]*Node, len(v.nodes))
+	for layer, nodes := range v.nodes {
+		layers[layer] = views[starts[layer]:starts[layer+1]:starts[layer+1]]
+		for i, node := range nodes {
+			flat[starts[layer]+i] = *node
+			layers[layer][i] = &flat[starts[layer]+i]
+		}
+	}
+
+	v.nodes, v.flat, v.starts = layers, flat, starts
+	v.root = &flat[0]
+
+	return v
+}
+
+// flattened returns true if every layer of v points into its arena; layers
+// are only ever replaced as a whole, so checking the first node suffices
+func (v *version) flattened() bool {
+
+	if len(v.starts) != len(v.nodes)+1 {
+		return false
+	}
+
+	for layer, nodes := range v.nodes {
+		if len(nodes) != v.starts[layer+1]-v.starts[layer] {
+			return false
+		} else if len(nodes) > 0 && nodes[0] != &v.flat[v.starts[layer]] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// node returns the node at position i of a layer of v
+func (v *version) node(layer, i int) *Node {
+	return &v.flat[v.starts[layer]+i]
+}
+
+// layerSize returns the number of nodes of a layer of v
+func (v *version) layerSize(layer int) int {
+	return v.starts[layer+1] - v.starts[layer]
+}
+
+// Coefficients converts the slopes and intercepts of the current version
+// to float64 in bulk, layer by layer (leaves keep only their first model,
+// see WithLeafEnsemble)
+func (rmi *RMI) Coefficients() (slopes [][]float64, intercepts [][]float64) {
+
+	v := rmi.current.Load()
+	for _, c := range v.coeffs {
+		m := make([]float64, len(c.m))
+		b := make([]float64, len(c.b))
+		for i := range c.m {
+			m[i], _ = c.m[i].Float64()
+			b[i], _ = c.b[i].Float64()
+		}
+		slopes = append(slopes, m)
+		intercepts = append(intercepts, b)
+	}
+
+	return slopes, intercepts
+}
+
+// SetPrecision rounds every coefficient to prec bits (the second models of
+// ensemble leaves keep theirs) and publishes the result as a new epoch
+func (rmi *RMI) SetPrecision(prec uint) uint64 {
+	return rmi.publish(func(v *version) *version {
+		next := v.next()
+		next.nodes = make([][]*Node, len(v.nodes))
+		next.coeffs = make([]*layerCoefficients, len(v.nodes))
+		for layer := range v.nodes {
+			next.nodes[layer], next.coeffs[layer] = packLayer(v.nodes[layer], prec)
+		}
+		next.root = next.nodes[0][0]
+
+		return next
+	})
+}
+
+// GetIndexBatch returns GetIndex of every value; the queries are evaluated
+// together layer by layer over the coefficient arrays of a single version
+// and share their temporaries (in key order, see WithBatchSorting)
+func (rmi *RMI) GetIndexBatch(values []*big.Int) []int {
+
+	v := rmi.current.Load()
+	indices := make([]int, len(values))
+	if !rmi.conf.batchSort || isSortedBatch(values) {
+		rmi.evalBatch(v, values, indices)
+		return indices
+	}
+
+	// an approximate order is enough for locality and is cheaper to sort
+	key := new(big.Float)
+	order := make([]batchQuery, len(values))
+	for i, value := range values {
+		order[i].key, _ = key.SetInt(value).Float64()
+		order[i].pos = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return order[i].key < order[j].key
+	})
+
+	sorted := make([]*big.Int, len(values))
+	for i, q := range order {
+		sorted[i] = values[q.pos]
+	}
+
+	results := make([]int, len(values))
+	rmi.evalBatch(v, sorted, results)
+	for i, q := range order {
+		indices[q.pos] = results[i]
+	}
+
+	return indices
+}
+
+// key (rounded to a float64) and position of a query of a batch
+type batchQuery struct {
+	key float64
+	pos int
+}
+
+// isSortedBatch returns true if the values are in non-decreasing order
+func isSortedBatch(values []*big.Int) bool {
+	for i := 1; i < len(values); i++ {
+		if values[i-1].Cmp(values[i]) == 1 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// evalBatch stores GetIndex of every value under v in indices
+func (rmi *RMI) evalBatch(v *version, values []*big.Int, indices []int) {
+
+	// log inputs are computed once per query on first use
+	xs := make([]big.Float, len(values))
+	logs := make([]*big.Float, len(values))
+	locations := make([]int, len(values))
+	for i, value := range values {
+		xs[i].SetInt(value)
+	}
+
+	input := func(node *Node, i int) *big.Float {
+		if !node.log {
+			return &xs[i]
+		}
+		if logs[i] == nil {
+			logs[i] = logInput(values[i])
+		}
+
+		return logs[i]
+	}
+
+	res := new(big.Float)
+	width := new(big.Float).SetFloat64(float64(rmi.width))
+	factor := new(big.Float).SetFloat64(float64(rmi.width))
+	maxIndex := new(big.Float).SetFloat64(routingMaxIndex(v.maxIndex))
+
+	// the parents of the leaves bound their predictions (see WithNeighborClamp)
+	var parents []int
+	if rmi.conf.neighborClamp {
+		parents = make([]int, len(values))
+	}
+
+	for layer := 1; layer < rmi.depth; layer++ {
+		c := v.coeffs[layer-1]
+		size := v.layerSize(layer)
+		copy(parents, locations)
+		for i := range xs {
+			res.SetPrec(0).Mul(&c.m[locations[i]], input(v.node(layer-1, locations[i]), i))
+			res.Add(res, &c.b[locations[i]])
+			res.Quo(res, maxIndex)
+			res.Mul(res, width)
+			locations[i] = clampIndex(res, size-1)
+		}
+		width.Mul(width, factor)
+	}
+
+	for i, value := range values {
+		leaf := v.node(rmi.depth-1, locations[i]).modelFor(value)
+		if leaf.exact != nil {
+			if index, ok := leaf.exact[tableKey(value)]; ok {
+				indices[i] = index
+				continue
+			}
+		}
+
+		res.SetPrec(0).Mul(leaf.m, input(leaf, i))
+		prediction := res.Add(res, leaf.b)
+		if rmi.conf.neighborClamp && rmi.depth > 1 {
+			prediction = rmi.clampToNeighbors(v, v.node(rmi.depth-2, parents[i]), locations[i], prediction)
+		}
+		if v.calib != nil {
+			prediction = v.calib.apply(prediction)
+		}
+		indices[i] = clampIndex(prediction, v.maxIndex)
+	}
+}
@@ -0,0 +1,164 @@
+package rmi
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+func TestGetIndexBatch(t *testing.T) {
+
+	values := make([]*big.Int, 3000)
+	for i := range values {
+		values[i] = big.NewInt(int64(i) * int64(i) * int64(i))
+	}
+
+	// shuffled queries, evaluated out of order with WithBatchSorting
+	shuffled := append([]*big.Int{}, values...)
+	rand.New(rand.NewSource(1)).Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+
+	for _, opts := range [][]Option{nil, {WithLeafEnsemble()}, {WithLastMileTables(4)}, {WithCalibration()},
+		{WithModelType(ModelLogLinear)}, {WithBatchSorting()}, {WithBatchSorting(), WithCalibration()}} {
+		rmi, err := NewRMI(values, 3, 3, opts...)
+		if err != nil {
+			t.Fatalf("Failed to build RMI %v\n", err)
+		}
+
+		queries := append(append(shuffled, big.NewInt(-5), big.NewInt(1<<40)), values[:10]...)
+		for i, index := range rmi.GetIndexBatch(queries) {
+			if index != rmi.GetIndex(queries[i]) {
+				t.Fatalf("batched index %v of query %v differs from GetIndex", index, i)
+			}
+		}
+	}
+}
+
+func BenchmarkGetIndexBatch(b *testing.B) {
+	values := sortedTestData(100000)
+	queries := append([]*big.Int{}, values...)
+	rand.New(rand.NewSource(1)).Shuffle(len(queries), func(i, j int) {
+		queries[i], queries[j] = queries[j], queries[i]
+	})
+
+	for _, bench := range []struct {
+		name string
+		opts []Option
+	}{{"unsorted", nil}, {"sorted", []Option{WithBatchSorting()}}} {
+		rmi, _ := NewRMI(values, RMIWidthParameter, 3, bench.opts...)
+		b.Run(bench.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				rmi.GetIndexBatch(queries)
+			}
+		})
+	}
+}
+
+func TestCoefficientArrays(t *testing.T) {
+
+	rmi, _, err := generateTestRMI()
+	if err != nil {
+		t.Fatalf("Failed to build RMI %v\n", err)
+	}
+	v := rmi.current.Load()
+
+	slopes, intercepts := rmi.Coefficients()
+	for layer, nodes := range v.nodes {
+		for i, node := range nodes {
+			if node.m != &v.coeffs[layer].m[i] || node.b != &v.coeffs[layer].b[i] {
+				t.Fatalf("node %v of layer %v does not point into the coefficient arrays", i, layer)
+			}
+
+			m, _ := node.m.Float64()
+			b, _ := node.b.Float64()
+			if slopes[layer][i] != m || intercepts[layer][i] != b {
+				t.Fatalf("exported coefficients of node %v of layer %v differ", i, layer)
+			}
+		}
+	}
+
+	// retrained leaves are repacked
+	rmi.RetrainLeaf(0, nil, nil)
+	v = rmi.current.Load()
+	leaves := v.nodes[rmi.depth-1]
+	if leaves[0].m != &v.coeffs[rmi.depth-1].m[0] {
+		t.Fatalf("retrained leaf does not point into the coefficient arrays")
+	}
+}
+
+func TestSetPrecision(t *testing.T) {
+
+	rmi, values, err := generateTestRMI()
+	if err != nil {
+		t.Fatalf("Failed to build RMI %v\n", err)
+	}
+	before := rmi.Pin()
+	maxErr := maxIndexError(rmi, values)
+
+	rmi.SetPrecision(24)
+	for _, layer := range rmi.current.Load().coeffs {
+		for i := range layer.m {
+			if layer.m[i].Prec() != 24 || layer.b[i].Prec() != 24 {
+				t.Fatalf("coefficient precision was not converted")
+			}
+		}
+	}
+
+	// pinned snapshots keep their coefficients
+	if before.v.coeffs[0].m[0].Prec() == 24 {
+		t.Fatalf("snapshot coefficients were modified")
+	}
+
+	// 24 bits are plenty for the coefficients of a model over few keys
+	if rounded := maxIndexError(rmi, values); rounded > maxErr+float64(len(values))/100 {
+		t.Fatalf("rounded model error %v is far above the original %v", rounded, maxErr)
+	}
+}
+
+func TestFlatLayout(t *testing.T) {
+	values := sortedTestData(20000)
+	rmi, _ := NewRMI(values, 16, 3)
+
+	// every published version keeps all of its nodes in its arena
+	check := func(v *version) {
+		if v.root != &v.flat[0] || len(v.flat) != v.starts[len(v.nodes)] {
+			t.Fatalf("epoch %v: root or size outside of the arena", v.epoch)
+		}
+		for layer, nodes := range v.nodes {
+			for i, node := range nodes {
+				if node != v.node(layer, i) {
+					t.Fatalf("epoch %v: node %v of layer %v outside of the arena", v.epoch, i, layer)
+				}
+			}
+		}
+	}
+
+	first := rmi.current.Load()
+	check(first)
+	want := rmi.GetIndexBatch(values)
+
+	rmi.SetPrecision(60)
+	check(rmi.current.Load())
+
+	rmi.Calibrate()
+	check(rmi.current.Load())
+
+	indices := make([]*big.Int, 10)
+	for i := range indices {
+		indices[i] = big.NewInt(int64(i))
+	}
+	if _, err := rmi.RetrainLeaf(3, values[:10], indices); err != nil {
+		t.Fatalf("Failed to retrain leaf %v\n", err)
+	}
+	check(rmi.current.Load())
+
+	// publishing copies the arena, earlier versions are left untouched
+	check(first)
+	for i, value := range values {
+		if rmi.getIndex(first, value) != want[i] {
+			t.Fatalf("first epoch changed its prediction of key %v", i)
+		}
+	}
+}
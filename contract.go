@@ -0,0 +1,146 @@
+// contract.go: the accuracy guarantees of a version of the model as a value.
+// The error bounds are measured over the keys the model was built on (see
+// accuracy.go), so what GetIndex promises for those keys can be stated
+// exactly: the bound on the distance to the true index, what the true index
+// of a repeated key is and whether the indices never decrease with the key.
+// Downstream code asserts the contract instead of relying on documentation,
+// and Verify checks it against the keys.
+
+package rmi
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// IndexSemantics states which index GetIndex approximates for a key
+type IndexSemantics int
+
+const (
+	// IndexWithinError: GetIndex of a key is within MaxError of the
+	// position of every one of its occurrences in the sorted keys
+	IndexWithinError IndexSemantics = iota
+
+	// IndexExact: the keys are distinct and GetIndex of a key is its position
+	IndexExact
+)
+
+func (s IndexSemantics) String() string {
+	switch s {
+	case IndexWithinError:
+		return "within-error"
+	case IndexExact:
+		return "exact"
+	}
+
+	return fmt.Sprintf("IndexSemantics(%d)", int(s))
+}
+
+/*
+AccuracyContract is what GetIndex guarantees for the keys a version of the
+model was built on; nothing is guaranteed for values that are not keys
+Epoch: the version of the model the contract holds for
+Keys: number of keys; every index returned is in [0, Keys-1]
+MaxError: bound on the distance between GetIndex and the true index of a key
+Semantics: the true index of a key (see IndexSemantics)
+Monotonic: GetIndex is non-decreasing over the keys in sorted order
+(false if it cannot be checked because the keys are not retained, see Load)
+*/
+type AccuracyContract struct {
+	Epoch     uint64
+	Keys      int
+	MaxError  int
+	Semantics IndexSemantics
+	Monotonic bool
+}
+
+// NewRMIWithContract is NewRMIWithOptions returning the accuracy contract
+// of the built model as well
+func NewRMIWithContract(values []*big.Int, opts ...Option) (*RMI, AccuracyContract, error) {
+
+	rmi, err := NewRMIWithOptions(values, opts...)
+	if err != nil {
+		return nil, AccuracyContract{}, err
+	}
+
+	return rmi, rmi.Contract(), nil
+}
+
+// Contract returns the accuracy contract of the current version of the
+// model; it changes whenever a new version is published (see Epoch)
+func (rmi *RMI) Contract() AccuracyContract {
+	return rmi.contractOf(rmi.current.Load())
+}
+
+// Contract returns the accuracy contract of the pinned version of the model
+func (s Snapshot) Contract() AccuracyContract {
+	return s.rmi.contractOf(s.v)
+}
+
+// contractOf returns the accuracy contract of version v
+func (rmi *RMI) contractOf(v *version) AccuracyContract {
+
+	c := AccuracyContract{Epoch: v.epoch, Keys: v.maxIndex + 1}
+	for _, e := range rmi.errorsOf(v) {
+		c.MaxError = max(c.MaxError, e.maxAbs())
+	}
+
+	c.Monotonic = rmi.monotonicOf(v)
+	if c.MaxError == 0 && c.Keys > 0 {
+		c.Semantics = IndexExact
+	}
+
+	return c
+}
+
+// monotonicOf reports whether GetIndex of version v is non-decreasing
+// over the keys, checked at most once per version
+func (rmi *RMI) monotonicOf(v *version) bool {
+	v.errs.monoOnce.Do(func() {
+		if rmi.values == nil || len(rmi.values) != v.maxIndex+1 {
+			return
+		}
+
+		prev := 0
+		v.errs.monotonic = true
+		for _, value := range rmi.values {
+			index := rmi.getIndex(v, value)
+			if index < prev {
+				v.errs.monotonic = false
+				return
+			}
+			prev = index
+		}
+	})
+
+	return v.errs.monotonic
+}
+
+// Verify checks the contract against the sorted keys it was stated for,
+// queried through getIndex (e.g. RMI.GetIndex or Snapshot.GetIndex), and
+// returns an error describing the first violation
+func (c AccuracyContract) Verify(values []*big.Int, getIndex func(*big.Int) int) error {
+
+	if len(values) != c.Keys {
+		return fmt.Errorf("contract covers %v keys, got %v", c.Keys, len(values))
+	}
+
+	prev := 0
+	for i, value := range values {
+		index := getIndex(value)
+		if index < 0 || index >= c.Keys {
+			return fmt.Errorf("index %v of key %v is outside of [0, %v]", index, i, c.Keys-1)
+		} else if c.Monotonic && index < prev {
+			return fmt.Errorf("index %v of key %v is below the index %v of the previous key", index, i, prev)
+		} else if i > 0 && c.Semantics == IndexExact && values[i-1].Cmp(value) == 0 {
+			return fmt.Errorf("key %v is repeated but the contract is exact", i)
+		}
+
+		if d := max(index-i, i-index); d > c.MaxError {
+			return fmt.Errorf("index %v of key %v is %v away, beyond the max error %v", index, i, d, c.MaxError)
+		}
+		prev = index
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package rmi
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestAccuracyContract(t *testing.T) {
+
+	values := sortedTestData(10000)
+	model, contract, err := NewRMIWithContract(values, WithMonotonicLeaves())
+	if err != nil {
+		t.Fatalf("Failed to build RMI %v\n", err)
+	}
+
+	if contract.Keys != len(values) || contract.MaxError != model.MaxError() || contract.Epoch != model.Epoch() {
+		t.Fatalf("contract %+v does not describe the model", contract)
+	}
+
+	if !contract.Monotonic {
+		t.Fatalf("contract of a model with monotonic leaves is not monotonic")
+	}
+
+	if err := contract.Verify(values, model.GetIndex); err != nil {
+		t.Fatalf("model violates its contract: %v", err)
+	}
+
+	// a tighter bound than the model achieves is violated
+	tight := contract
+	tight.MaxError = contract.MaxError - 1
+	if contract.MaxError > 0 && tight.Verify(values, model.GetIndex) == nil {
+		t.Fatalf("expected a violation of max error %v", tight.MaxError)
+	}
+
+	// keys equal to their positions are predicted exactly by a single model
+	exact := make([]*big.Int, 100)
+	for i := range exact {
+		exact[i] = big.NewInt(int64(i))
+	}
+	model, err = NewRMI(exact, 1, 1)
+	if err != nil {
+		t.Fatalf("Failed to build RMI %v\n", err)
+	}
+	if c := model.Contract(); c.Semantics != IndexExact || c.MaxError != 0 {
+		t.Fatalf("contract %+v of exactly predicted keys is not exact", c)
+	} else if err := c.Verify(exact, model.GetIndex); err != nil {
+		t.Fatalf("model violates its contract: %v", err)
+	}
+
+	// loaded models without their keys cannot check monotonicity
+	data, _ := model.MarshalBinary()
+	loaded, err := UnmarshalRMI(data)
+	if err != nil {
+		t.Fatalf("Failed to load RMI %v\n", err)
+	}
+	if c := loaded.Contract(); c.Monotonic || c.Keys != len(exact) {
+		t.Fatalf("contract %+v of a loaded model claims monotonicity", c)
+	}
+}
@@ -0,0 +1,137 @@
+// csv.go: building an index from a key column of a CSV or TSV file
+
+package rmi
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+)
+
+// KeyFormat is the textual format of the keys of a CSV column
+type KeyFormat int
+
+const (
+	// KeyInteger keys are base 10 integers of any size
+	KeyInteger KeyFormat = iota
+
+	// KeyHex keys are base 16 integers with an optional 0x prefix (e.g., hashes)
+	KeyHex
+
+	// KeyDecimal keys are decimal numbers (e.g., "12.50") scaled by 10^Scale
+	KeyDecimal
+)
+
+/*
+CSVOptions controls how keys are read from a CSV or TSV file
+Comma: field delimiter (',' if zero, '\t' for TSV)
+Header: skip the first record
+Format: textual format of the keys
+Scale: number of decimal places kept of KeyDecimal keys
+Sort: sort the keys instead of requiring the file to be sorted
+*/
+type CSVOptions struct {
+	Comma  rune
+	Header bool
+	Format KeyFormat
+	Scale  int
+	Sort   bool
+}
+
+// BuildFromCSV builds an RMI over the keys in the given column (0 is the
+// first) of the CSV or TSV file at path; blank lines are skipped and
+// errors report the line of the offending record
+func BuildFromCSV(
+	path string,
+	column int,
+	parse CSVOptions,
+	width int,
+	depth int,
+	opts ...Option) (*RMI, error) {
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values, err := readCSVKeys(f, column, parse)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+
+	return NewRMI(values, width, depth, opts...)
+}
+
+// readCSVKeys reads the (sorted) keys of column from r
+func readCSVKeys(r io.Reader, column int, parse CSVOptions) ([]*big.Int, error) {
+
+	if column < 0 {
+		return nil, errors.New("column must not be negative")
+	}
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.ReuseRecord = true
+	if parse.Comma != 0 {
+		reader.Comma = parse.Comma
+	}
+
+	values := make([]*big.Int, 0)
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		if first && parse.Header {
+			continue
+		}
+
+		line, _ := reader.FieldPos(0)
+		if column >= len(record) {
+			return nil, fmt.Errorf("line %v: no column %v", line, column)
+		}
+
+		value, err := parseKey(strings.TrimSpace(record[column]), parse)
+		if err != nil {
+			return nil, fmt.Errorf("line %v: %v", line, err)
+		}
+
+		if !parse.Sort && len(values) > 0 && values[len(values)-1].Cmp(value) == 1 {
+			return nil, fmt.Errorf("line %v: values must be in sorted order", line)
+		}
+
+		values = append(values, value)
+	}
+
+	if parse.Sort {
+		sort.Slice(values, func(i, j int) bool {
+			return values[i].Cmp(values[j]) == -1
+		})
+	}
+
+	return values, nil
+}
+
+// parses a key in the configured format
+func parseKey(s string, parse CSVOptions) (*big.Int, error) {
+	switch parse.Format {
+	case KeyInteger:
+		return parseDecimal(s)
+	case KeyHex:
+		return ParseHexKey(s)
+	case KeyDecimal:
+		return ParseDecimalKey(s, parse.Scale)
+	}
+
+	return nil, fmt.Errorf("unsupported key format %v", parse.Format)
+}
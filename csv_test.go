@@ -0,0 +1,80 @@
+package rmi
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, contents string) string {
+	path := filepath.Join(t.TempDir(), "keys.csv")
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatalf("Failed to write test file %v\n", err)
+	}
+
+	return path
+}
+
+func TestBuildFromCSV(t *testing.T) {
+	var csv, tsv, hex strings.Builder
+	csv.WriteString("id,name\n")
+	for i := 0; i < 500; i++ {
+		fmt.Fprintf(&csv, "%v%06d,row\n", strings.Repeat("9", 25), i)
+		fmt.Fprintf(&tsv, "x\t%v.5\n", i*3)
+		fmt.Fprintf(&hex, "0x%v%03x\n", strings.Repeat("f", 40), i)
+	}
+
+	tests := []struct {
+		contents string
+		column   int
+		parse    CSVOptions
+	}{
+		{csv.String(), 0, CSVOptions{Header: true}},
+		{tsv.String(), 1, CSVOptions{Comma: '\t', Format: KeyDecimal, Scale: 1}},
+		{hex.String(), 0, CSVOptions{Format: KeyHex}},
+	}
+
+	for _, test := range tests {
+		rmi, err := BuildFromCSV(writeTestFile(t, test.contents), test.column, test.parse, 10, 2)
+		if err != nil {
+			t.Fatalf("Failed to build from file %v\n", err)
+		}
+
+		if len(rmi.values) != 500 {
+			t.Fatalf("expected 500 keys but got %v", len(rmi.values))
+		}
+		for i := 1; i < len(rmi.values); i++ {
+			if rmi.values[i-1].Cmp(rmi.values[i]) != -1 {
+				t.Fatalf("keys parsed out of order at %v", i)
+			}
+		}
+	}
+}
+
+func TestBuildFromCSVErrors(t *testing.T) {
+	tests := []struct {
+		contents string
+		parse    CSVOptions
+		err      string
+	}{
+		{"3\n2\n", CSVOptions{}, "line 2: values must be in sorted order"},
+		{"1\nabc\n", CSVOptions{}, "line 2: \"abc\" is not an integer key"},
+		{"0x1\n0xzz\n", CSVOptions{Format: KeyHex}, "line 2"},
+		{"1.25\n", CSVOptions{Format: KeyDecimal, Scale: 1}, "more than 1 decimal places"},
+	}
+
+	for _, test := range tests {
+		_, err := BuildFromCSV(writeTestFile(t, test.contents), 0, test.parse, 2, 1)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Fatalf("expected error %q but got %v", test.err, err)
+		}
+	}
+
+	// unsorted files can be sorted on load
+	rmi, err := BuildFromCSV(writeTestFile(t, "3\n1\n2\n"), 0, CSVOptions{Sort: true}, 2, 1)
+	if err != nil || rmi.values[0].Int64() != 1 {
+		t.Fatalf("expected the keys to be sorted, got %v", err)
+	}
+}
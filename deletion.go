@@ -0,0 +1,82 @@
+// deletion.go: soft deletes via a deletion bitmap over the data array;
+// exact queries skip logically-deleted positions without retraining
+
+package rmi
+
+// Deletions reports which positions of the data array are logically deleted;
+// compressed bitmaps (e.g., roaring) can be plugged in with a small adapter
+type Deletions interface {
+	Contains(index int) bool
+}
+
+// Bitmap is a simple uncompressed Deletions implementation
+type Bitmap []uint64
+
+// NewBitmap returns an empty bitmap for n positions
+func NewBitmap(n int) Bitmap {
+	return make(Bitmap, (n+63)/64)
+}
+
+// Set marks the position as deleted
+func (b Bitmap) Set(index int) {
+	b[index/64] |= 1 << uint(index%64)
+}
+
+// Clear marks the position as live
+func (b Bitmap) Clear(index int) {
+	b[index/64] &^= 1 << uint(index%64)
+}
+
+// Contains reports whether the position is deleted
+func (b Bitmap) Contains(index int) bool {
+	if index < 0 || index/64 >= len(b) {
+		return false
+	}
+
+	return b[index/64]&(1<<uint(index%64)) != 0
+}
+
+// holder for the current deletion bitmap (nil means nothing is deleted)
+type deletionSet struct {
+	deleted Deletions
+}
+
+// SetDeletions installs the deletion bitmap consulted by exact queries
+// (nil clears it); the bitmap must not be modified while queries run
+// unless its implementation is safe for concurrent use
+func (rmi *RMI) SetDeletions(deleted Deletions) {
+	rmi.deleted.Store(&deletionSet{deleted})
+}
+
+// deletions returns the current deletion set (possibly empty)
+func (rmi *RMI) deletions() *deletionSet {
+	d := rmi.deleted.Load()
+	if d == nil {
+		return &deletionSet{}
+	}
+
+	return d
+}
+
+// isDeleted reports whether the position is logically deleted
+func (d *deletionSet) isDeleted(index int) bool {
+	return d.deleted != nil && d.deleted.Contains(index)
+}
+
+// skipLeft returns the first live position at or before index (-1 if none)
+func (d *deletionSet) skipLeft(index int) int {
+	for index >= 0 && d.isDeleted(index) {
+		index--
+	}
+
+	return index
+}
+
+// skipRight returns the first live position at or after index (n if none)
+func (d *deletionSet) skipRight(index int, n int) int {
+	for index < n && d.isDeleted(index) {
+		index++
+	}
+
+	return index
+}
@@ -0,0 +1,50 @@
+package rmi
+
+import (
+	"testing"
+)
+
+func TestBitmap(t *testing.T) {
+	b := NewBitmap(130)
+	b.Set(0)
+	b.Set(129)
+
+	if !b.Contains(0) || !b.Contains(129) || b.Contains(64) || b.Contains(500) {
+		t.Fatalf("unexpected bitmap contents")
+	}
+
+	b.Clear(129)
+	if b.Contains(129) {
+		t.Fatalf("position was not cleared")
+	}
+}
+
+func TestDeletionsSkipped(t *testing.T) {
+	values := sortedTestData(1000)
+	rmi, _ := NewRMI(values, RMIWidthParameter, RMIDepthParameter)
+
+	deleted := NewBitmap(len(values))
+	deleted.Set(500)
+	deleted.Set(501)
+	rmi.SetDeletions(deleted)
+
+	index, delta := rmi.Nearest(values[500])
+	if index == 500 || index == 501 || delta.Sign() == 0 {
+		t.Fatalf("nearest returned a deleted position %v", index)
+	}
+
+	if index != 499 && index != 502 {
+		t.Fatalf("expected the nearest live neighbour, got %v", index)
+	}
+
+	for _, index := range rmi.NearestK(values[500], 10) {
+		if deleted.Contains(index) {
+			t.Fatalf("nearest k returned a deleted position %v", index)
+		}
+	}
+
+	rmi.SetDeletions(nil)
+	if index, _ := rmi.Nearest(values[500]); index != 500 {
+		t.Fatalf("expected deleted position to be visible again, got %v", index)
+	}
+}
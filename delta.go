@@ -0,0 +1,167 @@
+// delta.go: model fingerprints and incremental replication of leaf coefficients
+
+package rmi
+
+import (
+	"errors"
+	"hash/fnv"
+	"math/big"
+)
+
+/*
+Fingerprint summarises the coefficients of a model
+Width, Depth: shape of the model
+Internal: hash over all non-leaf nodes
+Leaves: hash of each leaf node (in layer order)
+*/
+type Fingerprint struct {
+	Width, Depth int
+	Internal     uint64
+	Leaves       []uint64
+}
+
+/*
+Delta holds the leaves whose coefficients changed since a fingerprint
+MaxIndex: maximum index of the model the delta was taken from
+Leaves: the leaves that changed
+*/
+type Delta struct {
+	MaxIndex int
+	Leaves   []LeafUpdate
+}
+
+/*
+LeafUpdate holds the new coefficients of the leaf at position Index in the leaf layer
+M, B: coefficients of the leaf model
+Log: the leaf model is log-linear (see loglinear.go)
+Breakpoint, AltM, AltB: second model of the leaf for keys >= Breakpoint (nil if none)
+*/
+type LeafUpdate struct {
+	Index      int
+	M, B       *big.Float
+	Log        bool
+	Breakpoint *big.Int
+	AltM, AltB *big.Float
+}
+
+// hashes the exact coefficients of a node into h
+func writeNode(h interface{ Write([]byte) (int, error) }, node *Node) {
+	h.Write([]byte(node.m.Text('p', 0)))
+	h.Write([]byte{0})
+	h.Write([]byte(node.b.Text('p', 0)))
+	h.Write([]byte{0})
+
+	if node.log {
+		h.Write([]byte("log"))
+		h.Write([]byte{0})
+	}
+
+	if node.alt != nil {
+		h.Write([]byte(node.breakpoint.Text(16)))
+		h.Write([]byte{0})
+		writeNode(h, node.alt)
+	}
+}
+
+// Fingerprint returns the fingerprint of the current model coefficients
+func (rmi *RMI) Fingerprint() Fingerprint {
+	return rmi.fingerprint(rmi.current.Load())
+}
+
+func (rmi *RMI) fingerprint(v *version) Fingerprint {
+
+	internal := fnv.New64a()
+	for _, layer := range v.nodes[:rmi.depth-1] {
+		for _, node := range layer {
+			writeNode(internal, node)
+		}
+	}
+
+	leafLayer := v.nodes[rmi.depth-1]
+	leaves := make([]uint64, len(leafLayer))
+	for i, node := range leafLayer {
+		h := fnv.New64a()
+		writeNode(h, node)
+		leaves[i] = h.Sum64()
+	}
+
+	return Fingerprint{rmi.width, rmi.depth, internal.Sum64(), leaves}
+}
+
+// DeltaSince returns the leaves whose coefficients changed since the fingerprint was taken;
+// fails if the shape or any non-leaf node changed, in which case the full model must be shipped
+func (rmi *RMI) DeltaSince(fp Fingerprint) (*Delta, error) {
+	return rmi.Pin().DeltaSince(fp)
+}
+
+// Fingerprint returns the fingerprint of the pinned version of the model
+func (s Snapshot) Fingerprint() Fingerprint {
+	return s.rmi.fingerprint(s.v)
+}
+
+// DeltaSince is RMI.DeltaSince computed against the pinned version of the model
+func (s Snapshot) DeltaSince(fp Fingerprint) (*Delta, error) {
+
+	rmi := s.rmi
+	current := s.Fingerprint()
+	if fp.Width != current.Width || fp.Depth != current.Depth || len(fp.Leaves) != len(current.Leaves) {
+		return nil, errors.New("fingerprint was taken from a model with a different shape")
+	}
+
+	if fp.Internal != current.Internal {
+		return nil, errors.New("non-leaf nodes changed since the fingerprint was taken")
+	}
+
+	delta := &Delta{MaxIndex: s.v.maxIndex, Leaves: make([]LeafUpdate, 0)}
+	leafLayer := s.v.nodes[rmi.depth-1]
+	for i, h := range current.Leaves {
+		if h != fp.Leaves[i] {
+			node := leafLayer[i]
+			update := LeafUpdate{
+				Index: i,
+				M:     new(big.Float).Copy(node.m),
+				B:     new(big.Float).Copy(node.b),
+				Log:   node.log,
+			}
+
+			if node.alt != nil {
+				update.Breakpoint = new(big.Int).Set(node.breakpoint)
+				update.AltM = new(big.Float).Copy(node.alt.m)
+				update.AltB = new(big.Float).Copy(node.alt.b)
+			}
+
+			delta.Leaves = append(delta.Leaves, update)
+		}
+	}
+
+	return delta, nil
+}
+
+// ApplyDelta installs the leaf coefficients of a delta produced by DeltaSince
+// on a replica by publishing them as a new epoch (see epoch.go)
+func (rmi *RMI) ApplyDelta(delta *Delta) error {
+
+	numLeaves := len(rmi.current.Load().nodes[rmi.depth-1])
+	replaced := make(map[int]*Node)
+	for _, leaf := range delta.Leaves {
+		if leaf.Index < 0 || leaf.Index >= numLeaves {
+			return errors.New("delta leaf index out of range")
+		}
+
+		node := newLinearNode(leaf.M, leaf.B)
+		node.log = leaf.Log
+		if node.log {
+			node.fit = ModelLogLinear
+		}
+		if leaf.Breakpoint != nil {
+			node.alt = newLinearNode(leaf.AltM, leaf.AltB)
+			node.breakpoint = new(big.Int).Set(leaf.Breakpoint)
+		}
+
+		replaced[leaf.Index] = node
+	}
+
+	rmi.publishLeaves(replaced, delta.MaxIndex)
+
+	return nil
+}
@@ -0,0 +1,41 @@
+package rmi
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestDeltaSince(t *testing.T) {
+	values := sortedTestData(2000)
+	primary, _ := NewRMI(values, RMIWidthParameter, RMIDepthParameter)
+	replica, _ := NewRMI(values, RMIWidthParameter, RMIDepthParameter)
+
+	fp := replica.Fingerprint()
+
+	delta, err := primary.DeltaSince(fp)
+	if err != nil || len(delta.Leaves) != 0 {
+		t.Fatalf("identical models must have an empty delta (err = %v)", err)
+	}
+
+	// change a single leaf on the primary
+	primary.current.Load().nodes[RMIDepthParameter-1][3].b = big.NewFloat(42)
+
+	delta, err = primary.DeltaSince(fp)
+	if err != nil || len(delta.Leaves) != 1 || delta.Leaves[0].Index != 3 {
+		t.Fatalf("expected exactly leaf 3 to change (err = %v)", err)
+	}
+
+	if err := replica.ApplyDelta(delta); err != nil {
+		t.Fatalf("failed to apply delta %v", err)
+	}
+
+	if replica.Fingerprint().Leaves[3] != primary.Fingerprint().Leaves[3] {
+		t.Fatalf("replica leaf does not match the primary after applying the delta")
+	}
+
+	// changing the root cannot be expressed as a leaf delta
+	primary.current.Load().root.b = big.NewFloat(42)
+	if _, err := primary.DeltaSince(fp); err == nil {
+		t.Fatalf("expected root change to be rejected")
+	}
+}
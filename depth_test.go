@@ -0,0 +1,138 @@
+package rmi
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+)
+
+func TestDepthOne(t *testing.T) {
+	values := sortedTestData(1000)
+
+	rmi, err := NewRMI(values, RMIWidthParameter, 1)
+	if err != nil {
+		t.Fatalf("Failed to build single model RMI %v\n", err)
+	}
+
+	if rmi.NumLeaves() != 1 {
+		t.Fatalf("expected a single leaf but got %v", rmi.NumLeaves())
+	}
+
+	for i, value := range values {
+		if index := rmi.GetIndex(value); index < 0 || index >= len(values) {
+			t.Fatalf("index %v of key %v out of range", index, i)
+		}
+	}
+	t.Logf("single model max error %v mean error %v", rmi.MaxError(), rmi.MeanError())
+
+	for i := 0; i < len(values); i += 97 {
+		if index, ok := rmi.findExact(rmi.current.Load(), values[i]); !ok || values[index].Cmp(values[i]) != 0 {
+			t.Fatalf("failed to find key %v", i)
+		}
+	}
+
+	if _, err := rmi.RetrainLeaf(0, values, indicesOf(values)); err != nil {
+		t.Fatalf("Failed to retrain the single leaf %v\n", err)
+	}
+
+	if ranges := rmi.PlanParallelScan(values[0], values[len(values)-1], 4); len(ranges) == 0 {
+		t.Fatalf("expected a scan plan over the single model")
+	}
+
+	if delta, err := rmi.DeltaSince(rmi.Fingerprint()); err != nil || len(delta.Leaves) != 0 {
+		t.Fatalf("expected an empty delta against the model's own fingerprint")
+	}
+
+	var buf bytes.Buffer
+	if err := rmi.Save(&buf); err != nil {
+		t.Fatalf("Failed to save %v\n", err)
+	}
+	loaded, err := Load(&buf, values, rmi.Metadata())
+	if err != nil {
+		t.Fatalf("Failed to load %v\n", err)
+	}
+	if loaded.GetIndex(values[500]) != rmi.GetIndex(values[500]) {
+		t.Fatalf("loaded single model RMI predicts differently")
+	}
+
+	// options that act on the routing or the leaves must work without an internal layer
+	opts := []Option{WithRoutingCache(8, 16), WithLeafEnsemble(), WithLastMileTables(0), WithCalibration()}
+	for _, opt := range opts {
+		if _, err := NewRMI(values, RMIWidthParameter, 1, opt); err != nil {
+			t.Fatalf("Failed to build single model RMI with options %v\n", err)
+		}
+	}
+}
+
+func TestSingleKey(t *testing.T) {
+	values := []*big.Int{big.NewInt(42)}
+
+	for depth := 1; depth <= 3; depth++ {
+		rmi, err := NewRMI(values, 2, depth)
+		if err != nil {
+			t.Fatalf("Failed to build RMI over a single key %v\n", err)
+		}
+
+		if index := rmi.GetIndex(values[0]); index != 0 {
+			t.Fatalf("expected index 0 at depth %v but got %v", depth, index)
+		}
+	}
+}
+
+func indicesOf(values []*big.Int) []*big.Int {
+	indices := make([]*big.Int, len(values))
+	for i := range values {
+		indices[i] = big.NewInt(int64(i))
+	}
+
+	return indices
+}
+
+func TestWidthOne(t *testing.T) {
+	values := sortedTestData(1000)
+	single, _ := NewRMI(values, RMIWidthParameter, 1)
+
+	for depth := 2; depth <= 4; depth++ {
+		chain, err := NewRMI(values, 1, depth)
+		if err != nil {
+			t.Fatalf("Failed to build width 1 RMI %v\n", err)
+		}
+
+		if chain.NumLeaves() != 1 {
+			t.Fatalf("expected a single leaf at depth %v but got %v", depth, chain.NumLeaves())
+		}
+
+		// every model of the chain is trained on all keys, so the
+		// leaf is the same model as the single model RMI
+		for i := 0; i < len(values); i += 13 {
+			if chain.GetIndex(values[i]) != single.GetIndex(values[i]) {
+				t.Fatalf("width 1 chain of depth %v differs from the single model at key %v", depth, i)
+			}
+		}
+	}
+}
+
+func TestNewRMIWithLeaves(t *testing.T) {
+	values := sortedTestData(5000)
+
+	for _, leaves := range []int{1, 7, 100, 1000} {
+		rmi, err := NewRMIWithLeaves(values, leaves)
+		if err != nil {
+			t.Fatalf("Failed to build RMI with %v leaves %v\n", leaves, err)
+		}
+
+		if rmi.NumLeaves() != leaves {
+			t.Fatalf("expected %v leaves, got %v", leaves, rmi.NumLeaves())
+		}
+
+		for i, value := range values {
+			if index, ok := rmi.Lookup(value); !ok || values[index].Cmp(value) != 0 {
+				t.Fatalf("Lookup of key %v failed with %v leaves", i, leaves)
+			}
+		}
+	}
+
+	if _, err := NewRMIWithLeaves(values, 0); err == nil {
+		t.Fatalf("expected zero leaves to be rejected")
+	}
+}
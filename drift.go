@@ -0,0 +1,76 @@
+// drift.go: monitoring of live correction distances against the
+// training-time error, answering the "when to retrain" question
+
+package rmi
+
+import (
+	"math"
+	"sync"
+)
+
+/*
+DriftAlert describes a window of queries whose correction distances drifted
+Z: z-score of the window's mean distance relative to the training-time error
+LiveMean: mean absolute correction distance of the window
+TrainMean, TrainStd: mean and standard deviation of the training-time absolute error
+Samples: number of queries in the window
+*/
+type DriftAlert struct {
+	Z                   float64
+	LiveMean            float64
+	TrainMean, TrainStd float64
+	Samples             int
+}
+
+/*
+Monitor of the correction distances of exact queries
+threshold: z-score above which alert is called
+window: number of queries per evaluated window
+sum, count: running totals of the current window
+*/
+type driftMonitor struct {
+	threshold float64
+	window    int
+	alert     func(DriftAlert)
+
+	mu    sync.Mutex
+	sum   float64
+	count int
+}
+
+// recordCorrection feeds the distance between an exact query's result and
+// the model prediction to the drift monitor (if any)
+func (rmi *RMI) recordCorrection(distance int) {
+	monitor := rmi.conf.drift
+	if monitor == nil {
+		return
+	}
+
+	monitor.mu.Lock()
+	monitor.sum += math.Abs(float64(distance))
+	monitor.count++
+	if monitor.count < monitor.window {
+		monitor.mu.Unlock()
+		return
+	}
+
+	liveMean := monitor.sum / float64(monitor.count)
+	samples := monitor.count
+	monitor.sum = 0
+	monitor.count = 0
+	monitor.mu.Unlock()
+
+	trainMean, trainStd := rmi.meanStdError(rmi.current.Load())
+
+	// z-score of the window mean under the training-time error distribution
+	z := 0.0
+	if trainStd > 0 {
+		z = (liveMean - trainMean) / (trainStd / math.Sqrt(float64(samples)))
+	} else if liveMean > trainMean {
+		z = math.Inf(1)
+	}
+
+	if z > monitor.threshold && monitor.alert != nil {
+		monitor.alert(DriftAlert{z, liveMean, trainMean, trainStd, samples})
+	}
+}
@@ -0,0 +1,38 @@
+package rmi
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestDriftMonitor(t *testing.T) {
+	values := sortedTestData(5000)
+
+	alerts := make([]DriftAlert, 0)
+	rmi, _ := NewRMI(values, RMIWidthParameter, RMIDepthParameter,
+		WithDriftMonitor(6, 100, func(a DriftAlert) { alerts = append(alerts, a) }))
+
+	// queries over the training keys behave like the training data
+	for i := 0; i < 1000; i++ {
+		rmi.Nearest(values[(i*37)%len(values)])
+	}
+
+	if len(alerts) != 0 {
+		t.Fatalf("unexpected alert on training keys %+v", alerts[0])
+	}
+
+	// emulate data drifting away from the model: the retained keys now follow a
+	// skewed distribution while the model (and its training error) is unchanged
+	rmi.MeanError()
+	for i := range rmi.values {
+		rmi.values[i] = new(big.Int).Mul(big.NewInt(int64(i)), big.NewInt(int64(i)))
+	}
+
+	for i := 0; i < 100; i++ {
+		rmi.Nearest(rmi.values[len(values)/4+i])
+	}
+
+	if len(alerts) != 1 || alerts[0].Samples != 100 || alerts[0].LiveMean <= alerts[0].TrainMean {
+		t.Fatalf("expected exactly one drift alert, got %+v", alerts)
+	}
+}
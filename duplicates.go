@@ -0,0 +1,55 @@
+// duplicates.go: first/last semantics for repeated keys. Every occurrence of
+// a key is a training point, so the prediction of a repeated key targets the
+// middle of its run rather than either end; all occurrences are routed to the
+// same leaf and share its error window, which thus holds the whole run (a run
+// longer than the window of its leaf is searched to its end).
+
+package rmi
+
+import (
+	"math/big"
+	"sort"
+)
+
+// GetIndexFirst returns the index of the first live occurrence of value
+// (or false if it is absent or all its occurrences are deleted); it is
+// Lookup, named for symmetry with GetIndexLast
+func (rmi *RMI) GetIndexFirst(value *big.Int) (int, bool) {
+	return rmi.lookupAt(rmi.current.Load(), value)
+}
+
+// GetIndexLast returns the index of the last live occurrence of value
+// (or false if it is absent or all its occurrences are deleted)
+func (rmi *RMI) GetIndexLast(value *big.Int) (int, bool) {
+
+	v := rmi.current.Load()
+	first, ok := rmi.findExact(v, value)
+	if !ok {
+		return 0, false
+	}
+
+	deleted := rmi.deletions()
+	for index := rmi.runEnd(v, value, first) - 1; index >= first; index-- {
+		if !deleted.isDeleted(index) {
+			return index, true
+		}
+	}
+
+	return 0, false
+}
+
+// runEnd returns the index past the last occurrence of value, whose first
+// occurrence is at first; the end is searched within the error window of
+// the leaf of value unless the run reaches the end of the window
+func (rmi *RMI) runEnd(v *version, value *big.Int, first int) int {
+
+	_, loc := rmi.leaf(v, value)
+	hi := min(len(rmi.values)-1, rmi.getIndex(v, value)+rmi.errorsOf(v)[loc].maxResidual)
+	if hi < first || rmi.values[hi].Cmp(value) == 0 {
+		hi = len(rmi.values) - 1
+	}
+
+	return first + sort.Search(hi-first+1, func(i int) bool {
+		return rmi.values[first+i].Cmp(value) == 1
+	})
+}
@@ -0,0 +1,58 @@
+package rmi
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+func TestGetIndexFirstLast(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	// runs of 1 to 50 copies of every other even key
+	values := make([]*big.Int, 0)
+	first := make(map[int64]int)
+	last := make(map[int64]int)
+	for key := int64(0); len(values) < 20000; key += 2 + 2*r.Int63n(50) {
+		first[key] = len(values)
+		for n := 1 + r.Intn(50); n > 0; n-- {
+			values = append(values, big.NewInt(key))
+		}
+		last[key] = len(values) - 1
+	}
+
+	rmi, err := NewRMI(values, 16, 2)
+	if err != nil {
+		t.Fatalf("Failed to build RMI %v\n", err)
+	}
+
+	for key := range first {
+		lo, okFirst := rmi.GetIndexFirst(big.NewInt(key))
+		hi, okLast := rmi.GetIndexLast(big.NewInt(key))
+		if !okFirst || !okLast || lo != first[key] || hi != last[key] {
+			t.Fatalf("key %v at [%v, %v], expected [%v, %v]", key, lo, hi, first[key], last[key])
+		}
+
+		if _, ok := rmi.GetIndexLast(big.NewInt(key + 1)); ok {
+			t.Fatalf("found absent key %v", key+1)
+		}
+	}
+
+	// deleted occurrences are skipped from either end
+	var key int64
+	for key = range first {
+		if last[key] > first[key]+1 {
+			break
+		}
+	}
+	deleted := NewBitmap(len(values))
+	deleted.Set(first[key])
+	deleted.Set(last[key])
+	rmi.SetDeletions(deleted)
+
+	lo, _ := rmi.GetIndexFirst(big.NewInt(key))
+	hi, _ := rmi.GetIndexLast(big.NewInt(key))
+	if lo != first[key]+1 || hi != last[key]-1 {
+		t.Fatalf("key %v at [%v, %v] with deletions, expected [%v, %v]", key, lo, hi, first[key]+1, last[key]-1)
+	}
+}
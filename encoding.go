@@ -0,0 +1,208 @@
+// encoding.go: platform independent binary encoding of the coefficients.
+// Floats are written with their precision, rounding mode, exponent and
+// mantissa as explicit fixed-width big-endian fields (rather than the
+// GobEncode defaults), so models load bit-identically on every platform.
+
+package rmi
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/big"
+)
+
+// kinds of encoded floats
+const (
+	floatZero   byte = 0
+	floatFinite byte = 1
+	floatInf    byte = 2
+)
+
+// flags of encoded nodes
+const (
+	nodeAlt byte = 1 // a breakpoint and second model follow
+	nodeLog byte = 2 // the model is log-linear
+
+	nodeFallback byte = 4 // an ill-conditioned regression was replaced
+
+	// the model type the node was trained as is stored in the upper bits
+	nodeFitShift = 4
+)
+
+var errShortBuffer = errors.New("encoded data is truncated")
+
+// appendFloat appends the encoding of f to buf:
+// kind (1 byte), sign (1 byte), rounding mode (1 byte), precision (uint32),
+// and for finite non-zero values exponent (int32), mantissa length (uint32)
+// and the mantissa as a big-endian integer of prec bits
+func appendFloat(buf []byte, f *big.Float) []byte {
+
+	kind := floatFinite
+	if f.Sign() == 0 {
+		kind = floatZero
+	} else if f.IsInf() {
+		kind = floatInf
+	}
+
+	sign := byte(0)
+	if f.Signbit() {
+		sign = 1
+	}
+
+	buf = append(buf, kind, sign, byte(f.Mode()))
+	buf = binary.BigEndian.AppendUint32(buf, uint32(f.Prec()))
+
+	if kind != floatFinite {
+		return buf
+	}
+
+	mant := new(big.Float)
+	exp := f.MantExp(mant)
+	mant.Abs(mant)
+
+	// scale the mantissa into an integer of (at most) prec bits
+	integer, _ := mant.SetMantExp(mant, int(f.Prec())).Int(nil)
+	mantBytes := integer.Bytes()
+
+	buf = binary.BigEndian.AppendUint32(buf, uint32(int32(exp)))
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(mantBytes)))
+	return append(buf, mantBytes...)
+}
+
+// readFloat decodes a float written by appendFloat and returns the rest of data
+func readFloat(data []byte) (*big.Float, []byte, error) {
+
+	if len(data) < 7 {
+		return nil, nil, errShortBuffer
+	}
+
+	kind, sign, mode := data[0], data[1], big.RoundingMode(data[2])
+	prec := uint(binary.BigEndian.Uint32(data[3:7]))
+	data = data[7:]
+
+	if mode > big.ToPositiveInf || prec > big.MaxPrec {
+		return nil, nil, errors.New("invalid float encoding")
+	}
+
+	f := new(big.Float).SetPrec(prec).SetMode(mode)
+
+	switch kind {
+	case floatZero:
+		if sign == 1 {
+			f.Neg(f)
+		}
+		return f, data, nil
+
+	case floatInf:
+		f.SetInf(sign == 1)
+		return f, data, nil
+
+	case floatFinite:
+		if len(data) < 8 {
+			return nil, nil, errShortBuffer
+		}
+
+		exp := int(int32(binary.BigEndian.Uint32(data[0:4])))
+		n := binary.BigEndian.Uint32(data[4:8])
+		data = data[8:]
+		if uint64(len(data)) < uint64(n) || prec == 0 {
+			return nil, nil, errShortBuffer
+		}
+
+		f.SetInt(new(big.Int).SetBytes(data[:n]))
+		f.SetMantExp(f, exp-int(prec))
+		if sign == 1 {
+			f.Neg(f)
+		}
+		return f, data[n:], nil
+	}
+
+	return nil, nil, errors.New("invalid float encoding")
+}
+
+// MarshalBinary encodes the coefficients of the node model (and of its
+// second model and breakpoint if the leaf keeps one, see ensemble.go)
+func (node *Node) MarshalBinary() ([]byte, error) {
+	return appendNode(make([]byte, 0), node), nil
+}
+
+// UnmarshalBinary decodes coefficients written by MarshalBinary
+func (node *Node) UnmarshalBinary(data []byte) error {
+
+	decoded, rest, err := readNode(data)
+	if err != nil {
+		return err
+	}
+
+	if len(rest) != 0 {
+		return errors.New("trailing data after node encoding")
+	}
+
+	*node = *decoded
+	return nil
+}
+
+// appendNode appends m, b, a flag byte (see nodeAlt) and the breakpoint
+// and second model
+func appendNode(buf []byte, node *Node) []byte {
+	buf = appendFloat(buf, node.m)
+	buf = appendFloat(buf, node.b)
+
+	flags := byte(node.fit) << nodeFitShift
+	if node.log {
+		flags |= nodeLog
+	}
+	if node.fallback {
+		flags |= nodeFallback
+	}
+
+	if node.alt == nil {
+		return append(buf, flags)
+	}
+
+	buf = append(buf, flags|nodeAlt)
+	buf = appendFloat(buf, new(big.Float).SetInt(node.breakpoint))
+	return appendNode(buf, node.alt)
+}
+
+// readNode decodes a node written by appendNode and returns the rest of data
+func readNode(data []byte) (*Node, []byte, error) {
+
+	m, data, err := readFloat(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	b, data, err := readFloat(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	node := &Node{m: m, b: b, w: xIntercept(m, b)}
+
+	if len(data) < 1 {
+		return nil, nil, errShortBuffer
+	}
+
+	hasAlt := data[0]&nodeAlt != 0
+	node.log = data[0]&nodeLog != 0
+	node.fallback = data[0]&nodeFallback != 0
+	node.fit = ModelType(data[0] >> nodeFitShift)
+	data = data[1:]
+	if !hasAlt {
+		return node, data, nil
+	}
+
+	breakpoint, data, err := readFloat(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	node.breakpoint, _ = breakpoint.Int(nil)
+	node.alt, data, err = readNode(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return node, data, nil
+}
@@ -0,0 +1,84 @@
+package rmi
+
+import (
+	"encoding/hex"
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestFloatRoundTrip(t *testing.T) {
+	floats := []*big.Float{
+		big.NewFloat(0),
+		new(big.Float).Neg(big.NewFloat(0)),
+		big.NewFloat(math.Inf(-1)),
+		big.NewFloat(1.0 / 3.0),
+		big.NewFloat(-123456789.125),
+		new(big.Float).SetPrec(256).SetMode(big.ToZero).Quo(big.NewFloat(1), big.NewFloat(7)),
+		new(big.Float).SetPrec(200).SetInt(new(big.Int).Lsh(big.NewInt(3), 190)),
+	}
+
+	for _, f := range floats {
+		decoded, rest, err := readFloat(appendFloat(nil, f))
+		if err != nil || len(rest) != 0 {
+			t.Fatalf("failed to decode %v: %v", f, err)
+		}
+
+		if decoded.Cmp(f) != 0 || decoded.Prec() != f.Prec() || decoded.Mode() != f.Mode() || decoded.Signbit() != f.Signbit() {
+			t.Fatalf("round trip of %v (prec %v) gave %v (prec %v)", f, f.Prec(), decoded, decoded.Prec())
+		}
+	}
+}
+
+// the encoding must be byte-identical on every architecture (e.g., GOARCH=386 or arm);
+// golden values were produced on amd64
+func TestFloatEncodingGolden(t *testing.T) {
+	golden := map[float64]string{
+		0:     "00000000000035",
+		-2.5:  "01010000000035000000020000000714000000000000",
+		0.125: "01000000000035fffffffe0000000710000000000000",
+	}
+
+	for f, expected := range golden {
+		encoded := hex.EncodeToString(appendFloat(nil, big.NewFloat(f)))
+		if encoded != expected {
+			t.Fatalf("encoding of %v is %v, expected %v", f, encoded, expected)
+		}
+	}
+}
+
+func TestNodeRoundTrip(t *testing.T) {
+	values := sortedTestData(1000)
+	rmi, _ := NewRMI(values, 1, 1, WithLeafEnsemble())
+	node := rmi.current.Load().root
+
+	data, _ := node.MarshalBinary()
+	decoded := &Node{}
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatalf("failed to decode node %v", err)
+	}
+
+	for _, value := range values[:100] {
+		if decoded.modelFor(value).predict(value).Cmp(node.modelFor(value).predict(value)) != 0 {
+			t.Fatalf("decoded node predicts differently")
+		}
+	}
+
+	if err := decoded.UnmarshalBinary(data[:len(data)-3]); err == nil {
+		t.Fatalf("expected truncated data to be rejected")
+	}
+}
+
+func TestHugeLengthFields(t *testing.T) {
+
+	// lengths above 2^31 must be rejected rather than wrap around on 32 bit platforms
+	float := appendFloat(nil, big.NewFloat(3.5))
+	float[11], float[12], float[13], float[14] = 0xff, 0xff, 0xff, 0xff
+	if _, _, err := readFloat(float); err == nil {
+		t.Fatalf("expected a huge mantissa length to be rejected")
+	}
+
+	if _, _, err := readString([]byte{0xff, 0xff, 0xff, 0xfe, 'a'}); err == nil {
+		t.Fatalf("expected a huge string length to be rejected")
+	}
+}
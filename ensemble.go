@@ -0,0 +1,97 @@
+// ensemble.go: leaves with two models split at a breakpoint key.
+// Leaves whose data has a knee are poorly served by a single line;
+// such leaves keep a second model (linear or constant-median) for
+// the keys at and beyond the breakpoint and select one per query.
+
+package rmi
+
+import (
+	"math"
+	"math/big"
+)
+
+// candidate breakpoints of a leaf as fractions of its keys
+var ensembleSplits = []float64{0.25, 0.5, 0.75}
+
+// modelFor returns the model of the node that serves value
+func (node *Node) modelFor(value *big.Int) *Node {
+	if node.alt != nil && value.Cmp(node.breakpoint) != -1 {
+		return node.alt
+	}
+
+	return node
+}
+
+// trains the constant model predicting the median index of the task
+func trainMedian(task buildTask) *Node {
+	if len(task.indices) == 0 {
+		return trainNode(task)
+	}
+
+	median := new(big.Float).SetInt(task.indices[len(task.indices)/2])
+	return newLinearNode(big.NewFloat(0), median)
+}
+
+// maximum absolute error of the node model over the data of the task
+func maxNodeError(node *Node, task buildTask) float64 {
+	maxErr := 0.0
+	for i, value := range task.values {
+		prediction, _ := node.modelFor(value).predict(value).Float64()
+		index, _ := new(big.Float).SetInt(task.indices[i]).Float64()
+		maxErr = math.Max(maxErr, math.Abs(prediction-index))
+	}
+
+	return maxErr
+}
+
+// trains the best of a linear and a constant-median model for the task
+func trainSide(task buildTask) (*Node, float64) {
+	linear := trainNode(task)
+	median := trainMedian(task)
+
+	linearErr := maxNodeError(linear, task)
+	medianErr := maxNodeError(median, task)
+	if medianErr < linearErr {
+		return median, medianErr
+	}
+
+	return linear, linearErr
+}
+
+// trainEnsemble trains a leaf and splits it into two models at the
+// candidate breakpoint that minimises the maximum error, if any does
+// better than a single linear model
+func trainEnsemble(task buildTask) *Node {
+
+	best := trainNode(task)
+	bestErr := maxNodeError(best, task)
+
+	n := len(task.values)
+	if n < 4 {
+		return best
+	}
+
+	for _, fraction := range ensembleSplits {
+		split := int(float64(n) * fraction)
+
+		// keys equal to the breakpoint must all be served by the right model
+		for split > 0 && task.values[split-1].Cmp(task.values[split]) == 0 {
+			split--
+		}
+		if split == 0 {
+			continue
+		}
+
+		left, leftErr := trainSide(buildTask{task.values[:split], task.indices[:split], task.offset})
+		right, rightErr := trainSide(buildTask{task.values[split:], task.indices[split:], task.indices[split]})
+
+		if err := math.Max(leftErr, rightErr); err < bestErr {
+			left.alt = right
+			left.breakpoint = task.values[split]
+			best = left
+			bestErr = err
+		}
+	}
+
+	return best
+}
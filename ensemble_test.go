@@ -0,0 +1,54 @@
+package rmi
+
+import (
+	"math"
+	"math/big"
+	"testing"
+)
+
+// maximum distance between predicted and true index
+func maxIndexError(rmi *RMI, values []*big.Int) float64 {
+	maxErr := 0.0
+	for i, value := range values {
+		maxErr = math.Max(maxErr, math.Abs(float64(rmi.GetIndex(value)-i)))
+	}
+	return maxErr
+}
+
+func TestLeafEnsemble(t *testing.T) {
+
+	// keys with a knee: dense for the first half, sparse for the second
+	values := make([]*big.Int, 2000)
+	for i := range values {
+		if i < 1000 {
+			values[i] = big.NewInt(int64(i))
+		} else {
+			values[i] = big.NewInt(int64(1000 + (i-1000)*1000))
+		}
+	}
+
+	plain, _ := NewRMI(values, 1, 1)
+	ensemble, err := NewRMI(values, 1, 1, WithLeafEnsemble())
+	if err != nil {
+		t.Fatalf("Failed to build RMI %v\n", err)
+	}
+
+	plainErr := maxIndexError(plain, values)
+	ensembleErr := maxIndexError(ensemble, values)
+	t.Logf("max error single model %v, ensemble %v", plainErr, ensembleErr)
+
+	if ensembleErr >= plainErr {
+		t.Fatalf("ensemble did not reduce the error: %v >= %v", ensembleErr, plainErr)
+	}
+
+	// the ensemble leaf must survive replication through a delta
+	replica, _ := NewRMI(values, 1, 1)
+	delta, _ := ensemble.DeltaSince(replica.Fingerprint())
+	if err := replica.ApplyDelta(delta); err != nil {
+		t.Fatalf("failed to apply delta %v", err)
+	}
+
+	if maxIndexError(replica, values) != ensembleErr {
+		t.Fatalf("replica does not match the ensemble after applying the delta")
+	}
+}
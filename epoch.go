@@ -0,0 +1,154 @@
+// epoch.go: multi-version concurrency for the model coefficients.
+// Every change to the nodes publishes a new immutable version (epoch)
+// with copy-on-write layers, so queries never take a lock and readers
+// that need a consistent view across several queries pin an epoch.
+
+package rmi
+
+import (
+	"context"
+	"errors"
+	"math/big"
+)
+
+/*
+Immutable version of all nodes in the model
+epoch: version number, incremented by every published change
+root: top most node in rmi
+nodes: each []*Node is all the nodes of a layer (pointing into flat)
+flat: all nodes of all layers in one arena, layer by layer (see coefficients.go)
+starts: position in flat of the first node of each layer, and the number of nodes
+coeffs: coefficients of the nodes of each layer (see coefficients.go)
+maxIndex: maximum index in the data structure
+calib: optional calibration applied to leaf predictions (see calibration.go)
+errs: error statistics of this version, measured on first use (see accuracy.go)
+tree: Merkle tree over the nodes of this version, built on first use (see audit.go)
+*/
+type version struct {
+	epoch    uint64
+	root     *Node
+	nodes    [][]*Node
+	flat     []Node
+	starts   []int
+	coeffs   []*layerCoefficients
+	maxIndex int
+	calib    *calibration
+	errs     *lazyErrors
+	tree     *lazyTree
+}
+
+func newVersion(epoch uint64, nodes [][]*Node, maxIndex int) *version {
+	return &version{epoch: epoch, root: nodes[0][0], nodes: nodes, maxIndex: maxIndex, errs: &lazyErrors{}, tree: &lazyTree{}}
+}
+
+// next returns a (shallow) copy of v with the epoch incremented
+func (v *version) next() *version {
+	next := *v
+	next.epoch++
+	next.errs = &lazyErrors{}
+	next.tree = &lazyTree{}
+	return &next
+}
+
+// withLeaves returns the next version with the given leaves replaced;
+// only the leaf layer is copied (and repacked), all other layers are shared
+func (v *version) withLeaves(replaced map[int]*Node, maxIndex int) *version {
+
+	depth := len(v.nodes)
+	nodes := make([][]*Node, depth)
+	copy(nodes, v.nodes)
+
+	leaves := make([]*Node, len(v.nodes[depth-1]))
+	copy(leaves, v.nodes[depth-1])
+	for i, node := range replaced {
+		leaves[i] = node
+	}
+	coeffs := make([]*layerCoefficients, depth)
+	copy(coeffs, v.coeffs)
+	nodes[depth-1], coeffs[depth-1] = packLayer(leaves, 0)
+
+	next := v.next()
+	next.root = nodes[0][0]
+	next.nodes = nodes
+	next.coeffs = coeffs
+	next.maxIndex = maxIndex
+
+	return next
+}
+
+// publish atomically installs the version returned by change(current)
+func (rmi *RMI) publish(change func(v *version) *version) uint64 {
+	rmi.retrain.Lock()
+	defer rmi.retrain.Unlock()
+
+	current := rmi.current.Load()
+	next := change(current)
+	if next != current {
+		next.flatten()
+	}
+	rmi.current.Store(next)
+
+	return next.epoch
+}
+
+// publishLeaves atomically installs a new version with the given leaves replaced
+func (rmi *RMI) publishLeaves(replaced map[int]*Node, maxIndex int) uint64 {
+	return rmi.publish(func(v *version) *version {
+		return v.withLeaves(replaced, maxIndex)
+	})
+}
+
+// Epoch returns the epoch of the current version of the model
+func (rmi *RMI) Epoch() uint64 {
+	return rmi.current.Load().epoch
+}
+
+// Snapshot is a pinned epoch of the model; all queries on a
+// snapshot see the same coefficients even if leaves are retrained
+type Snapshot struct {
+	rmi *RMI
+	v   *version
+}
+
+// Pin returns a snapshot of the current version of the model
+func (rmi *RMI) Pin() Snapshot {
+	return Snapshot{rmi, rmi.current.Load()}
+}
+
+// Epoch returns the epoch the snapshot is pinned to
+func (s Snapshot) Epoch() uint64 {
+	return s.v.epoch
+}
+
+// GetIndex is RMI.GetIndex evaluated on the pinned version of the model
+func (s Snapshot) GetIndex(value *big.Int) int {
+	return s.rmi.getIndexAt(context.Background(), s.v, value)
+}
+
+// Locate is RMI.Locate evaluated on the pinned version of the model
+func (s Snapshot) Locate(value *big.Int) (index int, leafID int) {
+	return s.rmi.locate(s.v, value)
+}
+
+// RetrainLeaf retrains the leaf at position leaf in the leaf layer on the
+// given (sorted) keys and their indices and publishes it as a new epoch;
+// queries running concurrently keep using the version they started with
+func (rmi *RMI) RetrainLeaf(leaf int, values []*big.Int, indices []*big.Int) (uint64, error) {
+
+	if leaf < 0 || leaf >= len(rmi.current.Load().nodes[rmi.depth-1]) {
+		return 0, errors.New("leaf index out of range")
+	}
+
+	if len(values) != len(indices) {
+		return 0, errors.New("values and indices must have the same length")
+	}
+
+	offset := big.NewInt(0)
+	if len(indices) > 0 {
+		offset = indices[0]
+	}
+
+	node := rmi.conf.linear(buildTask{values, indices, offset})
+
+	return rmi.publishLeaves(map[int]*Node{leaf: node}, rmi.current.Load().maxIndex), nil
+}
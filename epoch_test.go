@@ -0,0 +1,55 @@
+package rmi
+
+import (
+	"math/big"
+	"sync"
+	"testing"
+)
+
+func TestRetrainLeafEpochs(t *testing.T) {
+	values := sortedTestData(2000)
+	rmi, _ := NewRMI(values, RMIWidthParameter, RMIDepthParameter)
+
+	query := values[1000]
+	pinned := rmi.Pin()
+	before := pinned.GetIndex(query)
+
+	// concurrent readers must never block or observe a partially published version
+	var wg sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				rmi.GetIndex(values[i*10%len(values)])
+			}
+		}()
+	}
+
+	// retrain every leaf as a constant model predicting the last index
+	leaves := len(rmi.current.Load().nodes[rmi.depth-1])
+	last := []*big.Int{values[len(values)-1]}
+	lastIndex := []*big.Int{big.NewInt(int64(len(values) - 1))}
+	for leaf := 0; leaf < leaves; leaf++ {
+		if _, err := rmi.RetrainLeaf(leaf, last, lastIndex); err != nil {
+			t.Fatalf("failed to retrain leaf %v", err)
+		}
+	}
+	wg.Wait()
+
+	if rmi.Epoch() != uint64(leaves) || pinned.Epoch() != 0 {
+		t.Fatalf("unexpected epochs current = %v pinned = %v", rmi.Epoch(), pinned.Epoch())
+	}
+
+	if pinned.GetIndex(query) != before {
+		t.Fatalf("pinned snapshot observed retrained leaves")
+	}
+
+	if rmi.GetIndex(query) != len(values)-1 {
+		t.Fatalf("current version does not use the retrained leaves")
+	}
+
+	if _, err := rmi.RetrainLeaf(leaves, last, lastIndex); err == nil {
+		t.Fatalf("expected out of range leaf to be rejected")
+	}
+}
@@ -0,0 +1,309 @@
+// main.go: learned index over a sorted key-value file. The file is an array
+// of fixed-size records, each an 8-byte big-endian key followed by the value,
+// sorted by key. The index maps a key to its record number, so a get is one
+// model evaluation, a search of the key column within the error window and
+// one read of the record; a range scan reads the records between the bounds
+// of its two keys in a single read.
+//
+// Usage:
+//
+//	kvfile -file data.kv -generate 1000000   write a sample file and benchmark it
+//	kvfile -file data.kv                     benchmark gets and scans on a file
+//	kvfile -file data.kv get KEY             print the value of KEY
+//	kvfile -file data.kv scan LO HI          print the records with keys in [LO, HI]
+
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/sachaservan/rmi"
+)
+
+// size of a record in bytes: the key and a fixed-size value
+const (
+	keySize    = 8
+	valueSize  = 24
+	recordSize = keySize + valueSize
+)
+
+/*
+Store is a read-only key-value file with a learned index over its keys
+file: the key-value file
+keys: the key column (retained by the index for the error window search)
+index: the RMI over the keys
+*/
+type Store struct {
+	file  *os.File
+	keys  []*big.Int
+	index *rmi.RMI
+}
+
+// OpenStore reads the key column of the file at path and builds its index
+func OpenStore(path string) (*Store, error) {
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := readKeys(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	index, err := rmi.NewRMIWithOptions(keys, rmi.WithClampShape())
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("indexing %v: %w", path, err)
+	}
+
+	return &Store{file: f, keys: keys, index: index}, nil
+}
+
+// readKeys returns the keys of all records of the file
+func readKeys(f *os.File) ([]*big.Int, error) {
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	if info.Size()%recordSize != 0 {
+		return nil, errors.New("file size is not a multiple of the record size")
+	}
+
+	keys := make([]*big.Int, info.Size()/recordSize)
+	r := bufio.NewReaderSize(io.NewSectionReader(f, 0, info.Size()), 1<<20)
+	record := make([]byte, recordSize)
+	for i := range keys {
+		if _, err := io.ReadFull(r, record); err != nil {
+			return nil, err
+		}
+		keys[i] = new(big.Int).SetUint64(binary.BigEndian.Uint64(record))
+	}
+
+	return keys, nil
+}
+
+// Close closes the file of the store
+func (s *Store) Close() error {
+	return s.file.Close()
+}
+
+// Get returns the value of key (or false if the file has no such key)
+func (s *Store) Get(key uint64) ([]byte, bool, error) {
+
+	i, ok := s.index.Lookup(new(big.Int).SetUint64(key))
+	if !ok {
+		return nil, false, nil
+	}
+
+	record := make([]byte, recordSize)
+	if _, err := s.file.ReadAt(record, int64(i)*recordSize); err != nil {
+		return nil, false, err
+	}
+
+	return record[keySize:], true, nil
+}
+
+// Scan calls fn with the key and value of every record with a key in [lo, hi]
+func (s *Store) Scan(lo, hi uint64, fn func(key uint64, value []byte)) error {
+
+	start, end := s.index.GetRange(new(big.Int).SetUint64(lo), new(big.Int).SetUint64(hi))
+	if start == end {
+		return nil
+	}
+
+	records := make([]byte, (end-start)*recordSize)
+	if _, err := s.file.ReadAt(records, int64(start)*recordSize); err != nil {
+		return err
+	}
+
+	for len(records) > 0 {
+		fn(binary.BigEndian.Uint64(records), records[keySize:recordSize])
+		records = records[recordSize:]
+	}
+
+	return nil
+}
+
+// generate writes n records with sorted distinct random keys to path
+func generate(path string, n int, r *rand.Rand) error {
+
+	keys := make(map[uint64]bool, n)
+	for len(keys) < n {
+		keys[r.Uint64()>>1] = true
+	}
+
+	sorted := make([]uint64, 0, n)
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriterSize(f, 1<<20)
+	record := make([]byte, recordSize)
+	for i, key := range sorted {
+		binary.BigEndian.PutUint64(record, key)
+		copy(record[keySize:], fmt.Sprintf("value-%-*d", valueSize-6, i))
+		if _, err := w.Write(record); err != nil {
+			f.Close()
+			return err
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// latencies prints the count, mean and percentiles of the durations
+func latencies(name string, d []time.Duration) {
+
+	if len(d) == 0 {
+		return
+	}
+
+	sort.Slice(d, func(i, j int) bool { return d[i] < d[j] })
+	var total time.Duration
+	for _, di := range d {
+		total += di
+	}
+
+	fmt.Printf("%-6s n=%-8d mean=%-10v p50=%-10v p99=%-10v max=%v\n", name, len(d),
+		total/time.Duration(len(d)), d[len(d)/2], d[len(d)*99/100], d[len(d)-1])
+}
+
+// benchmark times gets of existing and missing keys and scans of n keys
+func benchmark(s *Store, queries, scanKeys int, r *rand.Rand) error {
+
+	hits := make([]time.Duration, 0, queries)
+	misses := make([]time.Duration, 0, queries)
+	scans := make([]time.Duration, 0, queries)
+	for q := 0; q < queries; q++ {
+		i := r.Intn(len(s.keys))
+		key := s.keys[i].Uint64()
+
+		start := time.Now()
+		if _, ok, err := s.Get(key); err != nil || !ok {
+			return fmt.Errorf("get of key %v (record %v) failed: %v", key, i, err)
+		}
+		hits = append(hits, time.Since(start))
+
+		start = time.Now()
+		if _, ok, err := s.Get(key + 1); err != nil || (ok && (i+1 == len(s.keys) || s.keys[i+1].Uint64() != key+1)) {
+			return fmt.Errorf("get of missing key %v failed: %v", key+1, err)
+		}
+		misses = append(misses, time.Since(start))
+
+		hi := s.keys[min(i+scanKeys, len(s.keys))-1].Uint64()
+		rows := 0
+		start = time.Now()
+		if err := s.Scan(key, hi, func(uint64, []byte) { rows++ }); err != nil {
+			return err
+		}
+		scans = append(scans, time.Since(start))
+		if rows != min(scanKeys, len(s.keys)-i) {
+			return fmt.Errorf("scan of [%v, %v] returned %v records", key, hi, rows)
+		}
+	}
+
+	latencies("get", hits)
+	latencies("miss", misses)
+	latencies("scan", scans)
+
+	return nil
+}
+
+// run executes the command line and returns the error to exit with
+func run() error {
+
+	path := flag.String("file", "data.kv", "sorted key-value file")
+	n := flag.Int("generate", 0, "write a file with this many random records first")
+	queries := flag.Int("queries", 100000, "number of queries of the benchmark")
+	scanKeys := flag.Int("scan", 100, "number of records of each benchmark scan")
+	seed := flag.Int64("seed", 1, "seed of the generated keys and queries")
+	flag.Parse()
+
+	r := rand.New(rand.NewSource(*seed))
+	if *n > 0 {
+		if err := generate(*path, *n, r); err != nil {
+			return err
+		}
+	}
+
+	start := time.Now()
+	s, err := OpenStore(*path)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	stats := s.index.ErrorStats()
+	fmt.Fprintf(os.Stderr, "indexed %v records in %v (max error %v, mean error %.1f, %v leaves)\n",
+		len(s.keys), time.Since(start), stats.Max, stats.Mean, s.index.NumLeaves())
+
+	args := flag.Args()
+	switch {
+	case len(args) == 0:
+		if len(s.keys) == 0 {
+			return nil
+		}
+		return benchmark(s, *queries, *scanKeys, r)
+	case args[0] == "get" && len(args) == 2:
+		key, err := strconv.ParseUint(args[1], 10, 64)
+		if err != nil {
+			return err
+		}
+		value, ok, err := s.Get(key)
+		if err != nil {
+			return err
+		} else if !ok {
+			return fmt.Errorf("key %v not found", key)
+		}
+		fmt.Printf("%v\t%s\n", key, value)
+		return nil
+	case args[0] == "scan" && len(args) == 3:
+		lo, err := strconv.ParseUint(args[1], 10, 64)
+		if err != nil {
+			return err
+		}
+		hi, err := strconv.ParseUint(args[2], 10, 64)
+		if err != nil {
+			return err
+		}
+		return s.Scan(lo, hi, func(key uint64, value []byte) {
+			fmt.Printf("%v\t%s\n", key, value)
+		})
+	}
+
+	return errors.New("usage: kvfile [flags] [get KEY | scan LO HI]")
+}
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
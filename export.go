@@ -0,0 +1,121 @@
+// export.go: generation of Go source for a trained model. The frozen layout
+// (see frozen.go) is written out as arrays of float64 literals, one per layer,
+// together with a lookup function that evaluates one node per layer without
+// loops, pointers or big.Float arithmetic, so a model can be compiled into a
+// program that never trains or loads it. The lookup only clamps each leaf
+// prediction to the index range of the keys routed to the leaf, without the
+// key boundary checks of Frozen.GetIndex, and the generated file records the
+// max error of that lookup over the keys.
+
+package rmi
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/format"
+	"go/token"
+	"math"
+	"strconv"
+)
+
+// ExportGoSource returns a standalone Go file of package pkgName with the
+// coefficients of the current version of the model and a function
+// GetIndex(key float64) int evaluating it, together with the constants Keys
+// and MaxError (the maximum distance between GetIndex and the true index
+// over the keys); the model must be able to be frozen (see Freeze), must not
+// be calibrated and must retain its keys (see Load) to measure MaxError
+func (rmi *RMI) ExportGoSource(pkgName string) ([]byte, error) {
+
+	if !token.IsIdentifier(pkgName) {
+		return nil, fmt.Errorf("%q is not a valid package name", pkgName)
+	}
+
+	if rmi.values == nil {
+		return nil, errors.New("exporting needs the keys of the model to measure its error")
+	}
+
+	f, err := rmi.Freeze()
+	if err != nil {
+		return nil, err
+	} else if f.calib != nil {
+		return nil, errors.New("calibrated models cannot be exported")
+	}
+
+	maxErr := 0
+	for i, value := range rmi.values {
+		index := f.exportedIndex(keyFloat64(value))
+		maxErr = max(maxErr, index-i, i-index)
+	}
+
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Code generated by rmi.ExportGoSource; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&src, "// Package %v is a learned index over %v sorted keys.\n", pkgName, f.maxIndex+1)
+	fmt.Fprintf(&src, "package %v\n\nimport \"math\"\n\n", pkgName)
+	fmt.Fprintf(&src, "// Keys is the number of keys and MaxError the maximum distance\n")
+	fmt.Fprintf(&src, "// between GetIndex and the true index of a key\n")
+	fmt.Fprintf(&src, "const (\n\tKeys = %v\n\tMaxError = %v\n)\n\n", f.maxIndex+1, maxErr)
+
+	for l, layer := range f.layers {
+		fmt.Fprintf(&src, "// slope and intercept of the nodes of layer %v\n", l)
+		fmt.Fprintf(&src, "var layer%v = [%v][2]float64{\n", l, len(layer))
+		for _, node := range layer {
+			if err := writeCoefficients(&src, node.m, node.b); err != nil {
+				return nil, err
+			}
+		}
+		fmt.Fprintf(&src, "}\n\n")
+	}
+
+	fmt.Fprintf(&src, "// slope, intercept and first and last index of the leaves\n")
+	fmt.Fprintf(&src, "var leaves = [%v][4]float64{\n", len(f.leaves))
+	for _, leaf := range f.leaves {
+		if err := writeCoefficients(&src, leaf.m, leaf.b, float64(leaf.first), float64(leaf.last)); err != nil {
+			return nil, err
+		}
+	}
+	fmt.Fprintf(&src, "}\n\n")
+
+	fmt.Fprintf(&src, "// GetIndex returns the approximate index of key\n")
+	fmt.Fprintf(&src, "func GetIndex(key float64) int {\n\tloc := 0\n")
+	width := float64(f.width)
+	for l, layer := range f.layers {
+		fmt.Fprintf(&src, "\tloc = int(math.Max(0, math.Min((layer%v[loc][0]*key+layer%v[loc][1])/%v*%v, %v)))\n",
+			l, l, floatLiteral(math.Max(1, float64(f.maxIndex))), floatLiteral(width), len(layer)*f.width-1)
+		width *= float64(f.width)
+	}
+	fmt.Fprintf(&src, "\tleaf := &leaves[loc]\n")
+	fmt.Fprintf(&src, "\treturn int(math.Max(leaf[2], math.Min(leaf[0]*key+leaf[1], leaf[3])))\n}\n")
+
+	return format.Source(src.Bytes())
+}
+
+// exportedIndex is the GetIndex of the source exported from f (see ExportGoSource)
+func (f *Frozen) exportedIndex(x float64) int {
+	leaf := &f.leaves[f.route(x)]
+	return int(math.Max(float64(leaf.first), math.Min(leaf.m*x+leaf.b, float64(leaf.last))))
+}
+
+// writeCoefficients writes the coefficients as a line of an array literal
+func writeCoefficients(src *bytes.Buffer, coefficients ...float64) error {
+
+	src.WriteString("\t{")
+	for i, c := range coefficients {
+		if math.IsInf(c, 0) || math.IsNaN(c) {
+			return fmt.Errorf("coefficient %v does not fit a float64", c)
+		}
+
+		if i > 0 {
+			src.WriteString(", ")
+		}
+		src.WriteString(floatLiteral(c))
+	}
+	src.WriteString("},\n")
+
+	return nil
+}
+
+// floatLiteral returns the shortest literal parsing to exactly f
+func floatLiteral(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,127 @@
+package rmi
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// program reading keys from stdin and printing GetIndex of each
+const exportHarness = `package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+func main() {
+	in := bufio.NewScanner(os.Stdin)
+	for in.Scan() {
+		key, _ := strconv.ParseFloat(in.Text(), 64)
+		fmt.Println(GetIndex(key))
+	}
+	fmt.Println(Keys, MaxError)
+}
+`
+
+func TestExportGoSource(t *testing.T) {
+
+	values := sortedTestData(5000)
+	model, err := NewRMI(values, 20, 3)
+	if err != nil {
+		t.Fatalf("Failed to build RMI %v\n", err)
+	}
+
+	src, err := model.ExportGoSource("main")
+	if err != nil {
+		t.Fatalf("failed to export %v", err)
+	}
+
+	file, err := parser.ParseFile(token.NewFileSet(), "model.go", src, parser.ImportsOnly)
+	if err != nil {
+		t.Fatalf("exported source does not parse: %v", err)
+	}
+	if len(file.Imports) != 1 || file.Imports[0].Path.Value != `"math"` {
+		t.Fatalf("exported source depends on more than math")
+	}
+
+	frozen, _ := model.Freeze()
+	maxErr := 0
+	for i, value := range values {
+		index := frozen.exportedIndex(keyFloat64(value))
+		maxErr = max(maxErr, abs(index-i))
+	}
+	if !bytes.Contains(src, []byte(fmt.Sprintf("MaxError = %v\n", maxErr))) {
+		t.Fatalf("exported source does not record the max error %v", maxErr)
+	}
+
+	// compile and run the exported model if a Go toolchain is available
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		return
+	}
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "model.go"), src, 0o644)
+	os.WriteFile(filepath.Join(dir, "main.go"), []byte(exportHarness), 0o644)
+
+	var keys strings.Builder
+	for _, value := range values {
+		fmt.Fprintln(&keys, keyFloat64(value))
+	}
+
+	cmd := exec.Command(goTool, "run", "model.go", "main.go")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GO111MODULE=off")
+	cmd.Stdin = strings.NewReader(keys.String())
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("failed to run the exported model: %v", err)
+	}
+
+	lines := bufio.NewScanner(bytes.NewReader(out))
+	for i, value := range values {
+		lines.Scan()
+		index, _ := strconv.Atoi(lines.Text())
+		if want := frozen.exportedIndex(keyFloat64(value)); index != want {
+			t.Fatalf("exported model predicts key %v at %v instead of %v", i, index, want)
+		}
+	}
+
+	lines.Scan()
+	if lines.Text() != fmt.Sprintf("%v %v", len(values), maxErr) {
+		t.Fatalf("exported model has constants %v", lines.Text())
+	}
+}
+
+func TestExportGoSourceErrors(t *testing.T) {
+
+	values := sortedTestData(1000)
+	model, _ := NewRMI(values, 10, 2)
+	if _, err := model.ExportGoSource("not a name"); err == nil {
+		t.Fatalf("expected an error for an invalid package name")
+	}
+
+	for _, opts := range [][]Option{{WithLeafEnsemble()}, {WithCalibration()}, {WithModelType(ModelLogLinear)}} {
+		model, _ := NewRMI(values, 10, 2, opts...)
+		if _, err := model.ExportGoSource("model"); err == nil {
+			t.Fatalf("expected an error exporting a model built with %v options", len(opts))
+		}
+	}
+
+	data, _ := model.MarshalBinary()
+	loaded, _ := UnmarshalRMI(data)
+	if _, err := loaded.ExportGoSource("model"); err == nil {
+		t.Fatalf("expected an error exporting a model without its keys")
+	}
+}
@@ -0,0 +1,97 @@
+// finalize.go: per-leaf hook at the end of a build. The build measures the
+// error bounds by routing every key (see accuracy.go); with a finalizer the
+// measurement also records the leaf and residual of each key, and once the
+// final version of the build is measured the keys are grouped by leaf and
+// handed to the finalizer, so applications can derive their own per-leaf
+// structures (filters, zone maps, caches) without routing the keys again.
+
+package rmi
+
+import (
+	"math/big"
+)
+
+/*
+LeafInfo is a leaf of a finished build and the keys routed to it
+Leaf: position of the leaf in the leaf layer (see NumLeaves)
+Keys: the keys routed to the leaf, in sorted order (empty if none)
+Indices: the index of every key of Keys
+Residuals: true index - GetIndex of every key of Keys
+MinResidual, MaxResidual: extremes of Residuals (see GetIndexWithBounds)
+*/
+type LeafInfo struct {
+	Leaf                     int
+	Keys                     []*big.Int
+	Indices                  []int
+	Residuals                []int
+	MinResidual, MaxResidual int
+}
+
+// LeafFinalizer receives every leaf of a build, in leaf order; the slices
+// of its argument must not be modified or retained past the call
+type LeafFinalizer func(leaf LeafInfo)
+
+// WithLeafFinalizer calls fn for every leaf at the end of the build with the
+// keys routed to it and their residuals, collected while measuring the
+// error bounds; fn is called once the leaves are final (after WithMonotonicLeaves
+// and WithCalibration) and before NewRMI returns
+func WithLeafFinalizer(fn LeafFinalizer) Option {
+	return func(c *config) {
+		c.leafFinalizer = fn
+	}
+}
+
+/*
+Leaf and residual of every key measured by a build, for the leaf finalizer
+loc: position of the leaf of each key in the leaf layer
+residual: true index - predicted index of each key
+*/
+type keyResiduals struct {
+	loc, residual []int
+}
+
+// finalizeLeaves measures the errors of version v, recording the leaf and
+// residual of every key, and calls the leaf finalizer for every leaf
+func (rmi *RMI) finalizeLeaves(v *version) {
+
+	n := len(rmi.values)
+	keys := &keyResiduals{make([]int, n), make([]int, n)}
+	measured := false
+	v.errs.once.Do(func() {
+		v.errs.leaves, v.errs.hist = rmi.measureErrors(v, keys)
+		measured = true
+	})
+
+	// the errors of v were measured before, route the keys again
+	if !measured {
+		rmi.measureErrors(v, keys)
+	}
+
+	// group the keys by leaf, in order within each leaf
+	leaves := v.errs.leaves
+	starts := make([]int, len(leaves)+1)
+	for loc, e := range leaves {
+		starts[loc+1] = starts[loc] + e.count
+	}
+
+	values := make([]*big.Int, n)
+	indices := make([]int, n)
+	residuals := make([]int, n)
+	next := append([]int{}, starts[:len(leaves)]...)
+	for i, loc := range keys.loc {
+		values[next[loc]], indices[next[loc]], residuals[next[loc]] = rmi.values[i], i, keys.residual[i]
+		next[loc]++
+	}
+
+	for loc, e := range leaves {
+		lo, hi := starts[loc], starts[loc+1]
+		rmi.conf.leafFinalizer(LeafInfo{
+			Leaf:        loc,
+			Keys:        values[lo:hi:hi],
+			Indices:     indices[lo:hi:hi],
+			Residuals:   residuals[lo:hi:hi],
+			MinResidual: e.minResidual,
+			MaxResidual: e.maxResidual,
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package rmi
+
+import (
+	"testing"
+)
+
+func TestLeafFinalizer(t *testing.T) {
+
+	values := sortedTestData(20000)
+	for _, opts := range [][]Option{nil, {WithParallelism(4)}, {WithMonotonicLeaves()}, {WithCalibration()}} {
+		infos := make([]LeafInfo, 0)
+		finalizer := WithLeafFinalizer(func(leaf LeafInfo) {
+			infos = append(infos, leaf)
+		})
+
+		model, err := NewRMI(values, 50, 2, append(opts, finalizer)...)
+		if err != nil {
+			t.Fatalf("Failed to build RMI %v\n", err)
+		}
+
+		if len(infos) != model.NumLeaves() {
+			t.Fatalf("finalizer saw %v leaves of %v", len(infos), model.NumLeaves())
+		}
+
+		keys := 0
+		for loc, info := range infos {
+			if info.Leaf != loc || len(info.Indices) != len(info.Keys) || len(info.Residuals) != len(info.Keys) {
+				t.Fatalf("malformed info of leaf %v", loc)
+			}
+			keys += len(info.Keys)
+
+			for k, value := range info.Keys {
+				i := info.Indices[k]
+				index, leaf := model.Locate(value)
+				if values[i] != value || leaf != loc {
+					t.Fatalf("key %v is not routed to leaf %v", i, loc)
+				} else if info.Residuals[k] != i-index {
+					t.Fatalf("residual %v of key %v differs from %v", info.Residuals[k], i, i-index)
+				} else if k > 0 && info.Indices[k-1] >= i {
+					t.Fatalf("keys of leaf %v are out of order", loc)
+				}
+			}
+
+			if maxErr, _ := model.LeafMaxError(loc); maxErr != max(-info.MinResidual, info.MaxResidual) {
+				t.Fatalf("leaf %v has max error %v, finalizer saw [%v, %v]", loc, maxErr, info.MinResidual, info.MaxResidual)
+			}
+		}
+
+		if keys != len(values) {
+			t.Fatalf("finalizer saw %v keys of %v", keys, len(values))
+		}
+	}
+
+	if _, err := NewRMIFromIterator(&sliceIterator{values: values}, len(values), WithLeafFinalizer(func(LeafInfo) {})); err == nil {
+		t.Fatalf("expected an error finalizing leaves of a streamed build")
+	}
+}
@@ -0,0 +1,56 @@
+package rmi
+
+import (
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestEndpointFit(t *testing.T) {
+	values := sortedTestData(20000)
+
+	regression, _ := NewRMI(values, RMIWidthParameter, 3)
+	endpoints, err := NewRMI(values, RMIWidthParameter, 3, WithEndpointFit())
+	if err != nil {
+		t.Fatalf("Failed to build RMI with endpoint fit %v\n", err)
+	}
+	leaves, _ := NewRMI(values, RMIWidthParameter, 3, WithEndpointFit(2))
+
+	t.Logf("max error regression %v endpoints %v endpoint leaves %v",
+		regression.MaxError(), endpoints.MaxError(), leaves.MaxError())
+
+	// the exact search must still find every key
+	for i := 0; i < len(values); i += 101 {
+		for _, rmi := range []*RMI{endpoints, leaves} {
+			if index, ok := rmi.findExact(rmi.current.Load(), values[i]); !ok || values[index].Cmp(values[i]) != 0 {
+				t.Fatalf("failed to find key %v with endpoint fit", i)
+			}
+		}
+	}
+
+	// only the leaf layer uses the endpoint fit
+	if leaves.current.Load().root.m.Cmp(regression.current.Load().root.m) != 0 {
+		t.Fatalf("expected the root to be trained with a regression")
+	}
+}
+
+func TestEndpointCoefficients(t *testing.T) {
+	x := []*big.Int{big.NewInt(10), big.NewInt(15), big.NewInt(30)}
+	y := []*big.Int{big.NewInt(4), big.NewInt(5), big.NewInt(6)}
+
+	// line through (10, 4) and (30, 6)
+	b, m, w := endpointCoefficients(x, y)
+	slope, _ := m.Float64()
+	intercept, _ := b.Float64()
+	x0, _ := w.Float64()
+	if math.Abs(slope-0.1) > 1e-12 || math.Abs(intercept-3) > 1e-12 || math.Abs(x0+30) > 1e-9 {
+		t.Fatalf("unexpected endpoint fit %v x + %v (w %v)", m, b, w)
+	}
+
+	// equal end keys give a constant model
+	x = []*big.Int{big.NewInt(7), big.NewInt(7)}
+	b, m, _ = endpointCoefficients(x, y[:2])
+	if m.Sign() != 0 || b.Cmp(big.NewFloat(4)) != 0 {
+		t.Fatalf("expected a constant model but got %v x + %v", m, b)
+	}
+}
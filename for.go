@@ -0,0 +1,172 @@
+// for.go: training over frame-of-reference (FOR) bit-packed blocks.
+// Columnar data is often stored as blocks holding a reference value and
+// bit-packed offsets from it; models are streamed from the blocks (see
+// stream.go), decoding one key at a time, so no uncompressed copy of the
+// column is ever materialised.
+
+package rmi
+
+import (
+	"errors"
+	"math/big"
+	"math/bits"
+)
+
+/*
+FORBlock is a frame-of-reference encoded block of keys
+Reference: smallest key of the block; keys are stored as key - Reference
+BitWidth: number of bits per packed offset
+Count: number of keys in the block
+Packed: the offsets packed little-endian into 64 bit words
+*/
+type FORBlock struct {
+	Reference uint64
+	BitWidth  uint8
+	Count     int
+	Packed    []uint64
+}
+
+// EncodeFOR packs keys into FOR blocks of (at most) blockSize keys
+func EncodeFOR(keys []uint64, blockSize int) ([]FORBlock, error) {
+
+	if blockSize < 1 {
+		return nil, errors.New("block size must be positive")
+	}
+
+	blocks := make([]FORBlock, 0, (len(keys)+blockSize-1)/blockSize)
+	for start := 0; start < len(keys); start += blockSize {
+		end := start + blockSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		blocks = append(blocks, encodeBlock(keys[start:end]))
+	}
+
+	return blocks, nil
+}
+
+// packs a single block of keys
+func encodeBlock(keys []uint64) FORBlock {
+
+	ref := keys[0]
+	for _, key := range keys {
+		if key < ref {
+			ref = key
+		}
+	}
+
+	width := 0
+	for _, key := range keys {
+		if w := bits.Len64(key - ref); w > width {
+			width = w
+		}
+	}
+
+	block := FORBlock{ref, uint8(width), len(keys), make([]uint64, (len(keys)*width+63)/64)}
+	for i, key := range keys {
+		block.set(i, key-ref)
+	}
+
+	return block
+}
+
+// writes the offset of the i-th key
+func (b *FORBlock) set(i int, offset uint64) {
+	if b.BitWidth == 0 {
+		return
+	}
+
+	pos := i * int(b.BitWidth)
+	word, shift := pos/64, uint(pos%64)
+	b.Packed[word] |= offset << shift
+	if shift+uint(b.BitWidth) > 64 {
+		b.Packed[word+1] |= offset >> (64 - shift)
+	}
+}
+
+// At returns the i-th key of the block
+func (b *FORBlock) At(i int) uint64 {
+	if b.BitWidth == 0 {
+		return b.Reference
+	}
+
+	pos := i * int(b.BitWidth)
+	word, shift := pos/64, uint(pos%64)
+	offset := b.Packed[word] >> shift
+	if shift+uint(b.BitWidth) > 64 {
+		offset |= b.Packed[word+1] << (64 - shift)
+	}
+
+	mask := uint64(1)<<b.BitWidth - 1
+	if b.BitWidth == 64 {
+		mask = ^uint64(0)
+	}
+
+	return b.Reference + offset&mask
+}
+
+// Decode appends the keys of the block to dst
+func (b *FORBlock) Decode(dst []uint64) []uint64 {
+	for i := 0; i < b.Count; i++ {
+		dst = append(dst, b.At(i))
+	}
+
+	return dst
+}
+
+/*
+Iterator over the keys of FOR blocks (see KeyIterator)
+blocks: the blocks in key order
+block, i: position of the current key
+key: the current key, reused by every call to Key
+*/
+type forIterator struct {
+	blocks   []FORBlock
+	block, i int
+	key      *big.Int
+}
+
+func (it *forIterator) Reset() error {
+	it.block, it.i = 0, -1
+	return nil
+}
+
+func (it *forIterator) Next() bool {
+	it.i++
+	for it.block < len(it.blocks) && it.i >= it.blocks[it.block].Count {
+		it.block, it.i = it.block+1, 0
+	}
+
+	return it.block < len(it.blocks)
+}
+
+func (it *forIterator) Key() *big.Int {
+	return it.key.SetUint64(it.blocks[it.block].At(it.i))
+}
+
+func (it *forIterator) Err() error {
+	return nil
+}
+
+// NewRMIFromFOR builds an RMI over the keys of the (sorted) FOR blocks,
+// streamed from the blocks as by NewRMIFromIterator: the keys are not
+// retained and only the options of streamed builds are supported
+func NewRMIFromFOR(
+	blocks []FORBlock,
+	width int,
+	depth int,
+	opts ...Option) (*RMI, error) {
+
+	conf := config{}
+	for _, opt := range opts {
+		opt(&conf)
+	}
+
+	n := 0
+	for _, block := range blocks {
+		n += block.Count
+	}
+
+	return newRMIFromIterator(&forIterator{blocks: blocks, key: new(big.Int)}, n, width, depth, conf)
+}
@@ -0,0 +1,75 @@
+package rmi
+
+import (
+	"math/big"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestFORRoundTrip(t *testing.T) {
+	for _, spread := range []uint64{0, 1, 1000, 1 << 40, 1<<63 + 12345} {
+		keys := make([]uint64, 1000)
+		for i := range keys {
+			keys[i] = 1<<62 + uint64(rand.Int63())%(spread+1)
+		}
+		keys[17] = 1<<63 + spread // force wide offsets in one block
+
+		blocks, err := EncodeFOR(keys, 128)
+		if err != nil {
+			t.Fatalf("Failed to encode keys %v\n", err)
+		}
+
+		decoded := make([]uint64, 0)
+		for _, block := range blocks {
+			decoded = block.Decode(decoded)
+		}
+
+		for i := range keys {
+			if decoded[i] != keys[i] {
+				t.Fatalf("key %v decoded as %v, expected %v (spread %v)", i, decoded[i], keys[i], spread)
+			}
+		}
+	}
+}
+
+func TestNewRMIFromFOR(t *testing.T) {
+	keys := make([]uint64, 5000)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+	blocks, _ := EncodeFOR(keys, 256)
+	rmi, err := NewRMIFromFOR(blocks, RMIWidthParameter, RMIDepthParameter)
+	if err != nil {
+		t.Fatalf("Failed to build RMI %v\n", err)
+	}
+
+	// the streamed model is the one trained over the decoded keys
+	values := make([]*big.Int, len(keys))
+	for i, key := range keys {
+		values[i] = new(big.Int).SetUint64(key)
+	}
+
+	trained, _ := NewRMI(values, RMIWidthParameter, RMIDepthParameter)
+	for i, value := range values {
+		if lo, hi := rmi.GetIndexWithBounds(value); lo > i || hi < i {
+			t.Fatalf("key %v is outside of its bounds [%v, %v]", i, lo, hi)
+		}
+
+		if rmi.GetIndex(value) != trained.GetIndex(value) {
+			t.Fatalf("streamed model predicts differently for key %v", i)
+		}
+	}
+
+	keys[0], keys[1] = keys[1], keys[0]
+	blocks, _ = EncodeFOR(keys, 256)
+	if _, err := NewRMIFromFOR(blocks, 2, 2); err == nil && keys[0] != keys[1] {
+		t.Fatalf("expected unsorted blocks to be rejected")
+	}
+
+	if _, err := EncodeFOR(keys, 0); err == nil {
+		t.Fatalf("expected a zero block size to be rejected")
+	}
+}
@@ -0,0 +1,172 @@
+// frozen.go: read-optimized frozen serving layout.
+// The internal layers are flattened into arrays of float64 coefficients and
+// every leaf is a single 64 byte cache line holding its coefficients together
+// with the boundaries (smallest/largest key and index) of the keys routed to
+// it, so a query touches one line per layer and follows no pointers.
+
+package rmi
+
+import (
+	"errors"
+	"math"
+	"math/big"
+)
+
+// Index is the query API shared by the pointer-based RMI and its Frozen layout
+type Index interface {
+	GetIndex(value *big.Int) int
+}
+
+/*
+Internal node of the frozen layout
+m, b: slope and intercept of the node model
+*/
+type frozenNode struct {
+	m, b float64
+}
+
+/*
+Leaf of the frozen layout, padded to one cache line
+m, b: slope and intercept of the leaf model
+lo, hi: smallest and largest key routed to the leaf (+Inf/-Inf if none)
+first, last: smallest and largest index of the keys routed to the leaf
+*/
+type frozenLeaf struct {
+	m, b        float64
+	lo, hi      float64
+	first, last int64
+	_           [2]uint64
+}
+
+/*
+Frozen read-only copy of a version of the model
+width: number of children of every internal node
+maxIndex: maximum index in the data structure
+layers: the internal layers (the root is layers[0][0])
+leaves: the leaf layer
+calib: optional calibration applied to leaf predictions
+*/
+type Frozen struct {
+	width    int
+	maxIndex int
+	layers   [][]frozenNode
+	leaves   []frozenLeaf
+	calib    *calibration
+}
+
+// Freeze returns the frozen layout of the current version of the model;
+// models with ensemble leaves, last-mile tables, log-linear models or a
+// neighbor clamp cannot be frozen
+func (rmi *RMI) Freeze() (*Frozen, error) {
+
+	if rmi.conf.neighborClamp {
+		return nil, errors.New("frozen layout does not support the neighbor clamp")
+	}
+
+	v := rmi.current.Load()
+	errs := rmi.errorsOf(v)
+
+	f := &Frozen{width: rmi.width, maxIndex: v.maxIndex, calib: v.calib}
+
+	for _, layer := range v.nodes[:rmi.depth-1] {
+		nodes := make([]frozenNode, len(layer))
+		for i, node := range layer {
+			if node.log {
+				return nil, errors.New("frozen layout does not support log-linear models")
+			}
+			nodes[i].m, _ = node.m.Float64()
+			nodes[i].b, _ = node.b.Float64()
+		}
+		f.layers = append(f.layers, nodes)
+	}
+
+	// keys are routed to the leaves in order, so an empty leaf
+	// answers with the index following the previous non-empty leaf
+	next := int64(0)
+	f.leaves = make([]frozenLeaf, len(v.nodes[rmi.depth-1]))
+	for i, node := range v.nodes[rmi.depth-1] {
+		if node.alt != nil || node.exact != nil {
+			return nil, errors.New("frozen layout does not support ensemble leaves or last-mile tables")
+		} else if node.log {
+			return nil, errors.New("frozen layout does not support log-linear models")
+		}
+
+		leaf := &f.leaves[i]
+		leaf.m, _ = node.m.Float64()
+		leaf.b, _ = node.b.Float64()
+		leaf.lo, leaf.hi = math.Inf(1), math.Inf(-1)
+		leaf.first = int64(math.Min(float64(next), float64(v.maxIndex)))
+		leaf.last = leaf.first
+
+		if errs[i].count > 0 {
+			leaf.first, leaf.last = int64(errs[i].first), int64(errs[i].last)
+			next = leaf.last + 1
+
+			leaf.lo, leaf.hi = math.Inf(-1), math.Inf(1)
+			if rmi.values != nil {
+				leaf.lo = keyFloat64(rmi.values[errs[i].first])
+				leaf.hi = keyFloat64(rmi.values[errs[i].last])
+			}
+		}
+	}
+
+	return f, nil
+}
+
+// GetIndex returns the approximate index for the provided value query,
+// clamped to the index range of the keys routed to the selected leaf
+func (f *Frozen) GetIndex(value *big.Int) int {
+
+	x := keyFloat64(value)
+	leaf := &f.leaves[f.route(x)]
+	if x <= leaf.lo {
+		return int(leaf.first)
+	} else if x >= leaf.hi {
+		return int(leaf.last)
+	}
+
+	prediction := leaf.m*x + leaf.b
+	if f.calib != nil {
+		prediction = f.calib.applyFloat64(prediction)
+	}
+
+	return int(math.Max(float64(leaf.first), math.Min(prediction, float64(leaf.last))))
+}
+
+// route returns the position of the leaf of x in the leaf layer
+func (f *Frozen) route(x float64) int {
+
+	maxIndex := math.Max(1, float64(f.maxIndex))
+
+	location := 0
+	width := float64(f.width)
+	for _, layer := range f.layers {
+		node := layer[location]
+		next := (node.m*x + node.b) / maxIndex * width
+
+		// the next layer has width nodes per node of this layer
+		location = int(math.Max(0, math.Min(next, float64(len(layer)*f.width-1))))
+		width *= float64(f.width)
+	}
+
+	return location
+}
+
+// GetIndicesInto stores GetIndex of every value in out, which must have
+// room for len(values) indices; keys that fit an int64 allocate nothing
+func (f *Frozen) GetIndicesInto(values []*big.Int, out []int) {
+
+	for i, value := range values {
+		out[i] = f.GetIndex(value)
+	}
+}
+
+// keyFloat64 returns the key as the nearest float64
+func keyFloat64(value *big.Int) float64 {
+	if value.IsInt64() {
+		return float64(value.Int64())
+	}
+
+	x, _ := new(big.Float).SetInt(value).Float64()
+	return x
+}
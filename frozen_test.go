@@ -0,0 +1,98 @@
+package rmi
+
+import (
+	"testing"
+)
+
+func TestFrozen(t *testing.T) {
+	values := sortedTestData(20000)
+
+	for _, opts := range [][]Option{nil, {WithCalibration()}} {
+		rmi, _ := NewRMI(values, RMIWidthParameter, 3, opts...)
+		frozen, err := rmi.Freeze()
+		if err != nil {
+			t.Fatalf("Failed to freeze RMI %v\n", err)
+		}
+
+		// both layouts serve the same query API
+		maxErr := 0
+		for _, index := range []Index{rmi, frozen} {
+			for i, value := range values {
+				if e := abs(index.GetIndex(value) - i); e > maxErr {
+					maxErr = e
+				}
+			}
+		}
+
+		if maxErr > rmi.MaxError()+1 {
+			t.Fatalf("frozen max error %v exceeds RMI max error %v", maxErr, rmi.MaxError())
+		}
+	}
+
+	// leaves with a second model have no single cache line representation
+	ensemble, _ := NewRMI(values, RMIWidthParameter, 3, WithLeafEnsemble())
+	hasAlt := false
+	for _, leaf := range ensemble.current.Load().nodes[2] {
+		hasAlt = hasAlt || leaf.alt != nil
+	}
+	if _, err := ensemble.Freeze(); (err != nil) != hasAlt {
+		t.Fatalf("expected freezing to fail exactly for ensemble leaves, got %v", err)
+	}
+}
+
+func BenchmarkGetIndex(b *testing.B) {
+	rmi, values, _ := generateTestRMI()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rmi.GetIndex(values[i%len(values)])
+	}
+}
+
+func BenchmarkFrozenGetIndex(b *testing.B) {
+	rmi, values, _ := generateTestRMI()
+	frozen, _ := rmi.Freeze()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		frozen.GetIndex(values[i%len(values)])
+	}
+}
+
+func TestGetIndicesInto(t *testing.T) {
+	rmi, values, _ := generateTestRMI()
+	frozen, _ := rmi.Freeze()
+
+	out := make([]int, len(values))
+	rmi.GetIndicesInto(values, out)
+	for i, value := range values {
+		if out[i] != rmi.GetIndex(value) {
+			t.Fatalf("bulk index of key %v differs from GetIndex", i)
+		}
+	}
+
+	allocs := testing.AllocsPerRun(10, func() {
+		frozen.GetIndicesInto(values, out)
+	})
+	if allocs != 0 {
+		t.Fatalf("frozen bulk evaluation allocated %v times", allocs)
+	}
+	for i, value := range values {
+		if out[i] != frozen.GetIndex(value) {
+			t.Fatalf("frozen bulk index of key %v differs from GetIndex", i)
+		}
+	}
+}
+
+func BenchmarkGetIndicesInto(b *testing.B) {
+	rmi, values, _ := generateTestRMI()
+	out := make([]int, len(values))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rmi.GetIndicesInto(values, out)
+	}
+}
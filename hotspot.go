@@ -0,0 +1,96 @@
+// hotspot.go: splitting of hot leaves of an updatable index. Inserts into
+// the key range of a leaf move the positions of its keys away from what the
+// leaf predicts until the next compaction, and the compaction refits the
+// leaf with a single model even if the new keys gave its data a knee. With
+// WithHotSpotSplits the lookups of an Updatable compare their correction
+// distance against the trained error window of the leaf; leaves whose
+// lookups consistently fall outside their window are split into two models
+// (see ensemble.go) at the next compaction, which the compactor runs early.
+
+package rmi
+
+import (
+	"math/big"
+	"sort"
+	"sync"
+)
+
+/*
+Live correction distances of the leaves of the base model of an Updatable
+mu: guards the fields
+queries, exceeded: lookups per leaf in the current window and how many
+of them fell outside the leaf's error window
+hot: leaves to split at the next compaction
+*/
+type leafHeat struct {
+	mu                sync.Mutex
+	queries, exceeded map[int]int
+	hot               map[int]bool
+}
+
+func newLeafHeat() *leafHeat {
+	return &leafHeat{queries: make(map[int]int), exceeded: make(map[int]int), hot: make(map[int]bool)}
+}
+
+// WithHotSpotSplits makes the lookups of an Updatable over the model track
+// the correction distance of every leaf; a leaf is split at the next
+// compaction if at least fraction of window consecutive lookups routed to it
+// fall outside its trained error window
+func WithHotSpotSplits(window int, fraction float64) Option {
+	return func(c *config) {
+		c.splitWindow = window
+		c.splitFraction = fraction
+	}
+}
+
+// observe records the lookup of value, found at index among the merged
+// keys, against the error window of its leaf in the base model
+func (u *Updatable) observe(base *RMI, value *big.Int, index int) {
+
+	v := base.current.Load()
+	_, loc := base.leaf(v, value)
+	e := base.errorsOf(v)[loc]
+	residual := index - base.getIndex(v, value)
+
+	h := u.heat
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.queries[loc]++
+	if residual < e.minResidual || residual > e.maxResidual {
+		h.exceeded[loc]++
+	}
+
+	if h.queries[loc] >= base.conf.splitWindow {
+		if float64(h.exceeded[loc]) >= base.conf.splitFraction*float64(h.queries[loc]) {
+			h.hot[loc] = true
+		}
+		delete(h.queries, loc)
+		delete(h.exceeded, loc)
+	}
+}
+
+// take returns the hot leaves and resets the tracking for a new base model
+func (h *leafHeat) take() map[int]bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	hot := h.hot
+	h.queries, h.exceeded, h.hot = make(map[int]int), make(map[int]int), make(map[int]bool)
+	return hot
+}
+
+// HotLeaves returns the (sorted) leaves of the base model that will be
+// split at the next compaction
+func (u *Updatable) HotLeaves() []int {
+	u.heat.mu.Lock()
+	defer u.heat.mu.Unlock()
+
+	leaves := make([]int, 0, len(u.heat.hot))
+	for leaf := range u.heat.hot {
+		leaves = append(leaves, leaf)
+	}
+	sort.Ints(leaves)
+
+	return leaves
+}
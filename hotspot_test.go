@@ -0,0 +1,65 @@
+package rmi
+
+import (
+	"math/big"
+	"slices"
+	"testing"
+)
+
+func TestHotSpotSplits(t *testing.T) {
+	// uniform keys with a dense burst of inserts in the range of one leaf
+	values := make([]*big.Int, 10000)
+	for i := range values {
+		values[i] = big.NewInt(int64(i) * 1000)
+	}
+
+	inserts := make([]*big.Int, 0)
+	for i := int64(1); i < 1000; i++ {
+		inserts = append(inserts, big.NewInt(5000*1000+i))
+	}
+
+	rmi, err := NewRMI(values, 16, 2, WithHotSpotSplits(50, 0.5))
+	if err != nil {
+		t.Fatalf("Failed to build RMI %v\n", err)
+	}
+	u := NewUpdatable(rmi, 4)
+	u.InsertBatch(inserts)
+
+	for _, value := range append(inserts, values...) {
+		if _, ok := u.Lookup(value); !ok {
+			t.Fatalf("key %v not found", value)
+		}
+	}
+
+	_, loc := rmi.leaf(rmi.current.Load(), inserts[0])
+	if hot := u.HotLeaves(); !slices.Contains(hot, loc) {
+		t.Fatalf("expected leaf %v to be hot, got %v", loc, hot)
+	}
+
+	u.Compact()
+	if len(u.HotLeaves()) != 0 {
+		t.Fatalf("expected the compaction to take the hot leaves")
+	}
+
+	base := u.RMI()
+	if base.current.Load().nodes[1][loc].alt == nil {
+		t.Fatalf("expected hot leaf %v to be split", loc)
+	}
+
+	for i, value := range base.values {
+		if index, ok := u.Lookup(value); !ok || index != i {
+			t.Fatalf("key %v at %v, expected %v", value, index, i)
+		}
+	}
+
+	// leaves are not tracked without the option
+	unsplit, _ := NewRMI(values, 16, 2)
+	plain := NewUpdatable(unsplit, 4)
+	plain.InsertBatch(inserts)
+	for _, value := range inserts {
+		plain.Lookup(value)
+	}
+	if len(plain.HotLeaves()) != 0 {
+		t.Fatalf("expected no hot leaves without WithHotSpotSplits")
+	}
+}
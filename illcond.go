@@ -0,0 +1,132 @@
+// illcond.go: fallback for ill-conditioned regressions. The regression of a
+// node is computed from squared deviations at float64-like precision, so when
+// the keys of a node spread over a tiny fraction of their magnitude (e.g., a
+// dense run of 128 bit hashes sharing a long prefix) the variance cancels and
+// the slope explodes. Such nodes are detected from the ratio of the largest
+// key to the spread of the keys (the condition of the regression) and their
+// regression is replaced according to the configured policy.
+
+package rmi
+
+import (
+	"math"
+	"math/big"
+)
+
+// keys spread over less than this fraction of their magnitude
+// leave the regression of a node ill-conditioned
+const illConditionedSpread = 1.0 / (1 << 26)
+
+// IllConditionedPolicy selects what replaces an ill-conditioned regression
+type IllConditionedPolicy int
+
+const (
+	// FallbackMedianRank replaces the regression with the line through the
+	// median key and its rank whose slope is the number of keys over the
+	// key range, computed from exact key differences
+	FallbackMedianRank IllConditionedPolicy = iota
+
+	// FallbackConstant replaces the regression with the middle index of the keys
+	FallbackConstant
+
+	// FallbackNone keeps the regression
+	FallbackNone
+)
+
+// WithIllConditioned selects the replacement of the regressions (linear or
+// log-linear) of nodes whose keys spread over less than 2^-26 of their
+// magnitude; by default they are replaced with FallbackMedianRank
+func WithIllConditioned(policy IllConditionedPolicy) Option {
+	return func(c *config) {
+		c.illConditioned = policy
+	}
+}
+
+// illConditioned reports whether the keys spread over so small a fraction
+// of their magnitude that a regression over them is ill-conditioned
+func illConditioned(values []*big.Int) bool {
+
+	if len(values) < 2 {
+		return false
+	}
+
+	lo, hi := values[0], values[len(values)-1]
+	if lo.Cmp(hi) == 0 {
+		return false
+	}
+
+	spread := floatOf(new(big.Int).Sub(hi, lo))
+	magnitude := math.Max(math.Abs(floatOf(lo)), math.Abs(floatOf(hi)))
+	return spread < magnitude*illConditionedSpread
+}
+
+// floatOf returns the nearest float64 to value
+func floatOf(value *big.Int) float64 {
+	f, _ := new(big.Float).SetInt(value).Float64()
+	return f
+}
+
+// guarded returns train with ill-conditioned tasks trained by the fallback
+func (c *config) guarded(train func(buildTask) *Node) func(buildTask) *Node {
+
+	if c.illConditioned == FallbackNone {
+		return train
+	}
+
+	return func(task buildTask) *Node {
+		if !illConditioned(task.values) {
+			return train(task)
+		}
+
+		node := trainMedianRank(task)
+		if c.illConditioned == FallbackConstant {
+			node = trainConstant(task)
+		}
+		node.fallback = true
+
+		return node
+	}
+}
+
+// trains the line through the median key of the node and its index with the
+// slope of the first to the last key, at a precision that keeps the slope
+// and the intercept exact enough for keys of the node's magnitude
+func trainMedianRank(task buildTask) *Node {
+
+	if len(task.indices) < 2 {
+		return trainNode(task)
+	}
+
+	last := len(task.values) - 1
+	if task.values[0].Cmp(task.values[last]) == 0 {
+		return trainConstant(task)
+	}
+
+	prec := uint(max(task.values[0].BitLen(), task.values[last].BitLen()) + 64)
+
+	m := new(big.Float).SetPrec(prec).SetInt(new(big.Int).Sub(task.indices[last], task.indices[0]))
+	m.Quo(m, new(big.Float).SetPrec(prec).SetInt(new(big.Int).Sub(task.values[last], task.values[0])))
+
+	median := len(task.values) / 2
+	b := new(big.Float).SetPrec(prec).SetInt(task.values[median])
+	b.Mul(b, m)
+	b.Sub(new(big.Float).SetPrec(prec).SetInt(task.indices[median]), b)
+
+	return &Node{m: m, b: b, w: xIntercept(m, b), fit: ModelEndpoint}
+}
+
+// Fallbacks returns the number of nodes of the current version whose
+// ill-conditioned regression was replaced (see WithIllConditioned)
+func (rmi *RMI) Fallbacks() int {
+
+	count := 0
+	for _, layer := range rmi.current.Load().nodes {
+		for _, node := range layer {
+			if node.fallback {
+				count++
+			}
+		}
+	}
+
+	return count
+}
@@ -0,0 +1,71 @@
+package rmi
+
+import (
+	"math/big"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// keys sharing a long prefix, spread over a tiny fraction of their magnitude
+func prefixedKeys(n int) []*big.Int {
+	r := rand.New(rand.NewSource(1))
+	base := new(big.Int).Lsh(big.NewInt(1), 120)
+	values := make([]*big.Int, n)
+	for i := range values {
+		values[i] = new(big.Int).Add(base, big.NewInt(r.Int63n(1<<40)))
+	}
+	sort.Slice(values, func(i, j int) bool {
+		return values[i].Cmp(values[j]) == -1
+	})
+
+	return values
+}
+
+func TestIllConditionedFallback(t *testing.T) {
+	values := prefixedKeys(5000)
+
+	regression, err := NewRMI(values, 8, 2, WithIllConditioned(FallbackNone))
+	if err != nil {
+		t.Fatalf("Failed to build RMI %v\n", err)
+	}
+
+	for _, policy := range []IllConditionedPolicy{FallbackMedianRank, FallbackConstant} {
+		rmi, err := NewRMI(values, 8, 2, WithIllConditioned(policy))
+		if err != nil {
+			t.Fatalf("Failed to build RMI %v\n", err)
+		}
+
+		if rmi.Fallbacks() != 9 || regression.Fallbacks() != 0 {
+			t.Fatalf("policy %v: %v nodes fell back", policy, rmi.Fallbacks())
+		}
+
+		report, _ := rmi.BuildReport()
+		if report.Fallbacks != rmi.Fallbacks() {
+			t.Fatalf("report counts %v fallbacks, the model %v", report.Fallbacks, rmi.Fallbacks())
+		}
+
+		for i, value := range values {
+			if index, ok := rmi.Lookup(value); !ok || values[index].Cmp(value) != 0 {
+				t.Fatalf("Lookup of key %v failed", i)
+			}
+		}
+	}
+
+	// the median rank fallback keeps the model accurate
+	rmi, _ := NewRMI(values, 8, 2)
+	if rmi.MaxError() > 200 || rmi.MaxError() >= regression.MaxError() {
+		t.Fatalf("fallback max error %v, regression max error %v", rmi.MaxError(), regression.MaxError())
+	}
+
+	// the linear candidate of ModelAuto falls back as well
+	auto, _ := NewRMI(values, 8, 2, WithModelType(ModelAuto))
+	if auto.Fallbacks() == 0 || auto.MaxError() > rmi.MaxError() {
+		t.Fatalf("auto model has %v fallbacks and max error %v (fallback max error %v)", auto.Fallbacks(), auto.MaxError(), rmi.MaxError())
+	}
+
+	data, _ := rmi.MarshalBinary()
+	if loaded, err := UnmarshalRMI(data); err != nil || loaded.Fallbacks() != rmi.Fallbacks() {
+		t.Fatalf("fallbacks were not restored on loading")
+	}
+}
@@ -0,0 +1,34 @@
+//go:build 386 || arm || mips || mipsle
+
+package rmi
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestDecodeModelTooLargeForPlatform(t *testing.T) {
+	values := sortedTestData(100)
+	rmi, _ := NewRMI(values, 4, 2)
+
+	data := rmi.encode()
+	meta := rmi.Metadata()
+	offset := len(modelMagic) + 8
+	for _, s := range []string{meta.KeyType, meta.Arithmetic, meta.BuilderVersion} {
+		offset += 4 + len(s)
+	}
+
+	// a max index that only fits a 64 bit int
+	binary.BigEndian.PutUint64(data[offset:], 1<<40)
+	if _, err := decode(data, Metadata{}); err == nil {
+		t.Fatalf("expected a model over 2^40 keys to be rejected")
+	}
+}
+
+func TestShapeBoundedByPlatformInt(t *testing.T) {
+
+	// 2^28 keys allow 2^32 leaves, more than a 32 bit int can index
+	if _, _, err := (&config{}).checkShape(1<<28, 2, 33); err == nil {
+		t.Fatalf("expected a layer wider than the int size to be rejected")
+	}
+}
@@ -0,0 +1,119 @@
+// keys.go: parsing of textual and binary keys into big.Int keys whose
+// integer order is the order the keys should be indexed in, e.g. hex
+// encoded hashes, SHA-256 prefixes and arbitrary-precision decimals
+
+package rmi
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// ParseHexKey parses a base 16 integer with an optional 0x prefix; keys of
+// different lengths are ordered numerically (see ParseHexPrefix for hashes)
+func ParseHexKey(s string) (*big.Int, error) {
+
+	digits := strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	if digits == "" || strings.HasPrefix(digits, "-") || strings.HasPrefix(digits, "+") {
+		return nil, fmt.Errorf("%q is not a hexadecimal key", s)
+	}
+
+	value, ok := new(big.Int).SetString(digits, 16)
+	if !ok {
+		return nil, fmt.Errorf("%q is not a hexadecimal key", s)
+	}
+
+	return value, nil
+}
+
+// ParseHexPrefix parses the first bits bits of a hex encoded byte string
+// (e.g., a SHA-256 hash) with an optional 0x prefix; shorter strings are
+// padded with zeros on the right, so keys are ordered like the byte strings
+func ParseHexPrefix(s string, bits int) (*big.Int, error) {
+
+	if bits <= 0 {
+		return nil, fmt.Errorf("prefix length %v must be positive", bits)
+	}
+
+	digits := strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	n := (bits + 3) / 4
+	if len(digits) > n {
+		digits = digits[:n]
+	}
+	digits += strings.Repeat("0", n-len(digits))
+
+	value, err := ParseHexKey(digits)
+	if err != nil {
+		return nil, fmt.Errorf("%q is not a hexadecimal key", s)
+	}
+
+	// drop the bits of the last digit beyond the prefix
+	return value.Rsh(value, uint(4*n-bits)), nil
+}
+
+// KeyFromBytes returns the first size bytes of b as a big-endian integer,
+// padding shorter byte strings with zeros on the right, so keys are ordered
+// like the byte strings (e.g., KeyFromBytes(sha256.Sum256(x)[:], 8))
+func KeyFromBytes(b []byte, size int) *big.Int {
+
+	padded := make([]byte, size)
+	copy(padded, b)
+
+	return new(big.Int).SetBytes(padded)
+}
+
+// ParseDecimalKey parses an arbitrary-precision decimal number (e.g.,
+// "-1234.5678") and returns it scaled by 10^scale, so keys with up to scale
+// decimal places are ordered numerically; more decimal places are rejected
+func ParseDecimalKey(s string, scale int) (*big.Int, error) {
+
+	if scale < 0 {
+		return nil, fmt.Errorf("scale %v must not be negative", scale)
+	}
+
+	// big.Rat also accepts fractions, exponents, base prefixes and underscores
+	if !isDecimal(s) {
+		return nil, fmt.Errorf("%q is not a decimal key", s)
+	}
+
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, fmt.Errorf("%q is not a decimal key", s)
+	}
+
+	factor := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)
+	r.Mul(r, new(big.Rat).SetInt(factor))
+	if !r.IsInt() {
+		return nil, fmt.Errorf("%q has more than %v decimal places", s, scale)
+	}
+
+	return new(big.Int).Set(r.Num()), nil
+}
+
+// isDecimal reports whether s is an optional sign followed by digits and
+// optionally a decimal point and more digits
+func isDecimal(s string) bool {
+
+	if len(s) > 0 && (s[0] == '+' || s[0] == '-') {
+		s = s[1:]
+	}
+
+	integer, fraction, point := strings.Cut(s, ".")
+	if point && fraction == "" {
+		return false
+	}
+
+	return integer != "" && isDigits(integer) && isDigits(fraction)
+}
+
+// isDigits reports whether s consists of decimal digits only
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+
+	return true
+}
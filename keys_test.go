@@ -0,0 +1,87 @@
+package rmi
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"sort"
+	"testing"
+)
+
+func TestParseHexPrefix(t *testing.T) {
+	hashes := make([]string, 0)
+	for i := 0; i < 200; i++ {
+		sum := sha256.Sum256([]byte{byte(i), byte(i >> 8)})
+		hashes = append(hashes, hex.EncodeToString(sum[:]))
+	}
+	// prefixes shorter than the key length must order like the strings
+	hashes = append(hashes, "00", "7f", "ff")
+	sort.Strings(hashes)
+
+	for _, bits := range []int{64, 67, 256} {
+		keys := make([]*big.Int, len(hashes))
+		for i, h := range hashes {
+			key, err := ParseHexPrefix(h, bits)
+			if err != nil {
+				t.Fatalf("Failed to parse %v: %v\n", h, err)
+			}
+			if key.BitLen() > bits {
+				t.Fatalf("key %v has more than %v bits", key, bits)
+			}
+			keys[i] = key
+		}
+
+		for i := 1; i < len(keys); i++ {
+			if keys[i-1].Cmp(keys[i]) == 1 {
+				t.Fatalf("%v bit prefixes of %v and %v are out of order", bits, hashes[i-1], hashes[i])
+			}
+		}
+
+		if _, err := NewRMI(keys, 10, 2); err != nil {
+			t.Fatalf("Failed to index hash prefixes %v\n", err)
+		}
+	}
+
+	sum := sha256.Sum256([]byte("key"))
+	fromBytes := KeyFromBytes(sum[:], 8)
+	fromHex, _ := ParseHexPrefix(hex.EncodeToString(sum[:]), 64)
+	if fromBytes.Cmp(fromHex) != 0 {
+		t.Fatalf("byte and hex prefixes differ: %v %v", fromBytes, fromHex)
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	tests := []struct {
+		s     string
+		scale int
+		want  string
+	}{
+		{"-12.5", 2, "-1250"},
+		{"123456789012345678901234567890.25", 2, "12345678901234567890123456789025"},
+		{"7", 0, "7"},
+		{"+007.50", 2, "750"},
+	}
+
+	for _, test := range tests {
+		key, err := ParseDecimalKey(test.s, test.scale)
+		if err != nil || key.String() != test.want {
+			t.Fatalf("ParseDecimalKey(%q, %v) = %v, %v", test.s, test.scale, key, err)
+		}
+	}
+
+	for _, bad := range []string{"1e5", "1/2", "x", "0x10", "0b11", "0o17", "1_000", "", "-", "1.", ".5", "1.2.3"} {
+		if _, err := ParseDecimalKey(bad, 2); err == nil {
+			t.Fatalf("expected %q to be rejected", bad)
+		}
+	}
+
+	if key, _ := ParseHexPrefix("0x7f", 8); key.Int64() != 127 {
+		t.Fatalf("expected the 0x prefix to be ignored but got %v", key)
+	}
+	if key, err := ParseHexKey("0xff"); err != nil || key.Int64() != 255 {
+		t.Fatalf("ParseHexKey(0xff) = %v, %v", key, err)
+	}
+	if _, err := ParseHexKey("-ff"); err == nil {
+		t.Fatalf("expected negative hex keys to be rejected")
+	}
+}
@@ -0,0 +1,68 @@
+// lastmile.go: per-leaf hash tables from key to exact index for leaves
+// whose model error is too large, giving O(1) exact point lookups
+
+package rmi
+
+import (
+	"math"
+	"math/big"
+)
+
+// tableKey returns the hash table key of value (sign and magnitude bytes)
+func tableKey(value *big.Int) string {
+	if value.Sign() == -1 {
+		return "-" + string(value.Bytes())
+	}
+
+	return "+" + string(value.Bytes())
+}
+
+// attachExactTables routes every key through version v (which must not be
+// published yet) and attaches a last-mile table to each leaf whose maximum
+// error over the keys routed to it exceeds the configured threshold;
+// duplicate keys map to their first index
+func (rmi *RMI) attachExactTables(v *version) {
+
+	leaves := v.nodes[rmi.depth-1]
+	location := make([]int, len(rmi.values))
+	maxErr := make([]float64, len(leaves))
+	for i, value := range rmi.values {
+		leaf, loc := rmi.leaf(v, value)
+		prediction, _ := leaf.predict(value).Float64()
+
+		location[i] = loc
+		maxErr[loc] = math.Max(maxErr[loc], math.Abs(prediction-float64(i)))
+	}
+
+	for i, value := range rmi.values {
+		loc := location[i]
+		if maxErr[loc] <= rmi.conf.lastMileError {
+			continue
+		}
+
+		leaf := leaves[loc]
+		if leaf.exact == nil {
+			leaf.exact = make(map[string]int)
+			if leaf.alt != nil {
+				leaf.alt.exact = leaf.exact
+			}
+		}
+
+		key := tableKey(value)
+		if _, ok := leaf.exact[key]; !ok {
+			leaf.exact[key] = i
+		}
+	}
+}
+
+// LastMileEntries returns the total number of keys held in last-mile tables
+func (rmi *RMI) LastMileEntries() int {
+	v := rmi.current.Load()
+
+	entries := 0
+	for _, leaf := range v.nodes[rmi.depth-1] {
+		entries += len(leaf.exact)
+	}
+
+	return entries
+}
@@ -0,0 +1,105 @@
+package rmi
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+)
+
+func TestLastMileTables(t *testing.T) {
+
+	// cubic keys so that some leaves have large errors
+	values := make([]*big.Int, 3000)
+	for i := range values {
+		values[i] = big.NewInt(int64(i) * int64(i) * int64(i))
+	}
+
+	rmi, err := NewRMI(values, 3, 2, WithLastMileTables(4))
+	if err != nil {
+		t.Fatalf("Failed to build RMI %v\n", err)
+	}
+
+	if rmi.LastMileEntries() == 0 {
+		t.Fatalf("expected some leaves to carry a last-mile table")
+	}
+
+	// keys are either answered exactly or by a leaf within the error threshold
+	for i, value := range values {
+		index := rmi.GetIndex(value)
+		if index != i && float64(abs(index-i)) > 4+1 {
+			t.Fatalf("index %v of key %v is off by more than the threshold", index, i)
+		}
+	}
+
+	// the tables survive a round trip, so the loaded model answers the same
+	data, _ := rmi.MarshalBinary()
+	loaded, err := Load(bytes.NewReader(data), values, rmi.Metadata())
+	if err != nil {
+		t.Fatalf("Failed to load RMI %v\n", err)
+	}
+
+	if loaded.LastMileEntries() != rmi.LastMileEntries() {
+		t.Fatalf("loaded model has %v last-mile entries instead of %v", loaded.LastMileEntries(), rmi.LastMileEntries())
+	}
+
+	for _, value := range values {
+		if loaded.GetIndex(value) != rmi.GetIndex(value) {
+			t.Fatalf("loaded model predicts differently")
+		}
+	}
+
+	if err := loaded.SelfTest(values); err != nil {
+		t.Fatalf("loaded model fails its self test %v", err)
+	}
+
+	// a threshold above any error attaches no tables at all
+	plain, _ := NewRMI(values, 3, 2, WithLastMileTables(float64(len(values))))
+	if plain.LastMileEntries() != 0 {
+		t.Fatalf("expected no last-mile tables")
+	}
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func TestLastMileTablesRefit(t *testing.T) {
+
+	values := make([]*big.Int, 3000)
+	for i := range values {
+		values[i] = big.NewInt(int64(i) * int64(i) * int64(i))
+	}
+
+	model, err := NewRMI(values, 3, 2, WithLastMileTables(4))
+	if err != nil {
+		t.Fatalf("Failed to build RMI %v\n", err)
+	}
+
+	// the tables are rebuilt over the new indices of the keys
+	check := func(rmi *RMI) {
+		if rmi.LastMileEntries() == 0 {
+			t.Fatalf("expected the refit model to carry last-mile tables")
+		}
+
+		for i, value := range rmi.values {
+			index := rmi.GetIndex(value)
+			if index != i && float64(abs(index-i)) > 4+1 {
+				t.Fatalf("index %v of key %v is off by more than the threshold", index, i)
+			}
+		}
+	}
+
+	u := NewUpdatable(model, 2)
+	u.Insert(big.NewInt(5))
+	u.Compact()
+	check(u.RMI())
+
+	rebuilt, err := u.RMI().RebuildRange(big.NewInt(0), values[1000], nil)
+	if err != nil {
+		t.Fatalf("failed to rebuild range %v", err)
+	}
+	check(rebuilt)
+}
@@ -0,0 +1,122 @@
+// limits.go: validation of the model shape and estimates
+// of the model size used to enforce memory limits
+
+package rmi
+
+import (
+	"fmt"
+	"math"
+)
+
+// maximum number of leaf models per key before a configuration is considered pathological
+const maxLeavesPerKey = 16
+
+/*
+ShapeError is returned when the width/depth of the model is invalid
+or produces far more leaf models than there are keys
+Width, Depth: the requested configuration
+Keys: number of keys the model is built over
+SuggestedWidth, SuggestedDepth: a configuration that would be accepted
+*/
+type ShapeError struct {
+	Width, Depth                   int
+	Keys                           int
+	SuggestedWidth, SuggestedDepth int
+}
+
+func (e *ShapeError) Error() string {
+	return fmt.Sprintf(
+		"width %v and depth %v is not a valid configuration for %v keys (try width %v and depth %v)",
+		e.Width, e.Depth, e.Keys, e.SuggestedWidth, e.SuggestedDepth)
+}
+
+// numLeaves returns width^(depth-1) saturating at math.MaxInt64
+func numLeaves(width, depth int) int64 {
+	leaves := int64(1)
+	for i := 1; i < depth; i++ {
+		if leaves > math.MaxInt64/int64(width) {
+			return math.MaxInt64
+		}
+		leaves *= int64(width)
+	}
+
+	return leaves
+}
+
+// checkShape validates the width and depth against the number of keys
+// and returns the configuration to build with (clamped if clampShape is set)
+func (c *config) checkShape(keys, width, depth int) (int, int, error) {
+
+	// layers are indexed by int, so they are also bounded by the int size
+	maxLeaves := int64(math.Max(1, float64(keys))) * maxLeavesPerKey
+	if maxLeaves > math.MaxInt {
+		maxLeaves = math.MaxInt
+	}
+
+	if width >= 1 && depth >= 1 && numLeaves(width, depth) <= maxLeaves {
+		return width, depth, nil
+	}
+
+	// suggest the deepest model with (at least) the requested width that has a sensible number of leaves
+	suggestedWidth := int(math.Max(1, float64(width)))
+	suggestedDepth := int(math.Max(1, float64(depth)))
+	for suggestedDepth > 1 && numLeaves(suggestedWidth, suggestedDepth) > maxLeaves {
+		suggestedDepth--
+	}
+
+	if c.clampShape {
+		return suggestedWidth, suggestedDepth, nil
+	}
+
+	return 0, 0, &ShapeError{width, depth, keys, suggestedWidth, suggestedDepth}
+}
+
+// approximate number of bytes used by a single node: the *Node slot in its layer,
+// the Node struct itself and three big.Float values with a one word mantissa
+const approxNodeBytes int64 = 8 + 24 + 3*(40+8)
+
+// EstimateModelBytes returns the approximate memory used by the nodes of an RMI
+// with the given width and depth (math.MaxInt64 if the estimate overflows)
+func EstimateModelBytes(width, depth int) int64 {
+
+	total := int64(0)
+	layerSize := int64(1)
+	for i := 0; i < depth; i++ {
+		if total > math.MaxInt64-layerSize {
+			return math.MaxInt64
+		}
+		total += layerSize
+
+		if i != depth-1 && layerSize > math.MaxInt64/int64(width) {
+			return math.MaxInt64
+		}
+		layerSize *= int64(width)
+	}
+
+	if total > math.MaxInt64/approxNodeBytes {
+		return math.MaxInt64
+	}
+
+	return total * approxNodeBytes
+}
+
+// fitModelBytes checks the model against the configured memory limit
+// and returns the width to build with (reduced if shrinkToFit is set)
+func (c *config) fitModelBytes(width, depth int) (int, error) {
+
+	if c.maxModelBytes <= 0 || EstimateModelBytes(width, depth) <= c.maxModelBytes {
+		return width, nil
+	}
+
+	if c.shrinkToFit {
+		for w := width - 1; w >= 2; w-- {
+			if EstimateModelBytes(w, depth) <= c.maxModelBytes {
+				return w, nil
+			}
+		}
+	}
+
+	return 0, fmt.Errorf(
+		"model with width %v and depth %v needs ~%v bytes which exceeds the limit of %v bytes",
+		width, depth, EstimateModelBytes(width, depth), c.maxModelBytes)
+}
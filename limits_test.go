@@ -0,0 +1,62 @@
+package rmi
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEstimateModelBytes(t *testing.T) {
+	if EstimateModelBytes(10, 2) != 11*approxNodeBytes {
+		t.Fatalf("unexpected estimate %v", EstimateModelBytes(10, 2))
+	}
+
+	if EstimateModelBytes(1000, 10) != math.MaxInt64 {
+		t.Fatalf("expected saturated estimate, got %v", EstimateModelBytes(1000, 10))
+	}
+}
+
+func TestMaxModelBytes(t *testing.T) {
+	values := sortedTestData(1000)
+
+	// must be refused before anything is allocated
+	_, err := NewRMI(values, 100, 3, WithMaxModelBytes(1<<20))
+	if err == nil {
+		t.Fatalf("expected model to exceed the memory limit")
+	}
+
+	rmi, err := NewRMI(values, 100, 3, WithMaxModelBytes(1<<20), WithShrinkToFit())
+	if err != nil {
+		t.Fatalf("Failed to build RMI %v\n", err)
+	}
+
+	if EstimateModelBytes(rmi.width, rmi.depth) > 1<<20 || rmi.width >= 100 {
+		t.Fatalf("width %v was not shrunk to fit", rmi.width)
+	}
+}
+
+func TestShapeValidation(t *testing.T) {
+	values := sortedTestData(1000)
+
+	_, err := NewRMI(values, 1000, 4)
+	shapeErr, ok := err.(*ShapeError)
+	if !ok {
+		t.Fatalf("expected a ShapeError, got %v", err)
+	}
+
+	if shapeErr.SuggestedWidth != 1000 || shapeErr.SuggestedDepth != 2 {
+		t.Fatalf("unexpected suggestion %v", shapeErr)
+	}
+
+	if _, err := NewRMI(values, 0, 2); err == nil {
+		t.Fatalf("expected width 0 to be rejected")
+	}
+
+	rmi, err := NewRMI(values, 1000, 4, WithClampShape())
+	if err != nil {
+		t.Fatalf("Failed to build RMI %v\n", err)
+	}
+
+	if rmi.width != 1000 || rmi.depth != 2 {
+		t.Fatalf("shape was not clamped: width %v depth %v", rmi.width, rmi.depth)
+	}
+}
@@ -0,0 +1,61 @@
+// loglinear.go: log-linear node models. A log-linear node predicts
+// m*log2(1+x)+b instead of m*x+b (keys below 0 count as 0), which follows
+// the CDF of exponentially distributed or Zipfian keys far more closely
+// than a line. The logarithm is evaluated to float64 precision, enough to
+// route and predict indexes of any realistic number of keys; the layers
+// using it are selected with WithModelType or WithLayerModel.
+
+package rmi
+
+import (
+	"math"
+	"math/big"
+)
+
+// logInput returns log2(1 + max(value, 0)), the input of log-linear models
+func logInput(value *big.Int) *big.Float {
+
+	if value.Sign() < 0 {
+		return new(big.Float)
+	}
+
+	// 1 + value = mant * 2^exp with mant in [0.5, 1)
+	mant := new(big.Float)
+	exp := new(big.Float).SetInt(new(big.Int).Add(value, big.NewInt(1))).MantExp(mant)
+	f, _ := mant.Float64()
+
+	return new(big.Float).SetFloat64(float64(exp) + math.Log2(f))
+}
+
+// inputOf returns the input of the node model at value
+func (node *Node) inputOf(value *big.Int) *big.Float {
+	if node.log {
+		return logInput(value)
+	}
+
+	return new(big.Float).SetInt(value)
+}
+
+// trains the log-linear model of a single node (with the regression of the
+// typed models, see fitLinear); fewer than two keys give trainNode's constant
+func trainLogLinear(task buildTask) *Node {
+
+	if len(task.indices) < 2 {
+		return trainNode(task)
+	}
+
+	// the indices of subsampled tasks are not contiguous (see WithSampleAbove)
+	x := make([]float64, len(task.values))
+	y := make([]float64, len(task.values))
+	for i, value := range task.values {
+		x[i], _ = logInput(value).Float64()
+		y[i] = float64(task.indices[i].Int64())
+	}
+
+	model := fitLinear(x, y, int(task.offset.Int64()))
+
+	node := &Node{m: big.NewFloat(model.m), b: big.NewFloat(model.b), log: true, fit: ModelLogLinear}
+	node.w = xIntercept(node.m, node.b)
+
+	return node
+}
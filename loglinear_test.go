@@ -0,0 +1,126 @@
+package rmi
+
+import (
+	"math"
+	"math/big"
+	"testing"
+)
+
+// keys growing exponentially with their index
+func exponentialKeys(n int) []*big.Int {
+	values := make([]*big.Int, n)
+	for i := range values {
+		f := new(big.Float).SetFloat64(math.Pow(2, float64(i)/50))
+		values[i], _ = f.Add(f, big.NewFloat(float64(i))).Int(nil)
+	}
+
+	return values
+}
+
+func TestLogLinear(t *testing.T) {
+	values := exponentialKeys(5000)
+
+	linear, err := NewRMI(values, 8, 2)
+	if err != nil {
+		t.Fatalf("Failed to build RMI %v\n", err)
+	}
+
+	logLinear, err := NewRMI(values, 8, 2, WithModelType(ModelLogLinear))
+	if err != nil {
+		t.Fatalf("Failed to build RMI %v\n", err)
+	}
+
+	if logLinear.MaxError()*10 > linear.MaxError() {
+		t.Fatalf("log-linear max error %v is not well below the linear max error %v", logLinear.MaxError(), linear.MaxError())
+	}
+
+	for i, value := range values {
+		if index, ok := logLinear.Lookup(value); !ok || values[index].Cmp(value) != 0 {
+			t.Fatalf("Lookup of key %v failed", i)
+		}
+	}
+
+	// the model type survives saving
+	data, _ := logLinear.MarshalBinary()
+	loaded, err := UnmarshalRMI(data)
+	if err != nil {
+		t.Fatalf("Failed to load RMI %v\n", err)
+	}
+	for i, value := range values {
+		if loaded.GetIndex(value) != logLinear.GetIndex(value) {
+			t.Fatalf("loaded model predicts key %v differently", i)
+		}
+	}
+
+	if _, err := logLinear.Freeze(); err == nil {
+		t.Fatalf("expected log-linear models to be rejected by the frozen layout")
+	}
+}
+
+func TestWithLayerModel(t *testing.T) {
+	values := exponentialKeys(2000)
+
+	rmi, err := NewRMI(values, 4, 3, WithLayerModel(0, ModelLogLinear), WithLayerModel(2, ModelEndpoint))
+	if err != nil {
+		t.Fatalf("Failed to build RMI %v\n", err)
+	}
+
+	v := rmi.current.Load()
+	if !v.root.log || v.nodes[1][0].log || v.nodes[2][0].log {
+		t.Fatalf("layer models were not applied")
+	}
+
+	batch := rmi.GetIndexBatch(values)
+	for i, value := range values {
+		if batch[i] != rmi.GetIndex(value) {
+			t.Fatalf("batch prediction of key %v differs", i)
+		}
+	}
+}
+
+func TestModelAuto(t *testing.T) {
+
+	// exponential keys followed by uniform keys
+	values := exponentialKeys(3000)
+	last := values[len(values)-1]
+	for i := 1; i <= 3000; i++ {
+		values = append(values, new(big.Int).Add(last, new(big.Int).Mul(big.NewInt(int64(i)), last)))
+	}
+
+	auto, err := NewRMI(values, 8, 2, WithModelType(ModelAuto))
+	if err != nil {
+		t.Fatalf("Failed to build RMI %v\n", err)
+	}
+
+	// no single model type fits every leaf better
+	for _, model := range []ModelType{ModelLinear, ModelLogLinear, ModelEndpoint} {
+		fixed, _ := NewRMI(values, 8, 2, WithModelType(model))
+		if auto.MaxError() > fixed.MaxError() {
+			t.Fatalf("auto max error %v exceeds the %v max error %v", auto.MaxError(), model, fixed.MaxError())
+		}
+	}
+
+	chosen := make(map[ModelType]bool)
+	for _, layer := range auto.ModelTypes() {
+		for _, model := range layer {
+			chosen[model] = true
+		}
+	}
+	if !chosen[ModelLogLinear] || len(chosen) < 2 {
+		t.Fatalf("expected a mix of model types, got %v", chosen)
+	}
+
+	// the chosen types survive saving
+	data, _ := auto.MarshalBinary()
+	loaded, err := UnmarshalRMI(data)
+	if err != nil {
+		t.Fatalf("Failed to load RMI %v\n", err)
+	}
+	for layer, types := range loaded.ModelTypes() {
+		for i, model := range types {
+			if model != auto.ModelTypes()[layer][i] {
+				t.Fatalf("model type of node %v of layer %v changed on loading", i, layer)
+			}
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// mapped.go: indexes over keys in a custom ordering. The caller supplies
+// a comparator defining the ordering and a monotone mapping of keys to
+// integers (e.g., case-insensitive strings mapped through their folded
+// bytes); the model is trained on the mapped integers so predictions are
+// consistent with the custom ordering.
+
+package rmi
+
+import (
+	"errors"
+	"math/big"
+)
+
+/*
+MappedRMI is an RMI over keys of type K in the order defined by cmp
+keys: the sorted keys (retained, not copied)
+keyMap: monotone mapping of keys to integers
+cmp: comparator defining the order of the keys (negative, zero, positive)
+*/
+type MappedRMI[K any] struct {
+	rmi    *RMI
+	keys   []K
+	keyMap func(K) *big.Int
+	cmp    func(a, b K) int
+}
+
+// NewMappedRMI builds an index over keys sorted by cmp; keyMap must be monotone
+// with respect to cmp (a < b implies keyMap(a) <= keyMap(b)), which is verified
+func NewMappedRMI[K any](
+	keys []K,
+	keyMap func(K) *big.Int,
+	cmp func(a, b K) int,
+	width int,
+	depth int,
+	opts ...Option) (*MappedRMI[K], error) {
+
+	values := make([]*big.Int, len(keys))
+	for i, key := range keys {
+		values[i] = keyMap(key)
+
+		if i > 0 && cmp(keys[i-1], key) > 0 {
+			return nil, errors.New("keys must be in sorted order")
+		}
+
+		if i > 0 && values[i-1].Cmp(values[i]) == 1 {
+			return nil, errors.New("key mapping is not monotone in the key order")
+		}
+	}
+
+	rmi, err := NewRMI(values, width, depth, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MappedRMI[K]{rmi, keys, keyMap, cmp}, nil
+}
+
+// GetIndex returns the approximate index of key
+func (m *MappedRMI[K]) GetIndex(key K) int {
+	return m.rmi.GetIndex(m.keyMap(key))
+}
+
+// Find returns the index of the first key equal to key under cmp (or false
+// if absent); keys sharing a mapped integer are told apart with cmp
+func (m *MappedRMI[K]) Find(key K) (int, bool) {
+
+	value := m.keyMap(key)
+	for index := m.rmi.lowerBound(value); index < len(m.keys); index++ {
+		if m.rmi.values[index].Cmp(value) != 0 {
+			break
+		}
+
+		c := m.cmp(m.keys[index], key)
+		if c == 0 {
+			return index, true
+		} else if c > 0 {
+			break
+		}
+	}
+
+	return 0, false
+}
+
+// RMI returns the underlying index over the mapped integers
+func (m *MappedRMI[K]) RMI() *RMI {
+	return m.rmi
+}
@@ -0,0 +1,53 @@
+package rmi
+
+import (
+	"math/big"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// maps strings to integers by their first 8 case-folded bytes
+func foldedPrefix(s string) *big.Int {
+	b := make([]byte, 8)
+	copy(b, strings.ToLower(s))
+	return new(big.Int).SetBytes(b)
+}
+
+// case-insensitive order, ties broken by the raw bytes
+func foldedCompare(a, b string) int {
+	if c := strings.Compare(strings.ToLower(a), strings.ToLower(b)); c != 0 {
+		return c
+	}
+	return strings.Compare(a, b)
+}
+
+func TestMappedRMI(t *testing.T) {
+	keys := []string{"apple", "Banana", "banana", "Cherry", "date", "Elderberry", "fig", "Grape", "grapefruit", "kiwi"}
+	for i := 0; i < 500; i++ {
+		keys = append(keys, "zz"+strings.Repeat("a", i%7)+string(rune('a'+i%26)))
+	}
+	sort.SliceStable(keys, func(i, j int) bool { return foldedCompare(keys[i], keys[j]) < 0 })
+
+	m, err := NewMappedRMI(keys, foldedPrefix, foldedCompare, 4, 2)
+	if err != nil {
+		t.Fatalf("Failed to build RMI %v\n", err)
+	}
+
+	for i, key := range keys {
+		index, ok := m.Find(key)
+		if !ok || foldedCompare(keys[index], key) != 0 || index > i {
+			t.Fatalf("key %q was not found (got %v, %v)", key, index, ok)
+		}
+	}
+
+	if _, ok := m.Find("BANANA"); ok {
+		t.Fatalf("found a key that differs under the comparator")
+	}
+
+	// a mapping that is not monotone in the key order is rejected
+	reversed := func(s string) *big.Int { return new(big.Int).Neg(foldedPrefix(s)) }
+	if _, err := NewMappedRMI(keys, reversed, foldedCompare, 4, 2); err == nil {
+		t.Fatalf("expected non-monotone mapping to be rejected")
+	}
+}
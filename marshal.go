@@ -0,0 +1,66 @@
+// marshal.go: gob and JSON encodings of models and nodes, so an RMI can be
+// a field of values stored by existing Go persistence pipelines. Both carry
+// the binary encoding (see persist.go and encoding.go): the coefficients are
+// big.Floats in unexported fields whose text form drops their precision and
+// rounding mode, so a model in JSON is a string holding the base64 of
+// MarshalBinary and loads bit-identically like a saved model.
+
+package rmi
+
+import (
+	"encoding/json"
+)
+
+// GobEncode encodes the trained model (but not the keys) as MarshalBinary
+func (rmi *RMI) GobEncode() ([]byte, error) {
+	return rmi.MarshalBinary()
+}
+
+// GobDecode decodes a model written by GobEncode into rmi (see UnmarshalBinary)
+func (rmi *RMI) GobDecode(data []byte) error {
+	return rmi.UnmarshalBinary(data)
+}
+
+// MarshalJSON encodes the trained model (but not the keys)
+// as a JSON string of the base64 of MarshalBinary
+func (rmi *RMI) MarshalJSON() ([]byte, error) {
+	return json.Marshal(rmi.encode())
+}
+
+// UnmarshalJSON decodes a model written by MarshalJSON into rmi (see UnmarshalBinary)
+func (rmi *RMI) UnmarshalJSON(data []byte) error {
+
+	var encoded []byte
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+
+	return rmi.UnmarshalBinary(encoded)
+}
+
+// GobEncode encodes the node as MarshalBinary; the encoders have value
+// receivers so that nodes stored by value in other types are encoded too
+func (node Node) GobEncode() ([]byte, error) {
+	return node.MarshalBinary()
+}
+
+// GobDecode decodes a node written by GobEncode
+func (node *Node) GobDecode(data []byte) error {
+	return node.UnmarshalBinary(data)
+}
+
+// MarshalJSON encodes the node as a JSON string of the base64 of MarshalBinary
+func (node Node) MarshalJSON() ([]byte, error) {
+	return json.Marshal(appendNode(make([]byte, 0), &node))
+}
+
+// UnmarshalJSON decodes a node written by MarshalJSON
+func (node *Node) UnmarshalJSON(data []byte) error {
+
+	var encoded []byte
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+
+	return node.UnmarshalBinary(encoded)
+}
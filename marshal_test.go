@@ -0,0 +1,68 @@
+package rmi
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"testing"
+)
+
+// a record embedding an index, as stored by a persistence pipeline
+type indexRecord struct {
+	Name  string
+	Index *RMI
+	Root  Node
+}
+
+func TestGobJSONRoundTrip(t *testing.T) {
+
+	values := sortedTestData(5000)
+	model, err := NewRMIWithOptions(values, WithLeafEnsemble(), WithPrecision(200))
+	if err != nil {
+		t.Fatalf("Failed to build RMI %v\n", err)
+	}
+	record := indexRecord{"keys", model, *model.current.Load().root}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(record); err != nil {
+		t.Fatalf("failed to gob encode %v", err)
+	}
+	var fromGob indexRecord
+	if err := gob.NewDecoder(&buf).Decode(&fromGob); err != nil {
+		t.Fatalf("failed to gob decode %v", err)
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		t.Fatalf("failed to encode JSON %v", err)
+	}
+	var fromJSON indexRecord
+	if err := json.Unmarshal(data, &fromJSON); err != nil {
+		t.Fatalf("failed to decode JSON %v", err)
+	}
+
+	binary, _ := model.MarshalBinary()
+	for _, decoded := range []indexRecord{fromGob, fromJSON} {
+		if decoded.Name != record.Name {
+			t.Fatalf("decoded name %q instead of %q", decoded.Name, record.Name)
+		}
+
+		if again, _ := decoded.Index.MarshalBinary(); !bytes.Equal(again, binary) {
+			t.Fatalf("decoded model does not encode to the original model")
+		}
+
+		if decoded.Root.m.Cmp(record.Root.m) != 0 || decoded.Root.m.Prec() != record.Root.m.Prec() || decoded.Root.b.Cmp(record.Root.b) != 0 {
+			t.Fatalf("decoded root %v %v differs from %v %v", decoded.Root.m, decoded.Root.b, record.Root.m, record.Root.b)
+		}
+
+		for i, value := range values {
+			if decoded.Index.GetIndex(value) != model.GetIndex(value) {
+				t.Fatalf("decoded model predicts key %v differently", i)
+			}
+		}
+	}
+
+	if err := json.Unmarshal([]byte(`{"Index": "bm90IGEgbW9kZWw="}`), &fromJSON); err == nil {
+		t.Fatalf("expected an error decoding a string that is not a model")
+	}
+}
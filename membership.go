@@ -0,0 +1,54 @@
+// membership.go: approximate membership from the model and the key
+// boundaries of the leaves alone. Every key is routed to exactly one leaf
+// and lies between the smallest and largest key routed to it, so a value
+// outside the boundaries of its leaf cannot be a key (no false negatives).
+
+package rmi
+
+import (
+	"math/big"
+)
+
+/*
+Smallest and largest key routed to a leaf
+lo, hi: the boundary keys (nil if the leaf is empty or the keys are unknown)
+*/
+type keyBounds struct {
+	lo, hi *big.Int
+}
+
+// boundsOf returns the key boundaries of the leaves of version v
+func (rmi *RMI) boundsOf(v *version) []keyBounds {
+	v.errs.boundsOnce.Do(func() {
+		leaves := rmi.errorsOf(v)
+		v.errs.bounds = make([]keyBounds, len(leaves))
+		for i, e := range leaves {
+			if e.count > 0 && rmi.values != nil {
+				v.errs.bounds[i] = keyBounds{rmi.values[e.first], rmi.values[e.last]}
+			}
+		}
+	})
+
+	return v.errs.bounds
+}
+
+// MayContain reports whether value may be one of the keys using only the
+// model and the key boundaries of its leaf, without searching the keys;
+// false is always correct, true may be a false positive (e.g., for values
+// between two keys of the leaf or for deleted keys)
+func (rmi *RMI) MayContain(value *big.Int) bool {
+
+	v := rmi.current.Load()
+	_, loc := rmi.leaf(v, value)
+
+	if rmi.errorsOf(v)[loc].count == 0 {
+		return false
+	}
+
+	b := rmi.boundsOf(v)[loc]
+	if b.lo == nil {
+		return true
+	}
+
+	return value.Cmp(b.lo) >= 0 && value.Cmp(b.hi) <= 0
+}
@@ -0,0 +1,52 @@
+package rmi
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+)
+
+func TestMayContain(t *testing.T) {
+	values := sortedTestData(10000)
+	rmi, _ := NewRMI(values, RMIWidthParameter, 3)
+
+	// no false negatives
+	for i, value := range values {
+		if !rmi.MayContain(value) {
+			t.Fatalf("key %v reported as absent", i)
+		}
+	}
+
+	// values far outside the keys are filtered
+	below := new(big.Int).Sub(values[0], big.NewInt(1))
+	above := new(big.Int).Add(values[len(values)-1], big.NewInt(1))
+	if rmi.MayContain(below) || rmi.MayContain(above) {
+		t.Fatalf("expected values outside the key range to be filtered")
+	}
+
+	// some absent values between the keys land in empty leaves or gaps
+	filtered, absent := 0, 0
+	for i := 1; i < len(values); i++ {
+		mid := new(big.Int).Add(values[i-1], values[i])
+		mid.Rsh(mid, 1)
+		if mid.Cmp(values[i-1]) == 0 {
+			continue
+		}
+		absent++
+		if !rmi.MayContain(mid) {
+			filtered++
+		}
+	}
+	t.Logf("filtered %v of %v absent values between keys", filtered, absent)
+
+	// without the keys every non-empty leaf may contain the value
+	var buf bytes.Buffer
+	rmi.Save(&buf)
+	loaded, err := Load(&buf, nil, rmi.Metadata())
+	if err != nil {
+		t.Fatalf("Failed to load %v\n", err)
+	}
+	if !loaded.MayContain(values[10]) {
+		t.Fatalf("expected a conservative answer without the keys")
+	}
+}
@@ -0,0 +1,121 @@
+// monotone.go: monotonicity of the leaf predictions. Adjacent leaves are
+// fitted independently, so the last keys of a leaf may be predicted past
+// the first keys of the next one; the correction search and range windows
+// assume predictions never decrease with the key. The enforcement pass
+// walks the keys in order and raises the intercept of every leaf model
+// whose first key is predicted below the key preceding it.
+
+package rmi
+
+import (
+	"errors"
+	"math/big"
+)
+
+// WithMonotonicLeaves makes the leaf predictions non-decreasing over the
+// keys after the build (see EnforceMonotonic)
+func WithMonotonicLeaves() Option {
+	return func(c *config) {
+		c.monotonic = true
+	}
+}
+
+// Monotonic reports whether the raw leaf predictions (see Predict) of the
+// current version are non-decreasing over the keys the RMI was built on
+// (vacuously true if the keys are not retained, see Load)
+func (rmi *RMI) Monotonic() bool {
+
+	v := rmi.current.Load()
+
+	var prev *big.Float
+	for _, value := range rmi.values {
+		leaf, _ := rmi.leaf(v, value)
+		prediction := leaf.predict(value)
+		if prev != nil && prediction.Cmp(prev) == -1 {
+			return false
+		}
+		prev = prediction
+	}
+
+	return true
+}
+
+// EnforceMonotonic raises the intercepts of the leaf models so their raw
+// predictions are non-decreasing over the keys and publishes the result as
+// a new epoch; the keys must be retained (see Load)
+func (rmi *RMI) EnforceMonotonic() (uint64, error) {
+
+	if rmi.values == nil {
+		return 0, errors.New("enforcing monotonicity needs the keys of the model")
+	}
+
+	if rmi.current.Load().calib != nil {
+		return 0, errors.New("calibrated models cannot be adjusted, calibrate after enforcing monotonicity")
+	}
+
+	return rmi.publish(func(v *version) *version {
+		return v.withLeaves(rmi.monotoneLeaves(v), v.maxIndex)
+	}), nil
+}
+
+// monotoneLeaves returns the leaves of v whose models have to be raised
+// for the predictions to be non-decreasing over the keys. Leaf models have
+// non-negative slopes, so only the first key routed to each model (the first
+// key of a leaf and the first key past its breakpoint) can be out of order
+func (rmi *RMI) monotoneLeaves(v *version) map[int]*Node {
+
+	leaves := v.nodes[rmi.depth-1]
+	replaced := make(map[int]*Node)
+	seen := make(map[int]map[bool]bool)
+
+	var prev *big.Float
+	for _, value := range rmi.values {
+		_, loc := rmi.leaf(v, value)
+		leaf := leaves[loc]
+		if r, ok := replaced[loc]; ok {
+			leaf = r
+		}
+
+		alt := leaf.modelFor(value) != leaf
+		prediction := leaf.modelFor(value).predict(value)
+
+		if prev != nil && prediction.Cmp(prev) == -1 && !seen[loc][alt] {
+			// doubling the shift guarantees termination under rounding
+			delta := new(big.Float).Sub(prev, prediction)
+			for raised := leaf; prediction.Cmp(prev) == -1; delta.Add(delta, delta) {
+				raised = leaf.raised(alt, delta)
+				prediction = raised.modelFor(value).predict(value)
+				replaced[loc] = raised
+			}
+		}
+
+		if seen[loc] == nil {
+			seen[loc] = make(map[bool]bool)
+		}
+		seen[loc][alt] = true
+
+		if prev == nil || prediction.Cmp(prev) == 1 {
+			prev = prediction
+		}
+	}
+
+	return replaced
+}
+
+// raised returns a copy of the leaf whose first (or, if alt, second) model
+// predicts delta indices more; the intercept is rounded up
+func (node *Node) raised(alt bool, delta *big.Float) *Node {
+
+	res := *node
+	model := &res
+	if alt {
+		second := *node.alt
+		res.alt = &second
+		model = res.alt
+	}
+
+	model.b = new(big.Float).SetMode(big.ToPositiveInf).Add(model.b, delta)
+	model.w = xIntercept(model.m, model.b)
+
+	return &res
+}
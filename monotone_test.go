@@ -0,0 +1,56 @@
+package rmi
+
+import (
+	"testing"
+)
+
+func TestEnforceMonotonic(t *testing.T) {
+	values := exponentialKeys(2000)
+
+	rmi, err := NewRMI(values, 8, 2)
+	if err != nil {
+		t.Fatalf("Failed to build RMI %v\n", err)
+	}
+
+	if rmi.Monotonic() {
+		t.Fatalf("expected adjacent leaves of exponential keys to overlap")
+	}
+
+	epoch, err := rmi.EnforceMonotonic()
+	if err != nil || epoch != 1 {
+		t.Fatalf("EnforceMonotonic failed: %v (epoch %v)", err, epoch)
+	}
+
+	if !rmi.Monotonic() {
+		t.Fatalf("predictions are not monotonic after enforcement")
+	}
+
+	// the error bounds are measured on the adjusted model
+	prev := 0
+	for i, value := range values {
+		if index, ok := rmi.Lookup(value); !ok || values[index].Cmp(value) != 0 {
+			t.Fatalf("Lookup of key %v failed", i)
+		}
+
+		index := rmi.GetIndex(value)
+		if index < prev {
+			t.Fatalf("GetIndex of key %v is %v, below %v", i, index, prev)
+		}
+		prev = index
+	}
+
+	// the option enforces monotonicity at build time, also for ensemble leaves
+	ensemble, err := NewRMI(values, 8, 2, WithMonotonicLeaves(), WithLeafEnsemble())
+	if err != nil {
+		t.Fatalf("Failed to build RMI %v\n", err)
+	}
+
+	if !ensemble.Monotonic() {
+		t.Fatalf("predictions are not monotonic with WithMonotonicLeaves")
+	}
+
+	calibrated, _ := NewRMI(values, 8, 2, WithCalibration())
+	if _, err := calibrated.EnforceMonotonic(); err == nil {
+		t.Fatalf("expected an error adjusting a calibrated model")
+	}
+}
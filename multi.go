@@ -0,0 +1,38 @@
+// multi.go: queries over several per-segment indexes
+
+package rmi
+
+import (
+	"errors"
+	"math/big"
+)
+
+// MultiLookup looks value up in every segment, where indexes[i] was built over
+// datasets[i], and returns the global positions of all matching keys in
+// increasing order; the global position of a key is its index in its segment
+// plus the total length of all preceding segments
+func MultiLookup(indexes []*RMI, datasets [][]*big.Int, value *big.Int) ([]int, error) {
+
+	if len(indexes) != len(datasets) {
+		return nil, errors.New("every index needs exactly one dataset")
+	}
+
+	positions := make([]int, 0)
+	offset := 0
+	for i, rmi := range indexes {
+		values := datasets[i]
+
+		// start at the first occurrence and collect all duplicates
+		for index := lowerBoundFrom(values, value, rmi.GetIndex(value)); index < len(values); index++ {
+			if values[index].Cmp(value) != 0 {
+				break
+			}
+
+			positions = append(positions, offset+index)
+		}
+
+		offset += len(values)
+	}
+
+	return positions, nil
+}
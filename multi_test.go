@@ -0,0 +1,40 @@
+package rmi
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestMultiLookup(t *testing.T) {
+	values := sortedTestData(3000)
+
+	// three segments; the last key of the first segment is repeated in the second
+	segments := [][]*big.Int{values[:1000], append([]*big.Int{values[999]}, values[1000:2000]...), values[2000:]}
+	indexes := make([]*RMI, len(segments))
+	for i, segment := range segments {
+		indexes[i], _ = NewRMI(segment, RMIWidthParameter, RMIDepthParameter)
+	}
+
+	positions, err := MultiLookup(indexes, segments, values[999])
+	if err != nil {
+		t.Fatalf("lookup failed %v", err)
+	}
+
+	if len(positions) != 2 || positions[0] != 999 || positions[1] != 1000 {
+		t.Fatalf("unexpected positions %v", positions)
+	}
+
+	positions, _ = MultiLookup(indexes, segments, values[2500])
+	if len(positions) != 1 || positions[0] != 2501 {
+		t.Fatalf("unexpected positions %v", positions)
+	}
+
+	positions, _ = MultiLookup(indexes, segments, big.NewInt(-1))
+	if len(positions) != 0 {
+		t.Fatalf("absent key found at %v", positions)
+	}
+
+	if _, err := MultiLookup(indexes, segments[:2], values[0]); err == nil {
+		t.Fatalf("expected mismatched inputs to be rejected")
+	}
+}
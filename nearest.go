@@ -0,0 +1,76 @@
+// nearest.go: nearest-key queries over the retained keys
+
+package rmi
+
+import (
+	"math"
+	"math/big"
+)
+
+// Nearest returns the index of the key closest to value and the signed
+// distance delta = key - value (ties go to the smaller key)
+// returns index -1 and a nil delta if the RMI holds no (live) keys
+func (rmi *RMI) Nearest(value *big.Int) (int, *big.Int) {
+
+	nearest := rmi.NearestK(value, 1)
+	if len(nearest) == 0 {
+		return -1, nil
+	}
+
+	index := nearest[0]
+	return index, new(big.Int).Sub(rmi.values[index], value)
+}
+
+// NearestK returns the indices of the (up to) k keys closest to value
+// ordered by increasing absolute distance (ties go to the smaller key)
+// the keys are found by scanning outwards from the predicted position;
+// positions marked in the deletion bitmap are skipped (see SetDeletions)
+func (rmi *RMI) NearestK(value *big.Int, k int) []int {
+
+	n := len(rmi.values)
+	if k <= 0 || n == 0 {
+		return []int{}
+	}
+
+	result := make([]int, 0, int(math.Min(float64(k), float64(n))))
+	deleted := rmi.deletions()
+
+	// left and right are the next candidates on either side of value
+	right := rmi.lowerBound(value)
+	left := right - 1
+
+	leftDist := new(big.Int)
+	rightDist := new(big.Int)
+	for len(result) < k {
+		left = deleted.skipLeft(left)
+		right = deleted.skipRight(right, n)
+
+		if left < 0 && right >= n {
+			break
+		}
+
+		if left < 0 {
+			result = append(result, right)
+			right++
+			continue
+		}
+
+		if right >= n {
+			result = append(result, left)
+			left--
+			continue
+		}
+
+		leftDist.Sub(value, rmi.values[left])
+		rightDist.Sub(rmi.values[right], value)
+		if leftDist.Cmp(rightDist) != 1 {
+			result = append(result, left)
+			left--
+		} else {
+			result = append(result, right)
+			right++
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,83 @@
+package rmi
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+// brute force nearest key (ties go to the smaller key)
+func nearestLinear(values []*big.Int, value *big.Int) int {
+	best := 0
+	bestDist := new(big.Int).Abs(new(big.Int).Sub(values[0], value))
+	for i := range values {
+		dist := new(big.Int).Abs(new(big.Int).Sub(values[i], value))
+		if dist.Cmp(bestDist) == -1 {
+			best = i
+			bestDist = dist
+		}
+	}
+	return best
+}
+
+func TestNearest(t *testing.T) {
+	values := sortedTestData(2000)
+	rmi, err := NewRMI(values, RMIWidthParameter, RMIDepthParameter)
+	if err != nil {
+		t.Fatalf("Failed to build RMI %v\n", err)
+	}
+
+	for i := 0; i < 200; i++ {
+		query := big.NewInt(rand.Int63())
+		index, delta := rmi.Nearest(query)
+
+		expected := nearestLinear(values, query)
+		if values[index].Cmp(values[expected]) != 0 {
+			t.Fatalf("nearest key index %v, expected %v", index, expected)
+		}
+
+		if new(big.Int).Add(query, delta).Cmp(values[index]) != 0 {
+			t.Fatalf("delta %v does not lead to key %v", delta, values[index])
+		}
+	}
+
+	index, delta := rmi.Nearest(values[17])
+	if values[index].Cmp(values[17]) != 0 || delta.Sign() != 0 {
+		t.Fatalf("existing key not found exactly")
+	}
+}
+
+func TestNearestK(t *testing.T) {
+	values := sortedTestData(2000)
+	rmi, err := NewRMI(values, RMIWidthParameter, RMIDepthParameter)
+	if err != nil {
+		t.Fatalf("Failed to build RMI %v\n", err)
+	}
+
+	for i := 0; i < 50; i++ {
+		query := big.NewInt(rand.Int63())
+		result := rmi.NearestK(query, 10)
+
+		if len(result) != 10 {
+			t.Fatalf("expected 10 results, got %v", len(result))
+		}
+
+		// the first result is the nearest key and distances never decrease
+		if result[0] != nearestLinear(values, query) {
+			t.Fatalf("first result %v is not the nearest key", result[0])
+		}
+
+		prev := big.NewInt(0)
+		for _, index := range result {
+			dist := new(big.Int).Abs(new(big.Int).Sub(values[index], query))
+			if dist.Cmp(prev) == -1 {
+				t.Fatalf("results are not ordered by distance")
+			}
+			prev = dist
+		}
+	}
+
+	if len(rmi.NearestK(values[0], len(values)+5)) != len(values) {
+		t.Fatalf("expected k to be capped at the number of keys")
+	}
+}
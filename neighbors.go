@@ -0,0 +1,75 @@
+// neighbors.go: query-time bounds of the leaf predictions from the
+// neighbouring leaves. The parent of a leaf routes the keys between the two
+// keys at which its routing value reaches the position of the leaf and the
+// next position to the leaf, so the ranks of those keys lie between the
+// prediction of the previous leaf at the lower key and the prediction of the
+// next leaf at the upper key. A leaf extrapolating far beyond the keys it was
+// trained on is clamped to that range; nothing is stored, the bounds are
+// derived at query time from the coefficients of the parent and neighbours.
+
+package rmi
+
+import (
+	"math/big"
+)
+
+// WithNeighborClamp clamps every leaf prediction to the range given by the
+// predictions of the neighbouring leaves at the bounds of the keys the parent
+// routes to the leaf (see neighbors.go); the error bounds are measured on the
+// clamped predictions
+func WithNeighborClamp() Option {
+	return func(c *config) {
+		c.neighborClamp = true
+	}
+}
+
+// clampToNeighbors returns the prediction of the leaf at position loc of v
+// for a key routed to it by parent clamped to the bounds of its neighbours
+func (rmi *RMI) clampToNeighbors(v *version, parent *Node, loc int, prediction *big.Float) *big.Float {
+
+	if parent == nil || parent.log || parent.m.Sign() <= 0 {
+		return prediction
+	}
+
+	leaves := v.layerSize(rmi.depth - 1)
+	lo, hi := (*big.Float)(nil), (*big.Float)(nil)
+	if loc > 0 {
+		lo = v.node(rmi.depth-1, loc-1).predictAt(routedBound(parent, loc, leaves, v.maxIndex))
+	}
+	if loc < leaves-1 {
+		hi = v.node(rmi.depth-1, loc+1).predictAt(routedBound(parent, loc+1, leaves, v.maxIndex))
+	}
+
+	// neighbours that disagree on the order give no bound
+	if lo != nil && hi != nil && lo.Cmp(hi) == 1 {
+		return prediction
+	}
+
+	if lo != nil && prediction.Cmp(lo) == -1 {
+		return lo
+	} else if hi != nil && prediction.Cmp(hi) == 1 {
+		return hi
+	}
+
+	return prediction
+}
+
+// routedBound returns the key at which the routing value of parent over a
+// leaf layer of the given size reaches pos (rounded down to an integer)
+func routedBound(parent *Node, pos, leaves, maxIndex int) *big.Int {
+
+	// (m x + b) / maxIndex * leaves = pos
+	x := new(big.Float).SetFloat64(routingMaxIndex(maxIndex))
+	x.Mul(x, new(big.Float).SetInt64(int64(pos)))
+	x.Quo(x, new(big.Float).SetInt64(int64(leaves)))
+	x.Sub(x, parent.b)
+	x.Quo(x, parent.m)
+
+	key, _ := x.Int(nil)
+	return key
+}
+
+// predictAt evaluates the model of the leaf responsible for value
+func (node *Node) predictAt(value *big.Int) *big.Float {
+	return node.modelFor(value).predict(value)
+}
@@ -0,0 +1,98 @@
+package rmi
+
+import (
+	"math/big"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestNeighborClamp(t *testing.T) {
+
+	// evenly spaced keys with bursts of consecutive keys, whose leaves are
+	// trained on a steep fit but route the keys of a wide range
+	values := make([]*big.Int, 0)
+	for i := 0; i < 10000; i++ {
+		values = append(values, big.NewInt(int64(i)*1000))
+		if i%1000 == 500 {
+			for j := 1; j < 50; j++ {
+				values = append(values, big.NewInt(int64(i)*1000+int64(j)))
+			}
+		}
+	}
+
+	r := rand.New(rand.NewSource(1))
+	queries := make([]*big.Int, 10000)
+	for q := range queries {
+		queries[q] = big.NewInt(r.Int63n(values[len(values)-1].Int64()))
+	}
+
+	maxErr := make([]int, 2)
+	for k, opts := range [][]Option{nil, {WithNeighborClamp()}} {
+		model, err := NewRMI(values, 200, 2, opts...)
+		if err != nil {
+			t.Fatalf("Failed to build RMI %v\n", err)
+		}
+
+		for i, value := range values {
+			if abs(model.GetIndex(value)-i) > model.MaxError() {
+				t.Fatalf("key %v is outside of the error bound %v", i, model.MaxError())
+			}
+		}
+
+		batch := model.GetIndexBatch(queries)
+		for q, query := range queries {
+			index := model.GetIndex(query)
+			if batch[q] != index {
+				t.Fatalf("batch index %v of query %v differs from GetIndex %v", batch[q], q, index)
+			}
+
+			rank := sort.Search(len(values), func(i int) bool { return values[i].Cmp(query) >= 0 })
+			if e := abs(index - rank); e > maxErr[k] {
+				maxErr[k] = e
+			}
+		}
+	}
+
+	if maxErr[1] > maxErr[0]/4 {
+		t.Fatalf("clamped max error %v over the queries is not below %v", maxErr[1], maxErr[0]/4)
+	}
+}
+
+func TestNeighborClampSaved(t *testing.T) {
+
+	values := sortedTestData(2000)
+	model, err := NewRMI(values, 50, 2, WithNeighborClamp())
+	if err != nil {
+		t.Fatalf("Failed to build RMI %v\n", err)
+	}
+
+	data, _ := model.MarshalBinary()
+	loaded, err := UnmarshalRMI(data)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal RMI %v\n", err)
+	}
+
+	for _, value := range values {
+		if loaded.GetIndex(value) != model.GetIndex(value) {
+			t.Fatalf("loaded model predicts differently")
+		}
+	}
+
+	// the clamp is not part of the exported and blinded evaluations
+	if _, err := model.Freeze(); err == nil {
+		t.Fatalf("expected clamped model to be rejected by Freeze")
+	}
+	if _, err := model.Blind(big.NewInt(3), big.NewInt(5)); err == nil {
+		t.Fatalf("expected clamped model to be rejected by Blind")
+	}
+	if _, err := model.Share(2); err == nil {
+		t.Fatalf("expected clamped model to be rejected by Share")
+	}
+	if _, err := model.Circuit(); err == nil {
+		t.Fatalf("expected clamped model to be rejected by Circuit")
+	}
+	if _, _, err := model.GetIndexWithProof(values[0]); err == nil {
+		t.Fatalf("expected clamped model to be rejected by GetIndexWithProof")
+	}
+}
@@ -0,0 +1,382 @@
+// options.go: optional configuration knobs for building an RMI
+
+package rmi
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+)
+
+// Option configures optional behaviour of the RMI at construction time
+type Option func(*config)
+
+/*
+Optional configuration of the RMI
+tracer: tracer used to emit build and query spans (nil disables tracing)
+traceEvery: trace one out of every traceEvery queries (0 disables query spans)
+traceHook: called for every node evaluated by a query (see WithTraceHook)
+maxModelBytes: upper bound on the estimated model size (0 means unbounded)
+shrinkToFit: reduce the width instead of failing when maxModelBytes is exceeded
+clampShape: use the suggested width/depth instead of failing on pathological configurations
+calibrate: train an isotonic calibration of the leaf predictions after the build
+bucketSize: number of keys per bucket for bucket queries (see bucket.go)
+leafEnsemble: let leaves keep a second model beyond a breakpoint (see ensemble.go)
+lastMile, lastMileError: attach exact key tables to leaves whose error exceeds lastMileError
+drift: optional monitor of live correction distances (see drift.go)
+routingShift, routingCapacity: key prefix length and size of the routing cache (see routing.go)
+endpointAll, endpointFit: train all layers (or the given layers) with the endpoint fit
+model: model type of all layers not selected by the above (see WithModelType)
+layerModels: model type of individual layers, overriding the above (see WithLayerModel)
+width, depth: shape used by NewRMIWithOptions (0 picks the default)
+precision: number of bits the coefficients are rounded to after training (0 keeps them)
+parallelism: number of goroutines training the nodes of a layer (0 uses GOMAXPROCS)
+radixRoot: route keys at the root by their top bits (see radix.go)
+rateLimit: fraction of the wall time each build goroutine may work (see throttle.go)
+illConditioned: replacement of ill-conditioned regressions (see illcond.go)
+monotonic: make the leaf predictions non-decreasing after the build (see monotone.go)
+splitWindow, splitFraction: split leaves of an Updatable whose lookups exceed their error window (see hotspot.go)
+batchSort: evaluate the queries of GetIndexBatch in key order (see WithBatchSorting)
+lazySort: check the key order while training the root instead of before (see WithLazySortCheck)
+sampleAbove, sampleRate: train nodes above sampleAbove keys on a subsample (see subsample.go)
+neighborClamp: clamp leaf predictions to the bounds of their neighbours (see neighbors.go)
+leafFinalizer: called for every leaf at the end of the build (see finalize.go)
+*/
+type config struct {
+	tracer          Tracer
+	traceEvery      uint64
+	traceHook       TraceHook
+	maxModelBytes   int64
+	shrinkToFit     bool
+	clampShape      bool
+	calibrate       bool
+	bucketSize      int
+	leafEnsemble    bool
+	lastMile        bool
+	lastMileError   float64
+	drift           *driftMonitor
+	routingShift    uint
+	routingCapacity int
+	endpointAll     bool
+	endpointFit     map[int]bool
+	model           ModelType
+	layerModels     map[int]ModelType
+	width, depth    int
+	precision       uint
+	parallelism     int
+	radixRoot       bool
+	rateLimit       float64
+	illConditioned  IllConditionedPolicy
+	monotonic       bool
+	splitWindow     int
+	splitFraction   float64
+	batchSort       bool
+	lazySort        bool
+	sampleAbove     int
+	sampleRate      float64
+	neighborClamp   bool
+	leafFinalizer   LeafFinalizer
+}
+
+// ModelType selects how the nodes of the model are trained
+type ModelType int
+
+const (
+	// ModelLinear fits every node with a linear regression
+	ModelLinear ModelType = iota
+
+	// ModelEndpoint fits every node with the line through its
+	// first and last key (see WithEndpointFit)
+	ModelEndpoint
+
+	// ModelLogLinear fits every node with a linear regression of
+	// the index on log2(1+key), for exponential or Zipfian keys
+	ModelLogLinear
+
+	// ModelConstant predicts the middle index of the keys of every node
+	ModelConstant
+
+	// ModelAuto fits every node with each of the above and keeps
+	// the model with the smallest max error (see autofit.go)
+	ModelAuto
+)
+
+// String returns the name of the model type
+func (t ModelType) String() string {
+	switch t {
+	case ModelLinear:
+		return "linear"
+	case ModelEndpoint:
+		return "endpoint"
+	case ModelLogLinear:
+		return "log-linear"
+	case ModelConstant:
+		return "constant"
+	case ModelAuto:
+		return "auto"
+	}
+
+	return fmt.Sprintf("ModelType(%d)", int(t))
+}
+
+// default shape of NewRMIWithOptions: a two-stage model
+// with one leaf per defaultKeysPerLeaf keys
+const (
+	defaultDepth       = 2
+	defaultKeysPerLeaf = 100
+)
+
+// WithTracer emits a span for the build and for each layer trained
+// during the build using the provided tracer
+func WithTracer(tracer Tracer) Option {
+	return func(c *config) {
+		c.tracer = tracer
+	}
+}
+
+// WithQueryTracing emits a span for one out of every n queries
+// (n = 1 traces every query); requires WithTracer to take effect
+func WithQueryTracing(n int) Option {
+	return func(c *config) {
+		if n > 0 {
+			c.traceEvery = uint64(n)
+		}
+	}
+}
+
+// WithMaxModelBytes refuses to build a model whose estimated size
+// (see EstimateModelBytes) exceeds n bytes
+func WithMaxModelBytes(n int64) Option {
+	return func(c *config) {
+		c.maxModelBytes = n
+	}
+}
+
+// WithShrinkToFit reduces the width of the model until it fits within
+// the limit set by WithMaxModelBytes instead of returning an error
+func WithShrinkToFit() Option {
+	return func(c *config) {
+		c.shrinkToFit = true
+	}
+}
+
+// WithClampShape builds with the suggested width and depth (see ShapeError)
+// instead of returning an error for pathological configurations
+func WithClampShape() Option {
+	return func(c *config) {
+		c.clampShape = true
+	}
+}
+
+// WithLazySortCheck skips the check that the keys are sorted before the
+// build; the order is instead checked while the root model passes over the
+// keys, and the build fails at the first key out of order (roots that are
+// not linear regressions still check the keys before their training)
+func WithLazySortCheck() Option {
+	return func(c *config) {
+		c.lazySort = true
+	}
+}
+
+// WithCalibration trains an isotonic calibration of the leaf predictions
+// after the build (see Calibrate)
+func WithCalibration() Option {
+	return func(c *config) {
+		c.calibrate = true
+	}
+}
+
+// WithBucketSize splits the keys into fixed-size buckets of n keys
+// so that GetBucket returns the bounds of the buckets that may hold a key
+func WithBucketSize(n int) Option {
+	return func(c *config) {
+		c.bucketSize = n
+	}
+}
+
+// WithLeafEnsemble lets each leaf keep two models split at a breakpoint key
+// when that reduces the leaf's maximum error (see ensemble.go)
+func WithLeafEnsemble() Option {
+	return func(c *config) {
+		c.leafEnsemble = true
+	}
+}
+
+// WithLastMileTables attaches a hash table from key to exact index to every
+// leaf whose maximum training error exceeds maxError, so that GetIndex is
+// exact for the keys of those leaves at the cost of one entry per key
+func WithLastMileTables(maxError float64) Option {
+	return func(c *config) {
+		c.lastMile = true
+		c.lastMileError = maxError
+	}
+}
+
+// WithDriftMonitor compares the correction distances of exact queries
+// against the training-time error over windows of window queries and calls
+// alert when the z-score of a window's mean distance exceeds z
+func WithDriftMonitor(z float64, window int, alert func(DriftAlert)) Option {
+	return func(c *config) {
+		if window < 1 {
+			window = 1
+		}
+		c.drift = &driftMonitor{threshold: z, window: window, alert: alert}
+	}
+}
+
+// WithRoutingCache caches the second layer node chosen for up to capacity
+// recently seen key prefixes, where the prefix of a key is key >> shift,
+// so queries on hot prefixes skip the evaluation of the root
+func WithRoutingCache(shift uint, capacity int) Option {
+	return func(c *config) {
+		c.routingShift = shift
+		c.routingCapacity = capacity
+	}
+}
+
+// WithEndpointFit trains the nodes of the given layers (0 is the root,
+// depth-1 the leaves; all layers if none are given) with the line through
+// the first and last (key, index) of the node instead of a linear regression,
+// an O(1) fit per node that trades accuracy for much faster builds
+func WithEndpointFit(layers ...int) Option {
+	return func(c *config) {
+		c.endpointAll = len(layers) == 0
+		c.endpointFit = make(map[int]bool)
+		for _, layer := range layers {
+			c.endpointFit[layer] = true
+		}
+	}
+}
+
+// WithWidth sets the width of a model built by NewRMIWithOptions
+func WithWidth(width int) Option {
+	return func(c *config) {
+		c.width = width
+	}
+}
+
+// WithDepth sets the depth of a model built by NewRMIWithOptions
+func WithDepth(depth int) Option {
+	return func(c *config) {
+		c.depth = depth
+	}
+}
+
+// WithModelType trains all layers with the given model type
+func WithModelType(t ModelType) Option {
+	return func(c *config) {
+		c.endpointAll = false
+		c.endpointFit = nil
+		c.model = t
+	}
+}
+
+// WithLayerModel trains the nodes of a layer (0 is the root, depth-1 the
+// leaves) with the given model type, whatever the other options select
+func WithLayerModel(layer int, t ModelType) Option {
+	return func(c *config) {
+		if c.layerModels == nil {
+			c.layerModels = make(map[int]ModelType)
+		}
+		c.layerModels[layer] = t
+	}
+}
+
+// WithPrecision sets the mantissa precision of the coefficients: the linear
+// regressions are solved to prec bits (instead of 53, too few for keys of
+// hundreds of bits) and every coefficient is rounded to prec bits after
+// training (see SetPrecision). Queries evaluate at the precision of the
+// coefficients but never round the keys; the error bounds are measured on
+// the rounded model
+func WithPrecision(prec uint) Option {
+	return func(c *config) {
+		c.precision = prec
+	}
+}
+
+// WithParallelism trains the nodes of each layer (and measures the error
+// bounds) with n goroutines; by default GOMAXPROCS goroutines are used and
+// n = 1 builds on the calling goroutine only
+func WithParallelism(n int) Option {
+	return func(c *config) {
+		c.parallelism = n
+	}
+}
+
+// WithBatchSorting lets GetIndexBatch evaluate unsorted batches in key
+// order, so that consecutive queries walk the same nodes and neighbouring
+// coefficients; this pays off for models too large for the cache (the
+// indices are still returned in the order of the batch)
+func WithBatchSorting() Option {
+	return func(c *config) {
+		c.batchSort = true
+	}
+}
+
+// workers returns the number of goroutines sharing the given number of independent jobs
+func (c *config) workers(jobs int) int {
+	n := c.parallelism
+	if n <= 0 {
+		n = runtime.GOMAXPROCS(0)
+	}
+
+	return min(n, jobs)
+}
+
+// shape returns the width and depth configured for NewRMIWithOptions
+func (c *config) shape(keys int) (int, int) {
+	width, depth := c.width, c.depth
+	if depth <= 0 {
+		depth = defaultDepth
+	}
+	if width <= 0 {
+		width = int(math.Max(1, math.Ceil(float64(keys)/defaultKeysPerLeaf)))
+		if depth > 2 {
+			width = int(math.Max(1, math.Ceil(math.Pow(float64(width), 1/float64(depth-1)))))
+		}
+	}
+
+	return width, depth
+}
+
+// modelOf returns the model type of the nodes of the given layer
+func (c *config) modelOf(layer int) ModelType {
+	t, ok := c.layerModels[layer]
+	if !ok && (c.endpointAll || c.endpointFit[layer]) {
+		t = ModelEndpoint
+	} else if !ok {
+		t = c.model
+	}
+
+	return t
+}
+
+// fitFor returns the training function of the nodes of the given layer
+func (c *config) fitFor(layer int) func(buildTask) *Node {
+	return c.fitOf(c.modelOf(layer))
+}
+
+// fitOf returns the training function of nodes of the given model type
+func (c *config) fitOf(t ModelType) func(buildTask) *Node {
+
+	// only the regressions suffer from ill-conditioned keys
+	switch t {
+	case ModelLinear:
+		return c.guarded(c.linear)
+	case ModelLogLinear:
+		return c.guarded(trainLogLinear)
+	case ModelEndpoint:
+		return trainEndpoints
+	case ModelConstant:
+		return trainConstant
+	case ModelAuto:
+		return c.auto()
+	}
+
+	return c.linear
+}
+
+// linear trains the linear model of a node at the configured precision
+func (c *config) linear(task buildTask) *Node {
+	return trainLinear(task, c.precision)
+}
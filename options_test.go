@@ -0,0 +1,119 @@
+package rmi
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+func TestNewRMIWithOptions(t *testing.T) {
+	values := sortedTestData(5000)
+
+	defaults, err := NewRMIWithOptions(values)
+	if err != nil {
+		t.Fatalf("Failed to build RMI %v\n", err)
+	}
+	if defaults.depth != defaultDepth || defaults.NumLeaves() != 50 {
+		t.Fatalf("unexpected default shape: depth %v with %v leaves", defaults.depth, defaults.NumLeaves())
+	}
+
+	positional, _ := NewRMI(values, 8, 3, WithEndpointFit())
+	for _, opts := range [][]Option{
+		{WithWidth(8), WithDepth(3), WithModelType(ModelEndpoint)},
+		{WithWidth(8), WithDepth(3), WithModelType(ModelEndpoint), WithParallelism(4)},
+	} {
+		rmi, err := NewRMIWithOptions(values, opts...)
+		if err != nil {
+			t.Fatalf("Failed to build RMI %v\n", err)
+		}
+
+		for i, value := range values {
+			if rmi.GetIndex(value) != positional.GetIndex(value) {
+				t.Fatalf("model built with options predicts key %v differently", i)
+			}
+		}
+	}
+}
+
+func TestWithPrecision(t *testing.T) {
+	values := sortedTestData(5000)
+
+	rmi, err := NewRMIWithOptions(values, WithPrecision(12), WithParallelism(3))
+	if err != nil {
+		t.Fatalf("Failed to build RMI %v\n", err)
+	}
+
+	for _, layer := range rmi.current.Load().nodes {
+		for _, node := range layer {
+			if node.m.Prec() != 12 || node.b.Prec() != 12 {
+				t.Fatalf("coefficients were not rounded to 12 bits")
+			}
+		}
+	}
+
+	// the error bounds are those of the rounded model
+	for i, value := range values {
+		if index, ok := rmi.Lookup(value); !ok || values[index].Cmp(value) != 0 {
+			t.Fatalf("Lookup of key %v failed", i)
+		}
+	}
+}
+
+func TestWithPrecisionLargeKeys(t *testing.T) {
+
+	// 256 bit keys in a dense run: 53 bit intercepts are off by 2^17 indices
+	r := rand.New(rand.NewSource(1))
+	base := new(big.Int).Rand(r, new(big.Int).Lsh(big.NewInt(1), 250))
+	base.SetBit(base, 250, 1)
+	values := make([]*big.Int, 5000)
+	for i := range values {
+		values[i] = new(big.Int).Lsh(big.NewInt(int64(i)), 180)
+		values[i].Add(values[i], new(big.Int).Rand(r, new(big.Int).Lsh(big.NewInt(1), 170)))
+		values[i].Add(values[i], base)
+	}
+
+	coarse, _ := NewRMI(values, 8, 2, WithIllConditioned(FallbackNone))
+	precise, err := NewRMI(values, 8, 2, WithIllConditioned(FallbackNone), WithPrecision(256))
+	if err != nil {
+		t.Fatalf("Failed to build RMI %v\n", err)
+	}
+
+	for _, layer := range precise.current.Load().coeffs {
+		for i := range layer.m {
+			if layer.m[i].Prec() != 256 || layer.b[i].Prec() != 256 {
+				t.Fatalf("coefficients of %v bits, expected 256", layer.m[i].Prec())
+			}
+		}
+	}
+
+	if coarse.MaxError() < len(values)/10 || precise.MaxError() > 10 {
+		t.Fatalf("expected 256 bit training to fix the max error %v, got %v", coarse.MaxError(), precise.MaxError())
+	}
+}
+
+func TestParallelBuild(t *testing.T) {
+	values := sortedTestData(20000)
+
+	sequential, err := NewRMI(values, 16, 3, WithParallelism(1))
+	if err != nil {
+		t.Fatalf("Failed to build RMI %v\n", err)
+	}
+
+	parallel, err := NewRMI(values, 16, 3, WithParallelism(4))
+	if err != nil {
+		t.Fatalf("Failed to build RMI %v\n", err)
+	}
+
+	for i, value := range values {
+		if parallel.GetIndex(value) != sequential.GetIndex(value) {
+			t.Fatalf("parallel build predicts key %v differently", i)
+		}
+	}
+
+	seqErrs := sequential.errorsOf(sequential.current.Load())
+	for loc, e := range parallel.errorsOf(parallel.current.Load()) {
+		if e != seqErrs[loc] {
+			t.Fatalf("parallel error bounds of leaf %v are %+v, expected %+v", loc, e, seqErrs[loc])
+		}
+	}
+}
@@ -0,0 +1,160 @@
+// partition.go: composition of learned indexes below an existing coarse
+// partitioner. The first stage is a caller-supplied router (e.g., the
+// partition map of a sharding scheme) that is used as is; an RMI is trained
+// over the keys of every partition and the global index of a key is its
+// index in its partition plus the number of keys in preceding partitions.
+
+package rmi
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+)
+
+// Router is a first-stage partitioner of the keys; Route must be monotone
+// (a <= b implies Route(a) <= Route(b)) and return a partition in [0, Partitions())
+type Router interface {
+	Partitions() int
+	Route(value *big.Int) int
+}
+
+// RangeRouter is a range partition map: partition i holds the keys in
+// [RangeRouter[i-1], RangeRouter[i]) for the sorted split keys
+type RangeRouter []*big.Int
+
+// Partitions returns the number of partitions of the range map
+func (r RangeRouter) Partitions() int {
+	return len(r) + 1
+}
+
+// Route returns the partition of the range map holding value
+func (r RangeRouter) Route(value *big.Int) int {
+	return sort.Search(len(r), func(i int) bool {
+		return r[i].Cmp(value) == 1
+	})
+}
+
+/*
+PartitionedRMI is an index composed of one RMI per partition of a Router
+router: the first stage routing keys to partitions
+parts: the RMI over the keys of each partition (nil for an empty partition)
+offsets: global index of the first key of each partition
+*/
+type PartitionedRMI struct {
+	router  Router
+	parts   []*RMI
+	offsets []int
+}
+
+// NewPartitionedRMI trains an RMI of the given width and depth over the keys
+// of each partition of router (clamping the shape for small partitions, see
+// WithClampShape); values must be sorted and are retained (not copied)
+func NewPartitionedRMI(
+	values []*big.Int,
+	router Router,
+	width int,
+	depth int,
+	opts ...Option) (*PartitionedRMI, error) {
+
+	if err := checkSorted(values); err != nil {
+		return nil, err
+	}
+
+	n := router.Partitions()
+	if n < 1 {
+		return nil, errors.New("router must have at least one partition")
+	}
+
+	p := &PartitionedRMI{router: router, parts: make([]*RMI, n), offsets: make([]int, n+1)}
+	opts = append(append([]Option{}, opts...), WithClampShape())
+
+	// keys are sorted and routing is monotone, so partitions are consecutive
+	start, prev := 0, 0
+	for i := 0; i <= len(values); i++ {
+		part := n
+		if i < len(values) {
+			if part = router.Route(values[i]); part < prev || part >= n {
+				return nil, fmt.Errorf("router is not monotone or out of range at key %v", i)
+			}
+		}
+
+		if part == prev {
+			continue
+		}
+
+		if err := p.train(prev, values[start:i], width, depth, opts); err != nil {
+			return nil, err
+		}
+		for j := prev + 1; j <= part; j++ {
+			p.offsets[j] = i
+		}
+		start, prev = i, part
+	}
+
+	return p, nil
+}
+
+// train builds the RMI of partition part over its keys
+func (p *PartitionedRMI) train(part int, values []*big.Int, width, depth int, opts []Option) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	partition, err := NewRMI(values, width, depth, opts...)
+	if err != nil {
+		return fmt.Errorf("partition %v: %w", part, err)
+	}
+
+	p.parts[part] = partition
+	return nil
+}
+
+// Partition returns the RMI trained over the keys of a partition
+// (nil if the partition has no keys)
+func (p *PartitionedRMI) Partition(part int) *RMI {
+	return p.parts[part]
+}
+
+// route returns the partition of value clamped to the valid range
+func (p *PartitionedRMI) route(value *big.Int) int {
+	return int(clampInt64(int64(p.router.Route(value)), int64(len(p.parts)-1)))
+}
+
+// GetIndex returns the approximate global index of value
+func (p *PartitionedRMI) GetIndex(value *big.Int) int {
+	part := p.route(value)
+	if p.parts[part] == nil {
+		return p.offsets[part]
+	}
+
+	return p.offsets[part] + p.parts[part].GetIndex(value)
+}
+
+// Lookup returns the global index of the first live occurrence
+// of value (or false if it is absent)
+func (p *PartitionedRMI) Lookup(value *big.Int) (int, bool) {
+	part := p.route(value)
+	if p.parts[part] == nil {
+		return 0, false
+	}
+
+	index, ok := p.parts[part].Lookup(value)
+	if !ok {
+		return 0, false
+	}
+
+	return p.offsets[part] + index, true
+}
+
+// clampInt64 clamps x to [0, max]
+func clampInt64(x, max int64) int64 {
+	if x < 0 {
+		return 0
+	} else if x > max {
+		return max
+	}
+
+	return x
+}
@@ -0,0 +1,60 @@
+package rmi
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestPartitionedRMI(t *testing.T) {
+	values := sortedTestData(5000)
+
+	// uneven partitions, with an empty one below all keys and one above
+	router := RangeRouter{
+		new(big.Int).Sub(values[0], big.NewInt(1)),
+		values[100],
+		values[101],
+		values[3000],
+		new(big.Int).Add(values[len(values)-1], big.NewInt(1)),
+	}
+
+	p, err := NewPartitionedRMI(values, router, 8, 2)
+	if err != nil {
+		t.Fatalf("Failed to build partitioned RMI %v\n", err)
+	}
+
+	if p.Partition(0) != nil || p.Partition(5) != nil {
+		t.Fatalf("expected the outer partitions to be empty")
+	}
+
+	for i, value := range values {
+		index, ok := p.Lookup(value)
+		if !ok || values[index].Cmp(value) != 0 || (index > 0 && values[index-1].Cmp(value) == 0) {
+			t.Fatalf("Lookup of key %v returned %v, %v", i, index, ok)
+		}
+
+		if abs(p.GetIndex(value)-i) > p.Partition(router.Route(value)).MaxError()+abs(index-i) {
+			t.Fatalf("GetIndex of key %v is off by more than its partition's error", i)
+		}
+	}
+
+	if _, ok := p.Lookup(new(big.Int).Add(values[len(values)-1], big.NewInt(5))); ok {
+		t.Fatalf("found key above all keys")
+	}
+
+	// routers must be monotone
+	if _, err := NewPartitionedRMI(values, reverseRouter{}, 8, 2); err == nil {
+		t.Fatalf("expected a non-monotone router to be rejected")
+	}
+}
+
+// routes the smaller half of the keys to the second partition
+type reverseRouter struct{}
+
+func (reverseRouter) Partitions() int { return 2 }
+
+func (reverseRouter) Route(value *big.Int) int {
+	if value.Cmp(big.NewInt(MaxDataValue/2)) == -1 {
+		return 1
+	}
+	return 0
+}
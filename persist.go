@@ -0,0 +1,511 @@
+// persist.go: saving and loading trained models.
+// A saved model starts with a header recording the key type, arithmetic
+// mode, builder version, model types, precision and the options that
+// change its answers; loading restores those options and refuses models
+// whose header is incompatible with what the caller expects.
+
+package rmi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// metadata of the models built by this package
+const (
+	KeyTypeBigInt      = "big.Int"
+	ArithmeticBigFloat = "big.Float"
+	BuilderVersion     = "2"
+)
+
+// options recorded in Metadata.Options
+const (
+	optionLastMile      = "last-mile"
+	optionNeighborClamp = "neighbor-clamp"
+)
+
+// magic bytes at the start of every saved model
+var modelMagic = []byte("RMI\x00")
+
+/*
+Metadata records how a model was built
+KeyType: type of the keys (e.g., KeyTypeBigInt)
+Arithmetic: arithmetic used to train and evaluate the models (e.g., ArithmeticBigFloat)
+BuilderVersion: version of the builder, models are only compatible within a version
+Models: model types of the layers, root first and comma separated (e.g., "linear,linear")
+Precision: number of bits the coefficients were rounded to (0 if they were not)
+Options: options changing the answers of the model, comma separated (e.g., "last-mile")
+*/
+type Metadata struct {
+	KeyType        string
+	Arithmetic     string
+	BuilderVersion string
+	Models         string
+	Precision      uint
+	Options        string
+}
+
+// Metadata returns the metadata of the model
+func (rmi *RMI) Metadata() Metadata {
+
+	models := make([]string, rmi.depth)
+	for layer := range models {
+		models[layer] = rmi.conf.modelOf(layer).String()
+	}
+
+	options := make([]string, 0)
+	if rmi.conf.lastMile {
+		options = append(options, optionLastMile)
+	}
+	if rmi.conf.neighborClamp {
+		options = append(options, optionNeighborClamp)
+	}
+
+	return Metadata{
+		KeyType:        KeyTypeBigInt,
+		Arithmetic:     ArithmeticBigFloat,
+		BuilderVersion: BuilderVersion,
+		Models:         strings.Join(models, ","),
+		Precision:      rmi.conf.precision,
+		Options:        strings.Join(options, ","),
+	}
+}
+
+// Check returns an error if the model described by m cannot be served
+// by a caller that expects the given metadata (empty fields and a zero
+// precision match anything)
+func (m Metadata) Check(expected Metadata) error {
+	if expected.KeyType != "" && m.KeyType != expected.KeyType {
+		return fmt.Errorf("model has key type %q but %q was expected", m.KeyType, expected.KeyType)
+	}
+
+	if expected.Arithmetic != "" && m.Arithmetic != expected.Arithmetic {
+		return fmt.Errorf("model uses %q arithmetic but %q was expected", m.Arithmetic, expected.Arithmetic)
+	}
+
+	if expected.BuilderVersion != "" && m.BuilderVersion != expected.BuilderVersion {
+		return fmt.Errorf("model was built by builder version %q but %q was expected", m.BuilderVersion, expected.BuilderVersion)
+	}
+
+	if expected.Models != "" && m.Models != expected.Models {
+		return fmt.Errorf("model has layers of type %q but %q was expected", m.Models, expected.Models)
+	}
+
+	if expected.Precision != 0 && m.Precision != expected.Precision {
+		return fmt.Errorf("model has %v bit coefficients but %v bits were expected", m.Precision, expected.Precision)
+	}
+
+	if expected.Options != "" && m.Options != expected.Options {
+		return fmt.Errorf("model was built with options %q but %q was expected", m.Options, expected.Options)
+	}
+
+	return nil
+}
+
+// appends a length prefixed string
+func appendString(buf []byte, s string) []byte {
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(s)))
+	return append(buf, s...)
+}
+
+// reads a length prefixed string
+func readString(data []byte) (string, []byte, error) {
+	if len(data) < 4 {
+		return "", nil, errShortBuffer
+	}
+
+	// compared before converting, a length above 2^31 is negative as a 32 bit int
+	n := binary.BigEndian.Uint32(data)
+	if uint64(len(data)-4) < uint64(n) {
+		return "", nil, errShortBuffer
+	}
+
+	return string(data[4 : 4+n]), data[4+n:], nil
+}
+
+// encode writes the current version of the model: magic, metadata,
+// width, depth, maxIndex, the rest of the metadata and the options it
+// records, all nodes layer by layer, the per-leaf error bounds (so loaded
+// models can be verified, see SelfTest), the calibration and the last-mile
+// tables of the leaves
+func (rmi *RMI) encode() []byte {
+
+	v := rmi.current.Load()
+	meta := rmi.Metadata()
+
+	buf := append(make([]byte, 0), modelMagic...)
+	buf = appendString(buf, meta.KeyType)
+	buf = appendString(buf, meta.Arithmetic)
+	buf = appendString(buf, meta.BuilderVersion)
+
+	buf = binary.BigEndian.AppendUint32(buf, uint32(rmi.width))
+	buf = binary.BigEndian.AppendUint32(buf, uint32(rmi.depth))
+	buf = binary.BigEndian.AppendUint64(buf, uint64(int64(v.maxIndex)))
+
+	buf = appendString(buf, meta.Models)
+	buf = appendString(buf, meta.Options)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(meta.Precision))
+	buf = binary.BigEndian.AppendUint64(buf, math.Float64bits(rmi.conf.lastMileError))
+	buf = binary.BigEndian.AppendUint64(buf, uint64(int64(rmi.conf.bucketSize)))
+
+	for _, layer := range v.nodes {
+		buf = binary.BigEndian.AppendUint64(buf, uint64(len(layer)))
+		for _, node := range layer {
+			buf = appendNode(buf, node)
+		}
+	}
+
+	for _, e := range rmi.errorsOf(v) {
+		for _, field := range []int{e.count, e.minResidual, e.maxResidual, e.first, e.last} {
+			buf = binary.BigEndian.AppendUint64(buf, uint64(int64(field)))
+		}
+		buf = binary.BigEndian.AppendUint64(buf, math.Float64bits(e.sumAbs))
+		buf = binary.BigEndian.AppendUint64(buf, math.Float64bits(e.sumSq))
+	}
+
+	if v.calib == nil {
+		buf = append(buf, 0)
+	} else {
+		buf = append(buf, 1)
+		buf = binary.BigEndian.AppendUint64(buf, uint64(len(v.calib.x)))
+		for i := range v.calib.x {
+			buf = binary.BigEndian.AppendUint64(buf, math.Float64bits(v.calib.x[i]))
+			buf = binary.BigEndian.AppendUint64(buf, math.Float64bits(v.calib.y[i]))
+		}
+	}
+
+	// table entries in key order so equal models encode equally
+	for _, leaf := range v.nodes[rmi.depth-1] {
+		keys := make([]string, 0, len(leaf.exact))
+		for key := range leaf.exact {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		buf = binary.BigEndian.AppendUint64(buf, uint64(len(keys)))
+		for _, key := range keys {
+			buf = appendString(buf, key)
+			buf = binary.BigEndian.AppendUint64(buf, uint64(int64(leaf.exact[key])))
+		}
+	}
+
+	return buf
+}
+
+// decode reads a model written by encode after checking its metadata against expected
+func decode(data []byte, expected Metadata) (*RMI, error) {
+
+	if !bytes.HasPrefix(data, modelMagic) {
+		return nil, errors.New("data is not a saved RMI model")
+	}
+	data = data[len(modelMagic):]
+
+	meta := Metadata{}
+	var err error
+	for _, field := range []*string{&meta.KeyType, &meta.Arithmetic, &meta.BuilderVersion} {
+		if *field, data, err = readString(data); err != nil {
+			return nil, err
+		}
+	}
+
+	// this builder only evaluates models it knows how to evaluate
+	builder := Metadata{KeyType: KeyTypeBigInt, Arithmetic: ArithmeticBigFloat, BuilderVersion: BuilderVersion}
+	if err := meta.Check(builder); err != nil {
+		return nil, err
+	}
+
+	if len(data) < 16 {
+		return nil, errShortBuffer
+	}
+
+	rmi := &RMI{}
+	rmi.width = int(binary.BigEndian.Uint32(data[0:4]))
+	rmi.depth = int(binary.BigEndian.Uint32(data[4:8]))
+	maxIndex, err := platformInt(binary.BigEndian.Uint64(data[8:16]))
+	data = data[16:]
+	if err != nil {
+		return nil, err
+	}
+
+	if rmi.width < 1 || rmi.depth < 1 {
+		return nil, errors.New("invalid model shape")
+	}
+
+	for _, field := range []*string{&meta.Models, &meta.Options} {
+		if *field, data, err = readString(data); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(data) < 20 {
+		return nil, errShortBuffer
+	}
+	meta.Precision = uint(binary.BigEndian.Uint32(data))
+	rmi.conf.lastMileError = math.Float64frombits(binary.BigEndian.Uint64(data[4:]))
+	if rmi.conf.bucketSize, err = platformInt(binary.BigEndian.Uint64(data[12:])); err != nil {
+		return nil, err
+	}
+	data = data[20:]
+
+	if err := meta.Check(expected); err != nil {
+		return nil, err
+	} else if err := rmi.restore(meta); err != nil {
+		return nil, err
+	}
+
+	nodes := make([][]*Node, rmi.depth)
+	expectedSize := uint64(1)
+	for i := range nodes {
+		if len(data) < 8 {
+			return nil, errShortBuffer
+		}
+
+		size := binary.BigEndian.Uint64(data)
+		data = data[8:]
+		if size != expectedSize || size > uint64(len(data)) {
+			return nil, errors.New("invalid layer size")
+		}
+
+		nodes[i] = make([]*Node, size)
+		for j := range nodes[i] {
+			if nodes[i][j], data, err = readNode(data); err != nil {
+				return nil, err
+			}
+		}
+		expectedSize *= uint64(rmi.width)
+	}
+
+	v := newVersion(0, nodes, maxIndex)
+
+	// error bounds recorded when the model was saved
+	leafErrors := make([]leafError, len(nodes[rmi.depth-1]))
+	if uint64(len(data))/56 < uint64(len(leafErrors)) {
+		return nil, errShortBuffer
+	}
+
+	for i := range leafErrors {
+		fields := make([]int, 5)
+		for j := range fields {
+			if fields[j], err = platformInt(binary.BigEndian.Uint64(data[8*j:])); err != nil {
+				return nil, err
+			}
+		}
+
+		leafErrors[i] = leafError{
+			count:       fields[0],
+			minResidual: fields[1],
+			maxResidual: fields[2],
+			first:       fields[3],
+			last:        fields[4],
+			sumAbs:      math.Float64frombits(binary.BigEndian.Uint64(data[40:])),
+			sumSq:       math.Float64frombits(binary.BigEndian.Uint64(data[48:])),
+		}
+		data = data[56:]
+	}
+	v.errs.once.Do(func() { v.errs.leaves = leafErrors })
+
+	if len(data) < 1 {
+		return nil, errShortBuffer
+	}
+
+	if data[0] == 1 {
+		data = data[1:]
+		if len(data) < 8 {
+			return nil, errShortBuffer
+		}
+
+		n := binary.BigEndian.Uint64(data)
+		data = data[8:]
+		if n > uint64(len(data))/16 {
+			return nil, errShortBuffer
+		}
+
+		v.calib = &calibration{make([]float64, n), make([]float64, n)}
+		for i := range v.calib.x {
+			v.calib.x[i] = math.Float64frombits(binary.BigEndian.Uint64(data[16*i:]))
+			v.calib.y[i] = math.Float64frombits(binary.BigEndian.Uint64(data[16*i+8:]))
+		}
+		data = data[16*n:]
+	} else {
+		data = data[1:]
+	}
+
+	for _, leaf := range nodes[rmi.depth-1] {
+		if data, err = readExactTable(data, leaf, maxIndex); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(data) != 0 {
+		return nil, errors.New("unexpected data after the model")
+	}
+
+	rmi.current.Store(v.pack(0))
+
+	return rmi, nil
+}
+
+// restore sets the configuration of a decoded model to the one recorded by meta
+func (rmi *RMI) restore(meta Metadata) error {
+
+	models := strings.Split(meta.Models, ",")
+	if len(models) != rmi.depth {
+		return fmt.Errorf("model records %v layer types for %v layers", len(models), rmi.depth)
+	}
+
+	rmi.conf.layerModels = make(map[int]ModelType, rmi.depth)
+	for layer, name := range models {
+		t, err := modelTypeOf(name)
+		if err != nil {
+			return err
+		}
+		rmi.conf.layerModels[layer] = t
+	}
+	rmi.conf.precision = meta.Precision
+
+	if meta.Options == "" {
+		return nil
+	}
+
+	for _, option := range strings.Split(meta.Options, ",") {
+		switch option {
+		case optionLastMile:
+			rmi.conf.lastMile = true
+		case optionNeighborClamp:
+			rmi.conf.neighborClamp = true
+		default:
+			return fmt.Errorf("model was built with unknown option %q", option)
+		}
+	}
+
+	return nil
+}
+
+// modelTypeOf returns the model type named as by ModelType.String
+func modelTypeOf(name string) (ModelType, error) {
+
+	for _, t := range []ModelType{ModelLinear, ModelEndpoint, ModelLogLinear, ModelConstant, ModelAuto} {
+		if t.String() == name {
+			return t, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown model type %q", name)
+}
+
+// readExactTable decodes the last-mile table of leaf (possibly empty) and
+// returns the rest of data
+func readExactTable(data []byte, leaf *Node, maxIndex int) ([]byte, error) {
+
+	if len(data) < 8 {
+		return nil, errShortBuffer
+	}
+
+	n := binary.BigEndian.Uint64(data)
+	data = data[8:]
+	if n == 0 {
+		return data, nil
+	} else if n > uint64(len(data))/12 {
+		return nil, errShortBuffer
+	}
+
+	leaf.exact = make(map[string]int, n)
+	if leaf.alt != nil {
+		leaf.alt.exact = leaf.exact
+	}
+
+	for ; n > 0; n-- {
+		key, rest, err := readString(data)
+		if err != nil {
+			return nil, err
+		} else if len(rest) < 8 {
+			return nil, errShortBuffer
+		}
+
+		index, err := platformInt(binary.BigEndian.Uint64(rest))
+		if err != nil {
+			return nil, err
+		} else if index < 0 || index > maxIndex {
+			return nil, errors.New("invalid last-mile table index")
+		}
+
+		leaf.exact[key] = index
+		data = rest[8:]
+	}
+
+	return data, nil
+}
+
+// platformInt converts an encoded int64 to an int, failing if the value does
+// not fit the int of this platform (a model over more than 2^31 keys saved on
+// a 64 bit platform cannot be served on a 32 bit platform)
+func platformInt(encoded uint64) (int, error) {
+	x := int64(encoded)
+	if x > math.MaxInt || x < math.MinInt {
+		return 0, fmt.Errorf("encoded value %v does not fit the int size of this platform", x)
+	}
+
+	return int(x), nil
+}
+
+// Save writes the trained model (but not the keys) to w
+func (rmi *RMI) Save(w io.Writer) error {
+	_, err := w.Write(rmi.encode())
+	return err
+}
+
+// MarshalBinary encodes the trained model (but not the keys) as Save writes it
+func (rmi *RMI) MarshalBinary() ([]byte, error) {
+	return rmi.encode(), nil
+}
+
+// UnmarshalBinary decodes a model written by MarshalBinary into rmi, which
+// must not be in use; the keys are not restored (see Load)
+func (rmi *RMI) UnmarshalBinary(data []byte) error {
+
+	decoded, err := decode(data, Metadata{})
+	if err != nil {
+		return err
+	}
+
+	rmi.width, rmi.depth, rmi.values, rmi.conf = decoded.width, decoded.depth, nil, decoded.conf
+	rmi.current.Store(decoded.current.Load())
+
+	return nil
+}
+
+// UnmarshalRMI decodes a model written by MarshalBinary (or Save);
+// the model serves GetIndex, exact queries need the keys (see Load)
+func UnmarshalRMI(data []byte) (*RMI, error) {
+	return decode(data, Metadata{})
+}
+
+// Load reads a model written by Save and refuses it if its metadata is
+// incompatible with expected; values are the keys the model was built over
+// (needed by exact queries) or nil if only GetIndex will be used
+func Load(r io.Reader, values []*big.Int, expected Metadata) (*RMI, error) {
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	rmi, err := decode(data, expected)
+	if err != nil {
+		return nil, err
+	}
+
+	if values != nil && len(values) != rmi.current.Load().maxIndex+1 {
+		return nil, fmt.Errorf("model was built over %v keys but %v were provided", rmi.current.Load().maxIndex+1, len(values))
+	}
+	rmi.values = values
+
+	return rmi, nil
+}
@@ -0,0 +1,90 @@
+package rmi
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSaveLoad(t *testing.T) {
+	values := sortedTestData(2000)
+	rmi, _ := NewRMI(values, RMIWidthParameter, 3, WithCalibration(), WithLeafEnsemble())
+
+	var buf bytes.Buffer
+	if err := rmi.Save(&buf); err != nil {
+		t.Fatalf("failed to save %v", err)
+	}
+
+	loaded, err := Load(bytes.NewReader(buf.Bytes()), values, rmi.Metadata())
+	if err != nil {
+		t.Fatalf("failed to load %v", err)
+	}
+
+	for _, value := range values {
+		if loaded.GetIndex(value) != rmi.GetIndex(value) {
+			t.Fatalf("loaded model predicts differently")
+		}
+	}
+
+	if loaded.Fingerprint().Internal != rmi.Fingerprint().Internal {
+		t.Fatalf("loaded model has different coefficients")
+	}
+
+	if _, err := Load(bytes.NewReader(buf.Bytes()), values[1:], Metadata{}); err == nil {
+		t.Fatalf("expected mismatched keys to be rejected")
+	}
+
+	if _, err := Load(bytes.NewReader(buf.Bytes()[:buf.Len()-5]), nil, Metadata{}); err == nil {
+		t.Fatalf("expected truncated model to be rejected")
+	}
+}
+
+func TestLoadIncompatible(t *testing.T) {
+	values := sortedTestData(100)
+	rmi, _ := NewRMI(values, 4, 2)
+
+	var buf bytes.Buffer
+	rmi.Save(&buf)
+
+	for _, expected := range []Metadata{
+		{KeyType: "uint64"},
+		{Arithmetic: "float64"},
+		{BuilderVersion: "0"},
+		{Models: "linear,endpoint"},
+		{Precision: 32},
+		{Options: optionLastMile},
+	} {
+		if _, err := Load(bytes.NewReader(buf.Bytes()), values, expected); err == nil {
+			t.Fatalf("expected model to be incompatible with %+v", expected)
+		}
+	}
+}
+
+func TestMarshalBinary(t *testing.T) {
+	values := sortedTestData(1000)
+	rmi, _ := NewRMI(values, RMIWidthParameter, 3)
+
+	data, err := rmi.MarshalBinary()
+	if err != nil {
+		t.Fatalf("failed to marshal %v", err)
+	}
+
+	unmarshaled, err := UnmarshalRMI(data)
+	if err != nil {
+		t.Fatalf("failed to unmarshal %v", err)
+	}
+
+	decoded := &RMI{}
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatalf("failed to unmarshal %v", err)
+	}
+
+	for _, value := range values {
+		if unmarshaled.GetIndex(value) != rmi.GetIndex(value) || decoded.GetIndex(value) != rmi.GetIndex(value) {
+			t.Fatalf("unmarshaled model predicts differently")
+		}
+	}
+
+	if _, err := UnmarshalRMI(data[:len(data)/2]); err == nil {
+		t.Fatalf("expected truncated model to be rejected")
+	}
+}
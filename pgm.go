@@ -0,0 +1,193 @@
+// pgm.go: error-bounded piecewise linear index in the style of the PGM-index.
+// Instead of a fixed-fanout recursion of regressions, the keys are covered
+// greedily by the fewest segments (with the shrinking cone of feasible slopes)
+// whose predictions are within eps of the index of every key, and the first
+// keys of the segments are indexed the same way, level by level, until a
+// single segment remains. Every level thus has a provable error bound and
+// the number of models adapts to the data instead of being fixed upfront.
+
+package rmi
+
+import (
+	"errors"
+	"math/big"
+)
+
+// error bound of the levels above the keys
+const pgmLevelEps = 4
+
+// precision of the slopes of the segments
+const pgmPrecision = 128
+
+/*
+Segment of a PGM covering the keys from key to the next segment's key
+key: first key covered by the segment
+index: index of key in the level below
+slope: predicted index per unit of key
+*/
+type pgmSegment struct {
+	key   *big.Int
+	index int
+	slope *big.Float
+}
+
+/*
+PGM is an error-bounded piecewise linear index over sorted keys
+eps: max distance between the predicted and the true index of every key
+values: the sorted keys (retained, not copied)
+levels: the segments of every level, from the segments over the keys up
+to the single segment of the root
+starts: the first keys of the segments of every level
+*/
+type PGM struct {
+	eps    int
+	values []*big.Int
+	levels [][]pgmSegment
+	starts [][]*big.Int
+}
+
+// NewPGM builds the piecewise linear index over values (which must be
+// sorted) with predictions within eps of the index of the first occurrence
+// of every key
+func NewPGM(values []*big.Int, eps int) (*PGM, error) {
+
+	if err := checkSorted(values); err != nil {
+		return nil, err
+	}
+
+	if len(values) == 0 {
+		return nil, errors.New("cannot build an index over no keys")
+	}
+
+	if eps < 1 {
+		return nil, errors.New("error bound must be at least 1")
+	}
+
+	p := &PGM{eps: eps, values: values}
+
+	keys, levelEps := values, eps
+	for {
+		segments := pgmSegments(keys, levelEps)
+
+		keys = make([]*big.Int, len(segments))
+		for i, s := range segments {
+			keys[i] = s.key
+		}
+		p.levels = append(p.levels, segments)
+		p.starts = append(p.starts, keys)
+
+		if len(segments) == 1 {
+			break
+		}
+		levelEps = pgmLevelEps
+	}
+
+	return p, nil
+}
+
+// pgmSegments covers the first occurrence of every key by the fewest
+// segments predicting each index within eps, keeping for every segment
+// the cone [lo, hi] of the slopes that fit all its keys so far
+func pgmSegments(keys []*big.Int, eps int) []pgmSegment {
+
+	segments := make([]pgmSegment, 0)
+	lo, hi := new(big.Float), new(big.Float)
+	dx := new(big.Float).SetPrec(pgmPrecision)
+	up := new(big.Float).SetPrec(pgmPrecision)
+	down := new(big.Float).SetPrec(pgmPrecision)
+
+	// closes the current segment with a slope in the middle of its cone
+	closeSegment := func() {
+		s := &segments[len(segments)-1]
+		s.slope = new(big.Float).SetPrec(pgmPrecision)
+		if !hi.IsInf() {
+			s.slope.Add(lo, hi).Quo(s.slope, big.NewFloat(2))
+		}
+	}
+
+	for i, key := range keys {
+		if i > 0 && keys[i-1].Cmp(key) == 0 {
+			continue
+		}
+
+		if len(segments) > 0 {
+			s := segments[len(segments)-1]
+			dx.SetInt(new(big.Int).Sub(key, s.key))
+			down.SetInt64(int64(i-eps-s.index)).Quo(down, dx)
+			up.SetInt64(int64(i+eps-s.index)).Quo(up, dx)
+
+			// the key fits if its slopes intersect the cone
+			if down.Cmp(hi) <= 0 && up.Cmp(lo) >= 0 {
+				if down.Cmp(lo) == 1 {
+					lo.Set(down)
+				}
+				if up.Cmp(hi) == -1 {
+					hi.Set(up)
+				}
+				continue
+			}
+			closeSegment()
+		}
+
+		segments = append(segments, pgmSegment{key: key, index: i})
+		lo.SetPrec(pgmPrecision).SetInt64(0)
+		hi.SetPrec(pgmPrecision).SetInf(false)
+	}
+	closeSegment()
+
+	return segments
+}
+
+// predict returns the index of value predicted by the segment, clamped to [0, max]
+func (s *pgmSegment) predict(value *big.Int, max int) int {
+	res := new(big.Float).SetPrec(pgmPrecision).SetInt(new(big.Int).Sub(value, s.key))
+	res.Mul(res, s.slope)
+	res.Add(res, new(big.Float).SetInt64(int64(s.index)))
+
+	return clampIndex(res, max)
+}
+
+// GetIndex returns the approximate index of value, within the error
+// bound of the first occurrence of value if it is one of the keys
+func (p *PGM) GetIndex(value *big.Int) int {
+
+	// route down the levels: the segment of value in a level is the last
+	// one starting at or below it, found from the prediction of the level above
+	segment := &p.levels[len(p.levels)-1][0]
+	next := new(big.Int).Add(value, big.NewInt(1))
+	for level := len(p.levels) - 2; level >= 0; level-- {
+		guess := segment.predict(value, len(p.levels[level])-1)
+		index := lowerBoundFrom(p.starts[level], next, guess) - 1
+		segment = &p.levels[level][max(0, index)]
+	}
+
+	return segment.predict(value, len(p.values)-1)
+}
+
+// GetIndexWithBounds returns the window [lo, hi] guaranteed
+// to contain the index of value if it is one of the keys
+func (p *PGM) GetIndexWithBounds(value *big.Int) (int, int) {
+	index := p.GetIndex(value)
+	return max(0, index-p.eps), min(len(p.values)-1, index+p.eps)
+}
+
+// Lookup returns the index of the first occurrence of value (or false if absent)
+func (p *PGM) Lookup(value *big.Int) (int, bool) {
+	index := lowerBoundFrom(p.values, value, p.GetIndex(value))
+	if index < len(p.values) && p.values[index].Cmp(value) == 0 {
+		return index, true
+	}
+
+	return 0, false
+}
+
+// Segments returns the number of segments of every level, from the
+// level over the keys up to the root
+func (p *PGM) Segments() []int {
+	counts := make([]int, len(p.levels))
+	for i, level := range p.levels {
+		counts[i] = len(level)
+	}
+
+	return counts
+}
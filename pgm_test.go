@@ -0,0 +1,66 @@
+package rmi
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestPGM(t *testing.T) {
+	values := sortedTestData(20000)
+
+	for _, eps := range []int{1, 8, 64} {
+		p, err := NewPGM(values, eps)
+		if err != nil {
+			t.Fatalf("Failed to build PGM %v\n", err)
+		}
+
+		for i, value := range values {
+			first := i
+			for first > 0 && values[first-1].Cmp(value) == 0 {
+				first--
+			}
+
+			if abs(p.GetIndex(value)-first) > eps {
+				t.Fatalf("eps = %v: prediction %v of key %v exceeds the error bound", eps, p.GetIndex(value), first)
+			}
+
+			if index, ok := p.Lookup(value); !ok || index != first {
+				t.Fatalf("eps = %v: Lookup of key %v returned %v, %v", eps, first, index, ok)
+			}
+		}
+
+		// queries between the keys find their lower bound
+		between := new(big.Int).Add(values[100], big.NewInt(1))
+		if _, ok := p.Lookup(between); ok && values[101].Cmp(between) != 0 {
+			t.Fatalf("found a key absent from the index")
+		}
+
+		segments := p.Segments()
+		if segments[len(segments)-1] != 1 {
+			t.Fatalf("PGM has no single root segment: %v", segments)
+		}
+	}
+
+	// the same query interface as the RMI
+	var index Index
+	index, _ = NewPGM(values, 16)
+	if index.GetIndex(values[0]) > 16 {
+		t.Fatalf("unexpected prediction of the smallest key")
+	}
+
+	if _, err := NewPGM(values, 0); err == nil {
+		t.Fatalf("expected an error bound of 0 to be rejected")
+	}
+}
+
+func TestPGMFewerModels(t *testing.T) {
+	values := sortedTestData(20000)
+
+	p, _ := NewPGM(values, 32)
+	rmi, _ := NewRMI(values, 200, 2)
+
+	// far fewer segments than leaves for a guaranteed bound on uniform keys
+	if p.Segments()[0]*10 >= rmi.NumLeaves() {
+		t.Fatalf("PGM uses %v segments, the RMI %v leaves with max error %v", p.Segments()[0], rmi.NumLeaves(), rmi.MaxError())
+	}
+}
@@ -0,0 +1,142 @@
+// policy.go: refresh policy engine deciding when an updatable index is
+// compacted: pluggable triggers (time, insert count, drift, or custom)
+// are evaluated against the index state and any firing trigger refreshes
+
+package rmi
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+/*
+RefreshStats is the state of an updatable index that triggers decide on
+Staged: number of inserted keys not yet compacted
+Elapsed: time since the last refresh (or since the policy was created)
+DriftAlerts: number of drift alerts reported since the last refresh
+*/
+type RefreshStats struct {
+	Staged      int
+	Elapsed     time.Duration
+	DriftAlerts int
+}
+
+// Trigger decides whether the index should be refreshed
+type Trigger interface {
+	ShouldRefresh(stats RefreshStats) bool
+}
+
+// TriggerFunc adapts a function to a Trigger
+type TriggerFunc func(stats RefreshStats) bool
+
+// ShouldRefresh calls f(stats)
+func (f TriggerFunc) ShouldRefresh(stats RefreshStats) bool {
+	return f(stats)
+}
+
+// Every fires once interval has passed since the last refresh
+func Every(interval time.Duration) Trigger {
+	return TriggerFunc(func(stats RefreshStats) bool {
+		return stats.Elapsed >= interval
+	})
+}
+
+// AfterInserts fires once at least n keys are staged
+func AfterInserts(n int) Trigger {
+	return TriggerFunc(func(stats RefreshStats) bool {
+		return stats.Staged >= n
+	})
+}
+
+// OnDrift fires once at least n drift alerts were reported (see ReportDrift)
+func OnDrift(n int) Trigger {
+	return TriggerFunc(func(stats RefreshStats) bool {
+		return stats.DriftAlerts >= n
+	})
+}
+
+/*
+RefreshPolicy refreshes an index when any of its triggers fires
+triggers: the triggers evaluated by Check
+mu: guards the fields below
+last: time of the last refresh
+drifts: number of drift alerts since the last refresh
+refreshes: total number of refreshes
+*/
+type RefreshPolicy struct {
+	triggers []Trigger
+
+	mu        sync.Mutex
+	last      time.Time
+	drifts    int
+	refreshes int
+}
+
+// NewRefreshPolicy returns a policy refreshing when any trigger fires
+func NewRefreshPolicy(triggers ...Trigger) *RefreshPolicy {
+	return &RefreshPolicy{triggers: triggers, last: time.Now()}
+}
+
+// ReportDrift records a drift alert; it can be passed as
+// the alert function of WithDriftMonitor
+func (p *RefreshPolicy) ReportDrift(alert DriftAlert) {
+	p.mu.Lock()
+	p.drifts++
+	p.mu.Unlock()
+}
+
+// Refreshes returns the number of refreshes performed by the policy
+func (p *RefreshPolicy) Refreshes() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.refreshes
+}
+
+// Check evaluates the triggers against the state of u and compacts u if
+// any fires; it reports whether a refresh was performed
+func (p *RefreshPolicy) Check(u *Updatable) bool {
+
+	p.mu.Lock()
+	stats := RefreshStats{u.Staged(), time.Since(p.last), p.drifts}
+	p.mu.Unlock()
+
+	fire := false
+	for _, trigger := range p.triggers {
+		if trigger.ShouldRefresh(stats) {
+			fire = true
+			break
+		}
+	}
+
+	if !fire {
+		return false
+	}
+
+	u.Compact()
+
+	p.mu.Lock()
+	p.last = time.Now()
+	p.drifts -= stats.DriftAlerts
+	p.refreshes++
+	p.mu.Unlock()
+
+	return true
+}
+
+// Run checks the policy against u every poll interval until ctx is done
+func (p *RefreshPolicy) Run(ctx context.Context, u *Updatable, poll time.Duration) {
+
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.Check(u)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package rmi
+
+import (
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestRefreshPolicy(t *testing.T) {
+	values := sortedTestData(1000)
+	rmi, _ := NewRMI(values, RMIWidthParameter, 2)
+	u := NewUpdatable(rmi, 4)
+
+	policy := NewRefreshPolicy(AfterInserts(10), OnDrift(2))
+
+	for i := 0; i < 9; i++ {
+		u.Insert(big.NewInt(int64(i)))
+	}
+	if policy.Check(u) {
+		t.Fatalf("expected no refresh below the insert threshold")
+	}
+
+	u.Insert(big.NewInt(9))
+	if !policy.Check(u) || u.Staged() != 0 {
+		t.Fatalf("expected a refresh after 10 inserts")
+	}
+
+	// drift alerts accumulate until the trigger fires
+	policy.ReportDrift(DriftAlert{})
+	if policy.Check(u) {
+		t.Fatalf("expected no refresh after a single drift alert")
+	}
+	policy.ReportDrift(DriftAlert{})
+	if !policy.Check(u) || policy.Check(u) {
+		t.Fatalf("expected exactly one refresh after two drift alerts")
+	}
+
+	if policy.Refreshes() != 2 {
+		t.Fatalf("expected 2 refreshes but got %v", policy.Refreshes())
+	}
+}
+
+func TestRefreshPolicyTimeAndCustom(t *testing.T) {
+	values := sortedTestData(1000)
+	rmi, _ := NewRMI(values, RMIWidthParameter, 2)
+	u := NewUpdatable(rmi, 1)
+
+	timed := NewRefreshPolicy(Every(10 * time.Millisecond))
+	if timed.Check(u) {
+		t.Fatalf("expected no refresh before the interval passed")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if !timed.Check(u) {
+		t.Fatalf("expected a refresh after the interval passed")
+	}
+
+	custom := NewRefreshPolicy(TriggerFunc(func(stats RefreshStats) bool {
+		return stats.Staged > 0 && stats.Elapsed > 0
+	}))
+	u.Insert(big.NewInt(1))
+	if !custom.Check(u) {
+		t.Fatalf("expected the custom trigger to refresh")
+	}
+}
@@ -0,0 +1,168 @@
+// precision.go: reduction of the stored precision of trained coefficients.
+// Every key is routed through the full-precision model once; a leaf keeps
+// just enough bits that rounding its coefficients moves its prediction for
+// any of its keys by at most 1/4, and an internal node just enough that the
+// rounding cannot move the routing value of any of its keys across a child
+// boundary. Predictions for every key thus stay within 1 index of the full
+// precision model (with half of the margin left for evaluation rounding).
+
+package rmi
+
+import (
+	"errors"
+	"math/big"
+)
+
+// routing margins below this fraction of a child are kept at full precision
+const minRoutingMargin = 1.0 / (1 << 20)
+
+/*
+What the keys routed to a node require of its coefficients
+keys: number of keys routed to the node
+maxKey: largest absolute key routed to the node
+tolerance: largest change of the node's output that changes no key's result
+*/
+type precisionBound struct {
+	keys      int
+	maxKey    *big.Float
+	tolerance *big.Float
+}
+
+// ReducePrecision rounds every coefficient to the fewest bits that keep the
+// prediction of every key within 1 index of the current model and publishes
+// the result as a new epoch; the keys must be retained (see Load)
+func (rmi *RMI) ReducePrecision() (uint64, error) {
+
+	if rmi.values == nil {
+		return 0, errors.New("reducing the precision needs the keys of the model")
+	}
+
+	if rmi.current.Load().calib != nil {
+		return 0, errors.New("calibrated models cannot be reduced, calibrate after reducing")
+	}
+
+	return rmi.publish(func(v *version) *version {
+		bounds := rmi.precisionBounds(v)
+
+		next := v.next()
+		next.nodes = make([][]*Node, rmi.depth)
+		next.coeffs = make([]*layerCoefficients, rmi.depth)
+		for layer, nodes := range v.nodes {
+			reduced := make([]*Node, len(nodes))
+			for i, node := range nodes {
+				reduced[i] = node.reduced(bounds[layer][i])
+			}
+			next.nodes[layer], next.coeffs[layer] = packLayer(reduced, 0)
+		}
+		next.root = next.nodes[0][0]
+
+		return next
+	}), nil
+}
+
+// precisionBounds routes every key through v and returns the bound of every node
+func (rmi *RMI) precisionBounds(v *version) [][]precisionBound {
+
+	bounds := make([][]precisionBound, rmi.depth)
+	for layer, nodes := range v.nodes {
+		bounds[layer] = make([]precisionBound, len(nodes))
+	}
+
+	// leaf predictions may move by half an index (a quarter after the margin)
+	leafTolerance := big.NewFloat(0.5)
+
+	child := new(big.Float)
+	margin := new(big.Float)
+	for _, value := range rmi.values {
+		s := getScratch(value)
+		s.width.SetFloat64(float64(rmi.width))
+		s.maxIndex.SetFloat64(routingMaxIndex(v.maxIndex))
+
+		location := 0
+		for layer := 0; layer < rmi.depth; layer++ {
+			node := v.nodes[layer][location]
+			b := &bounds[layer][location]
+			b.keys++
+			if key := new(big.Float).Abs(s.input(node)); b.maxKey == nil || b.maxKey.Cmp(key) == -1 {
+				b.maxKey = key
+			}
+
+			if layer == rmi.depth-1 {
+				b.tolerance = leafTolerance
+				break
+			}
+
+			// a change of the output of t moves the routing value by
+			// t / maxIndex * width; children change at the integers 1..n-1
+			res := s.eval(node)
+			res.Quo(res, s.maxIndex)
+			res.Mul(res, s.width)
+
+			n := len(v.nodes[layer+1])
+			location = clampIndex(res, n-1)
+			margin.SetInf(false)
+			for _, boundary := range []int{location, location + 1} {
+				if boundary >= 1 && boundary <= n-1 {
+					child.SetInt64(int64(boundary))
+					child.Sub(child, res).Abs(child)
+					if child.Cmp(margin) == -1 {
+						margin.Set(child)
+					}
+				}
+			}
+
+			tolerance := new(big.Float)
+			if !margin.IsInf() && margin.Cmp(big.NewFloat(minRoutingMargin)) == 1 {
+				tolerance.Quo(margin, s.width).Mul(tolerance, s.maxIndex)
+			} else if margin.IsInf() {
+				tolerance.SetInf(false)
+			}
+			if b.tolerance == nil || tolerance.Cmp(b.tolerance) == -1 {
+				b.tolerance = tolerance
+			}
+
+			s.width.Mul(s.width, s.factor.SetFloat64(float64(rmi.width)))
+		}
+
+		putScratch(s)
+	}
+
+	return bounds
+}
+
+// reduced returns a copy of the node whose coefficients are rounded to the
+// fewest bits keeping its output within half of the tolerance of the bound
+func (node *Node) reduced(bound precisionBound) *Node {
+
+	res := *node
+	if node.alt != nil {
+		res.alt = node.alt.reduced(bound)
+	}
+
+	if bound.keys == 0 || bound.tolerance.Sign() == 0 {
+		return &res
+	}
+
+	prec := uint(1)
+	if !bound.tolerance.IsInf() {
+		// rounding to p bits moves m*x + b by at most 2^-p (|m| |x| + |b|)
+		scale := new(big.Float).Abs(node.m)
+		scale.Mul(scale, bound.maxKey)
+		scale.Add(scale, new(big.Float).Abs(node.b))
+		scale.Mul(scale, big.NewFloat(2))
+		scale.Quo(scale, bound.tolerance)
+		if exp := scale.MantExp(nil); exp > 1 {
+			prec = uint(exp)
+		}
+	}
+
+	if prec >= node.m.Prec() && prec >= node.b.Prec() {
+		return &res
+	}
+
+	res.m = new(big.Float).Copy(node.m).SetPrec(min(prec, node.m.Prec()))
+	res.b = new(big.Float).Copy(node.b).SetPrec(min(prec, node.b.Prec()))
+	res.w = xIntercept(res.m, res.b)
+
+	return &res
+}
@@ -0,0 +1,48 @@
+package rmi
+
+import (
+	"testing"
+)
+
+func TestReducePrecision(t *testing.T) {
+
+	for _, opts := range [][]Option{nil, {WithLeafEnsemble()}} {
+		rmi, values, err := generateTestRMI()
+		if opts != nil {
+			rmi, err = NewRMI(values, RMIWidthParameter, RMIDepthParameter, opts...)
+		}
+		if err != nil {
+			t.Fatalf("Failed to build RMI %v\n", err)
+		}
+
+		before := rmi.Pin()
+		encoded := len(rmi.encode())
+
+		if _, err := rmi.ReducePrecision(); err != nil {
+			t.Fatalf("failed to reduce precision %v", err)
+		}
+
+		for i, value := range values {
+			if abs(rmi.GetIndex(value)-before.GetIndex(value)) > 1 {
+				t.Fatalf("reduced model moved key %v by more than one index", i)
+			}
+		}
+
+		reduced := 0
+		for _, layer := range rmi.current.Load().nodes {
+			for _, node := range layer {
+				if node.m.Prec() < 53 {
+					reduced++
+				}
+			}
+		}
+		if reduced == 0 || len(rmi.encode()) >= encoded {
+			t.Fatalf("expected the model to shrink (%v reduced nodes, %v >= %v bytes)", reduced, len(rmi.encode()), encoded)
+		}
+	}
+
+	calibrated, _ := NewRMI(sortedTestData(1000), 4, 2, WithCalibration())
+	if _, err := calibrated.ReducePrecision(); err == nil {
+		t.Fatalf("expected calibrated models to be rejected")
+	}
+}
@@ -0,0 +1,85 @@
+// radix.go: radix root layer. Instead of a regression, the root routes a key
+// by its top bits: with L the bit length of the largest key, key k goes to
+// child floor(k * width / 2^L), i.e. the top log2(width) bits of k for a power
+// of two width (the "radix" model of the reference RMI). The root is stored
+// as the linear node with slope maxIndex / 2^L and no intercept, so queries,
+// encoding and the frozen layout need no special case; only the build splits
+// the keys by the child they are routed to instead of into equal counts.
+
+package rmi
+
+import (
+	"errors"
+	"math/big"
+	"sort"
+)
+
+// WithRadixRoot routes keys at the root by their top bits instead of
+// with a linear regression; keys must not be negative. For uniformly
+// distributed keys this is cheaper to build and routes more evenly
+func WithRadixRoot() Option {
+	return func(c *config) {
+		c.radixRoot = true
+	}
+}
+
+// checkRadix rejects keys the radix root cannot route
+func (c *config) checkRadix(values []*big.Int) error {
+	if c.radixRoot && len(values) > 0 && values[0].Sign() < 0 {
+		return errors.New("radix root requires non-negative keys")
+	}
+
+	return nil
+}
+
+// trains the radix root over the keys of the model
+func (rmi *RMI) trainRadix(task buildTask) *Node {
+
+	bits := 0
+	if len(task.values) > 0 {
+		bits = task.values[len(task.values)-1].BitLen()
+	}
+
+	// maxIndex / 2^L, rounded as the coefficients will be after training
+	// so that the split below matches the routing of the published model
+	m := new(big.Float).SetFloat64(routingMaxIndex(len(task.values) - 1))
+	m.SetMantExp(m, -bits)
+	if rmi.conf.precision > 0 {
+		m.SetPrec(rmi.conf.precision)
+	}
+
+	node := &Node{m: m, b: new(big.Float)}
+	node.w = xIntercept(node.m, node.b)
+
+	return node
+}
+
+// splits the training data of the root among its children by the child
+// each key is routed to (rather than into equal counts as splitTask), so
+// every child learns exactly the keys it answers; empty children predict
+// the index of the first key after them
+func (rmi *RMI) splitByRoute(task buildTask, root *Node, next []buildTask) []buildTask {
+
+	s := getScratch(nil)
+	defer putScratch(s)
+	s.maxIndex.SetFloat64(routingMaxIndex(len(task.values) - 1))
+	s.width.SetFloat64(float64(rmi.width))
+
+	// routing is monotone, so the children hold consecutive ranges of keys
+	start := 0
+	for child := 0; child < rmi.width; child++ {
+		end := start + sort.Search(len(task.values)-start, func(i int) bool {
+			s.set(task.values[start+i])
+			res := s.eval(root)
+			res.Quo(res, s.maxIndex)
+			res.Mul(res, s.width)
+			return clampIndex(res, rmi.width-1) > child
+		})
+
+		offset := new(big.Int).Add(task.offset, big.NewInt(int64(start)))
+		next = append(next, buildTask{task.values[start:end], task.indices[start:end], offset})
+		start = end
+	}
+
+	return next
+}
@@ -0,0 +1,37 @@
+package rmi
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestRadixRoot(t *testing.T) {
+	values := sortedTestData(5000)
+
+	rmi, err := NewRMI(values, 16, 2, WithRadixRoot())
+	if err != nil {
+		t.Fatalf("Failed to build RMI %v\n", err)
+	}
+
+	// the root routes by the top 4 bits of the largest key's length
+	shift := uint(values[len(values)-1].BitLen() - 4)
+	v := rmi.current.Load()
+	for i, value := range values {
+		if _, loc := rmi.leaf(v, value); int64(loc) != new(big.Int).Rsh(value, shift).Int64() {
+			t.Fatalf("key %v routed to leaf %v instead of its top bits", i, loc)
+		}
+
+		if index, ok := rmi.Lookup(value); !ok || values[index].Cmp(value) != 0 {
+			t.Fatalf("Lookup of key %v failed", i)
+		}
+	}
+
+	// children learn the keys routed to them
+	if rmi.MaxError() > 100 {
+		t.Fatalf("radix root model has max error %v", rmi.MaxError())
+	}
+
+	if _, err := NewRMI([]*big.Int{big.NewInt(-1), big.NewInt(1)}, 2, 2, WithRadixRoot()); err == nil {
+		t.Fatalf("expected negative keys to be rejected")
+	}
+}
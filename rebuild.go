@@ -0,0 +1,77 @@
+// rebuild.go: replacement of the keys of a key range. Bulk updates that
+// arrive as range-partitioned batches (e.g., re-ingesting one day of data)
+// only change the leaves covering the range: as in a compaction (see
+// updatable.go) the internal layers are kept, rescaled to the new number of
+// keys, the leaves routed keys of the range before or after the replacement
+// are retrained and all other leaves are shifted by the change in the
+// number of keys before them.
+
+package rmi
+
+import (
+	"errors"
+	"math/big"
+	"sort"
+)
+
+// RebuildRange returns a new model over the keys of rmi with the keys in
+// [lo, hi] replaced by newValues, which must be sorted and within [lo, hi];
+// rmi (which must retain its keys, see Load) is left unchanged, and
+// logically deleted keys (see SetDeletions) are dropped
+func (rmi *RMI) RebuildRange(lo, hi *big.Int, newValues []*big.Int) (*RMI, error) {
+
+	if rmi.values == nil {
+		return nil, errors.New("rebuilding a range needs the keys of the model")
+	}
+
+	if lo.Cmp(hi) == 1 {
+		return nil, errors.New("range bounds are out of order")
+	}
+
+	for i, value := range newValues {
+		if value.Cmp(lo) == -1 || value.Cmp(hi) == 1 {
+			return nil, errors.New("new values must be within the range")
+		} else if i > 0 && newValues[i-1].Cmp(value) == 1 {
+			return nil, errors.New("new values must be in sorted order")
+		}
+	}
+
+	// the old keys of the range are at [start, end)
+	start := sort.Search(len(rmi.values), func(i int) bool { return rmi.values[i].Cmp(lo) != -1 })
+	end := sort.Search(len(rmi.values), func(i int) bool { return rmi.values[i].Cmp(hi) == 1 })
+
+	deleted := rmi.deletions()
+	values := make([]*big.Int, 0, len(rmi.values)-(end-start)+len(newValues))
+	for i, value := range rmi.values[:start] {
+		if !deleted.isDeleted(i) {
+			values = append(values, value)
+		}
+	}
+
+	first := len(values)
+	values = append(values, newValues...)
+	for i, value := range rmi.values[end:] {
+		if !deleted.isDeleted(end + i) {
+			values = append(values, value)
+		}
+	}
+
+	// the new keys of the range are at [first, first+len(newValues))
+	oldErrs := rmi.errorsOf(rmi.current.Load())
+	return rmi.refit(values, func(loc int, leaf *Node, task buildTask) (*Node, bool) {
+
+		old := oldErrs[loc]
+		covered := old.count > 0 && old.first < end && old.last >= start
+		if n := len(task.indices); n > 0 {
+			covered = covered || (task.indices[0].Int64() < int64(first+len(newValues)) && task.indices[n-1].Int64() >= int64(first))
+		}
+
+		if covered || len(task.values) != old.count {
+			return rmi.trainLeaf(task), true
+		} else if len(task.values) > 0 {
+			return leaf.shifted(task.offset.Int64() - int64(old.first)), false
+		}
+
+		return leaf, false
+	}), nil
+}
@@ -0,0 +1,92 @@
+package rmi
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+func TestRebuildRange(t *testing.T) {
+
+	values := sortedTestData(20000)
+	model, err := NewRMI(values, 100, 2)
+	if err != nil {
+		t.Fatalf("Failed to build RMI %v\n", err)
+	}
+
+	// replace the keys of one slice of the key space with twice as many
+	r := rand.New(rand.NewSource(1))
+	lo, hi := values[8000], values[9000]
+	width := new(big.Int).Sub(hi, lo)
+	replacement := make([]*big.Int, 2000)
+	for i := range replacement {
+		replacement[i] = new(big.Int).Add(lo, new(big.Int).Rand(r, width))
+	}
+	sortKeys(replacement)
+
+	for _, newValues := range [][]*big.Int{replacement, nil} {
+		rebuilt, err := model.RebuildRange(lo, hi, newValues)
+		if err != nil {
+			t.Fatalf("failed to rebuild range %v", err)
+		}
+
+		expected := append(append(append([]*big.Int{}, values[:8000]...), newValues...), values[9001:]...)
+		if len(rebuilt.values) != len(expected) {
+			t.Fatalf("rebuilt model has %v keys instead of %v", len(rebuilt.values), len(expected))
+		}
+
+		for i, value := range expected {
+			if rebuilt.values[i].Cmp(value) != 0 {
+				t.Fatalf("key %v of the rebuilt model is %v instead of %v", i, rebuilt.values[i], value)
+			} else if index, ok := rebuilt.Lookup(value); !ok || rebuilt.values[index].Cmp(value) != 0 {
+				t.Fatalf("key %v is not found in the rebuilt model", i)
+			}
+		}
+
+		if err := rebuilt.Contract().Verify(expected, rebuilt.GetIndex); err != nil {
+			t.Fatalf("rebuilt model violates its contract: %v", err)
+		}
+
+		// leaves away from the range keep their slopes
+		retrained := 0
+		before, after := model.current.Load().nodes[1], rebuilt.current.Load().nodes[1]
+		for loc := range before {
+			if before[loc].m.Cmp(after[loc].m) != 0 {
+				retrained++
+			}
+		}
+		if retrained == 0 || retrained > 20 {
+			t.Fatalf("%v of %v leaves were retrained for a range of 5%% of the keys", retrained, len(before))
+		}
+	}
+
+	if len(model.values) != len(values) || model.Contract().Verify(values, model.GetIndex) != nil {
+		t.Fatalf("rebuilding changed the original model")
+	}
+}
+
+func TestRebuildRangeErrors(t *testing.T) {
+
+	values := sortedTestData(1000)
+	model, _ := NewRMI(values, 10, 2)
+	lo, hi := values[100], values[200]
+
+	for _, c := range []struct {
+		lo, hi    *big.Int
+		newValues []*big.Int
+	}{
+		{hi, lo, nil},
+		{lo, hi, []*big.Int{values[300]}},
+		{lo, hi, []*big.Int{values[150], values[120]}},
+	} {
+		if _, err := model.RebuildRange(c.lo, c.hi, c.newValues); err == nil {
+			t.Fatalf("expected an error rebuilding [%v, %v]", c.lo, c.hi)
+		}
+	}
+
+	data, _ := model.MarshalBinary()
+	loaded, _ := UnmarshalRMI(data)
+	if _, err := loaded.RebuildRange(lo, hi, nil); err == nil {
+		t.Fatalf("expected an error rebuilding a model without its keys")
+	}
+}
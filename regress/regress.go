@@ -0,0 +1,278 @@
+// regress.go: least squares linear regression of y on x with float64,
+// big.Float and big.Rat backends. The float64 backend is the fastest, the
+// big.Float backend computes the means and sums at a chosen precision and
+// the big.Rat backend is exact. Every backend has a weighted variant
+// minimizing the weighted sum of squared residuals. Sums accumulates the
+// exact integer statistics of a fit in one pass (the RMI trains its nodes
+// so) and rounds only the resulting coefficients.
+
+// Package regress implements the linear regression used to train the
+// models of an RMI, for keys larger than a float64 mantissa
+package regress
+
+import (
+	"errors"
+	"math/big"
+)
+
+var (
+	// ErrLength is returned if x, y (and the weights) differ in length
+	ErrLength = errors.New("regress: x, y and weights must have the same length")
+
+	// ErrDegenerate is returned if the (weighted) x have no variance,
+	// e.g., fewer than two distinct points have a positive weight
+	ErrDegenerate = errors.New("regress: x has no variance")
+)
+
+/*
+Line is the fitted line y = Intercept + Slope*x
+*/
+type Line struct {
+	Intercept, Slope float64
+}
+
+// At returns the value of the line at x
+func (l Line) At(x float64) float64 {
+	return l.Intercept + l.Slope*x
+}
+
+// Fit returns the least squares line of y on x
+func Fit(x, y []float64) (Line, error) {
+	return WeightedFit(x, y, nil)
+}
+
+// WeightedFit returns the line minimizing the sum of w[i] times the squared
+// residual of point i; nil weights weigh every point 1
+func WeightedFit(x, y, w []float64) (Line, error) {
+
+	if len(x) != len(y) || (w != nil && len(w) != len(x)) {
+		return Line{}, ErrLength
+	}
+
+	weight := func(i int) float64 {
+		if w == nil {
+			return 1
+		}
+		return w[i]
+	}
+
+	total, meanX, meanY := 0.0, 0.0, 0.0
+	for i := range x {
+		total += weight(i)
+		meanX += weight(i) * x[i]
+		meanY += weight(i) * y[i]
+	}
+
+	if total <= 0 {
+		return Line{}, ErrDegenerate
+	}
+	meanX /= total
+	meanY /= total
+
+	covar, variance := 0.0, 0.0
+	for i := range x {
+		covar += weight(i) * (x[i] - meanX) * (y[i] - meanY)
+		variance += weight(i) * (x[i] - meanX) * (x[i] - meanX)
+	}
+
+	if variance == 0 {
+		return Line{}, ErrDegenerate
+	}
+
+	slope := covar / variance
+	return Line{meanY - slope*meanX, slope}, nil
+}
+
+// FitFloat returns the intercept and slope of the least squares line of y
+// on x; the means and sums are rounded to prec bits (0 means 53)
+func FitFloat(x, y []*big.Int, prec uint) (intercept, slope *big.Float, err error) {
+	return WeightedFitFloat(x, y, nil, prec)
+}
+
+// WeightedFitFloat is FitFloat minimizing the sum of w[i] times the squared
+// residual of point i; nil weights weigh every point 1
+func WeightedFitFloat(x, y []*big.Int, w []*big.Float, prec uint) (intercept, slope *big.Float, err error) {
+
+	if len(x) != len(y) || (w != nil && len(w) != len(x)) {
+		return nil, nil, ErrLength
+	}
+
+	if prec == 0 {
+		prec = 53
+	}
+
+	total := new(big.Float).SetPrec(prec)
+	if w == nil {
+		total.SetInt64(int64(len(x)))
+	}
+	for i := range w {
+		total.Add(total, w[i])
+	}
+
+	if total.Sign() <= 0 {
+		return nil, nil, ErrDegenerate
+	}
+
+	meanX := weightedMean(x, w, total, prec)
+	meanY := weightedMean(y, w, total, prec)
+
+	covar := new(big.Float).SetPrec(prec)
+	variance := new(big.Float).SetPrec(prec)
+	for i := range x {
+		termX := new(big.Float).SetInt(x[i])
+		termX.Sub(termX, meanX)
+
+		termY := new(big.Float).SetInt(y[i])
+		termY.Sub(termY, meanY)
+
+		termXY := new(big.Float).Mul(termX, termY)
+		termXX := new(big.Float).Mul(termX, termX)
+		if w != nil {
+			termXY.Mul(termXY, w[i])
+			termXX.Mul(termXX, w[i])
+		}
+
+		covar.Add(covar, termXY)
+		variance.Add(variance, termXX)
+	}
+
+	if variance.Sign() == 0 {
+		return nil, nil, ErrDegenerate
+	}
+
+	slope = covar.Quo(covar, variance)
+	intercept = new(big.Float).Sub(meanY, meanX.Mul(meanX, slope))
+
+	return intercept, slope, nil
+}
+
+// weightedMean returns the mean of values weighted by w (nil weighs every
+// value 1) at prec bits, given the total weight
+func weightedMean(values []*big.Int, w []*big.Float, total *big.Float, prec uint) *big.Float {
+
+	mean := new(big.Float).SetPrec(prec)
+	for i := range values {
+		term := new(big.Float).SetInt(values[i])
+		if w != nil {
+			term = new(big.Float).Mul(term, w[i])
+		}
+		mean.Add(mean, term)
+	}
+
+	return mean.Quo(mean, total)
+}
+
+// FitRat returns the exact intercept and slope of the least squares line of y on x
+func FitRat(x, y []*big.Int) (intercept, slope *big.Rat, err error) {
+	return WeightedFitRat(x, y, nil)
+}
+
+// WeightedFitRat is FitRat minimizing the sum of w[i] times the squared
+// residual of point i; nil weights weigh every point 1
+func WeightedFitRat(x, y []*big.Int, w []*big.Rat) (intercept, slope *big.Rat, err error) {
+
+	if len(x) != len(y) || (w != nil && len(w) != len(x)) {
+		return nil, nil, ErrLength
+	}
+
+	// weighted sums of 1, x, y, xy and xx
+	total, sumX, sumY, sumXY, sumXX := new(big.Rat), new(big.Rat), new(big.Rat), new(big.Rat), new(big.Rat)
+	one := big.NewRat(1, 1)
+	for i := range x {
+		weight := one
+		if w != nil {
+			weight = w[i]
+		}
+
+		xi := new(big.Rat).SetInt(x[i])
+		yi := new(big.Rat).SetInt(y[i])
+		wx := new(big.Rat).Mul(weight, xi)
+
+		total.Add(total, weight)
+		sumX.Add(sumX, wx)
+		sumY.Add(sumY, new(big.Rat).Mul(weight, yi))
+		sumXY.Add(sumXY, new(big.Rat).Mul(wx, yi))
+		sumXX.Add(sumXX, new(big.Rat).Mul(wx, xi))
+	}
+
+	if total.Sign() <= 0 {
+		return nil, nil, ErrDegenerate
+	}
+
+	// slope = (W Sxy - Sx Sy) / (W Sxx - Sx Sx)
+	num := new(big.Rat).Mul(total, sumXY)
+	num.Sub(num, new(big.Rat).Mul(sumX, sumY))
+	den := new(big.Rat).Mul(total, sumXX)
+	den.Sub(den, new(big.Rat).Mul(sumX, sumX))
+
+	if den.Sign() == 0 {
+		return nil, nil, ErrDegenerate
+	}
+
+	slope = num.Quo(num, den)
+	intercept = new(big.Rat).Mul(slope, sumX)
+	intercept.Sub(sumY, intercept)
+	intercept.Quo(intercept, total)
+
+	return intercept, slope, nil
+}
+
+/*
+Sums are the sufficient statistics of a least squares fit, accumulated
+exactly so that points can be added in any order (or merged from separate
+passes) with no loss: the number of points and the sums of x, y, xy and xx
+*/
+type Sums struct {
+	N            int64
+	X, Y, XY, XX big.Int
+	tmp          big.Int
+}
+
+// Add adds the point (x, y)
+func (s *Sums) Add(x, y *big.Int) {
+	s.N++
+	s.X.Add(&s.X, x)
+	s.Y.Add(&s.Y, y)
+	s.XY.Add(&s.XY, s.tmp.Mul(x, y))
+	s.XX.Add(&s.XX, s.tmp.Mul(x, x))
+}
+
+// Merge adds the points of other
+func (s *Sums) Merge(other *Sums) {
+	s.N += other.N
+	s.X.Add(&s.X, &other.X)
+	s.Y.Add(&s.Y, &other.Y)
+	s.XY.Add(&s.XY, &other.XY)
+	s.XX.Add(&s.XX, &other.XX)
+}
+
+// Line returns the intercept and slope of the least squares line of the
+// points, each correctly rounded to prec bits (0 means 53)
+func (s *Sums) Line(prec uint) (intercept, slope *big.Float, err error) {
+
+	if prec == 0 {
+		prec = 53
+	}
+
+	// slope = (n Sxy - Sx Sy) / (n Sxx - Sx Sx)
+	n := big.NewInt(s.N)
+	num := new(big.Int).Mul(n, &s.XY)
+	num.Sub(num, new(big.Int).Mul(&s.X, &s.Y))
+	den := new(big.Int).Mul(n, &s.XX)
+	den.Sub(den, new(big.Int).Mul(&s.X, &s.X))
+
+	if den.Sign() == 0 {
+		return nil, nil, ErrDegenerate
+	}
+
+	// intercept = (Sy - slope Sx) / n = (Sy den - num Sx) / (n den)
+	a := new(big.Int).Mul(&s.Y, den)
+	a.Sub(a, new(big.Int).Mul(num, &s.X))
+
+	return quo(a, new(big.Int).Mul(n, den), prec), quo(num, den, prec), nil
+}
+
+// quo returns a / b correctly rounded to prec bits
+func quo(a, b *big.Int, prec uint) *big.Float {
+	return new(big.Float).SetPrec(prec).Quo(new(big.Float).SetInt(a), new(big.Float).SetInt(b))
+}
@@ -0,0 +1,172 @@
+package regress
+
+import (
+	"math"
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+// points on y = 3x + 5 with keys shifted by offset
+func linePoints(n int, offset *big.Int) ([]*big.Int, []*big.Int) {
+	x := make([]*big.Int, n)
+	y := make([]*big.Int, n)
+	for i := range x {
+		x[i] = new(big.Int).Add(offset, big.NewInt(int64(2*i)))
+		y[i] = big.NewInt(int64(6*i + 5))
+	}
+
+	return x, y
+}
+
+func TestFit(t *testing.T) {
+	x := []float64{0, 1, 2, 3, 4}
+	y := []float64{5, 8, 11, 14, 17}
+
+	line, err := Fit(x, y)
+	if err != nil || math.Abs(line.Slope-3) > 1e-12 || math.Abs(line.Intercept-5) > 1e-12 {
+		t.Fatalf("expected y = 3x + 5, got %+v (%v)", line, err)
+	}
+
+	if line.At(10) != 35 {
+		t.Fatalf("line at 10 is %v", line.At(10))
+	}
+
+	// a zero weight removes the outlier
+	line, err = WeightedFit(append(x, 5), append(y, 1000), []float64{1, 1, 1, 1, 1, 0})
+	if err != nil || math.Abs(line.Slope-3) > 1e-12 || math.Abs(line.Intercept-5) > 1e-12 {
+		t.Fatalf("expected the outlier to be ignored, got %+v (%v)", line, err)
+	}
+
+	if _, err := Fit([]float64{1, 1}, []float64{1, 2}); err != ErrDegenerate {
+		t.Fatalf("expected ErrDegenerate, got %v", err)
+	}
+
+	if _, err := WeightedFit(x, y, []float64{1}); err != ErrLength {
+		t.Fatalf("expected ErrLength, got %v", err)
+	}
+}
+
+func TestFitBig(t *testing.T) {
+	// keys far beyond a float64 mantissa: x = 2^100 + 2i, y = 6i + 5
+	offset := new(big.Int).Lsh(big.NewInt(1), 100)
+	x, y := linePoints(100, offset)
+
+	intercept, slope, err := FitRat(x, y)
+	if err != nil || slope.Cmp(big.NewRat(3, 1)) != 0 {
+		t.Fatalf("expected slope 3, got %v (%v)", slope, err)
+	}
+
+	// exact intercept: 5 - 3 * 2^100
+	expected := new(big.Rat).SetInt(new(big.Int).Mul(offset, big.NewInt(-3)))
+	if expected.Add(expected, big.NewRat(5, 1)); intercept.Cmp(expected) != 0 {
+		t.Fatalf("expected intercept %v, got %v", expected, intercept)
+	}
+
+	// 256 bits hold the keys and their products exactly
+	fb, fm, err := FitFloat(x, y, 256)
+	if err != nil {
+		t.Fatalf("FitFloat failed %v", err)
+	}
+	if m, _ := fm.Float64(); m != 3 {
+		t.Fatalf("expected slope 3, got %v", fm)
+	}
+	if r, _ := new(big.Float).SetRat(intercept).Float64(); r != mustFloat(fb) {
+		t.Fatalf("expected intercept %v, got %v", intercept, fb)
+	}
+
+	if _, _, err := FitFloat(x[:1], y[:1], 0); err != ErrDegenerate {
+		t.Fatalf("expected ErrDegenerate, got %v", err)
+	}
+}
+
+func TestWeightedFitBig(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	x := make([]*big.Int, 200)
+	y := make([]*big.Int, len(x))
+	wf := make([]*big.Float, len(x))
+	wr := make([]*big.Rat, len(x))
+	for i := range x {
+		x[i] = big.NewInt(int64(i))
+		y[i] = big.NewInt(int64(i*i) + r.Int63n(10))
+		weight := r.Int63n(5)
+		wf[i] = new(big.Float).SetInt64(weight)
+		wr[i] = big.NewRat(weight, 1)
+	}
+
+	rb, rm, err := WeightedFitRat(x, y, wr)
+	if err != nil {
+		t.Fatalf("WeightedFitRat failed %v", err)
+	}
+
+	fb, fm, err := WeightedFitFloat(x, y, wf, 128)
+	if err != nil {
+		t.Fatalf("WeightedFitFloat failed %v", err)
+	}
+
+	for _, pair := range [][2]float64{{ratFloat(rb), mustFloat(fb)}, {ratFloat(rm), mustFloat(fm)}} {
+		if math.Abs(pair[0]-pair[1]) > 1e-9*math.Abs(pair[0]) {
+			t.Fatalf("big.Float and big.Rat fits differ: %v != %v", pair[1], pair[0])
+		}
+	}
+
+	// unit weights give the unweighted fit
+	ones := make([]*big.Rat, len(x))
+	for i := range ones {
+		ones[i] = big.NewRat(1, 1)
+	}
+	wb, wm, _ := WeightedFitRat(x, y, ones)
+	ub, um, _ := FitRat(x, y)
+	if wb.Cmp(ub) != 0 || wm.Cmp(um) != 0 {
+		t.Fatalf("unit weights changed the fit")
+	}
+
+	if _, _, err := WeightedFitRat(x, y, make([]*big.Rat, 3)); err != ErrLength {
+		t.Fatalf("expected ErrLength, got %v", err)
+	}
+}
+
+func mustFloat(f *big.Float) float64 {
+	v, _ := f.Float64()
+	return v
+}
+
+func ratFloat(r *big.Rat) float64 {
+	v, _ := r.Float64()
+	return v
+}
+
+func TestSums(t *testing.T) {
+	offset := new(big.Int).Lsh(big.NewInt(1), 100)
+	x, y := linePoints(100, offset)
+
+	// two halves merged give the fit of all points
+	var all, left, right Sums
+	for i := range x {
+		all.Add(x[i], y[i])
+		if i < 40 {
+			left.Add(x[i], y[i])
+		} else {
+			right.Add(x[i], y[i])
+		}
+	}
+	left.Merge(&right)
+
+	intercept, slope, err := left.Line(0)
+	if err != nil || slope.Cmp(big.NewFloat(3)) != 0 || all.XX.Cmp(&left.XX) != 0 {
+		t.Fatalf("expected slope 3, got %v (%v)", slope, err)
+	}
+
+	// the coefficients are the exact fit correctly rounded
+	exact, _, _ := FitRat(x, y)
+	if expected := new(big.Float).SetRat(exact); intercept.Cmp(new(big.Float).SetPrec(53).Set(expected)) != 0 {
+		t.Fatalf("expected intercept %v, got %v", expected, intercept)
+	}
+
+	var single Sums
+	single.Add(x[0], y[0])
+	if _, _, err := single.Line(0); err != ErrDegenerate {
+		t.Fatalf("expected ErrDegenerate, got %v", err)
+	}
+}
@@ -1,76 +1,68 @@
-// regression.go: Basic linear regression implemented
-// with big.Rat for increased accuracy
+// regression.go: coefficients of the node models, from the exact sums of
+// the linear regression (see the regress package) or the line through the
+// endpoints
 
 package rmi
 
 import (
 	"math/big"
+
+	"github.com/sachaservan/rmi/regress"
 )
 
-// linear_regression on an array given a certain range from
-// start to end (inclusive, inclusive)
-// function to compute mean, input: float64 array
-func mean(values []*big.Int) *big.Float {
+// function to compute linar regression coefficients + x intercept from the
+// exact sums of the points (see regress.Sums)
+func coefficients(predVars []*big.Int, target []*big.Int, prec uint) (*big.Float, *big.Float, *big.Float) {
 
-	mean := big.NewFloat(0.0)
-	for i := 0; i < len(values); i++ {
-		mean.Add(mean, new(big.Float).SetInt(values[i]))
+	var sums regress.Sums
+	for i := range predVars {
+		sums.Add(predVars[i], target[i])
 	}
 
-	mean.Quo(mean, big.NewFloat(float64(len(values))))
-	return mean
+	return sumsCoefficients(&sums, prec, predVars, target)
 }
 
-// function to compute covariance of two arrays,
-// input: float64 arrayX and arrayY, meanX and meanY
-func covariance(
-	x []*big.Int,
-	y []*big.Int,
-	meanX *big.Float,
-	meanY *big.Float) *big.Float {
-
-	covar := big.NewFloat(0.0)
-	for i := 0; i < len(x); i++ {
-		termX := new(big.Float).SetInt(x[i])
-		termX.Sub(termX, meanX)
+// function to compute the coefficients + x intercept of the least squares line
+// of the points from their sums; points without variance in x get the
+// constant model at the first target
+func sumsCoefficients(sums *regress.Sums, prec uint, predVars []*big.Int, target []*big.Int) (*big.Float, *big.Float, *big.Float) {
 
-		termY := new(big.Float).SetInt(y[i])
-		termY.Sub(termY, meanY)
-
-		termXY := new(big.Float).Mul(termX, termY)
-		covar.Add(covar, termXY)
+	b0, b1, err := sums.Line(prec)
+	if err != nil {
+		return endpointCoefficients(predVars, target)
 	}
 
-	return covar
+	return b0, b1, xIntercept(b1, b0)
 }
 
-// function to compute variance of array, inp: float64 array1 mean1
-func variance(values []*big.Int, meanValue *big.Float) *big.Float {
+// function to compute the coefficients + x intercept of the line through the
+// first and last points, O(1) instead of a pass over the data; a constant
+// model at the first target is returned if the first and last x are equal
+func endpointCoefficients(predVars []*big.Int, target []*big.Int) (*big.Float, *big.Float, *big.Float) {
 
-	variance := big.NewFloat(0.0)
-	for i := 0; i < len(values); i++ {
-		abs := new(big.Float).SetInt(values[i])
-		abs.Sub(abs, meanValue)
-		abs.Mul(abs, abs)
-		variance.Add(variance, abs)
-	}
+	last := len(predVars) - 1
 
-	return variance
-}
+	dx := new(big.Float).SetInt(new(big.Int).Sub(predVars[last], predVars[0]))
+	if dx.Sign() == 0 {
+		return new(big.Float).SetInt(target[0]), big.NewFloat(0.0), big.NewFloat(0.0)
+	}
 
-// function to compute linar regression coefficients + x intercept
-func coefficients(predVars []*big.Int, target []*big.Int) (*big.Float, *big.Float, *big.Float) {
+	b1 := new(big.Float).SetInt(new(big.Int).Sub(target[last], target[0]))
+	b1.Quo(b1, dx)
 
-	meanX := mean(predVars)
-	meanY := mean(target)
+	b0 := new(big.Float).Mul(b1, new(big.Float).SetInt(predVars[0]))
+	b0.Sub(new(big.Float).SetInt(target[0]), b0)
 
-	b1 := covariance(predVars, target, meanX, meanY)
-	b1.Quo(b1, variance(predVars, meanX))
+	return b0, b1, xIntercept(b1, b0)
+}
 
-	b0 := new(big.Float).Sub(meanY, meanX.Mul(meanX, b1))
+// function to compute the x intercept w of mw + b = 0 (0 for a constant model)
+func xIntercept(m *big.Float, b *big.Float) *big.Float {
 
-	w := new(big.Float).Neg(b0)
-	w.Quo(w, b1)
+	if m.Sign() == 0 {
+		return big.NewFloat(0.0)
+	}
 
-	return b0, b1, w
+	w := new(big.Float).Neg(b)
+	return w.Quo(w, m)
 }
@@ -0,0 +1,145 @@
+// report.go: per-node diagnostics of a built model. The training set of
+// every node is recovered by replaying the split of the build, and each
+// node is evaluated on its own training set, so large builds can be
+// sanity-checked automatically (e.g., failing a pipeline on degenerate nodes).
+
+package rmi
+
+import (
+	"errors"
+	"math/big"
+)
+
+/*
+NodeReport holds the diagnostics of a single node
+Layer, Position: layer and position of the node
+Keys: number of keys in the training set of the node
+R2: coefficient of determination of the node over its training set (1 with fewer than two keys)
+MinResidual, MaxResidual: extremes of (true index - clamped prediction) over the training set
+Empty: the node has no training keys
+Constant: the node predicts the same index for every key (a single key, or all keys equal)
+IllConditioned: the keys of the node spread over a tiny fraction of their magnitude
+FellBack: the ill-conditioned regression of the node was replaced (see WithIllConditioned)
+*/
+type NodeReport struct {
+	Layer, Position          int
+	Keys                     int
+	R2                       float64
+	MinResidual, MaxResidual int
+	Empty                    bool
+	Constant                 bool
+	IllConditioned           bool
+	FellBack                 bool
+}
+
+// Degenerate reports whether the node is empty, constant or ill-conditioned
+func (r NodeReport) Degenerate() bool {
+	return r.Empty || r.Constant || r.IllConditioned
+}
+
+/*
+BuildReport holds the diagnostics of every node of a model
+Nodes: the report of every node in layer order, then position order
+Fallbacks: number of nodes whose ill-conditioned regression was replaced
+*/
+type BuildReport struct {
+	Nodes     []NodeReport
+	Fallbacks int
+}
+
+// Degenerate returns the reports of the degenerate nodes
+func (r *BuildReport) Degenerate() []NodeReport {
+	var degenerate []NodeReport
+	for _, node := range r.Nodes {
+		if node.Degenerate() {
+			degenerate = append(degenerate, node)
+		}
+	}
+
+	return degenerate
+}
+
+// BuildReport evaluates every node of the current version on the keys it
+// was trained on at build time; the keys must be retained (see Load)
+func (rmi *RMI) BuildReport() (*BuildReport, error) {
+
+	if rmi.values == nil {
+		return nil, errors.New("the build report needs the keys of the model")
+	}
+
+	indices := make([]*big.Int, len(rmi.values))
+	for i := range indices {
+		indices[i] = big.NewInt(int64(i))
+	}
+
+	v := rmi.current.Load()
+	report := &BuildReport{}
+
+	layer := []buildTask{{rmi.values, indices, big.NewInt(0)}}
+	for depth := 0; depth < rmi.depth; depth++ {
+		next := make([]buildTask, 0, len(layer)*rmi.width)
+		for pos, task := range layer {
+			node := reportNode(v, depth, pos, task)
+			if node.FellBack {
+				report.Fallbacks++
+			}
+			report.Nodes = append(report.Nodes, node)
+			if depth == rmi.depth-1 {
+				continue
+			} else if depth == 0 && rmi.conf.radixRoot {
+				next = rmi.splitByRoute(task, v.nodes[0][pos], next)
+			} else {
+				next = rmi.splitTask(task, next)
+			}
+		}
+		layer = next
+	}
+
+	return report, nil
+}
+
+// reportNode evaluates the node at position pos of layer on its training task
+func reportNode(v *version, layer int, pos int, task buildTask) NodeReport {
+
+	node := v.nodes[layer][pos]
+	r := NodeReport{Layer: layer, Position: pos, Keys: len(task.values), R2: 1}
+	if r.Keys == 0 {
+		r.Empty = true
+		return r
+	}
+
+	s := getScratch(nil)
+	defer putScratch(s)
+
+	first := int(task.indices[0].Int64())
+	meanIndex := float64(first) + float64(r.Keys-1)/2
+	ssRes, ssTot := 0.0, 0.0
+	for i, value := range task.values {
+		s.set(value)
+		res := s.eval(node.modelFor(value))
+
+		prediction, _ := res.Float64()
+		index := float64(first + i)
+		ssRes += (index - prediction) * (index - prediction)
+		ssTot += (index - meanIndex) * (index - meanIndex)
+
+		residual := first + i - clampIndex(res, v.maxIndex)
+		if i == 0 || residual < r.MinResidual {
+			r.MinResidual = residual
+		}
+		if i == 0 || residual > r.MaxResidual {
+			r.MaxResidual = residual
+		}
+	}
+
+	lo, hi := task.values[0], task.values[r.Keys-1]
+	r.Constant = lo.Cmp(hi) == 0 || node.m.Sign() == 0
+	if ssTot > 0 {
+		r.R2 = 1 - ssRes/ssTot
+	}
+
+	r.IllConditioned = !r.Constant && illConditioned(task.values)
+	r.FellBack = node.fallback
+
+	return r
+}
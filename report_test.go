@@ -0,0 +1,117 @@
+package rmi
+
+import (
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestBuildReport(t *testing.T) {
+
+	rmi, values, err := generateTestRMI()
+	if err != nil {
+		t.Fatalf("Failed to build RMI %v\n", err)
+	}
+
+	report, err := rmi.BuildReport()
+	if err != nil {
+		t.Fatalf("Failed to build report %v\n", err)
+	}
+
+	nodes := 0
+	for _, layer := range rmi.current.Load().nodes {
+		nodes += len(layer)
+	}
+	if len(report.Nodes) != nodes {
+		t.Fatalf("report has %v nodes", len(report.Nodes))
+	}
+
+	root := report.Nodes[0]
+	if root.Keys != len(values) || root.Degenerate() || root.R2 < 0.9 {
+		t.Fatalf("unexpected root report %+v", root)
+	}
+
+	// the training sets of the leaves partition the keys (up to the last
+	// key of each range, which the split of the build leaves out)
+	keys := 0
+	for _, node := range report.Nodes {
+		if node.Layer != rmi.depth-1 {
+			continue
+		}
+		keys += node.Keys
+		if node.MinResidual > node.MaxResidual || node.R2 > 1 {
+			t.Fatalf("inconsistent leaf report %+v", node)
+		}
+	}
+	if keys > len(values) || keys < len(values)-rmi.NumLeaves() {
+		t.Fatalf("leaf reports cover %v of %v keys", keys, len(values))
+	}
+}
+
+func TestBuildReportDegenerate(t *testing.T) {
+
+	// keys at a huge offset with unit gaps, split into ranges of a single key
+	base := new(big.Int).Lsh(big.NewInt(1), 100)
+	values := make([]*big.Int, 0)
+	for i := 0; i < 100; i++ {
+		values = append(values, new(big.Int).Add(base, big.NewInt(int64(i))))
+	}
+
+	rmi, err := NewRMI(values, 50, 2)
+	if err != nil {
+		t.Fatalf("Failed to build RMI %v\n", err)
+	}
+
+	report, err := rmi.BuildReport()
+	if err != nil {
+		t.Fatalf("Failed to build report %v\n", err)
+	}
+
+	constant, illConditioned := 0, 0
+	for _, node := range report.Degenerate() {
+		if node.Constant {
+			constant++
+		}
+		if node.IllConditioned {
+			illConditioned++
+		}
+	}
+
+	if constant == 0 || illConditioned == 0 {
+		t.Fatalf("expected constant and ill-conditioned nodes, got %+v", report.Degenerate())
+	}
+}
+
+func TestBuildReportRadixRoot(t *testing.T) {
+
+	// exponential keys, which the radix root routes very unevenly
+	values := make([]*big.Int, 5000)
+	for i := range values {
+		x := new(big.Float).SetFloat64(math.Exp(float64(i) / 100))
+		values[i], _ = x.Int(nil)
+	}
+
+	rmi, err := NewRMI(values, 16, 2, WithRadixRoot())
+	if err != nil {
+		t.Fatalf("Failed to build RMI %v\n", err)
+	}
+
+	report, err := rmi.BuildReport()
+	if err != nil {
+		t.Fatalf("Failed to build report %v\n", err)
+	}
+
+	// the leaves were trained on the keys the root routes to them
+	routed := make([]int, rmi.NumLeaves())
+	v := rmi.current.Load()
+	for _, value := range values {
+		_, loc := rmi.leaf(v, value)
+		routed[loc]++
+	}
+
+	for _, node := range report.Nodes[1:] {
+		if node.Keys != routed[node.Position] {
+			t.Fatalf("leaf %v reports %v keys but %v are routed to it", node.Position, node.Keys, routed[node.Position])
+		}
+	}
+}
@@ -1,10 +1,13 @@
 package rmi
 
 import (
+	"context"
 	"errors"
 	"math"
 	"math/big"
 	"sort"
+	"sync"
+	"sync/atomic"
 )
 
 /*
@@ -12,44 +15,118 @@ Node in the leanred index tree
 m: slope of the current node model
 b: intercept of current node model
 w: x intercept of the model mw + b = 0
-children: array of child nodes (if they exist)
+alt: optional second model of a leaf used for keys >= breakpoint (see ensemble.go)
+exact: optional last-mile table from key to exact index of a leaf (see lastmile.go)
+log: the model is m*log2(1+x) + b (see loglinear.go)
+fit: how the model was trained, for introspection (see ModelTypes)
+fallback: the regression of the node was ill-conditioned and replaced (see illcond.go)
 */
 type Node struct {
-	m, b, w *big.Float // mx + b and w is the x intercept (mw + b = 0)
+	m, b, w    *big.Float     // mx + b and w is the x intercept (mw + b = 0)
+	alt        *Node          // model used at and beyond the breakpoint
+	breakpoint *big.Int       // first key served by alt
+	exact      map[string]int // exact index of each key of the leaf
+	log        bool           // the model input is log2(1+x)
+	fit        ModelType      // model type the node was trained as
+	fallback   bool           // an ill-conditioned regression was replaced
 }
 
 /*
 RMI data structure
 Learns the CDF of the data using recursive linear regressions.
-root: root node in the model
 width: number of data chuncks the data is split into at each layer
 depth: recursive depth of the model
-nodes: all nodes in the model
+current: the current version of all nodes in the model (see epoch.go)
+values: the sorted keys the model was built over (retained, not copied)
+deleted: optional bitmap of logically deleted positions in values
+routing: optional cache of root routing decisions for hot key prefixes
+accuracy: latest sampled accuracy (see sampler.go)
+conf: optional configuration (see options.go)
 */
 type RMI struct {
-	width, depth int       // width and depth of the rmi
-	root         *Node     // top most node in rmi
-	nodes        [][]*Node // each []*Node is all the nodes of a layer
-	maxIndex     int       // maximum index in the data structure
+	width, depth int                           // width and depth of the rmi
+	current      atomic.Pointer[version]       // current version of the model
+	retrain      sync.Mutex                    // serializes writers publishing new versions
+	values       []*big.Int                    // sorted keys the model was trained on
+	deleted      atomic.Pointer[deletionSet]   // logically deleted positions (see deletion.go)
+	routing      *routingCache                 // routing decisions of hot prefixes (see routing.go)
+	accuracy     atomic.Pointer[AccuracyStats] // latest sampled accuracy (see sampler.go)
+	conf         config                        // optional configuration
+	queries      atomic.Uint64                 // number of queries served (used for trace sampling)
 }
 
 // NewRMI create a new recursive model index structure with the provided parameters
+// the values slice is retained by the RMI (for exact queries) and must not be modified;
+// depth 1 builds a single linear model over all keys (a minimal learned index)
 // see https://dl.acm.org/doi/pdf/10.1145/3183713.3196909?download=true
 // for details on the datastructure
 func NewRMI(
 	values []*big.Int,
 	width int,
-	depth int) (*RMI, error) {
+	depth int,
+	opts ...Option) (*RMI, error) {
 
-	// values must be provided in sorted order
+	conf := config{}
+	for _, opt := range opts {
+		opt(&conf)
+	}
+
+	// values must be provided in sorted order (see WithLazySortCheck)
+	if !conf.lazySort {
+		if err := checkSorted(values); err != nil {
+			return nil, err
+		}
+	}
+
+	return newRMI(values, width, depth, conf)
+}
+
+// NewRMIWithOptions creates an RMI configured entirely by options; the shape
+// is set by WithWidth and WithDepth and defaults to a two-stage model with a
+// leaf per 100 keys
+func NewRMIWithOptions(values []*big.Int, opts ...Option) (*RMI, error) {
+
+	conf := config{}
+	for _, opt := range opts {
+		opt(&conf)
+	}
+
+	// values must be provided in sorted order (see WithLazySortCheck)
+	if !conf.lazySort {
+		if err := checkSorted(values); err != nil {
+			return nil, err
+		}
+	}
+
+	width, depth := conf.shape(len(values))
+	return newRMI(values, width, depth, conf)
+}
+
+// checkSorted returns an error if values are not in sorted order
+func checkSorted(values []*big.Int) error {
 	isSorted := sort.SliceIsSorted(values, func(i, j int) bool {
 		return values[i].Cmp(values[j]) == -1
 	})
 
 	if !isSorted {
-		return nil, errors.New("values must be in sorted order")
+		return errors.New("values must be in sorted order")
 	}
 
+	return nil
+}
+
+// NewRMIWithLeaves creates the two-stage RMI of the original design: a root
+// model routing to numLeaves leaf models, so the number of leaf models (and
+// with it the model size) is chosen directly rather than as width^depth
+func NewRMIWithLeaves(values []*big.Int, numLeaves int, opts ...Option) (*RMI, error) {
+
+	// the leaf layer of a two-layer model has width nodes
+	return NewRMI(values, numLeaves, 2, opts...)
+}
+
+// newRMI builds the RMI over the sorted values with the given configuration
+func newRMI(values []*big.Int, width int, depth int, conf config) (*RMI, error) {
+
 	indices := make([]*big.Int, len(values))
 
 	// set indices to be the index of each (sorted) value
@@ -57,6 +134,74 @@ func NewRMI(
 		indices[i] = big.NewInt(int64(i))
 	}
 
+	rmi := &RMI{conf: conf}
+	if err := rmi.conf.checkRadix(values); err != nil {
+		return nil, err
+	}
+
+	nodes, err := rmi.allocate(len(values), width, depth)
+	if err != nil {
+		return nil, err
+	}
+	rmi.values = values
+
+	// build the RMI
+	ctx, span := rmi.startSpan(context.Background(), SpanBuild)
+	span.SetAttribute("keys", len(values))
+	span.SetAttribute("width", rmi.width)
+	span.SetAttribute("depth", rmi.depth)
+	err = rmi.build(ctx, nodes, values, indices)
+	span.End()
+	if err != nil {
+		return nil, err
+	}
+
+	v := newVersion(0, nodes, len(values)-1).pack(rmi.conf.precision)
+	if rmi.conf.lastMile {
+		rmi.attachExactTables(v)
+	}
+
+	// track the error bounds of every leaf at build time (see GetIndexWithBounds);
+	// the leaf finalizer sees the leaves once they are final (see finalize.go)
+	adjusted := rmi.conf.monotonic || rmi.conf.calibrate
+	if rmi.conf.leafFinalizer != nil && !adjusted {
+		rmi.finalizeLeaves(v)
+	} else {
+		rmi.errorsOf(v)
+	}
+	rmi.current.Store(v)
+
+	if rmi.conf.monotonic {
+		rmi.EnforceMonotonic()
+	}
+
+	if rmi.conf.calibrate {
+		rmi.Calibrate()
+	}
+
+	if rmi.conf.leafFinalizer != nil && adjusted {
+		rmi.finalizeLeaves(rmi.current.Load())
+	}
+
+	return rmi, nil
+}
+
+// allocate sets the shape of an RMI over the given number of keys, checked
+// against the configuration, and returns its (untrained) layers
+func (rmi *RMI) allocate(keys, width, depth int) ([][]*Node, error) {
+
+	// reject (or clamp) configurations that make no sense for the data
+	width, depth, err := rmi.conf.checkShape(keys, width, depth)
+	if err != nil {
+		return nil, err
+	}
+
+	// check the model fits the memory budget before allocating any layer
+	width, err = rmi.conf.fitModelBytes(width, depth)
+	if err != nil {
+		return nil, err
+	}
+
 	nodes := make([][]*Node, depth)
 	layerSize := 1
 	for i := range nodes {
@@ -64,83 +209,360 @@ func NewRMI(
 		layerSize *= width
 	}
 
-	rmi := RMI{}
-	rmi.maxIndex = len(values) - 1
-	rmi.nodes = nodes
 	rmi.width = width
 	rmi.depth = depth
 
-	// build the RMI
-	rmi.root = rmi.buildRecursive(values, indices, big.NewInt(0), 0, 0)
+	if rmi.conf.routingCapacity > 0 {
+		rmi.routing = newRoutingCache(rmi.conf.routingShift, rmi.conf.routingCapacity)
+	}
 
-	return &rmi, nil
+	return nodes, nil
 }
 
 // GetIndex returns the approximate index for the provided value query
 // this is done by having each model (starting from the root) predict
-// the model at the subsequent layer that should be queried
+// the model at the subsequent layer that should be queried; the accuracy
+// guaranteed for the keys of the model is stated by Contract
 func (rmi *RMI) GetIndex(value *big.Int) int {
+	return rmi.GetIndexContext(context.Background(), value)
+}
 
-	width := big.NewFloat(float64(rmi.width))
+// GetIndexContext is GetIndex but emits (sampled) query spans
+// as children of the span carried by ctx, see WithQueryTracing
+func (rmi *RMI) GetIndexContext(ctx context.Context, value *big.Int) int {
+	return rmi.getIndexAt(ctx, rmi.current.Load(), value)
+}
 
-	// current node that is going to predict the next model for the value
-	currentNode := rmi.root
+// GetIndicesInto stores GetIndex of every value in out, which must have room
+// for len(values) indices; all queries use one version of the model and share
+// one set of temporaries, so only big.Float additions allocate (the frozen
+// layout answers without any allocation, see Freeze)
+func (rmi *RMI) GetIndicesInto(values []*big.Int, out []int) {
 
-	nextLayer := 1
+	v := rmi.current.Load()
+	s := getScratch(nil)
+	defer putScratch(s)
+	s.hook = rmi.conf.traceHook
 
-	// iterate through all nodes
-	for {
+	for i, value := range values {
+		s.set(value)
+		out[i] = rmi.getIndexWith(s, v)
+	}
+}
 
-		m := currentNode.m
-		b := currentNode.b
-		res := big.NewFloat(0)
+// getIndexAt is GetIndexContext over a specific version of the model
+func (rmi *RMI) getIndexAt(ctx context.Context, v *version, value *big.Int) int {
+	if !rmi.sampleQuery() {
+		return rmi.queryIndex(v, value)
+	}
 
-		if nextLayer == rmi.depth {
-			// reached the leaf layer; return the predicted index (not divided by the width)
-			nextIndex64, _ := res.Mul(m, new(big.Float).SetInt(value)).Add(res, b).Int64()
-			nextIndex := int(nextIndex64)
-			if nextIndex > rmi.maxIndex {
-				return rmi.maxIndex
-			} else if nextIndex < 0 {
-				return 0
-			}
+	_, span := rmi.startSpan(ctx, SpanQuery)
+	index := rmi.queryIndex(v, value)
+	span.SetAttribute("index", index)
+	span.End()
+
+	return index
+}
+
+// getIndex traverses version v of the model from the root to a leaf
+// and returns the leaf's prediction clamped to the valid index range
+func (rmi *RMI) getIndex(v *version, value *big.Int) int {
+
+	s := getScratch(value)
+	defer putScratch(s)
+
+	return rmi.getIndexWith(s, v)
+}
+
+// queryIndex is getIndex for a query of the user, reported to the trace hook
+func (rmi *RMI) queryIndex(v *version, value *big.Int) int {
+	index, _ := rmi.locate(v, value)
+	return index
+}
+
+// Locate returns GetIndex of value together with the position of the leaf
+// that predicted it in the leaf layer (see NumLeaves), from a single
+// traversal of the model, so per-leaf structures can follow the routing
+func (rmi *RMI) Locate(value *big.Int) (index int, leafID int) {
+	return rmi.locate(rmi.current.Load(), value)
+}
+
+// locate is Locate over a specific version of the model
+func (rmi *RMI) locate(v *version, value *big.Int) (int, int) {
+
+	s := getScratch(value)
+	defer putScratch(s)
+	s.hook = rmi.conf.traceHook
+
+	return rmi.locateWith(s, v)
+}
+
+// getIndexWith is getIndex for the query value held by s
+func (rmi *RMI) getIndexWith(s *scratch, v *version) int {
+	index, _ := rmi.locateWith(s, v)
+	return index
+}
+
+// locateWith is locate for the query value held by s
+func (rmi *RMI) locateWith(s *scratch, v *version) (int, int) {
+
+	value := s.value
+	leaf, loc := rmi.leafWith(s, v)
+
+	// keys of leaves with a last-mile table are answered exactly
+	if leaf.exact != nil {
+		if index, ok := leaf.exact[tableKey(value)]; ok {
+			s.trace(rmi.depth-1, loc, big.NewFloat(float64(index)))
+			return index, loc
+		}
+	}
+
+	// reached the leaf layer; return the predicted index (not divided by the width)
+	prediction := s.eval(leaf)
+	s.trace(rmi.depth-1, loc, prediction)
+	if rmi.conf.neighborClamp {
+		prediction = rmi.clampToNeighbors(v, s.parent, loc, prediction)
+	}
+	if v.calib != nil {
+		prediction = v.calib.apply(prediction)
+	}
+
+	return clampIndex(prediction, v.maxIndex), loc
+}
+
+// clampIndex truncates the prediction to an integer in [0, maxIndex];
+// the bounds are checked in int64 before converting to int so that huge
+// predictions cannot wrap around on platforms where int is 32 bits
+func clampIndex(prediction *big.Float, maxIndex int) int {
+
+	index, _ := prediction.Int64()
+	if index > int64(maxIndex) {
+		return maxIndex
+	} else if index < 0 {
+		return 0
+	}
 
-			return nextIndex
+	return int(index)
+}
+
+// Predict returns the raw output of the leaf model for value,
+// without truncating it to an integer or clamping it to the index range
+// (and without applying the calibration, see WithCalibration)
+func (rmi *RMI) Predict(value *big.Int) *big.Float {
+	leaf, _ := rmi.leaf(rmi.current.Load(), value)
+	return leaf.predict(value)
+}
+
+// PredictFloat64 is Predict converted to the nearest float64
+func (rmi *RMI) PredictFloat64(value *big.Int) float64 {
+	prediction, _ := rmi.Predict(value).Float64()
+	return prediction
+}
+
+// evaluates the node model mx+b at x = value
+func (node *Node) predict(value *big.Int) *big.Float {
+	res := new(big.Float).Mul(node.m, node.inputOf(value))
+	return res.Add(res, node.b)
+}
+
+// leaf returns the leaf node of version v responsible for value together
+// with its location in the leaf layer
+func (rmi *RMI) leaf(v *version, value *big.Int) (*Node, int) {
+	s := getScratch(value)
+	defer putScratch(s)
+
+	return rmi.leafWith(s, v)
+}
+
+// leafWith is leaf for the query value held by s; this is done by having
+// each model (starting from the root) predict the model at the subsequent layer
+func (rmi *RMI) leafWith(s *scratch, v *version) (*Node, int) {
+
+	s.width.SetFloat64(float64(rmi.width))
+	s.maxIndex.SetFloat64(routingMaxIndex(v.maxIndex))
+
+	// current node that is going to predict the next model for the value
+	currentNode := v.root
+	location := 0
+	firstLayer := 1
+	s.parent = nil
+
+	// hot key prefixes skip the evaluation of the root (see routing.go)
+	if rmi.routing != nil && rmi.depth > 1 {
+		if loc, ok := rmi.routing.lookup(s, v); ok {
+			currentNode = v.node(1, loc)
+			location = loc
+			s.parent = v.root
+			firstLayer = 2
+			s.width.Mul(s.width, s.factor.SetFloat64(float64(rmi.width)))
 		}
+	}
+
+	for nextLayer := firstLayer; nextLayer < rmi.depth; nextLayer++ {
 
 		// take the model prediction and figure out which child
 		// node to select by dividing by layer width
-		res.Mul(m, new(big.Float).SetInt(value)).Add(res, b) // mx+b
-		res.Quo(res, big.NewFloat(float64(rmi.maxIndex)))    // compute index relative to max index (percentage)
-		res.Mul(res, width)                                  // * number of nodes to get index of the responsible node
-		nextIndex64, _ := res.Int64()
-		nextIndex := int(nextIndex64)
+		res := s.eval(currentNode) // mx+b
+		s.trace(nextLayer-1, location, res)
+		res.Quo(res, s.maxIndex) // compute index relative to max index (percentage)
+		res.Mul(res, s.width)    // * number of nodes to get index of the responsible node
 
 		// make sure the predicted index is within the bounds
-		if nextIndex < 0 {
-			nextIndex = 0
-		} else if nextIndex >= len(rmi.nodes[nextLayer]) {
-			nextIndex = len(rmi.nodes[nextLayer]) - 1
+		nextIndex := clampIndex(res, v.layerSize(nextLayer)-1)
+
+		if nextLayer == 1 && rmi.routing != nil {
+			rmi.routing.remember(rmi, s, v, nextIndex)
 		}
 
-		currentNode = rmi.nodes[nextLayer][nextIndex]
-		nextLayer++
-		width.Mul(width, big.NewFloat(float64(rmi.width)))
+		s.parent = currentNode
+		currentNode = v.node(nextLayer, nextIndex)
+		location = nextIndex
+		s.width.Mul(s.width, s.factor.SetFloat64(float64(rmi.width)))
 	}
+
+	return currentNode.modelFor(s.value), location
 }
 
-// Builds the RMI structure recursively from top
-// Note: doesnt create new arrays, calculates on same array given two boundaries
-func (rmi *RMI) buildRecursive(
-	values []*big.Int,
-	indices []*big.Int,
-	offset *big.Int,
-	currentDepth int,
-	locationInLayer int) *Node {
+// routingMaxIndex is the max index the layer predictions are divided by
+// when selecting a child; a model over a single key has max index 0, and
+// its (zero) predictions route to the first child instead of dividing 0/0
+func routingMaxIndex(maxIndex int) float64 {
+	return math.Max(1, float64(maxIndex))
+}
 
-	node := &Node{}
+// training data of a single node in the model
+// offset: start index of the bucket the node's ancestor is responsible for
+type buildTask struct {
+	values, indices []*big.Int
+	offset          *big.Int
+}
+
+// Builds the RMI structure layer by layer from the top into nodes
+// Note: doesnt create new arrays, each node trains on a slice of the given arrays
+func (rmi *RMI) build(ctx context.Context, nodes [][]*Node, values []*big.Int, indices []*big.Int) error {
+
+	layer := []buildTask{{values, indices, big.NewInt(0)}}
+
+	for currentDepth := 0; currentDepth < rmi.depth; currentDepth++ {
+		_, span := rmi.startSpan(ctx, SpanBuildLayer)
+
+		train := rmi.conf.fitFor(currentDepth)
+		batch := rmi.conf.modelOf(currentDepth) == ModelLinear
+		if currentDepth == rmi.depth-1 {
+			train = rmi.trainLeaf
+			batch = batch && !rmi.conf.leafEnsemble
+		} else if currentDepth == 0 && rmi.conf.radixRoot {
+			train = rmi.trainRadix
+			batch = false
+		}
+
+		// large nodes are trained on a subsample of their keys (see WithSampleAbove)
+		tasks := layer
+		if rmi.conf.sampleAbove > 0 {
+			tasks = make([]buildTask, len(layer))
+			for i, task := range layer {
+				tasks[i] = rmi.conf.subsample(task)
+			}
+		}
+
+		// the root passes over all keys, a lazy sort check is part of its sums
+		sampledRoot := len(tasks[0].values) != len(layer[0].values)
+		if currentDepth == 0 && rmi.conf.lazySort && (!batch || sampledRoot) {
+			if err := checkSorted(values); err != nil {
+				span.End()
+				return err
+			}
+		}
+
+		if batch {
+			if err := rmi.trainBatch(nodes[currentDepth], tasks); err != nil {
+				span.End()
+				return err
+			}
+		} else {
+			rmi.trainLayer(nodes[currentDepth], tasks, train)
+		}
+
+		// leaf layer not reached yet, split the data among the children of each node
+		next := make([]buildTask, 0)
+		for i, task := range layer {
+			if currentDepth == rmi.depth-1 {
+				break
+			} else if currentDepth == 0 && rmi.conf.radixRoot {
+				next = rmi.splitByRoute(task, nodes[0][i], next)
+			} else {
+				next = rmi.splitTask(task, next)
+			}
+		}
+
+		span.SetAttribute("layer", currentDepth)
+		span.SetAttribute("nodes", len(layer))
+		span.End()
+
+		layer = next
+	}
 
-	rmi.nodes[currentDepth][locationInLayer] = node
+	return nil
+}
+
+// trains the nodes of a layer on their tasks; sibling subtrees share no
+// data, so the nodes are trained by the configured number of goroutines
+// (see WithParallelism)
+func (rmi *RMI) trainLayer(nodes []*Node, tasks []buildTask, train func(buildTask) *Node) {
+
+	workers := rmi.conf.workers(len(tasks))
+	if workers <= 1 {
+		p := rmi.conf.pacer()
+		for i, task := range tasks {
+			nodes[i] = train(task)
+			p.pause()
+		}
+		return
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			p := rmi.conf.pacer()
+			for i := w; i < len(tasks); i += workers {
+				nodes[i] = train(tasks[i])
+				p.pause()
+			}
+		}(w)
+	}
+	wg.Wait()
+}
+
+// trains a leaf node with the configured leaf options
+func (rmi *RMI) trainLeaf(task buildTask) *Node {
+
+	node := rmi.conf.fitFor(rmi.depth - 1)(task)
+	if rmi.conf.leafEnsemble {
+		node = trainEnsemble(task)
+	}
+
+	return node
+}
+
+// returns a linear node with (copies of) the given coefficients
+func newLinearNode(m *big.Float, b *big.Float) *Node {
+	node := &Node{m: new(big.Float).Copy(m), b: new(big.Float).Copy(b)}
+	node.w = xIntercept(node.m, node.b)
+	return node
+}
+
+// trains the linear model of a single node
+func trainNode(task buildTask) *Node {
+	return trainLinear(task, 0)
+}
+
+// trains the linear model of a single node with coefficients
+// correctly rounded to prec bits (0 means 53, see WithPrecision)
+func trainLinear(task buildTask, prec uint) *Node {
+
+	node := &Node{}
 
 	// compute linear regression for the data of this node
 	// m: slope
@@ -150,61 +572,90 @@ func (rmi *RMI) buildRecursive(
 	m := big.NewFloat(0.0)
 	w := big.NewFloat(0.0)
 
-	if len(indices) >= 2 {
-		b, m, w = coefficients(values, indices)
+	if len(task.indices) >= 2 {
+		b, m, w = coefficients(task.values, task.indices, prec)
 	} else {
 		// this handles the special case where the node contains fewer than 2 points (can't compute regression).
 		// The node must still return an index and so it returns offset
 		// (the start index of bucket its ancestor is responsible for)
-		b = new(big.Float).SetInt(offset)
+		b = new(big.Float).SetInt(task.offset)
 	}
 
 	node.b = b
 	node.m = m
 	node.w = w
 
-	// leaf layer not reached yet, recursivley create children for the current node
-	if currentDepth != rmi.depth-1 {
-		currentDepth++
+	return node
+}
 
-		// find the range (number of values) that the current layer must learn
-		rangeSize := int(float64(len(values)) / float64(rmi.width))
+// trains the line through the first and last (key, index) of a node,
+// see WithEndpointFit
+func trainEndpoints(task buildTask) *Node {
 
-		//left and right bounds index bounds
-		leftIndex := 0
-		rightIndex := int(math.Max(0, float64(rangeSize)))
+	if len(task.indices) < 2 {
+		return trainNode(task)
+	}
 
-		for i := 0; i < rmi.width; i++ {
+	b, m, w := endpointCoefficients(task.values, task.indices)
+	return &Node{m: m, b: b, w: w, fit: ModelEndpoint}
+}
 
-			// slice of indicies for the children nodes
-			subIndices := make([]*big.Int, 0)
+// returns the bounds [lo, hi) of the keys of a node with n keys
+// that each of its rmi.width children learns
+func (rmi *RMI) childBounds(n int) [][2]int {
 
-			// make sure that the indices are within bounds
-			if rightIndex <= 0 {
-				rightIndex = 0
-				leftIndex = 0
-			} else if rightIndex >= len(indices) {
-				rightIndex = len(indices) - 1
-			}
+	// with a single child per node (a chain of models) the child learns
+	// all of its parent's data; the split below would drop the last key
+	if rmi.width == 1 {
+		return [][2]int{{0, n}}
+	}
 
-			// update the offset; used in case the slice is empty
-			// to make sure the node returns the right index
-			if leftIndex != rightIndex {
-				subIndices = indices[leftIndex:rightIndex]
-				offset = subIndices[0]
-			}
+	// find the range (number of values) that the current layer must learn
+	rangeSize := int(float64(n) / float64(rmi.width))
+
+	//left and right bounds index bounds
+	leftIndex := 0
+	rightIndex := int(math.Max(0, float64(rangeSize)))
 
-			rmi.buildRecursive(
-				values[leftIndex:rightIndex],
-				subIndices,
-				offset,
-				currentDepth,
-				locationInLayer*rmi.width+i)
+	bounds := make([][2]int, rmi.width)
+	for i := range bounds {
 
-			leftIndex = rightIndex
-			rightIndex = int(math.Max(0, math.Min(float64(rightIndex+rangeSize), float64(len(indices)))-1))
+		// make sure that the indices are within bounds
+		if rightIndex <= 0 {
+			rightIndex = 0
+			leftIndex = 0
+		} else if rightIndex >= n {
+			rightIndex = n - 1
 		}
+
+		bounds[i] = [2]int{leftIndex, rightIndex}
+
+		leftIndex = rightIndex
+		rightIndex = int(math.Max(0, math.Min(float64(rightIndex+rangeSize), float64(n))-1))
 	}
 
-	return node
+	return bounds
+}
+
+// splits the training data of a node into rmi.width child tasks
+// and appends them (in order) to next
+func (rmi *RMI) splitTask(task buildTask, next []buildTask) []buildTask {
+
+	offset := task.offset
+	for _, bound := range rmi.childBounds(len(task.values)) {
+
+		// slice of indicies for the children nodes
+		subIndices := make([]*big.Int, 0)
+
+		// update the offset; used in case the slice is empty
+		// to make sure the node returns the right index
+		if bound[0] != bound[1] {
+			subIndices = task.indices[bound[0]:bound[1]]
+			offset = subIndices[0]
+		}
+
+		next = append(next, buildTask{task.values[bound[0]:bound[1]], subIndices, offset})
+	}
+
+	return next
 }
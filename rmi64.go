@@ -0,0 +1,65 @@
+// rmi64.go: fast path for machine-word keys. RMI64 is the TypedRMI over
+// uint64 keys with the key conversion inlined, so queries are a handful of
+// float64 multiply-adds and a bounded binary search without allocations.
+
+package rmi
+
+import (
+	"math"
+	"sort"
+)
+
+/*
+RMI64 is an RMI over sorted uint64 keys with float64 coefficients
+TypedRMI: the model (see typed.go)
+*/
+type RMI64 struct {
+	*TypedRMI[uint64]
+}
+
+// NewRMI64 builds an index over keys, which must be sorted and are
+// retained (not copied); see NewRMI for the meaning of width and depth
+func NewRMI64(keys []uint64, width int, depth int) (*RMI64, error) {
+
+	r, err := NewTypedRMI(keys, width, depth)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RMI64{r}, nil
+}
+
+// GetIndex returns the approximate index of key
+func (r *RMI64) GetIndex(key uint64) int {
+	_, index := r.predict(float64(key))
+	return index
+}
+
+// GetIndexWithBounds returns the window [lo, hi] guaranteed
+// to contain the index of key if it is one of the keys
+func (r *RMI64) GetIndexWithBounds(key uint64) (int, int) {
+	loc, prediction := r.predict(float64(key))
+	lo := int(math.Max(0, float64(prediction+r.minResidual[loc])))
+	hi := int(math.Min(float64(len(r.keys)-1), float64(prediction+r.maxResidual[loc])))
+
+	return lo, hi
+}
+
+// Lookup returns the index of the first occurrence of key (or false if absent)
+func (r *RMI64) Lookup(key uint64) (int, bool) {
+
+	if len(r.keys) == 0 {
+		return 0, false
+	}
+
+	lo, hi := r.GetIndexWithBounds(key)
+	index := lo + sort.Search(hi-lo+1, func(i int) bool {
+		return r.keys[lo+i] >= key
+	})
+
+	if index <= hi && r.keys[index] == key {
+		return index, true
+	}
+
+	return 0, false
+}
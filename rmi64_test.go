@@ -0,0 +1,73 @@
+package rmi
+
+import (
+	"math/big"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+func TestRMI64(t *testing.T) {
+
+	keys := make([]uint64, 10000)
+	for i := range keys {
+		keys[i] = rand.Uint64() | 1
+	}
+	slices.Sort(keys)
+
+	r, err := NewRMI64(keys, RMIWidthParameter, 3)
+	if err != nil {
+		t.Fatalf("Failed to build RMI64 %v\n", err)
+	}
+
+	typed, _ := NewTypedRMI(keys, RMIWidthParameter, 3)
+	for i, key := range keys {
+		if r.GetIndex(key) != typed.GetIndex(key) {
+			t.Fatalf("RMI64 and TypedRMI disagree on key %v", i)
+		}
+
+		index, ok := r.Lookup(key)
+		if !ok || keys[index] != key {
+			t.Fatalf("Lookup of key %v returned %v, %v", i, index, ok)
+		}
+	}
+
+	if _, ok := r.Lookup(0); ok {
+		t.Fatalf("found absent key 0")
+	}
+}
+
+func BenchmarkRMI64Lookup(b *testing.B) {
+	keys := make([]uint64, NumDataPoints)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	slices.Sort(keys)
+	r, _ := NewRMI64(keys, RMIWidthParameter, RMIDepthParameter)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Lookup(keys[i%len(keys)])
+	}
+}
+
+func BenchmarkBigIntLookup(b *testing.B) {
+	keys := make([]uint64, NumDataPoints)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	slices.Sort(keys)
+
+	values := make([]*big.Int, len(keys))
+	for i, key := range keys {
+		values[i] = new(big.Int).SetUint64(key)
+	}
+	r, _ := NewRMI(values, RMIWidthParameter, RMIDepthParameter)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Lookup(values[i%len(values)])
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"math"
 	"math/big"
 	"math/rand"
+	"slices"
 	"sort"
 	"testing"
 	"time"
@@ -12,26 +13,36 @@ import (
 // test configuration parameters
 const RMIWidthParameter int = 10
 const RMIDepthParameter int = 2
-const MinDataValue int = 0
-const MaxDataValue int = math.MaxInt64
+const MinDataValue int64 = 0
+const MaxDataValue int64 = math.MaxInt64
 const NumDataPoints int = 10000
 const NumQueries int = 20
 
 // error tolerance (true index - pred index); this is a heuristic that
-// depends on the data parameters above
-// TODO: devise a more rigorous notion of accuracy and measurment for this test
+// depends on the data parameters above (the measured bound is ErrorStats)
 const QueryAccuracyThreshold float64 = 200.0
 
 // generates 'n' random values in the range min..max
-func generateRandomData(n int, min int, max int) []*big.Int {
+func generateRandomData(n int, min int64, max int64) []*big.Int {
 	values := make([]*big.Int, n)
 	for i := range values {
-		values[i] = big.NewInt(int64(rand.Intn(max-min) + min))
+		values[i] = big.NewInt(rand.Int63n(max-min) + min)
 	}
 
 	return values
 }
 
+// generates 'n' random values over the full data range in sorted order
+func sortedTestData(n int) []*big.Int {
+	values := generateRandomData(n, MinDataValue, MaxDataValue)
+
+	sort.Slice(values, func(i, j int) bool {
+		return values[i].Cmp(values[j]) == -1
+	})
+
+	return values
+}
+
 // Generates random data and constructs an RMI
 // data structure over it.
 // All parameters are specified up top.
@@ -101,6 +112,7 @@ func TestGetIndex(t *testing.T) {
 	rand.Seed(time.Now().Unix())
 
 	rmi, values, _ := generateTestRMI()
+	stats := rmi.ErrorStats()
 
 	avgErr := 0.0
 	for i := 0; i < NumQueries; i++ {
@@ -111,7 +123,11 @@ func TestGetIndex(t *testing.T) {
 		avgErr += err
 		t.Logf("dist err %v (actual index = %v)\n", err, actualIndex)
 
-		// TODO: have error guarantees on the model and check them here; 100 is arbitrary
+		// the build measured the error of every key
+		if err > float64(stats.Max) {
+			t.Fatalf("Error exceeds the measured max error: %v > %v", err, stats.Max)
+		}
+
 		if err > QueryAccuracyThreshold {
 			t.Fatalf(
 				"Error is too large: %v > %v",
@@ -138,3 +154,87 @@ func BenchmarkBuild(b *testing.B) {
 		NewRMI(values, RMIWidthParameter, RMIDepthParameter)
 	}
 }
+
+func TestPredict(t *testing.T) {
+	rmi, values, _ := generateTestRMI()
+
+	for i := 0; i < NumQueries; i++ {
+		value := values[rand.Intn(NumDataPoints)]
+		prediction, _ := rmi.Predict(value).Int64()
+
+		index := int(math.Max(0, math.Min(float64(prediction), float64(NumDataPoints-1))))
+		if index != rmi.GetIndex(value) {
+			t.Fatalf("clamped prediction %v does not match GetIndex %v", index, rmi.GetIndex(value))
+		}
+	}
+
+	// far beyond the largest key the raw prediction is not clamped
+	beyond := new(big.Int).Mul(values[NumDataPoints-1], big.NewInt(4))
+	if rmi.PredictFloat64(beyond) <= float64(NumDataPoints-1) {
+		t.Fatalf("expected unclamped prediction, got %v", rmi.PredictFloat64(beyond))
+	}
+}
+
+func BenchmarkGetIndexParallel(b *testing.B) {
+	rmi, values, _ := generateTestRMI()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			rmi.GetIndex(values[i%len(values)])
+			i++
+		}
+	})
+}
+
+// keys above math.MaxInt64 and extreme queries must never produce indices
+// outside [0, maxIndex]; run with GOARCH=386 to cover 32-bit int
+func TestUint64KeysAndOverflow(t *testing.T) {
+	values := make([]*big.Int, NumDataPoints)
+	for i := range values {
+		values[i] = new(big.Int).SetUint64(rand.Uint64() | 1<<63)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		return values[i].Cmp(values[j]) == -1
+	})
+
+	rmi, err := NewRMI(values, RMIWidthParameter, RMIDepthParameter)
+	if err != nil {
+		t.Fatalf("Failed to build RMI %v\n", err)
+	}
+
+	if rmi.MaxError() > int(QueryAccuracyThreshold) {
+		t.Fatalf("Error is too large: %v > %v", rmi.MaxError(), QueryAccuracyThreshold)
+	}
+
+	huge := new(big.Int).Lsh(big.NewInt(1), 200)
+	if index := rmi.GetIndex(huge); index != NumDataPoints-1 {
+		t.Fatalf("huge query predicted index %v", index)
+	}
+
+	if index := rmi.GetIndex(new(big.Int).Neg(huge)); index != 0 {
+		t.Fatalf("hugely negative query predicted index %v", index)
+	}
+
+	if clampIndex(new(big.Float).SetInt(huge), math.MaxInt32) != math.MaxInt32 {
+		t.Fatalf("prediction beyond int range was not clamped")
+	}
+}
+
+func TestLocate(t *testing.T) {
+	rmi, values, _ := generateTestRMI()
+	snapshot := rmi.Pin()
+
+	for _, value := range append(slices.Clone(values[:1000]), big.NewInt(-1), big.NewInt(MaxDataValue)) {
+		index, leaf := rmi.Locate(value)
+		if _, loc := rmi.leaf(rmi.current.Load(), value); index != rmi.GetIndex(value) || leaf != loc {
+			t.Fatalf("Locate returned (%v, %v), expected (%v, %v)", index, leaf, rmi.GetIndex(value), loc)
+		}
+
+		if i, l := snapshot.Locate(value); i != index || l != leaf {
+			t.Fatalf("snapshot located (%v, %v), expected (%v, %v)", i, l, index, leaf)
+		}
+	}
+}
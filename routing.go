@@ -0,0 +1,99 @@
+// routing.go: cache of root routing decisions for hot key prefixes.
+// The root is a linear model, so (with a non-negative slope) the second
+// layer node it selects is monotone in the key; if both ends of a prefix's
+// key range select the same node then every key with that prefix does, and
+// the decision can be cached exactly. The cache is direct-mapped with a fixed
+// number of slots, so its memory is bounded and hot prefixes stay resident.
+
+package rmi
+
+import (
+	"math/big"
+	"sync/atomic"
+)
+
+/*
+Cached routing decision
+prefix: the key prefix (key >> shift)
+epoch: version of the model the decision was made on
+location: position of the selected node in the second layer
+*/
+type routingEntry struct {
+	prefix   *big.Int
+	epoch    uint64
+	location int
+}
+
+/*
+Direct-mapped cache of routing decisions
+shift: number of low key bits dropped to obtain the prefix
+slots: the cached decisions, indexed by the low bits of the prefix
+hits, misses: lookup counters
+*/
+type routingCache struct {
+	shift        uint
+	slots        []atomic.Pointer[routingEntry]
+	hits, misses atomic.Uint64
+}
+
+func newRoutingCache(shift uint, capacity int) *routingCache {
+	return &routingCache{shift: shift, slots: make([]atomic.Pointer[routingEntry], capacity)}
+}
+
+// slot of the prefix held by s
+func (c *routingCache) slot(s *scratch) *atomic.Pointer[routingEntry] {
+	return &c.slots[s.prefix.Uint64()%uint64(len(c.slots))]
+}
+
+// lookup returns the cached second layer node for the query held by s
+func (c *routingCache) lookup(s *scratch, v *version) (int, bool) {
+
+	s.prefix.Rsh(s.value, c.shift)
+
+	entry := c.slot(s).Load()
+	if entry != nil && entry.epoch == v.epoch && entry.prefix.Cmp(s.prefix) == 0 {
+		c.hits.Add(1)
+		return entry.location, true
+	}
+
+	c.misses.Add(1)
+	return 0, false
+}
+
+// remember caches the routing of the query's prefix if every key with
+// that prefix is routed to the same second layer node (location)
+func (c *routingCache) remember(rmi *RMI, s *scratch, v *version, location int) {
+
+	if v.root.m.Sign() == -1 {
+		return
+	}
+
+	lo := new(big.Int).Lsh(s.prefix, c.shift)
+	hi := new(big.Int).Add(s.prefix, big.NewInt(1))
+	hi.Lsh(hi, c.shift).Sub(hi, big.NewInt(1))
+
+	if rmi.rootChild(v, lo) != location || rmi.rootChild(v, hi) != location {
+		return
+	}
+
+	c.slot(s).Store(&routingEntry{new(big.Int).Set(s.prefix), v.epoch, location})
+}
+
+// rootChild returns the second layer node the root of v selects for value
+func (rmi *RMI) rootChild(v *version, value *big.Int) int {
+	res := v.root.predict(value)
+	res.Quo(res, big.NewFloat(routingMaxIndex(v.maxIndex)))
+	res.Mul(res, big.NewFloat(float64(rmi.width)))
+
+	return clampIndex(res, v.layerSize(1)-1)
+}
+
+// RoutingCacheStats returns the number of routing cache hits and misses
+// (both zero if WithRoutingCache was not used)
+func (rmi *RMI) RoutingCacheStats() (uint64, uint64) {
+	if rmi.routing == nil {
+		return 0, 0
+	}
+
+	return rmi.routing.hits.Load(), rmi.routing.misses.Load()
+}
@@ -0,0 +1,40 @@
+package rmi
+
+import (
+	"testing"
+)
+
+func TestRoutingCache(t *testing.T) {
+	values := sortedTestData(5000)
+	plain, _ := NewRMI(values, RMIWidthParameter, 3)
+	cached, err := NewRMI(values, RMIWidthParameter, 3, WithRoutingCache(40, 64))
+	if err != nil {
+		t.Fatalf("Failed to build RMI %v\n", err)
+	}
+
+	// hot range: repeatedly query a small set of keys
+	for round := 0; round < 5; round++ {
+		for i := 2000; i < 2100; i++ {
+			if cached.GetIndex(values[i]) != plain.GetIndex(values[i]) {
+				t.Fatalf("cached routing changed the prediction of key %v", i)
+			}
+		}
+	}
+
+	// cached decisions must stay exact for all keys
+	for i, value := range values {
+		if cached.GetIndex(value) != plain.GetIndex(value) {
+			t.Fatalf("cached routing changed the prediction of key %v", i)
+		}
+	}
+
+	hits, misses := cached.RoutingCacheStats()
+	t.Logf("routing cache hits %v misses %v", hits, misses)
+	if hits == 0 {
+		t.Fatalf("expected routing cache hits on a hot range")
+	}
+
+	if hits, misses := plain.RoutingCacheStats(); hits != 0 || misses != 0 {
+		t.Fatalf("expected no routing cache stats without a cache")
+	}
+}
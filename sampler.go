@@ -0,0 +1,95 @@
+// sampler.go: opt-in background accuracy sampling. A sampler periodically
+// queries a random sample of the retained keys against the current version
+// of the model and publishes the measured error, so monitoring can read the
+// live accuracy without running an evaluation over all keys.
+
+package rmi
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"time"
+)
+
+/*
+AccuracyStats is the error of the model measured on a sample of its keys
+Epoch: version of the model the sample was measured on
+Samples: number of (live) keys queried
+MaxError, MeanError: maximum and mean distance between GetIndex and the true index
+SearchSteps: mean binary search steps after the prediction (see ExpectedSearchSteps)
+SampledAt: time the sample was taken
+*/
+type AccuracyStats struct {
+	Epoch       uint64
+	Samples     int
+	MaxError    int
+	MeanError   float64
+	SearchSteps float64
+	SampledAt   time.Time
+}
+
+// SampleAccuracy measures the error of the current version of the model on
+// n keys drawn uniformly at random with r (deleted keys are skipped) and
+// publishes the result as the latest AccuracyStats
+func (rmi *RMI) SampleAccuracy(r *rand.Rand, n int) AccuracyStats {
+
+	v := rmi.current.Load()
+	deleted := rmi.deletions()
+	errs := rmi.errorsOf(v)
+
+	stats := AccuracyStats{Epoch: v.epoch, SampledAt: time.Now()}
+	sum, steps := 0.0, 0.0
+	for i := 0; i < n && len(rmi.values) > 0; i++ {
+		index := r.Intn(len(rmi.values))
+		if deleted.isDeleted(index) {
+			continue
+		}
+
+		distance := int(math.Abs(float64(rmi.getIndex(v, rmi.values[index]) - index)))
+		stats.MaxError = int(math.Max(float64(stats.MaxError), float64(distance)))
+		sum += float64(distance)
+		stats.Samples++
+
+		_, loc := rmi.leaf(v, rmi.values[index])
+		steps += errs[loc].searchSteps()
+	}
+
+	if stats.Samples > 0 {
+		stats.MeanError = sum / float64(stats.Samples)
+		stats.SearchSteps = steps / float64(stats.Samples)
+	}
+
+	rmi.accuracy.Store(&stats)
+	return stats
+}
+
+// AccuracyStats returns the latest sampled accuracy (see RunAccuracySampler)
+// and false if no sample was taken yet
+func (rmi *RMI) AccuracyStats() (AccuracyStats, bool) {
+	stats := rmi.accuracy.Load()
+	if stats == nil {
+		return AccuracyStats{}, false
+	}
+
+	return *stats, true
+}
+
+// RunAccuracySampler samples the accuracy of n keys every interval until
+// ctx is done; it must be started explicitly (go rmi.RunAccuracySampler(...))
+// and needs the keys to be retained (see Load)
+func (rmi *RMI) RunAccuracySampler(ctx context.Context, interval time.Duration, n int) {
+
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			rmi.SampleAccuracy(r, n)
+		}
+	}
+}
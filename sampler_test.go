@@ -0,0 +1,64 @@
+package rmi
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestSampleAccuracy(t *testing.T) {
+
+	rmi, values, err := generateTestRMI()
+	if err != nil {
+		t.Fatalf("Failed to build RMI %v\n", err)
+	}
+
+	if _, ok := rmi.AccuracyStats(); ok {
+		t.Fatalf("expected no accuracy stats before sampling")
+	}
+
+	// sampling every key with replacement stays within the exact error
+	stats := rmi.SampleAccuracy(rand.New(rand.NewSource(1)), 2*len(values))
+	if stats.Samples != 2*len(values) || stats.MaxError > rmi.MaxError() || stats.Epoch != rmi.Epoch() {
+		t.Fatalf("unexpected accuracy stats %+v (max error %v)", stats, rmi.MaxError())
+	}
+
+	if stats.SearchSteps <= 0 || stats.SearchSteps > math.Log2(float64(2*rmi.MaxError()+1)) {
+		t.Fatalf("sampled search steps %v out of range", stats.SearchSteps)
+	}
+
+	if latest, ok := rmi.AccuracyStats(); !ok || latest != stats {
+		t.Fatalf("sampled stats were not published")
+	}
+}
+
+func TestRunAccuracySampler(t *testing.T) {
+
+	rmi, _, err := generateTestRMI()
+	if err != nil {
+		t.Fatalf("Failed to build RMI %v\n", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		rmi.RunAccuracySampler(ctx, time.Millisecond, 10)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if stats, ok := rmi.AccuracyStats(); ok && stats.Samples == 10 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("sampler did not publish any stats")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	<-done
+}
@@ -0,0 +1,124 @@
+// sampling.go: index-aware sampling for approximate query processing. The
+// model maps key ranges to position ranges, so uniform samples of the keys
+// in a range are drawn as uniform positions within the range's bounds
+// without scanning it; without the keys (see Load), sampled positions are
+// mapped back to approximate keys by inverting the leaf models.
+
+package rmi
+
+import (
+	"math"
+	"math/big"
+	"math/rand"
+	"sort"
+)
+
+// draws per sample before giving up on finding a live position
+const sampleAttempts = 16
+
+// SampleUniformKeys draws k keys uniformly at random (with replacement)
+// with r, skipping deleted keys; without the keys of the model the samples
+// are the approximate keys at uniform positions under the learned CDF (of
+// the first models of the leaves, ignoring any calibration), and positions
+// in constant leaves are skipped. Fewer than k keys are returned if too
+// many draws are skipped, and none for a model over no keys
+func (rmi *RMI) SampleUniformKeys(r *rand.Rand, k int) []*big.Int {
+
+	v := rmi.current.Load()
+	if v.maxIndex < 0 {
+		return []*big.Int{}
+	}
+	deleted := rmi.deletions()
+
+	// the keys are routed to the leaves in order, so the last positions of
+	// the leaves with keys are increasing
+	errs := rmi.errorsOf(v)
+	covering := make([]int, 0, len(errs))
+	for loc, e := range errs {
+		if e.count > 0 {
+			covering = append(covering, loc)
+		}
+	}
+
+	samples := make([]*big.Int, 0, k)
+	for i := 0; i < k; i++ {
+		for attempt := 0; attempt < sampleAttempts; attempt++ {
+			pos := r.Intn(v.maxIndex + 1)
+			if deleted.isDeleted(pos) {
+				continue
+			}
+
+			if rmi.values != nil {
+				samples = append(samples, rmi.values[pos])
+				break
+			}
+
+			if key := rmi.keyAt(v, errs, covering, pos); key != nil {
+				samples = append(samples, key)
+				break
+			}
+		}
+	}
+
+	return samples
+}
+
+// SampleRange draws k positions uniformly at random (with replacement) with
+// r among the keys in [lo, hi], skipping deleted keys; the bounds are exact
+// with the keys of the model (see GetRange) and predicted without them.
+// No positions are returned for an empty range
+func (rmi *RMI) SampleRange(r *rand.Rand, lo, hi *big.Int, k int) []int {
+
+	start, end := 0, 0
+	if rmi.values != nil {
+		start, end = rmi.GetRange(lo, hi)
+	} else if lo.Cmp(hi) <= 0 {
+		start, end = rmi.GetIndex(lo), rmi.GetIndex(hi)+1
+	}
+
+	if end <= start {
+		return []int{}
+	}
+
+	deleted := rmi.deletions()
+	samples := make([]int, 0, k)
+	for i := 0; i < k; i++ {
+		for attempt := 0; attempt < sampleAttempts; attempt++ {
+			if pos := start + r.Intn(end-start); !deleted.isDeleted(pos) {
+				samples = append(samples, pos)
+				break
+			}
+		}
+	}
+
+	return samples
+}
+
+// keyAt returns the key predicted at position pos by inverting the model of
+// the leaf whose keys cover pos (nil if that model is constant); covering
+// are the leaves with keys in order and errs the error bounds of v
+func (rmi *RMI) keyAt(v *version, errs []leafError, covering []int, pos int) *big.Int {
+
+	j := sort.Search(len(covering), func(j int) bool {
+		return errs[covering[j]].last >= pos
+	})
+	if j == len(covering) {
+		return nil
+	}
+
+	leaf := v.nodes[rmi.depth-1][covering[j]]
+	if leaf.m.Sign() == 0 {
+		return nil
+	}
+
+	// x = (pos - b) / m, with x = log2(1+key) for log-linear models
+	x := new(big.Float).SetInt64(int64(pos))
+	x.Sub(x, leaf.b).Quo(x, leaf.m)
+	if leaf.log {
+		f, _ := x.Float64()
+		x.SetFloat64(math.Exp2(math.Min(f, 1023)) - 1)
+	}
+
+	key, _ := x.Int(nil)
+	return key
+}
@@ -0,0 +1,121 @@
+package rmi
+
+import (
+	"bytes"
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+func TestSampleRange(t *testing.T) {
+
+	rmi, values, err := generateTestRMI()
+	if err != nil {
+		t.Fatalf("Failed to build RMI %v\n", err)
+	}
+
+	lo, hi := values[1000], values[3000]
+	samples := rmi.SampleRange(rand.New(rand.NewSource(1)), lo, hi, 5000)
+	if len(samples) != 5000 {
+		t.Fatalf("drew %v samples, expected 5000", len(samples))
+	}
+
+	below := 0
+	for _, pos := range samples {
+		if values[pos].Cmp(lo) == -1 || values[pos].Cmp(hi) == 1 {
+			t.Fatalf("sampled key %v outside of the range", pos)
+		}
+		if pos < 2000 {
+			below++
+		}
+	}
+
+	// about half of the samples fall in the lower half of the range
+	if below < 2000 || below > 3000 {
+		t.Fatalf("%v of 5000 samples in the lower half of the range", below)
+	}
+
+	if len(rmi.SampleRange(rand.New(rand.NewSource(1)), hi, lo, 10)) != 0 {
+		t.Fatalf("sampled an empty range")
+	}
+}
+
+func TestSampleUniformKeys(t *testing.T) {
+
+	rmi, values, err := generateTestRMI()
+	if err != nil {
+		t.Fatalf("Failed to build RMI %v\n", err)
+	}
+
+	// without the keys, samples are drawn through the inverted leaves
+	var buf bytes.Buffer
+	rmi.Save(&buf)
+	model, err := Load(&buf, nil, Metadata{})
+	if err != nil {
+		t.Fatalf("Failed to load RMI %v\n", err)
+	}
+
+	r := rand.New(rand.NewSource(1))
+	for _, index := range []*RMI{rmi, model} {
+		samples := index.SampleUniformKeys(r, 4000)
+		if len(samples) < 3900 {
+			t.Fatalf("drew %v samples, expected 4000", len(samples))
+		}
+
+		// the sampled keys spread uniformly over the positions
+		median := values[len(values)/2]
+		below := 0
+		for _, key := range samples {
+			if key.Cmp(median) == -1 {
+				below++
+			}
+		}
+		if below < len(samples)*4/10 || below > len(samples)*6/10 {
+			t.Fatalf("%v of %v samples below the median key", below, len(samples))
+		}
+	}
+
+	if len(rmi.SampleUniformKeys(r, 0)) != 0 {
+		t.Fatalf("drew samples for k = 0")
+	}
+
+	empty, _ := NewRMI([]*big.Int{}, 2, 2)
+	if len(empty.SampleUniformKeys(r, 3)) != 0 {
+		t.Fatalf("drew samples from a model over no keys")
+	}
+}
+
+func TestSampleUniformKeysEmptyLeaves(t *testing.T) {
+
+	// two clusters of keys, leaving most leaves between them empty
+	values := make([]*big.Int, 0, 100)
+	for i := 0; i < 50; i++ {
+		values = append(values, big.NewInt(int64(i)))
+	}
+	for i := 0; i < 50; i++ {
+		values = append(values, big.NewInt(1e9+int64(i)))
+	}
+
+	rmi, err := NewRMI(values, 20, 2)
+	if err != nil {
+		t.Fatalf("Failed to build RMI %v\n", err)
+	}
+
+	data, _ := rmi.MarshalBinary()
+	model, err := UnmarshalRMI(data)
+	if err != nil {
+		t.Fatalf("Failed to load RMI %v\n", err)
+	}
+
+	// positions of the first cluster are inverted through its own leaf
+	below := 0
+	samples := model.SampleUniformKeys(rand.New(rand.NewSource(1)), 2000)
+	for _, key := range samples {
+		if key.Cmp(big.NewInt(5e8)) == -1 {
+			below++
+		}
+	}
+	if below < len(samples)*4/10 || below > len(samples)*6/10 {
+		t.Fatalf("%v of %v samples in the first cluster", below, len(samples))
+	}
+}
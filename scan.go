@@ -0,0 +1,74 @@
+// scan.go: planning of parallel range scans using the learned CDF
+
+package rmi
+
+import (
+	"math/big"
+)
+
+/*
+Range is a sub-range of keys [Lo, Hi] (inclusive) assigned to one worker
+Rows: number of rows in the range as estimated by the model
+*/
+type Range struct {
+	Lo, Hi *big.Int
+	Rows   int
+}
+
+// PlanParallelScan splits the key range [lo, hi] into (at most) workers
+// consecutive sub-ranges holding approximately the same number of rows;
+// the split keys are found by inverting the learned CDF so no data is accessed
+func (rmi *RMI) PlanParallelScan(lo, hi *big.Int, workers int) []Range {
+
+	if workers < 1 || lo.Cmp(hi) == 1 {
+		return []Range{}
+	}
+
+	v := rmi.current.Load()
+	startPos := rmi.getIndex(v, lo)
+	endPos := rmi.getIndex(v, hi) + 1
+	rows := endPos - startPos
+
+	ranges := make([]Range, 0, workers)
+	rangeLo := new(big.Int).Set(lo)
+	for k := 1; k <= workers && rangeLo.Cmp(hi) != 1; k++ {
+
+		// last worker takes everything that is left
+		if k == workers {
+			ranges = append(ranges, Range{rangeLo, new(big.Int).Set(hi), endPos - rmi.getIndex(v, rangeLo)})
+			break
+		}
+
+		// first key predicted at or beyond the k-th split position starts the next range
+		target := startPos + k*rows/workers
+		split := rmi.invert(v, rangeLo, hi, target)
+		if split.Cmp(rangeLo) != 1 {
+			continue
+		}
+
+		rangeHi := new(big.Int).Sub(split, big.NewInt(1))
+		ranges = append(ranges, Range{rangeLo, rangeHi, rmi.getIndex(v, split) - rmi.getIndex(v, rangeLo)})
+		rangeLo = split
+	}
+
+	return ranges
+}
+
+// invert returns the smallest key x in [lo, hi+1] with a predicted index >= target
+// (hi+1 if there is none); assumes predictions are non-decreasing in the key
+func (rmi *RMI) invert(v *version, lo, hi *big.Int, target int) *big.Int {
+
+	left := new(big.Int).Set(lo)
+	right := new(big.Int).Add(hi, big.NewInt(1))
+	mid := new(big.Int)
+	for left.Cmp(right) == -1 {
+		mid.Add(left, right).Rsh(mid, 1)
+		if rmi.getIndex(v, mid) >= target {
+			right.Set(mid)
+		} else {
+			left.Add(mid, big.NewInt(1))
+		}
+	}
+
+	return left
+}
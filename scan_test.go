@@ -0,0 +1,43 @@
+package rmi
+
+import (
+	"math/big"
+	"sort"
+	"testing"
+)
+
+// number of values in [lo, hi]
+func countInRange(values []*big.Int, lo, hi *big.Int) int {
+	start := sort.Search(len(values), func(i int) bool { return values[i].Cmp(lo) != -1 })
+	end := sort.Search(len(values), func(i int) bool { return values[i].Cmp(hi) == 1 })
+	return end - start
+}
+
+func TestPlanParallelScan(t *testing.T) {
+	rmi, values, _ := generateTestRMI()
+
+	lo := values[1000]
+	hi := values[9000]
+	ranges := rmi.PlanParallelScan(lo, hi, 8)
+
+	if len(ranges) != 8 {
+		t.Fatalf("expected 8 ranges, got %v", len(ranges))
+	}
+
+	// ranges must tile [lo, hi] exactly
+	if ranges[0].Lo.Cmp(lo) != 0 || ranges[len(ranges)-1].Hi.Cmp(hi) != 0 {
+		t.Fatalf("ranges do not cover [lo, hi]")
+	}
+
+	total := countInRange(values, lo, hi)
+	for i, r := range ranges {
+		if i > 0 && new(big.Int).Add(ranges[i-1].Hi, big.NewInt(1)).Cmp(r.Lo) != 0 {
+			t.Fatalf("range %v does not start right after range %v", i, i-1)
+		}
+
+		rows := countInRange(values, r.Lo, r.Hi)
+		if float64(rows) > 1.5*float64(total)/8 {
+			t.Fatalf("range %v has %v of %v rows, too unbalanced", i, rows, total)
+		}
+	}
+}
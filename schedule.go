@@ -0,0 +1,87 @@
+// schedule.go: priority based building of many per-partition indexes.
+// During large backfills the serving layer benefits most from the indexes
+// of the hottest partitions, so those are built first.
+
+package rmi
+
+import (
+	"math/big"
+	"sync"
+)
+
+// Partition is a named set of sorted keys to build an index over
+type Partition struct {
+	ID     string
+	Values []*big.Int
+}
+
+// BuildResult is the outcome of building the index of one partition
+type BuildResult struct {
+	ID  string
+	RMI *RMI
+	Err error
+}
+
+// BuildByPriority builds an index for every partition using build, with up to
+// workers builds running at a time; whenever a worker is free it starts the
+// remaining partition with the highest priority, which is re-evaluated at that
+// point so it may reflect live query counts. Results are delivered on the
+// returned channel as builds complete; it is closed once all are done.
+func BuildByPriority(
+	partitions []Partition,
+	priority func(Partition) float64,
+	workers int,
+	build func(values []*big.Int) (*RMI, error)) <-chan BuildResult {
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	results := make(chan BuildResult, len(partitions))
+
+	var mu sync.Mutex
+	remaining := make([]Partition, len(partitions))
+	copy(remaining, partitions)
+
+	// removes and returns the remaining partition with the highest priority
+	next := func() (Partition, bool) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if len(remaining) == 0 {
+			return Partition{}, false
+		}
+
+		best := 0
+		bestPriority := priority(remaining[0])
+		for i := 1; i < len(remaining); i++ {
+			if p := priority(remaining[i]); p > bestPriority {
+				best = i
+				bestPriority = p
+			}
+		}
+
+		partition := remaining[best]
+		remaining = append(remaining[:best], remaining[best+1:]...)
+		return partition, true
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for partition, ok := next(); ok; partition, ok = next() {
+				rmi, err := build(partition.Values)
+				results <- BuildResult{partition.ID, rmi, err}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	return results
+}
@@ -0,0 +1,42 @@
+package rmi
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+func TestBuildByPriority(t *testing.T) {
+	partitions := make([]Partition, 10)
+	queries := make(map[string]float64)
+	for i := range partitions {
+		id := fmt.Sprintf("p%v", i)
+		partitions[i] = Partition{id, sortedTestData(200)}
+		queries[id] = float64((i * 7) % 10) // a permutation of 0..9
+	}
+
+	priority := func(p Partition) float64 { return queries[p.ID] }
+	build := func(values []*big.Int) (*RMI, error) { return NewRMI(values, 4, 2) }
+
+	// a single worker builds strictly in priority order
+	expected := 9.0
+	for result := range BuildByPriority(partitions, priority, 1, build) {
+		if result.Err != nil || result.RMI == nil {
+			t.Fatalf("build of %v failed %v", result.ID, result.Err)
+		}
+
+		if queries[result.ID] != expected {
+			t.Fatalf("partition %v built out of priority order", result.ID)
+		}
+		expected--
+	}
+
+	count := 0
+	for range BuildByPriority(partitions, priority, 4, build) {
+		count++
+	}
+
+	if count != len(partitions) {
+		t.Fatalf("expected %v results, got %v", len(partitions), count)
+	}
+}
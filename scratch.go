@@ -0,0 +1,94 @@
+// scratch.go: pooled per-query temporaries so that concurrent
+// queries do not contend on the allocator for big.Float values
+
+package rmi
+
+import (
+	"math/big"
+	"sync"
+)
+
+/*
+Temporaries used while evaluating a single query
+value: the query value
+prefix: the query value's key prefix (see routing.go)
+x: the query value as a float
+res: output of the node model being evaluated
+width: number of nodes in the next layer
+factor: width of the rmi
+maxIndex: maximum index of the version being queried
+logx: the input of log-linear models at the query value (valid if hasLog)
+hook: receives the nodes evaluated by the query (nil if it is not traced)
+parent: the node that routed the query to its leaf (nil for a single model)
+*/
+type scratch struct {
+	value, prefix                   *big.Int
+	x, res, width, factor, maxIndex *big.Float
+	logx                            *big.Float
+	hasLog                          bool
+	hook                            TraceHook
+	parent                          *Node
+}
+
+var scratchPool = sync.Pool{
+	New: func() interface{} {
+		return &scratch{nil, new(big.Int), new(big.Float), new(big.Float), new(big.Float), new(big.Float), new(big.Float), new(big.Float), false, nil, nil}
+	},
+}
+
+// getScratch returns pooled temporaries holding the query value
+func getScratch(value *big.Int) *scratch {
+	s := scratchPool.Get().(*scratch)
+	if value != nil {
+		s.set(value)
+	}
+
+	return s
+}
+
+// set makes value the query value held by s
+func (s *scratch) set(value *big.Int) {
+	s.value = value
+
+	// reset the precision so x is exact as with new(big.Float).SetInt(value)
+	s.x.SetPrec(0).SetInt(value)
+	s.hasLog = false
+}
+
+// putScratch returns the temporaries to the pool
+func putScratch(s *scratch) {
+	s.value = nil
+	s.hook = nil
+	s.parent = nil
+	scratchPool.Put(s)
+}
+
+// eval computes mx+b of node at the query value; the result
+// is only valid until the next use of s
+func (s *scratch) eval(node *Node) *big.Float {
+	s.res.SetPrec(0).Mul(node.m, s.input(node))
+	return s.res.Add(s.res, node.b)
+}
+
+// trace reports the output of the node at position node of layer to the
+// trace hook of the query (if any)
+func (s *scratch) trace(layer, node int, prediction *big.Float) {
+	if s.hook != nil {
+		f, _ := prediction.Float64()
+		s.hook(layer, node, f)
+	}
+}
+
+// input returns the input of the node model at the query value
+func (s *scratch) input(node *Node) *big.Float {
+	if !node.log {
+		return s.x
+	}
+
+	if !s.hasLog {
+		s.logx.SetPrec(0).Set(logInput(s.value))
+		s.hasLog = true
+	}
+
+	return s.logx
+}
@@ -0,0 +1,268 @@
+// search.go: correction of model predictions by searching
+// the retained keys around the predicted index
+
+package rmi
+
+import (
+	"math"
+	"math/big"
+	"sort"
+	"time"
+)
+
+// lowerBound returns the first index i such that values[i] >= value
+// (len(values) if there is none); the search gallops outwards from
+// the model prediction so its cost depends on the prediction error
+func (rmi *RMI) lowerBound(value *big.Int) int {
+	guess := rmi.GetIndex(value)
+	index := lowerBoundFrom(rmi.values, value, guess)
+	rmi.recordCorrection(index - guess)
+
+	return index
+}
+
+// lowerBoundFrom is lowerBound over values starting at the index guess
+func lowerBoundFrom(values []*big.Int, value *big.Int, guess int) int {
+
+	n := len(values)
+	if n == 0 {
+		return 0
+	}
+
+	if guess < 0 {
+		guess = 0
+	} else if guess >= n {
+		guess = n - 1
+	}
+
+	// find a window (lo, hi] that contains the lower bound
+	lo, hi := guess, guess
+	if values[guess].Cmp(value) == -1 {
+		step := 1
+		for hi < n && values[hi].Cmp(value) == -1 {
+			lo = hi
+			hi += step
+			step *= 2
+		}
+		if hi > n {
+			hi = n
+		}
+	} else {
+		step := 1
+		for lo >= 0 && values[lo].Cmp(value) != -1 {
+			hi = lo
+			lo -= step
+			step *= 2
+		}
+		if lo < -1 {
+			lo = -1
+		}
+	}
+
+	// binary search within the window
+	return lo + 1 + sort.Search(hi-lo-1, func(i int) bool {
+		return values[lo+1+i].Cmp(value) != -1
+	})
+}
+
+// findExact returns the index of the first occurrence of value in the
+// retained keys (or false if absent); the key is searched within the error
+// window of its leaf and, if it is not there (e.g., a boundary key that the
+// model assigns to the "wrong" leaf), within the keys of the neighbouring leaves
+func (rmi *RMI) findExact(v *version, value *big.Int) (int, bool) {
+
+	leaves := rmi.errorsOf(v)
+	prediction, loc := rmi.locate(v, value)
+
+	index, ok := rmi.searchLeaves(leaves, loc, value, prediction)
+	if ok {
+		rmi.recordCorrection(index - prediction)
+	}
+
+	return index, ok
+}
+
+// searchLeaves searches the error window of leaf loc around the prediction
+// and then the key ranges of its neighbours for value
+func (rmi *RMI) searchLeaves(leaves []leafError, loc int, value *big.Int, prediction int) (int, bool) {
+
+	e := leaves[loc]
+	if index, ok := searchWindow(rmi.values, value, prediction, prediction+e.minResidual, prediction+e.maxResidual); ok {
+		return index, true
+	}
+
+	// spill over to the closest non-empty leaf on either side
+	for left := loc - 1; left >= 0; left-- {
+		if leaves[left].count > 0 {
+			if index, ok := searchRange(rmi.values, value, leaves[left].first, leaves[left].last); ok {
+				return index, true
+			}
+			break
+		}
+	}
+
+	for right := loc + 1; right < len(leaves); right++ {
+		if leaves[right].count > 0 {
+			if index, ok := searchRange(rmi.values, value, leaves[right].first, leaves[right].last); ok {
+				return index, true
+			}
+			break
+		}
+	}
+
+	return 0, false
+}
+
+// searchWindow is searchRange galloping outwards from the index guess,
+// so its cost depends on the distance to the key rather than the window size
+func searchWindow(values []*big.Int, value *big.Int, guess, lo, hi int) (int, bool) {
+
+	lo = int(math.Max(0, float64(lo)))
+	hi = int(math.Min(float64(len(values)-1), float64(hi)))
+	if lo > hi {
+		return 0, false
+	}
+
+	index := lo + lowerBoundFrom(values[lo:hi+1], value, guess-lo)
+	if index <= hi && values[index].Cmp(value) == 0 {
+		return index, true
+	}
+
+	return 0, false
+}
+
+// searchRange binary searches values[lo..hi] (inclusive, clamped to the slice)
+// for the first occurrence of value
+func searchRange(values []*big.Int, value *big.Int, lo, hi int) (int, bool) {
+
+	lo = int(math.Max(0, float64(lo)))
+	hi = int(math.Min(float64(len(values)-1), float64(hi)))
+	if lo > hi {
+		return 0, false
+	}
+
+	index := lo + sort.Search(hi-lo+1, func(i int) bool {
+		return values[lo+i].Cmp(value) != -1
+	})
+
+	if index <= hi && values[index].Cmp(value) == 0 {
+		return index, true
+	}
+
+	return 0, false
+}
+
+// Lookup returns the exact index of the first live occurrence of value in
+// the keys (or false if it is absent or deleted); the model prediction is
+// corrected by a search bounded by the error window of the key's leaf
+func (rmi *RMI) Lookup(value *big.Int) (int, bool) {
+	return rmi.lookupAt(rmi.current.Load(), value)
+}
+
+// Lookup is RMI.Lookup evaluated on the pinned version of the model
+func (s Snapshot) Lookup(value *big.Int) (int, bool) {
+	return s.rmi.lookupAt(s.v, value)
+}
+
+// lookupAt is Lookup over version v of the model
+func (rmi *RMI) lookupAt(v *version, value *big.Int) (int, bool) {
+
+	index, ok := rmi.findExact(v, value)
+	if !ok {
+		return 0, false
+	}
+
+	// skip deleted duplicates of the key
+	deleted := rmi.deletions()
+	for ; index < len(rmi.values) && rmi.values[index].Cmp(value) == 0; index++ {
+		if !deleted.isDeleted(index) {
+			return index, true
+		}
+	}
+
+	return 0, false
+}
+
+// LookupWithin is Lookup with a latency budget: if the correction search
+// cannot finish within budget it returns the best-known position of value
+// (the middle of the remaining search window) with approximate set; found
+// is only meaningful if approximate is false
+func (rmi *RMI) LookupWithin(value *big.Int, budget time.Duration) (index int, found bool, approximate bool) {
+
+	deadline := time.Now().Add(budget)
+
+	v := rmi.current.Load()
+	_, loc := rmi.leaf(v, value)
+	prediction := rmi.getIndex(v, value)
+	e := rmi.errorsOf(v)[loc]
+
+	// binary search the error window of the leaf until the deadline
+	lo := int(math.Max(0, float64(prediction+e.minResidual)))
+	hi := int(math.Min(float64(len(rmi.values)-1), float64(prediction+e.maxResidual)))
+	for lo < hi {
+		if time.Now().After(deadline) {
+			return lo + (hi-lo)/2, false, true
+		}
+
+		mid := lo + (hi-lo)/2
+		if rmi.values[mid].Cmp(value) == -1 {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+
+	if lo < len(rmi.values) && rmi.values[lo].Cmp(value) == 0 {
+		index, found = lo, true
+	} else if time.Now().After(deadline) {
+		return lo, false, true
+	} else {
+		index, found = rmi.searchLeaves(rmi.errorsOf(v), loc, value, prediction)
+	}
+
+	if !found {
+		return 0, false, false
+	}
+
+	// skip deleted duplicates of the key
+	deleted := rmi.deletions()
+	for ; index < len(rmi.values) && rmi.values[index].Cmp(value) == 0; index++ {
+		if !deleted.isDeleted(index) {
+			return index, true, false
+		}
+	}
+
+	return 0, false, false
+}
+
+// GetRange returns the bounds [start, end) of the keys in [lo, hi] in the
+// data array (deleted keys within the bounds are included, see SetDeletions);
+// each bound is searched within the error window of its leaf first
+func (rmi *RMI) GetRange(lo, hi *big.Int) (int, int) {
+
+	start := rmi.boundedLowerBound(lo)
+	end := rmi.boundedLowerBound(new(big.Int).Add(hi, big.NewInt(1)))
+	if end < start {
+		end = start
+	}
+
+	return start, end
+}
+
+// boundedLowerBound is lowerBound searching the error window of value's leaf
+// before galloping outwards (the window only bounds the keys themselves,
+// other values may have their lower bound outside of it)
+func (rmi *RMI) boundedLowerBound(value *big.Int) int {
+
+	n := len(rmi.values)
+	lo, hi := rmi.GetIndexWithBounds(value)
+	index := lo + sort.Search(int(math.Max(0, float64(hi-lo+1))), func(i int) bool {
+		return rmi.values[lo+i].Cmp(value) != -1
+	})
+
+	if (index == 0 || rmi.values[index-1].Cmp(value) == -1) && (index == n || rmi.values[index].Cmp(value) != -1) {
+		return index
+	}
+
+	return lowerBoundFrom(rmi.values, value, index)
+}
@@ -0,0 +1,143 @@
+package rmi
+
+import (
+	"math"
+	"math/big"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestFindExact(t *testing.T) {
+	rmi, values, _ := generateTestRMI()
+	v := rmi.current.Load()
+
+	for i := 0; i < len(values); i += 7 {
+		index, ok := rmi.findExact(v, values[i])
+		if !ok || values[index].Cmp(values[i]) != 0 {
+			t.Fatalf("key %v was not found", i)
+		}
+	}
+
+	absent := new(big.Int).Add(values[100], big.NewInt(1))
+	if absent.Cmp(values[101]) != 0 {
+		if _, ok := rmi.findExact(v, absent); ok {
+			t.Fatalf("absent key was found")
+		}
+	}
+}
+
+func TestFindExactSpillOver(t *testing.T) {
+	rmi, values, _ := generateTestRMI()
+	v := rmi.current.Load()
+
+	// collapse every error window and hand the first key of each leaf to the
+	// key range of its left neighbour, as if the model had assigned the
+	// boundary key to the "wrong" leaf
+	leaves := rmi.errorsOf(v)
+	for i := range leaves {
+		leaves[i].minResidual = 0
+		leaves[i].maxResidual = 0
+	}
+
+	for i := len(leaves) - 1; i > 0; i-- {
+		if leaves[i].count == 0 || leaves[i-1].count == 0 {
+			continue
+		}
+
+		first := leaves[i].first
+		leaves[i-1].last = first
+		leaves[i].first = first + 1
+
+		if index, ok := rmi.findExact(v, values[first]); !ok || values[index].Cmp(values[first]) != 0 {
+			t.Fatalf("boundary key of leaf %v was not found in its neighbour", i)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	values := sortedTestData(10000)
+	rmi, _ := NewRMI(values, RMIWidthParameter, 3)
+
+	for i, value := range values {
+		index, ok := rmi.Lookup(value)
+		if !ok || values[index].Cmp(value) != 0 || (index > 0 && values[index-1].Cmp(value) == 0) {
+			t.Fatalf("Lookup of key %v returned %v, %v", i, index, ok)
+		}
+	}
+
+	// keys between and beyond the stored keys are absent
+	for i := 1; i < len(values); i += 17 {
+		between := new(big.Int).Add(values[i-1], big.NewInt(1))
+		if between.Cmp(values[i]) == -1 {
+			if _, ok := rmi.Lookup(between); ok {
+				t.Fatalf("found absent key %v", between)
+			}
+		}
+	}
+	if _, ok := rmi.Lookup(new(big.Int).Neg(values[len(values)-1])); ok {
+		t.Fatalf("found absent negative key")
+	}
+
+	// deleted keys are absent, also from pinned snapshots
+	deleted := NewBitmap(len(values))
+	deleted.Set(42)
+	rmi.SetDeletions(deleted)
+	if _, ok := rmi.Pin().Lookup(values[42]); ok && values[43].Cmp(values[42]) != 0 {
+		t.Fatalf("found deleted key")
+	}
+}
+
+func TestLookupWithin(t *testing.T) {
+	values := sortedTestData(10000)
+	rmi, _ := NewRMI(values, RMIWidthParameter, 3)
+
+	// an ample budget always finishes the search
+	for i := 0; i < len(values); i += 13 {
+		index, found, approximate := rmi.LookupWithin(values[i], time.Minute)
+		if approximate || !found || values[index].Cmp(values[i]) != 0 {
+			t.Fatalf("LookupWithin of key %v returned %v, %v, %v", i, index, found, approximate)
+		}
+	}
+
+	absent := new(big.Int).Add(values[len(values)-1], big.NewInt(1))
+	if _, found, approximate := rmi.LookupWithin(absent, time.Minute); found || approximate {
+		t.Fatalf("expected an exact miss for an absent key")
+	}
+
+	// an exhausted budget returns a position within the error window
+	for i := 0; i < len(values); i += 13 {
+		index, _, approximate := rmi.LookupWithin(values[i], 0)
+		lo, hi := rmi.GetIndexWithBounds(values[i])
+		if approximate && (index < lo || index > hi) {
+			t.Fatalf("approximate position %v outside the window [%v, %v]", index, lo, hi)
+		}
+	}
+}
+
+func TestGetRange(t *testing.T) {
+	values := sortedTestData(3000)
+	rmi, _ := NewRMI(values, RMIWidthParameter, 3)
+
+	// reference bounds by binary search over the keys
+	bounds := func(lo, hi *big.Int) (int, int) {
+		start := sort.Search(len(values), func(i int) bool { return values[i].Cmp(lo) != -1 })
+		end := sort.Search(len(values), func(i int) bool { return values[i].Cmp(hi) == 1 })
+		return start, int(math.Max(float64(start), float64(end)))
+	}
+
+	queries := []*big.Int{big.NewInt(-10), new(big.Int).Add(values[len(values)-1], big.NewInt(10))}
+	for i := 0; i < len(values); i += 97 {
+		queries = append(queries, values[i], new(big.Int).Add(values[i], big.NewInt(1)))
+	}
+
+	for _, lo := range queries {
+		for _, hi := range queries {
+			start, end := rmi.GetRange(lo, hi)
+			expectedStart, expectedEnd := bounds(lo, hi)
+			if start != expectedStart || end != expectedEnd {
+				t.Fatalf("GetRange(%v, %v) = [%v, %v), expected [%v, %v)", lo, hi, start, end, expectedStart, expectedEnd)
+			}
+		}
+	}
+}
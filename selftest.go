@@ -0,0 +1,47 @@
+// selftest.go: verification of loaded models against their recorded error bounds
+
+package rmi
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+)
+
+// SelfTest looks up each sample key and verifies that the model predicts it
+// within the error bounds recorded for its leaf and that exact search finds
+// it; meant to be run after Load to keep corrupted model files out of
+// serving. The samples must be keys the model was built over.
+func (rmi *RMI) SelfTest(sampleValues []*big.Int) error {
+
+	if rmi.values == nil {
+		return fmt.Errorf("self test needs the keys the model was built over")
+	}
+
+	v := rmi.current.Load()
+	leaves := rmi.errorsOf(v)
+
+	for _, value := range sampleValues {
+		index := sort.Search(len(rmi.values), func(i int) bool {
+			return rmi.values[i].Cmp(value) != -1
+		})
+		if index == len(rmi.values) || rmi.values[index].Cmp(value) != 0 {
+			return fmt.Errorf("sample key %v is not one of the keys of the model", value)
+		}
+
+		_, loc := rmi.leaf(v, value)
+		predicted := rmi.getIndex(v, value)
+		residual := index - predicted
+		if residual < leaves[loc].minResidual || residual > leaves[loc].maxResidual {
+			return fmt.Errorf(
+				"key %v predicted at %v but found at %v, outside the error bounds [%v, %v] of leaf %v",
+				value, predicted, index, leaves[loc].minResidual, leaves[loc].maxResidual, loc)
+		}
+
+		if found, ok := rmi.findExact(v, value); !ok || found != index {
+			return fmt.Errorf("exact search for key %v failed", value)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,42 @@
+package rmi
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+)
+
+func TestSelfTest(t *testing.T) {
+	values := sortedTestData(2000)
+	rmi, _ := NewRMI(values, RMIWidthParameter, RMIDepthParameter)
+
+	var buf bytes.Buffer
+	rmi.Save(&buf)
+
+	loaded, err := Load(bytes.NewReader(buf.Bytes()), values, Metadata{})
+	if err != nil {
+		t.Fatalf("failed to load %v", err)
+	}
+
+	samples := make([]*big.Int, 0)
+	for i := 0; i < len(values); i += 37 {
+		samples = append(samples, values[i])
+	}
+
+	if err := loaded.SelfTest(samples); err != nil {
+		t.Fatalf("self test of intact model failed %v", err)
+	}
+
+	// corrupt the intercepts of all leaves; the recorded bounds no longer hold
+	for _, leaf := range loaded.current.Load().nodes[RMIDepthParameter-1] {
+		leaf.b.Add(leaf.b, big.NewFloat(500))
+	}
+
+	if err := loaded.SelfTest(samples); err == nil {
+		t.Fatalf("self test of corrupted model passed")
+	}
+
+	if err := loaded.SelfTest([]*big.Int{big.NewInt(-1)}); err == nil {
+		t.Fatalf("expected unknown sample key to be rejected")
+	}
+}
@@ -0,0 +1,189 @@
+// share.go: additive secret sharing of the model coefficients.
+// Every coefficient is encoded in fixed point and split into n shares
+// that sum to it modulo 2^shareModulusBits, so n servers can each evaluate
+// their share of a node on a query and only the sum reveals the output.
+
+package rmi
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+)
+
+const (
+	// fractional bits of the fixed-point encoding of the coefficients
+	shareFracBits = 256
+
+	// shares are taken modulo 2^shareModulusBits
+	shareModulusBits = 1024
+)
+
+// modulus of the additive shares
+var shareModulus = new(big.Int).Lsh(big.NewInt(1), shareModulusBits)
+
+/*
+ShareNode is one party's share of the coefficients of a node
+M, B: shares of the fixed-point slope and intercept
+*/
+type ShareNode struct {
+	M, B *big.Int
+}
+
+/*
+CoefficientShare is one party's additive share of all coefficients
+Party: index of the party holding the share
+Width, Depth, MaxIndex: public shape of the model
+Layers: shares of the nodes, layer by layer
+*/
+type CoefficientShare struct {
+	Party                  int
+	Width, Depth, MaxIndex int
+	Layers                 [][]ShareNode
+}
+
+// Share splits the fixed-point coefficients of the current version into
+// n additive shares; ensemble leaves, last-mile tables, calibrations and
+// the neighbor clamp are not linear in the coefficients and cannot be shared
+func (rmi *RMI) Share(n int) ([]*CoefficientShare, error) {
+
+	if n < 1 {
+		return nil, errors.New("number of shares must be positive")
+	}
+
+	v := rmi.current.Load()
+	if v.calib != nil {
+		return nil, errors.New("calibrated models cannot be shared")
+	} else if rmi.conf.neighborClamp {
+		return nil, errors.New("models with a neighbor clamp cannot be shared")
+	}
+
+	shares := make([]*CoefficientShare, n)
+	for p := range shares {
+		shares[p] = &CoefficientShare{p, rmi.width, rmi.depth, v.maxIndex, make([][]ShareNode, len(v.nodes))}
+	}
+
+	for i, layer := range v.nodes {
+		for p := range shares {
+			shares[p].Layers[i] = make([]ShareNode, len(layer))
+		}
+
+		for j, node := range layer {
+			if node.alt != nil || node.exact != nil {
+				return nil, errors.New("ensemble leaves and last-mile tables cannot be shared")
+			} else if node.log {
+				return nil, errors.New("log-linear models cannot be shared")
+			}
+
+			m, err := splitShares(fixedPoint(node.m, shareFracBits), n)
+			if err != nil {
+				return nil, err
+			}
+			b, err := splitShares(fixedPoint(node.b, shareFracBits), n)
+			if err != nil {
+				return nil, err
+			}
+
+			for p := range shares {
+				shares[p].Layers[i][j] = ShareNode{m[p], b[p]}
+			}
+		}
+	}
+
+	return shares, nil
+}
+
+// fixedPoint returns f * 2^fracBits truncated to an integer
+func fixedPoint(f *big.Float, fracBits int) *big.Int {
+	fixed, _ := new(big.Float).SetMantExp(f, fracBits).Int(nil)
+	return fixed
+}
+
+// splitShares returns n random values summing to value modulo the share modulus
+func splitShares(value *big.Int, n int) ([]*big.Int, error) {
+
+	shares := make([]*big.Int, n)
+	last := new(big.Int).Mod(value, shareModulus)
+	for p := 0; p < n-1; p++ {
+		share, err := rand.Int(rand.Reader, shareModulus)
+		if err != nil {
+			return nil, err
+		}
+
+		shares[p] = share
+		last.Sub(last, share)
+	}
+	shares[n-1] = last.Mod(last, shareModulus)
+
+	return shares, nil
+}
+
+// EvalNode returns the party's share of the fixed-point output m*x + b
+// of the node at position node in layer for the (public) query x
+func (s *CoefficientShare) EvalNode(layer, node int, x *big.Int) *big.Int {
+	share := s.Layers[layer][node]
+
+	res := new(big.Int).Mul(share.M, x)
+	res.Add(res, share.B)
+	return res.Mod(res, shareModulus)
+}
+
+// reconstruct sums the output shares and decodes the fixed-point value
+func reconstruct(outputs []*big.Int) *big.Float {
+
+	sum := big.NewInt(0)
+	for _, output := range outputs {
+		sum.Add(sum, output)
+	}
+	sum.Mod(sum, shareModulus)
+
+	// values in the upper half of the ring are negative
+	if sum.Cmp(new(big.Int).Rsh(shareModulus, 1)) >= 0 {
+		sum.Sub(sum, shareModulus)
+	}
+
+	res := new(big.Float).SetInt(sum)
+	return res.SetMantExp(res, -shareFracBits)
+}
+
+// EvaluateShares is the reference evaluation of a query over all shares:
+// at every layer each party evaluates its share of the selected node and
+// the outputs are reconstructed to select the child; it returns GetIndex
+// of the shared model (up to the fixed-point rounding of the coefficients)
+func EvaluateShares(shares []*CoefficientShare, x *big.Int) (int, error) {
+
+	if len(shares) == 0 {
+		return 0, errors.New("no shares to evaluate")
+	}
+
+	shape := shares[0]
+	for _, share := range shares {
+		if share.Width != shape.Width || share.Depth != shape.Depth || share.MaxIndex != shape.MaxIndex {
+			return 0, errors.New("shares of different models")
+		}
+	}
+
+	maxIndex := big.NewFloat(routingMaxIndex(shape.MaxIndex))
+	width := big.NewFloat(float64(shape.Width))
+	factor := big.NewFloat(float64(shape.Width))
+
+	outputs := make([]*big.Int, len(shares))
+	location := 0
+	for layer := 0; layer < shape.Depth; layer++ {
+		for p, share := range shares {
+			outputs[p] = share.EvalNode(layer, location, x)
+		}
+		res := reconstruct(outputs)
+
+		if layer == shape.Depth-1 {
+			return clampIndex(res, shape.MaxIndex), nil
+		}
+
+		res.Quo(res, maxIndex)
+		res.Mul(res, width)
+		location = clampIndex(res, len(shape.Layers[layer+1])-1)
+		width.Mul(width, factor)
+	}
+
+	return 0, nil
+}
@@ -0,0 +1,42 @@
+package rmi
+
+import (
+	"testing"
+)
+
+func TestShare(t *testing.T) {
+	values := sortedTestData(5000)
+	rmi, _ := NewRMI(values, RMIWidthParameter, 3)
+
+	shares, err := rmi.Share(3)
+	if err != nil {
+		t.Fatalf("Failed to share the model %v\n", err)
+	}
+
+	mismatches := 0
+	for i, value := range values {
+		index, err := EvaluateShares(shares, value)
+		if err != nil {
+			t.Fatalf("Failed to evaluate the shares %v\n", err)
+		}
+		// the fixed-point evaluation is exact while GetIndex rounds, so a
+		// prediction that is (almost) an integer may truncate differently
+		if diff := abs(index - rmi.GetIndex(value)); diff > 1 {
+			t.Fatalf("shared evaluation of key %v returned %v instead of %v", i, index, rmi.GetIndex(value))
+		} else if diff == 1 {
+			mismatches++
+		}
+	}
+	if mismatches > len(values)/100 {
+		t.Fatalf("%v shared evaluations were off by one", mismatches)
+	}
+
+	// a single share reveals nothing about the coefficients
+	if shares[0].Layers[0][0].M.Cmp(fixedPoint(rmi.current.Load().root.m, shareFracBits)) == 0 {
+		t.Fatalf("share equals the coefficient it hides")
+	}
+
+	if _, err := EvaluateShares(shares[:2], values[0]); err != nil {
+		t.Fatalf("evaluating a subset of shares must not fail %v\n", err)
+	}
+}
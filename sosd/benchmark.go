@@ -0,0 +1,105 @@
+// benchmark.go: the SOSD lookup benchmark. As in SOSD every lookup is a
+// lower bound search for an existing key: the model predicts a window of
+// positions in the key array (see RMI.GetIndexWithBounds) that is binary
+// searched, and the baseline binary searches the whole array. Both return
+// the position of the first occurrence of the key and are checked against
+// each other, and the mean time per lookup is reported for each.
+
+package sosd
+
+import (
+	"fmt"
+	"math/big"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/sachaservan/rmi"
+)
+
+/*
+Report of a benchmark run
+Keys, Lookups: number of keys and of timed lookups
+Build: time taken to build the model
+ModelBytes: size of the serialized model (see RMI.MarshalBinary)
+Stats: error statistics of the model over the keys
+RMI, BinarySearch: mean time of a lookup with the model and with binary search
+*/
+type Report struct {
+	Keys, Lookups     int
+	Build             time.Duration
+	ModelBytes        int
+	Stats             rmi.ErrorStats
+	RMI, BinarySearch time.Duration
+}
+
+func (r Report) String() string {
+	return fmt.Sprintf("keys=%v lookups=%v build=%v size=%vB max error=%v mean error=%.1f rmi=%v/lookup binary search=%v/lookup",
+		r.Keys, r.Lookups, r.Build, r.ModelBytes, r.Stats.Max, r.Stats.Mean, r.RMI, r.BinarySearch)
+}
+
+// SampleLookups returns n keys drawn uniformly (with repetition) from keys,
+// or none if there are no keys
+func SampleLookups(keys []uint64, n int, r *rand.Rand) []uint64 {
+
+	if len(keys) == 0 {
+		return []uint64{}
+	}
+
+	lookups := make([]uint64, n)
+	for i := range lookups {
+		lookups[i] = keys[r.Intn(len(keys))]
+	}
+
+	return lookups
+}
+
+// Run builds an RMI with opts over the sorted keys (see NewRMIWithOptions)
+// and times the lower bound search of every key of lookups, which must be
+// keys of the model, with the model and with binary search
+func Run(keys []uint64, lookups []uint64, opts ...rmi.Option) (Report, error) {
+
+	report := Report{Keys: len(keys), Lookups: len(lookups)}
+
+	start := time.Now()
+	index, err := rmi.NewRMIWithOptions(BigInts(keys), opts...)
+	if err != nil {
+		return report, err
+	}
+	report.Build = time.Since(start)
+
+	encoded, err := index.MarshalBinary()
+	if err != nil {
+		return report, err
+	}
+	report.ModelBytes = len(encoded)
+	report.Stats = index.ErrorStats()
+
+	if len(lookups) == 0 {
+		return report, nil
+	}
+
+	learned := make([]int, len(lookups))
+	value := new(big.Int)
+	start = time.Now()
+	for q, key := range lookups {
+		lo, hi := index.GetIndexWithBounds(value.SetUint64(key))
+		learned[q] = lo + sort.Search(hi-lo+1, func(i int) bool { return keys[lo+i] >= key })
+	}
+	report.RMI = time.Since(start) / time.Duration(len(lookups))
+
+	searched := make([]int, len(lookups))
+	start = time.Now()
+	for q, key := range lookups {
+		searched[q] = sort.Search(len(keys), func(i int) bool { return keys[i] >= key })
+	}
+	report.BinarySearch = time.Since(start) / time.Duration(len(lookups))
+
+	for q := range lookups {
+		if learned[q] != searched[q] {
+			return report, fmt.Errorf("sosd: lookup of %v found position %v instead of %v", lookups[q], learned[q], searched[q])
+		}
+	}
+
+	return report, nil
+}
@@ -0,0 +1,135 @@
+// sosd.go: the key files of the SOSD benchmark (Kipf et al., "SOSD: A
+// Benchmark for Learned Indexes"). A file is the number of keys as a
+// little-endian uint64 followed by the sorted keys as little-endian
+// integers of the width named by the file (e.g., books_200M_uint64), so
+// models of this package can be compared with the published results.
+
+// Package sosd reads and writes the key files of the SOSD learned index
+// benchmark and times RMI lookups against binary search over them
+package sosd
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strings"
+)
+
+// ErrUnsorted is returned for key files whose keys are not in sorted order
+var ErrUnsorted = errors.New("sosd: keys must be in sorted order")
+
+// ReadUint64 reads a file of uint64 keys from r
+func ReadUint64(r io.Reader) ([]uint64, error) {
+
+	count, err := readCount(r)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]uint64, 0, min(count, 1<<20))
+	word := make([]byte, 8)
+	for uint64(len(keys)) < count {
+		if _, err := io.ReadFull(r, word); err != nil {
+			return nil, fmt.Errorf("sosd: key %v of %v: %w", len(keys), count, err)
+		}
+
+		key := binary.LittleEndian.Uint64(word)
+		if len(keys) > 0 && keys[len(keys)-1] > key {
+			return nil, ErrUnsorted
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// ReadUint32 reads a file of uint32 keys from r, widened to uint64
+func ReadUint32(r io.Reader) ([]uint64, error) {
+
+	count, err := readCount(r)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]uint64, 0, min(count, 1<<20))
+	word := make([]byte, 4)
+	for uint64(len(keys)) < count {
+		if _, err := io.ReadFull(r, word); err != nil {
+			return nil, fmt.Errorf("sosd: key %v of %v: %w", len(keys), count, err)
+		}
+
+		key := uint64(binary.LittleEndian.Uint32(word))
+		if len(keys) > 0 && keys[len(keys)-1] > key {
+			return nil, ErrUnsorted
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// readCount reads the key count header of a file
+func readCount(r io.Reader) (uint64, error) {
+
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, fmt.Errorf("sosd: reading the key count: %w", err)
+	}
+
+	return binary.LittleEndian.Uint64(header), nil
+}
+
+// Load reads the key file at path, of uint32 keys if its name ends in
+// "uint32" and of uint64 keys otherwise (the naming of the SOSD data sets)
+func Load(path string) ([]uint64, error) {
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReaderSize(f, 1<<20)
+	if strings.HasSuffix(path, "uint32") {
+		return ReadUint32(r)
+	}
+
+	return ReadUint64(r)
+}
+
+// WriteUint64 writes the sorted keys to w as a file of uint64 keys
+func WriteUint64(w io.Writer, keys []uint64) error {
+
+	bw := bufio.NewWriter(w)
+	buf := binary.LittleEndian.AppendUint64(make([]byte, 0, 8), uint64(len(keys)))
+	if _, err := bw.Write(buf); err != nil {
+		return err
+	}
+
+	for i, key := range keys {
+		if i > 0 && keys[i-1] > key {
+			return ErrUnsorted
+		}
+
+		if _, err := bw.Write(binary.LittleEndian.AppendUint64(buf[:0], key)); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+// BigInts returns the keys as the big.Int keys an RMI is built over
+func BigInts(keys []uint64) []*big.Int {
+
+	values := make([]*big.Int, len(keys))
+	for i, key := range keys {
+		values[i] = new(big.Int).SetUint64(key)
+	}
+
+	return values
+}
@@ -0,0 +1,121 @@
+package sosd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/sachaservan/rmi"
+)
+
+// sorted random keys with runs of duplicates
+func testKeys(n int, r *rand.Rand) []uint64 {
+	keys := make([]uint64, n)
+	for i := range keys {
+		keys[i] = r.Uint64() >> 8
+		if i%100 == 1 {
+			keys[i] = keys[i-1]
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+	return keys
+}
+
+func TestReadWrite(t *testing.T) {
+
+	keys := testKeys(10000, rand.New(rand.NewSource(1)))
+
+	var buf bytes.Buffer
+	if err := WriteUint64(&buf, keys); err != nil {
+		t.Fatalf("failed to write keys %v", err)
+	}
+	if buf.Len() != 8+8*len(keys) || binary.LittleEndian.Uint64(buf.Bytes()) != uint64(len(keys)) {
+		t.Fatalf("written file has %v bytes", buf.Len())
+	}
+
+	path := filepath.Join(t.TempDir(), "keys_10K_uint64")
+	os.WriteFile(path, buf.Bytes(), 0o644)
+	read, err := Load(path)
+	if err != nil {
+		t.Fatalf("failed to read keys %v", err)
+	}
+	for i := range keys {
+		if read[i] != keys[i] {
+			t.Fatalf("key %v read as %v instead of %v", i, read[i], keys[i])
+		}
+	}
+
+	// a file of uint32 keys
+	data := binary.LittleEndian.AppendUint64(nil, 3)
+	for _, key := range []uint32{7, 7, 1 << 31} {
+		data = binary.LittleEndian.AppendUint32(data, key)
+	}
+	path = filepath.Join(t.TempDir(), "keys_3_uint32")
+	os.WriteFile(path, data, 0o644)
+	if read, err := Load(path); err != nil || len(read) != 3 || read[2] != 1<<31 {
+		t.Fatalf("failed to read uint32 keys %v: %v", read, err)
+	}
+
+	if _, err := ReadUint64(bytes.NewReader(buf.Bytes()[:buf.Len()-1])); err == nil {
+		t.Fatalf("expected an error reading a truncated file")
+	}
+
+	swapped := append([]byte{}, buf.Bytes()...)
+	copy(swapped[8:16], buf.Bytes()[16:24])
+	copy(swapped[16:24], buf.Bytes()[8:16])
+	if _, err := ReadUint64(bytes.NewReader(swapped)); err != ErrUnsorted {
+		t.Fatalf("expected an error reading unsorted keys, got %v", err)
+	}
+
+	if err := WriteUint64(&buf, []uint64{2, 1}); err != ErrUnsorted {
+		t.Fatalf("expected an error writing unsorted keys, got %v", err)
+	}
+}
+
+func TestRun(t *testing.T) {
+
+	r := rand.New(rand.NewSource(1))
+	keys := testKeys(50000, r)
+
+	report, err := Run(keys, SampleLookups(keys, 10000, r), rmi.WithDepth(2))
+	if err != nil {
+		t.Fatalf("benchmark failed %v", err)
+	}
+
+	if report.Keys != len(keys) || report.Lookups != 10000 || report.ModelBytes == 0 || report.RMI <= 0 || report.BinarySearch <= 0 {
+		t.Fatalf("incomplete report %v", report)
+	}
+
+	if report.Stats.Keys != len(keys) {
+		t.Fatalf("report measures %v keys of %v", report.Stats.Keys, len(keys))
+	}
+	if lookups := SampleLookups(nil, 10, r); len(lookups) != 0 {
+		t.Fatalf("sampled %v lookups from no keys", len(lookups))
+	}
+}
+
+// lookups of the keys of the SOSD file named by $SOSD_FILE (random keys if unset)
+func BenchmarkLookup(b *testing.B) {
+
+	r := rand.New(rand.NewSource(1))
+	keys := testKeys(1000000, r)
+	if path := os.Getenv("SOSD_FILE"); path != "" {
+		var err error
+		if keys, err = Load(path); err != nil {
+			b.Fatalf("failed to load %v: %v", path, err)
+		}
+	}
+
+	report, err := Run(keys, SampleLookups(keys, b.N, r))
+	if err != nil {
+		b.Fatalf("benchmark failed %v", err)
+	}
+
+	b.ReportMetric(float64(report.RMI.Nanoseconds()), "rmi-ns/lookup")
+	b.ReportMetric(float64(report.BinarySearch.Nanoseconds()), "bs-ns/lookup")
+}
@@ -0,0 +1,78 @@
+// sql.go: building an index directly from a sorted database/sql column
+
+package rmi
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// NewRMIFromRows builds an RMI over the first column of rows, which must
+// be returned in ascending order (e.g., by an ORDER BY clause); keys are
+// converted to big.Int as they are scanned and retained by the model (for
+// exact queries), so the whole column is held in memory; to build without
+// holding it, stream a re-runnable query through NewRMIFromIterator;
+// integer, decimal string and []byte columns are supported and rows is
+// closed once it has been consumed
+func NewRMIFromRows(
+	rows *sql.Rows,
+	width int,
+	depth int,
+	opts ...Option) (*RMI, error) {
+
+	defer rows.Close()
+
+	values := make([]*big.Int, 0)
+	var column interface{}
+	for rows.Next() {
+		if err := rows.Scan(&column); err != nil {
+			return nil, err
+		}
+
+		value, err := columnToInt(column)
+		if err != nil {
+			return nil, fmt.Errorf("row %v: %v", len(values), err)
+		}
+
+		// fail fast on the first unsorted row rather than after the whole scan
+		if len(values) > 0 && values[len(values)-1].Cmp(value) == 1 {
+			return nil, errors.New("values must be in sorted order")
+		}
+
+		values = append(values, value)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return NewRMI(values, width, depth, opts...)
+}
+
+// converts a scanned column value to a key
+func columnToInt(column interface{}) (*big.Int, error) {
+	switch v := column.(type) {
+	case int64:
+		return big.NewInt(v), nil
+	case []byte:
+		return parseDecimal(string(v))
+	case string:
+		return parseDecimal(v)
+	case nil:
+		return nil, errors.New("NULL keys cannot be indexed")
+	}
+
+	return nil, fmt.Errorf("unsupported key column type %T", column)
+}
+
+// parses a base 10 integer
+func parseDecimal(s string) (*big.Int, error) {
+	value, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return nil, fmt.Errorf("%q is not an integer key", s)
+	}
+
+	return value, nil
+}
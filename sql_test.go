@@ -0,0 +1,81 @@
+package rmi
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"testing"
+)
+
+// minimal database/sql driver serving a single column of fixed rows
+type columnDriver struct{ rows []driver.Value }
+type columnConn struct{ rows []driver.Value }
+type columnStmt struct{ rows []driver.Value }
+type columnRows struct {
+	rows []driver.Value
+	next int
+}
+
+func (d columnDriver) Open(string) (driver.Conn, error)         { return columnConn(d), nil }
+func (c columnConn) Prepare(string) (driver.Stmt, error)        { return columnStmt(c), nil }
+func (c columnConn) Close() error                               { return nil }
+func (c columnConn) Begin() (driver.Tx, error)                  { return nil, io.EOF }
+func (s columnStmt) Close() error                               { return nil }
+func (s columnStmt) NumInput() int                              { return 0 }
+func (s columnStmt) Exec([]driver.Value) (driver.Result, error) { return nil, io.EOF }
+func (s columnStmt) Query([]driver.Value) (driver.Rows, error) {
+	return &columnRows{rows: s.rows}, nil
+}
+func (r *columnRows) Columns() []string { return []string{"key"} }
+func (r *columnRows) Close() error      { return nil }
+func (r *columnRows) Next(dest []driver.Value) error {
+	if r.next == len(r.rows) {
+		return io.EOF
+	}
+	dest[0] = r.rows[r.next]
+	r.next++
+	return nil
+}
+
+func queryColumn(t *testing.T, name string, column []driver.Value) *sql.Rows {
+	sql.Register(name, columnDriver{column})
+	db, err := sql.Open(name, "")
+	if err != nil {
+		t.Fatalf("failed to open db %v", err)
+	}
+
+	rows, err := db.Query("SELECT key FROM keys ORDER BY key")
+	if err != nil {
+		t.Fatalf("failed to query %v", err)
+	}
+
+	return rows
+}
+
+func TestNewRMIFromRows(t *testing.T) {
+	values := sortedTestData(1000)
+	column := make([]driver.Value, len(values))
+	for i, value := range values {
+		if i%2 == 0 {
+			column[i] = value.Int64()
+		} else {
+			column[i] = []byte(value.String())
+		}
+	}
+
+	rmi, err := NewRMIFromRows(queryColumn(t, "rmi-sorted", column), RMIWidthParameter, RMIDepthParameter)
+	if err != nil {
+		t.Fatalf("Failed to build RMI %v\n", err)
+	}
+
+	for i, value := range values {
+		if rmi.values[i].Cmp(value) != 0 {
+			t.Fatalf("key %v was not read correctly", i)
+		}
+	}
+
+	unsorted := []driver.Value{int64(3), int64(1), int64(2)}
+	if _, err := NewRMIFromRows(queryColumn(t, "rmi-unsorted", unsorted), 2, 2); err == nil {
+		t.Fatalf("expected unsorted rows to be rejected")
+	}
+}
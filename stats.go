@@ -0,0 +1,55 @@
+// stats.go: compact per-leaf statistics for external query optimizers,
+// e.g. zone-map style pruning of the key ranges covered by each leaf
+
+package rmi
+
+import (
+	"math/big"
+)
+
+/*
+SegmentStats summarizes the keys routed to a single leaf
+MinKey, MaxKey: smallest and largest key routed to the leaf (nil if none or unknown)
+Count: number of keys routed to the leaf
+Slope: slope of the leaf model (of its first model for ensemble leaves)
+*/
+type SegmentStats struct {
+	MinKey, MaxKey *big.Int
+	Count          int
+	Slope          float64
+}
+
+// Overlaps reports whether a key in [lo, hi] may be routed to the segment
+func (s SegmentStats) Overlaps(lo, hi *big.Int) bool {
+	if s.Count == 0 {
+		return false
+	}
+
+	if s.MinKey == nil || s.MaxKey == nil {
+		return true
+	}
+
+	return lo.Cmp(s.MaxKey) <= 0 && hi.Cmp(s.MinKey) >= 0
+}
+
+// SegmentStats returns the statistics of every leaf in leaf order; the keys
+// are unknown (nil) for models loaded without their values (see Load)
+func (rmi *RMI) SegmentStats() []SegmentStats {
+
+	v := rmi.current.Load()
+	leaves := v.nodes[rmi.depth-1]
+	errs := rmi.errorsOf(v)
+
+	stats := make([]SegmentStats, len(leaves))
+	for i, leaf := range leaves {
+		stats[i].Count = errs[i].count
+		stats[i].Slope, _ = leaf.m.Float64()
+
+		if errs[i].count > 0 && rmi.values != nil {
+			stats[i].MinKey = rmi.values[errs[i].first]
+			stats[i].MaxKey = rmi.values[errs[i].last]
+		}
+	}
+
+	return stats
+}
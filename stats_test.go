@@ -0,0 +1,43 @@
+package rmi
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestSegmentStats(t *testing.T) {
+	values := sortedTestData(5000)
+	rmi, _ := NewRMI(values, RMIWidthParameter, 2)
+
+	stats := rmi.SegmentStats()
+	if len(stats) != rmi.NumLeaves() {
+		t.Fatalf("expected %v segments but got %v", rmi.NumLeaves(), len(stats))
+	}
+
+	count := 0
+	for _, s := range stats {
+		count += s.Count
+		if s.Count > 0 && s.MinKey.Cmp(s.MaxKey) > 0 {
+			t.Fatalf("segment min key %v exceeds max key %v", s.MinKey, s.MaxKey)
+		}
+	}
+	if count != len(values) {
+		t.Fatalf("segments cover %v keys instead of %v", count, len(values))
+	}
+
+	// pruning must keep every segment a queried key is routed to
+	lo, hi := values[1200], values[1300]
+	for i := 1200; i <= 1300; i++ {
+		_, loc := rmi.leaf(rmi.current.Load(), values[i])
+		if !stats[loc].Overlaps(lo, hi) {
+			t.Fatalf("segment %v of key %v was pruned", loc, i)
+		}
+	}
+
+	above := new(big.Int).Add(values[len(values)-1], big.NewInt(1))
+	for i, s := range stats {
+		if s.Overlaps(above, above) {
+			t.Fatalf("segment %v overlaps a key beyond the data", i)
+		}
+	}
+}
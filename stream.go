@@ -0,0 +1,262 @@
+// stream.go: builds from a restartable stream of sorted keys. The training
+// split of every node is a range of key positions that only depends on the
+// number of keys, so each layer is trained in one pass over the stream that
+// adds every key to the exact regression sums of its node (see batch.go);
+// besides the sums a node only keeps its first, median and last key, which
+// is all the fallbacks and the endpoint and constant models read. A final
+// pass measures the error bounds. The keys are never held in memory, so a
+// model can be built directly from a database cursor or a compressed file.
+
+package rmi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/sachaservan/rmi/regress"
+)
+
+// KeyIterator is a restartable cursor over sorted keys; Reset is called
+// before every pass and Key may reuse its result once Next is called again
+type KeyIterator interface {
+	Reset() error  // restarts the iteration before the first key
+	Next() bool    // advances to the next key, false at the end or on error
+	Key() *big.Int // the current key
+	Err() error    // the error that ended the iteration, if any
+}
+
+/*
+Keys of a node of a streamed layer
+lo, hi: range [lo, hi) of the positions of the keys the node learns
+offset: index predicted by the node if it learns fewer than 2 keys
+*/
+type streamTask struct {
+	lo, hi int
+	offset int64
+}
+
+// NewRMIFromIterator builds an RMI over the n sorted keys of it (shaped as
+// by NewRMIWithOptions) with depth + 1 passes over the keys; the keys are
+// not retained, so as for loaded models the exact queries are unavailable,
+// and only models and options that need no more than the sums, first,
+// median and last key of each node are supported
+func NewRMIFromIterator(it KeyIterator, n int, opts ...Option) (*RMI, error) {
+
+	conf := config{}
+	for _, opt := range opts {
+		opt(&conf)
+	}
+
+	width, depth := conf.shape(n)
+	return newRMIFromIterator(it, n, width, depth, conf)
+}
+
+// newRMIFromIterator builds an RMI of the given shape over the n sorted keys of it
+func newRMIFromIterator(it KeyIterator, n, width, depth int, conf config) (*RMI, error) {
+
+	if err := conf.checkStream(); err != nil {
+		return nil, err
+	}
+
+	if n < 0 {
+		return nil, errors.New("number of keys must not be negative")
+	}
+
+	rmi := &RMI{conf: conf}
+	nodes, err := rmi.allocate(n, width, depth)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, span := rmi.startSpan(context.Background(), SpanBuild)
+	span.SetAttribute("keys", n)
+	span.SetAttribute("width", rmi.width)
+	span.SetAttribute("depth", rmi.depth)
+	err = rmi.buildStream(ctx, nodes, it, n)
+	span.End()
+	if err != nil {
+		return nil, err
+	}
+
+	v := newVersion(0, nodes, n-1).pack(rmi.conf.precision)
+	leaves, hist, err := rmi.measureStream(v, it, n)
+	if err != nil {
+		return nil, err
+	}
+	v.errs.once.Do(func() { v.errs.leaves, v.errs.hist = leaves, hist })
+	rmi.current.Store(v)
+
+	return rmi, nil
+}
+
+// checkStream returns an error for options that need the keys in memory
+func (c *config) checkStream() error {
+
+	switch {
+	case c.radixRoot, c.leafEnsemble, c.lastMile, c.monotonic, c.calibrate, c.sampleAbove > 0, c.leafFinalizer != nil:
+		return errors.New("option requires the keys in memory, build with NewRMI")
+	case c.model != ModelLinear && c.model != ModelEndpoint && c.model != ModelConstant:
+		return fmt.Errorf("model type %v cannot be trained from a key stream", c.model)
+	}
+
+	for layer, t := range c.layerModels {
+		if t != ModelLinear && t != ModelEndpoint && t != ModelConstant {
+			return fmt.Errorf("model type %v of layer %v cannot be trained from a key stream", t, layer)
+		}
+	}
+
+	return nil
+}
+
+// buildStream trains the layers of the RMI with one pass over it per layer
+func (rmi *RMI) buildStream(ctx context.Context, nodes [][]*Node, it KeyIterator, n int) error {
+
+	layer := []streamTask{{0, n, 0}}
+
+	for currentDepth := 0; currentDepth < rmi.depth; currentDepth++ {
+		_, span := rmi.startSpan(ctx, SpanBuildLayer)
+
+		sums := make([]regress.Sums, len(layer))
+		tasks := make([]buildTask, len(layer))
+		if err := rmi.accumulateStream(it, n, layer, sums, tasks); err != nil {
+			span.End()
+			return fmt.Errorf("layer %v: %w", currentDepth, err)
+		}
+
+		if rmi.conf.modelOf(currentDepth) == ModelLinear {
+			rmi.solveBatch(nodes[currentDepth], tasks, sums)
+		} else {
+			train := rmi.conf.fitFor(currentDepth)
+			for i, task := range tasks {
+				nodes[currentDepth][i] = train(task)
+			}
+		}
+
+		// leaf layer not reached yet, split the keys among the children of each node
+		next := make([]streamTask, 0)
+		for _, task := range layer {
+			if currentDepth == rmi.depth-1 {
+				break
+			}
+			next = rmi.splitStream(task, next)
+		}
+
+		span.SetAttribute("layer", currentDepth)
+		span.SetAttribute("nodes", len(layer))
+		span.End()
+
+		layer = next
+	}
+
+	return nil
+}
+
+// splitStream appends the tasks of the children of a node (see splitTask)
+func (rmi *RMI) splitStream(task streamTask, next []streamTask) []streamTask {
+
+	offset := task.offset
+	for _, bound := range rmi.childBounds(task.hi - task.lo) {
+		lo, hi := task.lo+bound[0], task.lo+bound[1]
+		if lo != hi {
+			offset = int64(lo)
+		}
+
+		next = append(next, streamTask{lo, hi, offset})
+	}
+
+	return next
+}
+
+// accumulateStream adds every key of it to the sums of the task learning
+// it and stores the first, median and last key of each task in tasks
+func (rmi *RMI) accumulateStream(it KeyIterator, n int, layer []streamTask, sums []regress.Sums, tasks []buildTask) error {
+
+	for i, task := range layer {
+		tasks[i].offset = big.NewInt(task.offset)
+	}
+
+	p := rmi.conf.pacer()
+	index := new(big.Int)
+	t := 0
+	err := scanKeys(it, n, func(i int, value *big.Int) {
+		if i%pacingChunk == pacingChunk-1 {
+			p.pause()
+		}
+
+		// tasks are consecutive and skip no key but those the split drops
+		for t < len(layer) && layer[t].hi <= i {
+			t++
+		}
+		if t == len(layer) || layer[t].lo > i {
+			return
+		}
+
+		task := layer[t]
+		sums[t].Add(value, index.SetInt64(int64(i)))
+
+		// the median of the keys of a task is the key at lo + (hi-lo)/2
+		count := task.hi - task.lo
+		if i == task.lo || i == task.hi-1 || (count > 2 && i == task.lo+count/2) {
+			tasks[t].values = append(tasks[t].values, new(big.Int).Set(value))
+			tasks[t].indices = append(tasks[t].indices, big.NewInt(int64(i)))
+		}
+	})
+
+	return err
+}
+
+// measureStream records the residuals of every key of it per leaf of v and their distribution
+func (rmi *RMI) measureStream(v *version, it KeyIterator, n int) ([]leafError, errorHistogram, error) {
+
+	p := rmi.conf.pacer()
+	leaves := make([]leafError, len(v.nodes[rmi.depth-1]))
+	hist := errorHistogram{}
+	err := scanKeys(it, n, func(i int, value *big.Int) {
+		if i%pacingChunk == pacingChunk-1 {
+			p.pause()
+		}
+
+		_, loc := rmi.leaf(v, value)
+		residual := i - rmi.getIndex(v, value)
+		leaves[loc].add(i, residual)
+		hist.add(int(math.Abs(float64(residual))))
+	})
+
+	return leaves, hist, err
+}
+
+// scanKeys restarts it and calls fn with the position and value of each
+// of its keys, checking that there are n keys in sorted order
+func scanKeys(it KeyIterator, n int, fn func(i int, value *big.Int)) error {
+
+	if err := it.Reset(); err != nil {
+		return err
+	}
+
+	prev := new(big.Int)
+	i := 0
+	for ; it.Next(); i++ {
+		value := it.Key()
+		if i == n {
+			return fmt.Errorf("iterator yields more than %v keys", n)
+		} else if i > 0 && prev.Cmp(value) == 1 {
+			return fmt.Errorf("values must be in sorted order (key %v)", i)
+		}
+
+		fn(i, value)
+		prev.Set(value)
+	}
+
+	if err := it.Err(); err != nil {
+		return err
+	}
+
+	if i != n {
+		return fmt.Errorf("iterator yields %v keys instead of %v", i, n)
+	}
+
+	return nil
+}
@@ -0,0 +1,104 @@
+package rmi
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+)
+
+// iterator over a slice of keys that fails after fail keys (if positive)
+type sliceIterator struct {
+	values []*big.Int
+	pos    int
+	fail   int
+	resets int
+}
+
+func (it *sliceIterator) Reset() error {
+	it.pos = -1
+	it.resets++
+	return nil
+}
+
+func (it *sliceIterator) Next() bool {
+	it.pos++
+	return it.pos < len(it.values) && (it.fail <= 0 || it.pos < it.fail)
+}
+
+func (it *sliceIterator) Key() *big.Int {
+	return it.values[it.pos]
+}
+
+func (it *sliceIterator) Err() error {
+	if it.fail > 0 && it.pos >= it.fail {
+		return errors.New("cursor closed")
+	}
+	return nil
+}
+
+func TestNewRMIFromIterator(t *testing.T) {
+
+	duplicates := sortedTestData(5000)
+	for i := 1000; i < 1500; i++ {
+		duplicates[i] = duplicates[1000]
+	}
+
+	for _, values := range [][]*big.Int{sortedTestData(20000), prefixedKeys(5000), duplicates} {
+		for _, opts := range [][]Option{nil, {WithWidth(7), WithDepth(3)}, {WithEndpointFit(0)},
+			{WithLayerModel(1, ModelConstant)}, {WithIllConditioned(FallbackConstant)}, {WithWidth(1), WithDepth(2)}} {
+
+			want, err := NewRMIWithOptions(values, opts...)
+			if err != nil {
+				t.Fatalf("Failed to build RMI %v\n", err)
+			}
+
+			it := &sliceIterator{values: values}
+			got, err := NewRMIFromIterator(it, len(values), opts...)
+			if err != nil {
+				t.Fatalf("Failed to build RMI from iterator %v\n", err)
+			}
+
+			if it.resets != got.depth+1 {
+				t.Fatalf("expected %v passes over the keys, got %v", got.depth+1, it.resets)
+			}
+
+			for i, value := range values {
+				if got.GetIndex(value) != want.GetIndex(value) {
+					t.Fatalf("streamed index of key %v differs from NewRMI", i)
+				}
+			}
+
+			if got.Fallbacks() != want.Fallbacks() {
+				t.Fatalf("streamed build has %v fallbacks instead of %v", got.Fallbacks(), want.Fallbacks())
+			}
+
+			if got.MaxError() != want.MaxError() || got.ErrorStats() != want.ErrorStats() {
+				t.Fatalf("streamed error stats %+v differ from %+v", got.ErrorStats(), want.ErrorStats())
+			}
+		}
+	}
+}
+
+func TestNewRMIFromIteratorErrors(t *testing.T) {
+
+	values := sortedTestData(1000)
+	unsorted := append([]*big.Int{}, values...)
+	unsorted[10], unsorted[500] = unsorted[500], unsorted[10]
+
+	for _, c := range []struct {
+		it   *sliceIterator
+		n    int
+		opts []Option
+	}{
+		{&sliceIterator{values: unsorted}, len(values), nil},
+		{&sliceIterator{values: values}, len(values) + 1, nil},
+		{&sliceIterator{values: values}, len(values) - 1, nil},
+		{&sliceIterator{values: values, fail: 300}, len(values), nil},
+		{&sliceIterator{values: values}, len(values), []Option{WithCalibration()}},
+		{&sliceIterator{values: values}, len(values), []Option{WithModelType(ModelAuto)}},
+	} {
+		if _, err := NewRMIFromIterator(c.it, c.n, c.opts...); err == nil {
+			t.Fatalf("expected an error building from %v keys of %v", c.n, len(c.it.values))
+		}
+	}
+}
@@ -0,0 +1,116 @@
+// strings.go: string keys through an order-preserving prefix compression.
+// Keys such as URLs and file paths share long common prefixes, so their
+// leading bytes carry no information and a fixed number of leading bytes
+// maps most keys to a few integers; the shared prefix is stripped before
+// the keys are encoded, keeping the encoded bytes for the part that differs.
+
+package rmi
+
+import (
+	"math/big"
+	"strings"
+)
+
+/*
+PrefixEncoder maps strings to integers monotonically in the byte order
+prefix: the common prefix of the keys the encoder was built over
+size: number of bytes after the prefix that are encoded
+*/
+type PrefixEncoder struct {
+	prefix string
+	size   int
+}
+
+// NewPrefixEncoder returns an encoder stripping the longest common prefix
+// of keys and encoding the following size bytes of each key
+func NewPrefixEncoder(keys []string, size int) *PrefixEncoder {
+
+	prefix := ""
+	if len(keys) > 0 {
+		prefix = keys[0]
+	}
+	for _, key := range keys {
+		n := 0
+		for n < len(prefix) && n < len(key) && prefix[n] == key[n] {
+			n++
+		}
+		prefix = prefix[:n]
+	}
+
+	return &PrefixEncoder{prefix, size}
+}
+
+// Prefix returns the common prefix stripped by the encoder
+func (e *PrefixEncoder) Prefix() string {
+	return e.prefix
+}
+
+// Encode maps s to an integer such that a < b implies Encode(a) <= Encode(b)
+// for all strings (also those without the common prefix): strings before the
+// prefixed range map to 0, strings after it to the largest value and strings
+// with the prefix to 1 + their next size bytes (padded with zeros)
+func (e *PrefixEncoder) Encode(s string) *big.Int {
+
+	if !strings.HasPrefix(s, e.prefix) {
+		if s < e.prefix {
+			return big.NewInt(0)
+		}
+
+		// 256^size + 1 is larger than every prefixed string
+		after := new(big.Int).Lsh(big.NewInt(1), uint(8*e.size))
+		return after.Add(after, big.NewInt(1))
+	}
+
+	value := KeyFromBytes([]byte(s[len(e.prefix):]), e.size)
+	return value.Add(value, big.NewInt(1))
+}
+
+// longest encoding chosen by NewRMIString, in bytes after the common prefix
+const maxStringKeyBytes = 16
+
+// NewRMIString builds an index over the sorted strings keys configured by
+// options (see NewRMIWithOptions); keys are prefix-compressed and encoded
+// with the fewest bytes after the common prefix that tell adjacent keys
+// apart, up to maxStringKeyBytes (see NewStringRMI for a fixed size)
+func NewRMIString(keys []string, opts ...Option) (*MappedRMI[string], error) {
+
+	conf := config{}
+	for _, opt := range opts {
+		opt(&conf)
+	}
+	width, depth := conf.shape(len(keys))
+
+	return NewStringRMI(keys, distinguishingBytes(keys), width, depth, opts...)
+}
+
+// distinguishingBytes returns the number of bytes after the common prefix
+// of the keys up to the first difference of any two adjacent keys, in
+// [1, maxStringKeyBytes]
+func distinguishingBytes(keys []string) int {
+
+	prefix := len(NewPrefixEncoder(keys, 0).Prefix())
+	size := 1
+	for i := 1; i < len(keys) && size < maxStringKeyBytes; i++ {
+		a, b := keys[i-1], keys[i]
+		n := prefix
+		for n < len(a) && n < len(b) && a[n] == b[n] {
+			n++
+		}
+		size = max(size, min(n-prefix+1, maxStringKeyBytes))
+	}
+
+	return size
+}
+
+// NewStringRMI builds an index over the sorted strings keys, trained on
+// their prefix-compressed encoding (see PrefixEncoder) of size bytes
+func NewStringRMI(
+	keys []string,
+	size int,
+	width int,
+	depth int,
+	opts ...Option) (*MappedRMI[string], error) {
+
+	encoder := NewPrefixEncoder(keys, size)
+	return NewMappedRMI(keys, encoder.Encode, strings.Compare, width, depth, opts...)
+}
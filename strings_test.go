@@ -0,0 +1,87 @@
+package rmi
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestStringRMI(t *testing.T) {
+	keys := make([]string, 0)
+	for i := 0; i < 3000; i++ {
+		keys = append(keys, fmt.Sprintf("https://example.com/static/assets/images/%07d.png", i*7))
+	}
+	sort.Strings(keys)
+
+	m, err := NewStringRMI(keys, 8, 10, 2)
+	if err != nil {
+		t.Fatalf("Failed to build string RMI %v\n", err)
+	}
+
+	for i, key := range keys {
+		if index, ok := m.Find(key); !ok || index != i {
+			t.Fatalf("key %q was not found (got %v, %v)", key, index, ok)
+		}
+	}
+	if _, ok := m.Find("https://example.com/static/assets/images/0000001.png"); ok {
+		t.Fatalf("found absent key")
+	}
+
+	// without compression the first 8 bytes of every key are equal
+	plain, _ := NewMappedRMI(keys, func(s string) *big.Int { return KeyFromBytes([]byte(s), 8) }, strings.Compare, 10, 2)
+	t.Logf("max error compressed %v plain %v", m.RMI().MaxError(), plain.RMI().MaxError())
+	if m.RMI().MaxError() >= plain.RMI().MaxError() {
+		t.Fatalf("expected prefix compression to reduce the error")
+	}
+}
+
+func TestPrefixEncoderOrder(t *testing.T) {
+	e := NewPrefixEncoder([]string{"/usr/lib/a", "/usr/lib/b", "/usr/local"}, 4)
+	if e.Prefix() != "/usr/l" {
+		t.Fatalf("unexpected common prefix %q", e.Prefix())
+	}
+
+	ordered := []string{"", "/usr", "/usr/k", "/usr/l", "/usr/lib", "/usr/local/bin", "/usr/m", "/var"}
+	for i := 1; i < len(ordered); i++ {
+		if e.Encode(ordered[i-1]).Cmp(e.Encode(ordered[i])) == 1 {
+			t.Fatalf("encoding of %q and %q is out of order", ordered[i-1], ordered[i])
+		}
+	}
+}
+
+func TestNewRMIString(t *testing.T) {
+	keys := make([]string, 0)
+	for i := 0; i < 2000; i++ {
+		keys = append(keys, fmt.Sprintf("user:%05d:profile", i*3))
+	}
+	sort.Strings(keys)
+
+	// the keys differ within the 4 digits following "user:0"
+	if size := distinguishingBytes(keys); size != 4 {
+		t.Fatalf("expected 4 distinguishing bytes, got %v", size)
+	}
+
+	m, err := NewRMIString(keys, WithWidth(20), WithDepth(2))
+	if err != nil {
+		t.Fatalf("Failed to build string RMI %v\n", err)
+	}
+
+	for i, key := range keys {
+		if index, ok := m.Find(key); !ok || index != i {
+			t.Fatalf("key %q was not found (got %v, %v)", key, index, ok)
+		}
+		if abs(m.GetIndex(key)-i) > m.RMI().MaxError() {
+			t.Fatalf("GetIndex of %q is %v, more than the max error from %v", key, m.GetIndex(key), i)
+		}
+	}
+
+	if _, ok := m.Find("user:00001:profile"); ok {
+		t.Fatalf("found absent key")
+	}
+
+	if m.RMI().NumLeaves() != 20 {
+		t.Fatalf("expected the shape of the options, got %v leaves", m.RMI().NumLeaves())
+	}
+}
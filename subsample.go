@@ -0,0 +1,62 @@
+// subsample.go: training of large nodes on a random subsample of their keys.
+// The cost of training a node grows with its keys while the accuracy of its
+// model levels off long before, so nodes above a threshold are trained on a
+// sorted subsample at a fixed rate and small nodes keep all of their keys.
+// The subsample of a node is drawn with a generator seeded by the node's
+// first index, so builds stay reproducible for any parallelism; the keys
+// are still split among the children and measured for the error bounds in
+// full.
+
+package rmi
+
+import (
+	"math"
+	"math/big"
+	"math/rand"
+)
+
+// WithSampleAbove trains every node of a build with more than n keys on a
+// random subsample of rate of its keys (but at least its first and last key);
+// nodes with at most n keys are trained on all of them
+func WithSampleAbove(n int, rate float64) Option {
+	return func(c *config) {
+		c.sampleAbove = n
+		c.sampleRate = rate
+	}
+}
+
+// subsample returns the task the node of task is trained on: task itself or,
+// above the threshold, its first and last key and a sorted random subsample
+// of the keys in between
+func (c *config) subsample(task buildTask) buildTask {
+
+	n := len(task.values)
+	if c.sampleAbove <= 0 || n <= c.sampleAbove {
+		return task
+	}
+
+	m := int(math.Max(2, math.Ceil(c.sampleRate*float64(n))))
+	if m >= n {
+		return task
+	}
+
+	sample := buildTask{make([]*big.Int, 0, m), make([]*big.Int, 0, m), task.offset}
+	sample.values = append(sample.values, task.values[0])
+	sample.indices = append(sample.indices, task.indices[0])
+
+	// selection sampling of m-2 of the n-2 inner keys (Knuth's algorithm S)
+	r := rand.New(rand.NewSource(task.indices[0].Int64()))
+	need := m - 2
+	for i := 1; i < n-1 && need > 0; i++ {
+		if r.Intn(n-1-i) < need {
+			sample.values = append(sample.values, task.values[i])
+			sample.indices = append(sample.indices, task.indices[i])
+			need--
+		}
+	}
+
+	sample.values = append(sample.values, task.values[n-1])
+	sample.indices = append(sample.indices, task.indices[n-1])
+
+	return sample
+}
@@ -0,0 +1,89 @@
+package rmi
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestSubsample(t *testing.T) {
+	values := sortedTestData(10000)
+	indices := make([]*big.Int, len(values))
+	for i := range indices {
+		indices[i] = big.NewInt(int64(i))
+	}
+
+	conf := config{sampleAbove: 1000, sampleRate: 0.1}
+	small := conf.subsample(buildTask{values[:1000], indices[:1000], indices[0]})
+	if len(small.values) != 1000 {
+		t.Fatalf("expected a node at the threshold to keep all keys, got %v", len(small.values))
+	}
+
+	task := buildTask{values[500:], indices[500:], indices[500]}
+	sample := conf.subsample(task)
+	if len(sample.values) != 950 || len(sample.indices) != 950 {
+		t.Fatalf("expected a subsample of 950 keys, got %v", len(sample.values))
+	}
+	if sample.values[0] != values[500] || sample.values[949] != values[len(values)-1] {
+		t.Fatalf("expected the subsample to keep the first and last key")
+	}
+	for i := 1; i < len(sample.indices); i++ {
+		if sample.indices[i].Cmp(sample.indices[i-1]) != 1 ||
+			sample.values[i] != values[sample.indices[i].Int64()] {
+			t.Fatalf("subsample key %v is out of order or not the key of its index", i)
+		}
+	}
+
+	// the subsample of a node does not change between builds
+	again := conf.subsample(task)
+	for i := range sample.indices {
+		if again.indices[i].Cmp(sample.indices[i]) != 0 {
+			t.Fatalf("subsample key %v differs between builds", i)
+		}
+	}
+}
+
+func TestSampleAbove(t *testing.T) {
+	values := sortedTestData(50000)
+
+	full, _ := NewRMI(values, 16, 2)
+	var first *RMI
+	for _, parallelism := range []int{1, 4} {
+		sampled, err := NewRMI(values, 16, 2, WithSampleAbove(5000, 0.2), WithParallelism(parallelism))
+		if err != nil {
+			t.Fatalf("Failed to build RMI %v\n", err)
+		}
+
+		// the error bounds are measured over all keys
+		for i, value := range values {
+			if e := abs(sampled.GetIndex(value) - i); e > sampled.MaxError() {
+				t.Fatalf("error %v of key %v exceeds the max error %v", e, i, sampled.MaxError())
+			}
+		}
+
+		if sampled.MaxError() > 2*full.MaxError()+10 {
+			t.Fatalf("sampled max error %v is far above the full max error %v", sampled.MaxError(), full.MaxError())
+		}
+
+		if first == nil {
+			first = sampled
+		} else if first.MaxError() != sampled.MaxError() || first.GetIndex(values[123]) != sampled.GetIndex(values[123]) {
+			t.Fatalf("sampled build depends on the parallelism")
+		}
+	}
+}
+
+func TestSampleAboveLogLinear(t *testing.T) {
+
+	// the log-linear regression is fit to the indices of the sampled keys
+	values := exponentialKeys(20000)
+	full, _ := NewRMI(values, 16, 2, WithModelType(ModelLogLinear))
+	sampled, err := NewRMI(values, 16, 2, WithModelType(ModelLogLinear), WithSampleAbove(100, 0.1))
+	if err != nil {
+		t.Fatalf("Failed to build RMI %v\n", err)
+	}
+
+	t.Logf("log-linear max error %v, sampled %v", full.MaxError(), sampled.MaxError())
+	if sampled.MaxError() > 2*full.MaxError()+10 {
+		t.Fatalf("sampled max error %v is far above the full max error %v", sampled.MaxError(), full.MaxError())
+	}
+}
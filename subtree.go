@@ -0,0 +1,117 @@
+// subtree.go: export and import of trained subtrees. The subtree below a
+// node covers the key range routed to that node; a participant of a
+// federated build can train the subtree of its partition and hand it to a
+// coordinator, which imports it into the global model at the same position.
+
+package rmi
+
+import (
+	"errors"
+	"math/big"
+)
+
+/*
+Subtree is the trained nodes below (and including) one node of a model
+Width: width of the model the subtree was exported from
+Layer, Position: layer and position of the root of the subtree
+MaxIndex: max index of the model the subtree was exported from
+Nodes: encoding of the nodes of each layer of the subtree from its root
+down to the leaves, in position order (see Node.MarshalBinary)
+*/
+type Subtree struct {
+	Width, Layer, Position int
+	MaxIndex               int
+	Nodes                  [][][]byte
+}
+
+// ExportSubtree returns the subtree rooted at the node at position pos of
+// layer in the current version of the model (last-mile tables are not exported)
+func (rmi *RMI) ExportSubtree(layer, pos int) (*Subtree, error) {
+
+	v := rmi.current.Load()
+	if layer < 0 || layer >= rmi.depth || pos < 0 || pos >= len(v.nodes[layer]) {
+		return nil, errors.New("subtree root out of range")
+	}
+
+	sub := &Subtree{Width: rmi.width, Layer: layer, Position: pos, MaxIndex: v.maxIndex}
+	size := 1
+	for l := layer; l < rmi.depth; l++ {
+		encoded := make([][]byte, size)
+		for i := range encoded {
+			encoded[i] = appendNode(nil, v.nodes[l][pos*size+i])
+		}
+		sub.Nodes = append(sub.Nodes, encoded)
+		size *= rmi.width
+	}
+
+	return sub, nil
+}
+
+// ImportSubtree replaces the subtree at the position of sub with its nodes
+// and publishes the result as a new epoch. The internal nodes of sub are
+// rescaled from its max index to the model's so routing is preserved, and
+// the leaf predictions are moved by shift indices (e.g., the start index
+// of the partition the subtree was trained on)
+func (rmi *RMI) ImportSubtree(sub *Subtree, shift int64) (uint64, error) {
+
+	if sub.Width != rmi.width || sub.Layer < 0 || sub.Layer+len(sub.Nodes) != rmi.depth {
+		return 0, errors.New("subtree does not match the shape of the model")
+	}
+
+	if sub.Position < 0 || int64(sub.Position) >= numLeaves(rmi.width, sub.Layer+1) {
+		return 0, errors.New("subtree root out of range")
+	}
+
+	// decode the nodes before publishing anything
+	nodes := make([][]*Node, len(sub.Nodes))
+	size := 1
+	for l, encoded := range sub.Nodes {
+		if len(encoded) != size {
+			return 0, errors.New("invalid subtree layer size")
+		}
+
+		nodes[l] = make([]*Node, size)
+		for i, data := range encoded {
+			node := &Node{}
+			if err := node.UnmarshalBinary(data); err != nil {
+				return 0, err
+			}
+			nodes[l][i] = node
+		}
+		size *= rmi.width
+	}
+
+	return rmi.publish(func(v *version) *version {
+
+		scale := big.NewFloat(routingMaxIndex(v.maxIndex))
+		scale.Quo(scale, big.NewFloat(routingMaxIndex(sub.MaxIndex)))
+
+		next := v.next()
+		next.nodes = make([][]*Node, rmi.depth)
+		next.coeffs = make([]*layerCoefficients, rmi.depth)
+		copy(next.nodes, v.nodes)
+		copy(next.coeffs, v.coeffs)
+
+		size := 1
+		for l, imported := range nodes {
+			layer := sub.Layer + l
+			replaced := make([]*Node, len(v.nodes[layer]))
+			copy(replaced, v.nodes[layer])
+
+			for i, node := range imported {
+				if layer < rmi.depth-1 {
+					node = node.scaled(scale)
+				} else {
+					node = node.shifted(shift)
+				}
+				replaced[sub.Position*size+i] = node
+			}
+
+			next.nodes[layer], next.coeffs[layer] = packLayer(replaced, 0)
+			size *= rmi.width
+		}
+		next.root = next.nodes[0][0]
+
+		return next
+	}), nil
+}
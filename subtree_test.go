@@ -0,0 +1,61 @@
+package rmi
+
+import (
+	"testing"
+)
+
+func TestImportSubtree(t *testing.T) {
+	values := sortedTestData(3000)
+	trained, _ := NewRMI(values, 4, 3)
+	coordinator, _ := NewRMI(values, 4, 3, WithEndpointFit())
+
+	// importing the whole tree reproduces the exporting model
+	sub, err := trained.ExportSubtree(0, 0)
+	if err != nil {
+		t.Fatalf("failed to export subtree %v", err)
+	}
+	if _, err := coordinator.ImportSubtree(sub, 0); err != nil {
+		t.Fatalf("failed to import subtree %v", err)
+	}
+
+	for i, value := range values {
+		if coordinator.GetIndex(value) != trained.GetIndex(value) {
+			t.Fatalf("imported model predicts key %v differently", i)
+		}
+	}
+	if coordinator.MaxError() != trained.MaxError() {
+		t.Fatalf("imported model has a different error")
+	}
+
+	// a shifted leaf moves the predictions of its keys only
+	leaf, _ := trained.ExportSubtree(2, 5)
+	before := trained.Pin()
+	trained.ImportSubtree(leaf, 7)
+	for i, value := range values {
+		_, loc := trained.leaf(before.v, value)
+		expected := before.GetIndex(value)
+		if loc == 5 {
+			expected = clampInt(expected+7, len(values)-1)
+		}
+		if got := trained.GetIndex(value); got != expected {
+			t.Fatalf("key %v predicted at %v, expected %v", i, got, expected)
+		}
+	}
+
+	other, _ := NewRMI(values, 3, 3)
+	if _, err := other.ImportSubtree(sub, 0); err == nil {
+		t.Fatalf("expected a subtree of another width to be rejected")
+	}
+	if _, err := trained.ExportSubtree(3, 0); err == nil {
+		t.Fatalf("expected an out of range subtree root to be rejected")
+	}
+}
+
+func clampInt(x, max int) int {
+	if x > max {
+		return max
+	} else if x < 0 {
+		return 0
+	}
+	return x
+}
@@ -0,0 +1,244 @@
+package rmi
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/big"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"testing"
+)
+
+var (
+	suiteSeed       = flag.Int64("rmi.seed", 1, "base seed of the synthetic benchmark datasets")
+	suiteKeys       = flag.Int("rmi.keys", 20000, "number of keys of each benchmark dataset")
+	suiteProfileDir = flag.String("rmi.profiledir", "", "directory receiving per configuration pprof profiles")
+	suiteSummary    = flag.String("rmi.summary", "", "file receiving the JSON summary of the benchmark suite")
+)
+
+// synthetic dataset of the benchmark suite
+type suiteDataset struct {
+	name     string
+	generate func(r *rand.Rand, n int) []*big.Int
+}
+
+var suiteDatasets = []suiteDataset{
+	{"uniform", func(r *rand.Rand, n int) []*big.Int {
+		values := make([]*big.Int, n)
+		for i := range values {
+			values[i] = big.NewInt(r.Int63())
+		}
+		return values
+	}},
+	{"lognormal", func(r *rand.Rand, n int) []*big.Int {
+		values := make([]*big.Int, n)
+		for i := range values {
+			values[i] = big.NewInt(int64(math.Exp(r.NormFloat64()*2 + 20)))
+		}
+		return values
+	}},
+	{"clustered", func(r *rand.Rand, n int) []*big.Int {
+		centers := make([]int64, 16)
+		for i := range centers {
+			centers[i] = r.Int63n(math.MaxInt64 / 2)
+		}
+		values := make([]*big.Int, n)
+		for i := range values {
+			values[i] = big.NewInt(centers[r.Intn(len(centers))] + r.Int63n(1<<20))
+		}
+		return values
+	}},
+}
+
+// configuration of the benchmark suite
+type suiteConfig struct {
+	name         string
+	width, depth int
+	opts         []Option
+}
+
+var suiteConfigs = []suiteConfig{
+	{"linear-w100-d2", 100, 2, nil},
+	{"linear-w16-d3", 16, 3, nil},
+	{"auto-w100-d2", 100, 2, []Option{WithModelType(ModelAuto)}},
+	{"radix-w128-d2", 128, 2, []Option{WithRadixRoot()}},
+}
+
+/*
+Result of one operation of the suite, as written to the summary
+Dataset, Config, Op: what was measured
+Seed, Keys: seed and size of the dataset
+NsPerOp, AllocsPerOp, BytesPerOp: cost of one operation
+MaxError, MeanError, SearchSteps: accuracy of the model (see ExpectedSearchSteps)
+*/
+type suiteResult struct {
+	Dataset     string  `json:"dataset"`
+	Config      string  `json:"config"`
+	Op          string  `json:"op"`
+	Seed        int64   `json:"seed"`
+	Keys        int     `json:"keys"`
+	NsPerOp     float64 `json:"ns_per_op"`
+	AllocsPerOp uint64  `json:"allocs_per_op"`
+	BytesPerOp  uint64  `json:"bytes_per_op"`
+	MaxError    int     `json:"max_error"`
+	MeanError   float64 `json:"mean_error"`
+	SearchSteps float64 `json:"search_steps"`
+}
+
+// datasetSeed derives the seed of a dataset from the base seed and its name
+func datasetSeed(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return *suiteSeed ^ int64(h.Sum64())
+}
+
+// sortedDataset draws the sorted keys of a dataset
+func sortedDataset(d suiteDataset, n int) []*big.Int {
+	values := d.generate(rand.New(rand.NewSource(datasetSeed(d.name))), n)
+	sort.Slice(values, func(i, j int) bool {
+		return values[i].Cmp(values[j]) == -1
+	})
+
+	return values
+}
+
+// runProfiled runs op b.N times, profiling it if -rmi.profiledir is set,
+// and returns its cost; the latest run (with the final b.N) is kept
+func runProfiled(b *testing.B, name string, op func(i int)) suiteResult {
+
+	if *suiteProfileDir != "" {
+		f, err := os.Create(filepath.Join(*suiteProfileDir, name+".cpu.pprof"))
+		if err != nil {
+			b.Fatalf("Failed to create profile %v\n", err)
+		}
+		defer f.Close()
+
+		// fails if the test binary is already profiling (-cpuprofile)
+		if err := pprof.StartCPUProfile(f); err != nil {
+			b.Logf("not profiling %v: %v", name, err)
+		} else {
+			defer pprof.StopCPUProfile()
+		}
+	}
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		op(i)
+	}
+	b.StopTimer()
+	runtime.ReadMemStats(&after)
+
+	if *suiteProfileDir != "" {
+		f, err := os.Create(filepath.Join(*suiteProfileDir, name+".allocs.pprof"))
+		if err != nil {
+			b.Fatalf("Failed to create profile %v\n", err)
+		}
+		defer f.Close()
+		pprof.Lookup("allocs").WriteTo(f, 0)
+	}
+
+	return suiteResult{
+		NsPerOp:     float64(b.Elapsed().Nanoseconds()) / float64(b.N),
+		AllocsPerOp: (after.Mallocs - before.Mallocs) / uint64(b.N),
+		BytesPerOp:  (after.TotalAlloc - before.TotalAlloc) / uint64(b.N),
+	}
+}
+
+// BenchmarkSuite builds and queries every configuration over seedable
+// synthetic datasets. Every dataset is drawn from its own seed (derived from
+// -rmi.seed and the dataset name, so adding a dataset does not change the
+// others). With -rmi.profiledir a CPU profile and an allocation profile are
+// written per dataset, configuration and operation; allocation profiles are
+// cumulative over the run (compare consecutive ones with pprof -diff_base).
+// With -rmi.summary a JSON summary of every result is written for tracking
+// regressions across releases, e.g.
+//
+//	go test -run '^$' -bench Suite -rmi.profiledir prof -rmi.summary bench.json
+func BenchmarkSuite(b *testing.B) {
+
+	if *suiteProfileDir != "" {
+		if err := os.MkdirAll(*suiteProfileDir, 0o755); err != nil {
+			b.Fatalf("Failed to create profile directory %v\n", err)
+		}
+	}
+
+	results := make(map[string]suiteResult)
+	for _, d := range suiteDatasets {
+		values := sortedDataset(d, *suiteKeys)
+
+		for _, c := range suiteConfigs {
+			rmi, err := NewRMI(values, c.width, c.depth, c.opts...)
+			if err != nil {
+				b.Fatalf("Failed to build %v over %v: %v\n", c.name, d.name, err)
+			}
+
+			ops := []struct {
+				name string
+				op   func(i int)
+			}{
+				{"build", func(i int) { NewRMI(values, c.width, c.depth, c.opts...) }},
+				{"getindex", func(i int) { rmi.GetIndex(values[i%len(values)]) }},
+				{"lookup", func(i int) { rmi.Lookup(values[i%len(values)]) }},
+			}
+
+			for _, op := range ops {
+				name := fmt.Sprintf("%v-%v-%v", d.name, c.name, op.name)
+				b.Run(name, func(b *testing.B) {
+					res := runProfiled(b, name, op.op)
+					res.Dataset, res.Config, res.Op = d.name, c.name, op.name
+					res.Seed, res.Keys = datasetSeed(d.name), len(values)
+					res.MaxError, res.MeanError = rmi.MaxError(), rmi.MeanError()
+					res.SearchSteps = rmi.ExpectedSearchSteps()
+					results[name] = res
+				})
+			}
+		}
+	}
+
+	if *suiteSummary == "" {
+		return
+	}
+
+	summary := make([]suiteResult, 0, len(results))
+	for _, res := range results {
+		summary = append(summary, res)
+	}
+	sort.Slice(summary, func(i, j int) bool {
+		a, b := summary[i], summary[j]
+		return a.Dataset+a.Config+a.Op < b.Dataset+b.Config+b.Op
+	})
+
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		b.Fatalf("Failed to encode summary %v\n", err)
+	}
+	if err := os.WriteFile(*suiteSummary, data, 0o644); err != nil {
+		b.Fatalf("Failed to write summary %v\n", err)
+	}
+}
+
+func TestSuiteDatasets(t *testing.T) {
+
+	for _, d := range suiteDatasets {
+		a, b := sortedDataset(d, 100), sortedDataset(d, 100)
+		for i := range a {
+			if a[i].Cmp(b[i]) != 0 {
+				t.Fatalf("dataset %v is not reproducible from its seed", d.name)
+			}
+		}
+
+		if datasetSeed(d.name) == *suiteSeed {
+			t.Fatalf("dataset %v uses the base seed", d.name)
+		}
+	}
+}
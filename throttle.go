@@ -0,0 +1,69 @@
+// throttle.go: CPU rate limiting of builds. A build on a serving host (e.g.,
+// the background compaction of an Updatable) can be limited to a fraction
+// of the CPU time of each of its goroutines: the build works in chunks (a
+// node, or pacingChunk keys when routing keys), and after each chunk it
+// yields to the scheduler and sleeps off the idle time its work owes, so
+// query goroutines keep the remaining share of the cores.
+
+package rmi
+
+import (
+	"runtime"
+	"time"
+)
+
+// number of keys routed between two pauses of a rate limited build
+const pacingChunk = 1024
+
+// owed idle time below this is carried over rather than slept
+const minPause = time.Millisecond
+
+/*
+Pacing of one build goroutine
+duty: fraction of the wall time the goroutine may work, in (0, 1)
+start: start of the current chunk of work
+owed: idle time owed by the work done so far
+*/
+type pacer struct {
+	duty  float64
+	start time.Time
+	owed  time.Duration
+}
+
+// WithRateLimit limits every goroutine of a build (and of folds of an
+// Updatable over the model, see Compact) to working duty of the wall time,
+// e.g. 0.25 for a quarter of a core per goroutine; single nodes are trained
+// without pausing, so a node over many keys may exceed its share briefly
+func WithRateLimit(duty float64) Option {
+	return func(c *config) {
+		c.rateLimit = duty
+	}
+}
+
+// pacer returns the pacer of a new build goroutine (nil without a rate limit)
+func (c *config) pacer() *pacer {
+	if c.rateLimit <= 0 || c.rateLimit >= 1 {
+		return nil
+	}
+
+	return &pacer{duty: c.rateLimit, start: time.Now()}
+}
+
+// pause ends a chunk of work: it yields to the scheduler and sleeps
+// once the idle time owed by the work done exceeds minPause
+func (p *pacer) pause() {
+	if p == nil {
+		return
+	}
+
+	work := time.Since(p.start)
+	p.owed += time.Duration(float64(work) * (1 - p.duty) / p.duty)
+
+	runtime.Gosched()
+	if p.owed >= minPause {
+		time.Sleep(p.owed)
+		p.owed = 0
+	}
+
+	p.start = time.Now()
+}
@@ -0,0 +1,40 @@
+package rmi
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPacer(t *testing.T) {
+
+	p := (&config{rateLimit: 0.25}).pacer()
+	start := time.Now()
+	for time.Since(start) < 10*time.Millisecond {
+	}
+	p.pause()
+
+	// 10ms of work at a quarter duty owes 30ms of idle time
+	if elapsed := time.Since(start); elapsed < 35*time.Millisecond {
+		t.Fatalf("pacer only paused for %v", elapsed-10*time.Millisecond)
+	}
+
+	if (&config{}).pacer() != nil || (&config{rateLimit: 1}).pacer() != nil {
+		t.Fatalf("expected no pacing without a rate limit")
+	}
+}
+
+func TestWithRateLimit(t *testing.T) {
+	values := sortedTestData(5000)
+
+	limited, err := NewRMI(values, 64, 2, WithRateLimit(0.5), WithParallelism(2))
+	if err != nil {
+		t.Fatalf("Failed to build RMI %v\n", err)
+	}
+
+	unlimited, _ := NewRMI(values, 64, 2)
+	for i, value := range values {
+		if limited.GetIndex(value) != unlimited.GetIndex(value) {
+			t.Fatalf("rate limited build predicts key %v differently", i)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+// trace.go: optional tracing of index build and queries.
+// The interfaces mirror the shape of OpenTelemetry's tracer so that
+// an otel trace.Tracer can be plugged in with a thin adapter, without
+// this package depending on the otel module.
+
+package rmi
+
+import (
+	"context"
+)
+
+// Tracer starts spans; ctx carries the parent span (if any)
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a single traced operation
+type Span interface {
+	SetAttribute(key string, value interface{})
+	End()
+}
+
+// TraceHook receives the layer, the position in the layer and the raw output
+// (mx+b) of every node a query evaluates, see WithTraceHook
+type TraceHook func(layer, node int, prediction float64)
+
+// span names emitted by the RMI
+const (
+	SpanBuild      = "rmi.build"
+	SpanBuildLayer = "rmi.build.layer"
+	SpanQuery      = "rmi.query"
+)
+
+// noopSpan is used when tracing is disabled
+type noopSpan struct{}
+
+func (noopSpan) SetAttribute(string, interface{}) {}
+func (noopSpan) End()                             {}
+
+// WithTraceHook calls hook for every node evaluated by GetIndex, GetIndicesInto
+// and the lookups, from the root (or the node selected by the routing cache,
+// see WithRoutingCache) to the leaf; leaves answering from their last-mile
+// table report the exact index. The hook runs on the querying goroutine and
+// must be safe for concurrent use; it is not called for the queries of the
+// build or of the error measurements
+func WithTraceHook(hook TraceHook) Option {
+	return func(c *config) {
+		c.traceHook = hook
+	}
+}
+
+// startSpan starts a span with the configured tracer or returns a no-op span
+func (rmi *RMI) startSpan(ctx context.Context, name string) (context.Context, Span) {
+	if rmi.conf.tracer == nil {
+		return ctx, noopSpan{}
+	}
+
+	return rmi.conf.tracer.Start(ctx, name)
+}
+
+// sampleQuery reports whether the current query should be traced
+func (rmi *RMI) sampleQuery() bool {
+	if rmi.conf.tracer == nil || rmi.conf.traceEvery == 0 {
+		return false
+	}
+
+	return rmi.queries.Add(1)%rmi.conf.traceEvery == 0
+}
@@ -0,0 +1,111 @@
+package rmi
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+// records the names of all spans that were started and ended
+type recordingTracer struct {
+	mu    sync.Mutex
+	names []string
+	open  int
+}
+
+type recordingSpan struct {
+	tracer *recordingTracer
+}
+
+func (t *recordingTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.names = append(t.names, name)
+	t.open++
+	return ctx, &recordingSpan{t}
+}
+
+func (s *recordingSpan) SetAttribute(string, interface{}) {}
+
+func (s *recordingSpan) End() {
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.tracer.open--
+}
+
+func (t *recordingTracer) count(name string) int {
+	n := 0
+	for _, v := range t.names {
+		if v == name {
+			n++
+		}
+	}
+	return n
+}
+
+func TestTracing(t *testing.T) {
+	values := sortedTestData(1000)
+	tracer := &recordingTracer{}
+
+	rmi, err := NewRMI(values, RMIWidthParameter, 3, WithTracer(tracer), WithQueryTracing(4))
+	if err != nil {
+		t.Fatalf("Failed to build RMI %v\n", err)
+	}
+
+	if tracer.count(SpanBuild) != 1 || tracer.count(SpanBuildLayer) != 3 {
+		t.Fatalf("unexpected build spans %v", tracer.names)
+	}
+
+	for i := 0; i < 8; i++ {
+		rmi.GetIndexContext(context.Background(), values[i])
+	}
+
+	if tracer.count(SpanQuery) != 2 {
+		t.Fatalf("expected 2 sampled query spans, got %v", tracer.count(SpanQuery))
+	}
+
+	if tracer.open != 0 {
+		t.Fatalf("%v spans were not ended", tracer.open)
+	}
+}
+
+func TestTraceHook(t *testing.T) {
+	values := sortedTestData(1000)
+
+	type hit struct {
+		layer, node int
+		prediction  float64
+	}
+	var hits []hit
+	rmi, err := NewRMI(values, 4, 3, WithTraceHook(func(layer, node int, prediction float64) {
+		hits = append(hits, hit{layer, node, prediction})
+	}))
+	if err != nil {
+		t.Fatalf("Failed to build RMI %v\n", err)
+	}
+
+	if len(hits) != 0 {
+		t.Fatalf("the build reported %v nodes to the hook", len(hits))
+	}
+
+	value := values[500]
+	rmi.GetIndex(value)
+	if len(hits) != 3 || hits[0].layer != 0 || hits[0].node != 0 || hits[1].layer != 1 || hits[2].layer != 2 {
+		t.Fatalf("unexpected nodes reported %+v", hits)
+	}
+
+	if _, loc := rmi.leaf(rmi.current.Load(), value); hits[2].node != loc || hits[2].prediction != rmi.PredictFloat64(value) {
+		t.Fatalf("leaf reported as %+v, expected leaf %v predicting %v", hits[2], loc, rmi.PredictFloat64(value))
+	}
+
+	hits = nil
+	if _, ok := rmi.Lookup(value); !ok || len(hits) != 3 {
+		t.Fatalf("expected a lookup to report 3 nodes, got %v", len(hits))
+	}
+
+	hits = nil
+	rmi.GetIndicesInto(values[:10], make([]int, 10))
+	if len(hits) != 30 {
+		t.Fatalf("expected 30 reported nodes, got %v", len(hits))
+	}
+}
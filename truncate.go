@@ -0,0 +1,127 @@
+// truncate.go: indexes over very wide keys (e.g., cryptographic hashes)
+// trained on a truncated prefix. The model is an RMI64 over the top bits of
+// every key, so training and prediction never touch the full key; keys that
+// share a prefix collide in the model and exact queries resolve collisions
+// by comparing the full keys within the run of the colliding prefix.
+
+package rmi
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"sort"
+)
+
+/*
+TruncatedRMI is an index over wide keys trained on their top bits
+model: the RMI over the key prefixes
+keys: the sorted full keys (retained, not copied)
+prefixes: the prefix of every key
+shift: number of low bits dropped from the keys
+collisions: number of keys whose prefix equals the previous key's
+longestRun: largest number of keys sharing one prefix
+*/
+type TruncatedRMI struct {
+	model      *RMI64
+	keys       []*big.Int
+	prefixes   []uint64
+	shift      uint
+	collisions int
+	longestRun int
+}
+
+// NewTruncatedRMI builds an index over the sorted non-negative keys trained
+// on their top bits (at most 64); see NewRMI for width and depth
+func NewTruncatedRMI(keys []*big.Int, bits uint, width int, depth int) (*TruncatedRMI, error) {
+
+	if bits == 0 || bits > 64 {
+		return nil, errors.New("prefixes must have between 1 and 64 bits")
+	}
+
+	for i, key := range keys {
+		if key.Sign() == -1 {
+			return nil, errors.New("truncated keys must be non-negative")
+		}
+		if i > 0 && keys[i-1].Cmp(key) == 1 {
+			return nil, errors.New("values must be in sorted order")
+		}
+	}
+
+	t := &TruncatedRMI{keys: keys, prefixes: make([]uint64, len(keys))}
+	if len(keys) > 0 && uint(keys[len(keys)-1].BitLen()) > bits {
+		t.shift = uint(keys[len(keys)-1].BitLen()) - bits
+	}
+
+	run := 0
+	prefix := new(big.Int)
+	for i, key := range keys {
+		t.prefixes[i] = prefix.Rsh(key, t.shift).Uint64()
+
+		if i > 0 && t.prefixes[i] == t.prefixes[i-1] {
+			t.collisions++
+			run++
+		} else {
+			run = 1
+		}
+		t.longestRun = int(math.Max(float64(t.longestRun), float64(run)))
+	}
+
+	model, err := NewRMI64(t.prefixes, width, depth)
+	if err != nil {
+		return nil, err
+	}
+	t.model = model
+
+	return t, nil
+}
+
+// prefix returns the prefix of value (saturated to 64 bits, so
+// values above all keys keep an order consistent with the keys)
+func (t *TruncatedRMI) prefix(value *big.Int) uint64 {
+	if value.Sign() == -1 {
+		return 0
+	}
+
+	prefix := new(big.Int).Rsh(value, t.shift)
+	if !prefix.IsUint64() {
+		return math.MaxUint64
+	}
+
+	return prefix.Uint64()
+}
+
+// Collisions returns the number of keys whose prefix equals the previous
+// key's and the largest number of keys sharing one prefix
+func (t *TruncatedRMI) Collisions() (int, int) {
+	return t.collisions, t.longestRun
+}
+
+// GetIndex returns the approximate index of value (the index of
+// a key of the run of value's prefix)
+func (t *TruncatedRMI) GetIndex(value *big.Int) int {
+	return t.model.GetIndex(t.prefix(value))
+}
+
+// Lookup returns the index of the first occurrence of value (or false if
+// absent): the model finds the run of keys sharing value's prefix exactly
+// and the full keys of the run are searched for value
+func (t *TruncatedRMI) Lookup(value *big.Int) (int, bool) {
+
+	if value.Sign() == -1 {
+		return 0, false
+	}
+
+	prefix := t.prefix(value)
+	first, ok := t.model.Lookup(prefix)
+	if !ok {
+		return 0, false
+	}
+
+	// end of the run of colliding keys
+	end := first + sort.Search(len(t.prefixes)-first, func(i int) bool {
+		return t.prefixes[first+i] != prefix
+	})
+
+	return searchRange(t.keys, value, first, end-1)
+}
@@ -0,0 +1,83 @@
+package rmi
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math/big"
+	"sort"
+	"testing"
+)
+
+// sorted sha256 hashes of 0..n-1 as keys
+func hashKeys(n int) []*big.Int {
+	keys := make([]*big.Int, n)
+	for i := range keys {
+		h := sha256.Sum256(binary.BigEndian.AppendUint64(nil, uint64(i)))
+		keys[i] = new(big.Int).SetBytes(h[:])
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Cmp(keys[j]) == -1
+	})
+
+	return keys
+}
+
+func TestTruncatedRMI(t *testing.T) {
+	keys := hashKeys(5000)
+
+	for _, bits := range []uint{64, 10} {
+		r, err := NewTruncatedRMI(keys, bits, RMIWidthParameter, 3)
+		if err != nil {
+			t.Fatalf("Failed to build truncated RMI %v\n", err)
+		}
+
+		collisions, longest := r.Collisions()
+		if bits == 64 && collisions != 0 {
+			t.Fatalf("unexpected collisions of 64 bit prefixes")
+		} else if bits == 10 && (collisions == 0 || longest < 2) {
+			t.Fatalf("expected collisions of 10 bit prefixes")
+		}
+
+		for i, key := range keys {
+			index, ok := r.Lookup(key)
+			if !ok || index != i {
+				t.Fatalf("Lookup of key %v returned %v, %v", i, index, ok)
+			}
+		}
+
+		// an absent key sharing the prefix of a key
+		absent := new(big.Int).Add(keys[100], big.NewInt(1))
+		if _, ok := r.Lookup(absent); ok {
+			t.Fatalf("found absent key")
+		}
+		if _, ok := r.Lookup(new(big.Int).Lsh(keys[len(keys)-1], 1)); ok {
+			t.Fatalf("found key above all keys")
+		}
+	}
+
+	if _, err := NewTruncatedRMI(keys, 65, 2, 2); err == nil {
+		t.Fatalf("expected prefixes wider than 64 bits to be rejected")
+	}
+}
+
+func BenchmarkTruncatedLookup(b *testing.B) {
+	keys := hashKeys(NumDataPoints)
+	r, _ := NewTruncatedRMI(keys, 64, RMIWidthParameter, RMIDepthParameter)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Lookup(keys[i%len(keys)])
+	}
+}
+
+func BenchmarkHashLookup(b *testing.B) {
+	keys := hashKeys(NumDataPoints)
+	r, _ := NewRMI(keys, RMIWidthParameter, RMIDepthParameter)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Lookup(keys[i%len(keys)])
+	}
+}
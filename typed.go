@@ -0,0 +1,292 @@
+// typed.go: RMI over native key types. Keys are converted to float64 for
+// training and evaluation (strings through their bytes after the common
+// prefix, see strings.go) and the model uses float64 coefficients, so
+// indexes over uint64, int64, float64 or string keys avoid the big.Int
+// path entirely; exact queries compare the native keys.
+
+package rmi
+
+import (
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"math"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+)
+
+/*
+Linear model of a node of a TypedRMI
+m, b: slope and intercept
+*/
+type linearModel struct {
+	m, b float64
+}
+
+/*
+Keys of a node of a TypedRMI under construction
+lo, hi: range [lo, hi) of the keys of the node
+offset: index predicted by the node if it has fewer than two keys
+*/
+type typedTask struct {
+	lo, hi, offset int
+}
+
+/*
+TypedRMI is an RMI over sorted keys of a native ordered type
+width, depth: width and depth of the rmi
+keys: the sorted keys (retained, not copied)
+toFloat: monotone conversion of keys to float64
+layers: the models of every layer (the root is layers[0][0])
+minResidual, maxResidual: error window of every leaf over the keys routed to it
+*/
+type TypedRMI[K cmp.Ordered] struct {
+	width, depth             int
+	keys                     []K
+	toFloat                  func(K) float64
+	layers                   [][]linearModel
+	minResidual, maxResidual []int
+}
+
+// NewTypedRMI builds an index over keys, which must be sorted; see NewRMI
+// for the meaning of width and depth
+func NewTypedRMI[K cmp.Ordered](keys []K, width int, depth int) (*TypedRMI[K], error) {
+
+	if !slices.IsSorted(keys) {
+		return nil, errors.New("values must be in sorted order")
+	}
+
+	width, depth, err := (&config{}).checkShape(len(keys), width, depth)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &TypedRMI[K]{width: width, depth: depth, keys: keys, toFloat: floatConversion(keys)}
+
+	x := make([]float64, len(keys))
+	y := make([]float64, len(keys))
+	for i, key := range keys {
+		x[i], y[i] = r.toFloat(key), float64(i)
+	}
+
+	// build layer by layer with the same split as the big.Int build
+	layer := []typedTask{{0, len(keys), 0}}
+	for d := 0; d < depth; d++ {
+		models := make([]linearModel, len(layer))
+		next := make([]typedTask, 0, len(layer)*width)
+		for i, t := range layer {
+			models[i] = fitLinear(x[t.lo:t.hi], y[t.lo:t.hi], t.offset)
+			if d < depth-1 {
+				next = splitRange(t.lo, t.hi, t.offset, width, next)
+			}
+		}
+		r.layers = append(r.layers, models)
+		layer = next
+	}
+
+	// error window of every leaf over the keys routed to it
+	leaves := len(r.layers[depth-1])
+	r.minResidual = make([]int, leaves)
+	r.maxResidual = make([]int, leaves)
+	seen := make([]bool, leaves)
+	for i := range keys {
+		loc, prediction := r.predict(x[i])
+		residual := i - prediction
+		if !seen[loc] || residual < r.minResidual[loc] {
+			r.minResidual[loc] = residual
+		}
+		if !seen[loc] || residual > r.maxResidual[loc] {
+			r.maxResidual[loc] = residual
+		}
+		seen[loc] = true
+	}
+
+	return r, nil
+}
+
+// fitLinear fits index = m*x + b over x, whose keys have the indices y;
+// fewer than two keys give the constant model at offset (as trainNode)
+func fitLinear(x []float64, y []float64, offset int) linearModel {
+
+	if len(x) < 2 {
+		return linearModel{0, float64(offset)}
+	}
+
+	n := float64(len(x))
+	meanX, meanY := 0.0, 0.0
+	for i, xi := range x {
+		meanX += xi
+		meanY += y[i]
+	}
+	meanX /= n
+	meanY /= n
+
+	covar, variance := 0.0, 0.0
+	for i, xi := range x {
+		covar += (xi - meanX) * (y[i] - meanY)
+		variance += (xi - meanX) * (xi - meanX)
+	}
+
+	if variance == 0 {
+		return linearModel{0, meanY}
+	}
+
+	m := covar / variance
+	return linearModel{m, meanY - m*meanX}
+}
+
+// splitRange is splitTask over the key range [lo, hi) and appends the
+// width child ranges (with the offset of empty children) to next
+func splitRange(lo, hi, offset, width int, next []typedTask) []typedTask {
+
+	n := hi - lo
+	rangeSize := int(float64(n) / float64(width))
+	if width == 1 {
+		return append(next, typedTask{lo, hi, offset})
+	}
+
+	leftIndex := 0
+	rightIndex := int(math.Max(0, float64(rangeSize)))
+	for i := 0; i < width; i++ {
+		if rightIndex <= 0 {
+			rightIndex = 0
+			leftIndex = 0
+		} else if rightIndex >= n {
+			rightIndex = n - 1
+		}
+
+		if leftIndex != rightIndex {
+			offset = lo + leftIndex
+		}
+
+		next = append(next, typedTask{lo + leftIndex, lo + rightIndex, offset})
+
+		leftIndex = rightIndex
+		rightIndex = int(math.Max(0, math.Min(float64(rightIndex+rangeSize), float64(n))-1))
+	}
+
+	return next
+}
+
+// predict routes x to a leaf and returns the leaf and its clamped prediction
+func (r *TypedRMI[K]) predict(x float64) (int, int) {
+
+	maxIndex := routingMaxIndex(len(r.keys) - 1)
+	location := 0
+	width := float64(r.width)
+	for d := 1; d < r.depth; d++ {
+		model := r.layers[d-1][location]
+		next := (model.m*x + model.b) / maxIndex * width
+		location = clampFloat(next, len(r.layers[d])-1)
+		width *= float64(r.width)
+	}
+
+	leaf := r.layers[r.depth-1][location]
+	return location, clampFloat(leaf.m*x+leaf.b, len(r.keys)-1)
+}
+
+// clampFloat truncates f to an integer in [0, max]
+func clampFloat(f float64, max int) int {
+	if math.IsNaN(f) || f < 0 {
+		return 0
+	} else if f > float64(max) {
+		return max
+	}
+
+	return int(f)
+}
+
+// GetIndex returns the approximate index of key
+func (r *TypedRMI[K]) GetIndex(key K) int {
+	_, index := r.predict(r.toFloat(key))
+	return index
+}
+
+// GetIndexWithBounds returns the window [lo, hi] guaranteed
+// to contain the index of key if it is one of the keys
+func (r *TypedRMI[K]) GetIndexWithBounds(key K) (int, int) {
+	loc, prediction := r.predict(r.toFloat(key))
+	lo := int(math.Max(0, float64(prediction+r.minResidual[loc])))
+	hi := int(math.Min(float64(len(r.keys)-1), float64(prediction+r.maxResidual[loc])))
+
+	return lo, hi
+}
+
+// Lookup returns the index of the first occurrence of key (or false if absent)
+func (r *TypedRMI[K]) Lookup(key K) (int, bool) {
+
+	if len(r.keys) == 0 {
+		return 0, false
+	}
+
+	lo, hi := r.GetIndexWithBounds(key)
+	index := lo + sort.Search(hi-lo+1, func(i int) bool {
+		return cmp.Compare(r.keys[lo+i], key) >= 0
+	})
+
+	if index <= hi && cmp.Compare(r.keys[index], key) == 0 {
+		return index, true
+	}
+
+	return 0, false
+}
+
+// floatConversion returns the monotone float64 conversion of the key type;
+// strings are converted through the 8 bytes following their common prefix
+func floatConversion[K cmp.Ordered](keys []K) func(K) float64 {
+
+	switch reflect.TypeFor[K]().Kind() {
+	case reflect.String:
+		strs := make([]string, len(keys))
+		for i, key := range keys {
+			strs[i] = reflect.ValueOf(key).String()
+		}
+		prefix := NewPrefixEncoder(strs, 8).Prefix()
+		return func(key K) float64 {
+			if s, ok := any(key).(string); ok {
+				return prefixFloat(prefix, s)
+			}
+			return prefixFloat(prefix, reflect.ValueOf(key).String())
+		}
+	case reflect.Float32, reflect.Float64:
+		return func(key K) float64 {
+			if f, ok := any(key).(float64); ok {
+				return f
+			}
+			return reflect.ValueOf(key).Float()
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return func(key K) float64 {
+			if u, ok := any(key).(uint64); ok {
+				return float64(u)
+			}
+			return float64(reflect.ValueOf(key).Uint())
+		}
+	}
+
+	return func(key K) float64 {
+		if i, ok := any(key).(int64); ok {
+			return float64(i)
+		} else if i, ok := any(key).(int); ok {
+			return float64(i)
+		}
+		return float64(reflect.ValueOf(key).Int())
+	}
+}
+
+// prefixFloat is PrefixEncoder.Encode with 8 bytes as a float64
+func prefixFloat(prefix string, s string) float64 {
+
+	if !strings.HasPrefix(s, prefix) {
+		if s < prefix {
+			return 0
+		}
+		return math.Ldexp(1, 64) + 1
+	}
+
+	padded := make([]byte, 8)
+	copy(padded, s[len(prefix):])
+	return float64(binary.BigEndian.Uint64(padded)) + 1
+}
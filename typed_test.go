@@ -0,0 +1,79 @@
+package rmi
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+func testTypedRMI[K interface {
+	~int64 | ~uint64 | ~float64 | ~string
+}](t *testing.T, keys []K, absent K) {
+	slices.Sort(keys)
+
+	r, err := NewTypedRMI(keys, RMIWidthParameter, 3)
+	if err != nil {
+		t.Fatalf("Failed to build typed RMI %v\n", err)
+	}
+
+	for i, key := range keys {
+		lo, hi := r.GetIndexWithBounds(key)
+		if i < lo || i > hi {
+			t.Fatalf("index %v of key %v outside its bounds [%v, %v]", i, key, lo, hi)
+		}
+
+		index, ok := r.Lookup(key)
+		if !ok || keys[index] != key || (index > 0 && keys[index-1] == key) {
+			t.Fatalf("Lookup of key %v returned %v, %v", key, index, ok)
+		}
+	}
+
+	if _, ok := r.Lookup(absent); ok {
+		t.Fatalf("found absent key %v", absent)
+	}
+}
+
+type userID uint64
+
+func TestTypedRMI(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+
+	ints := make([]int64, 5000)
+	uints := make([]uint64, 5000)
+	floats := make([]float64, 5000)
+	strs := make([]string, 5000)
+	ids := make([]userID, 5000)
+	for i := range ints {
+		ints[i] = r.Int63n(1<<40) - 1<<39
+		uints[i] = r.Uint64() | 1
+		floats[i] = r.NormFloat64() * 1e6
+		strs[i] = fmt.Sprintf("/home/user/data/file-%08d.txt", r.Intn(1e8))
+		ids[i] = userID(r.Uint64() | 1)
+	}
+
+	testTypedRMI(t, ints, 1<<41)
+	testTypedRMI(t, uints, 0)
+	testTypedRMI(t, floats, 1e12)
+	testTypedRMI(t, strs, "/home/user/data/file-x")
+	testTypedRMI(t, ids, 2)
+
+	if _, err := NewTypedRMI([]int64{3, 1}, 2, 2); err == nil {
+		t.Fatalf("expected an error for unsorted keys")
+	}
+}
+
+func BenchmarkTypedLookup(b *testing.B) {
+	keys := make([]uint64, NumDataPoints)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	slices.Sort(keys)
+	r, _ := NewTypedRMI(keys, RMIWidthParameter, RMIDepthParameter)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Lookup(keys[i%len(keys)])
+	}
+}
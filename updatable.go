@@ -0,0 +1,580 @@
+// updatable.go: updatable variant of the RMI. Inserted keys are staged in
+// sorted per-leaf buffers guarded by striped locks, so concurrent writers
+// rarely contend, and queries merge the buffer of their leaf with the keys of
+// the base model (the routing is monotone in the key, so the staged keys of
+// the leaves before it are all smaller and only need to be counted); a
+// compactor periodically folds the staged keys into a new model: the
+// internal layers are kept (rescaled to the new number of keys) and only the
+// leaves whose keys changed are retrained.
+
+package rmi
+
+import (
+	"context"
+	"math"
+	"math/big"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+Staging buffers of one lock stripe
+mu: guards leaves
+leaves: sorted staged keys by the leaf of the base model they are routed to
+*/
+type stagingStripe struct {
+	mu     sync.Mutex
+	leaves map[int][]*big.Int
+}
+
+/*
+Numbers of staged keys by leaf as a Fenwick tree, so that the keys staged
+in the leaves before a leaf are counted without visiting them
+*/
+type stagedCounts []atomic.Int64
+
+func newStagedCounts(leaves int) stagedCounts {
+	return make(stagedCounts, leaves+1)
+}
+
+// add adds n keys staged in leaf
+func (c stagedCounts) add(leaf int, n int64) {
+	for i := leaf + 1; i < len(c); i += i & -i {
+		c[i].Add(n)
+	}
+}
+
+// before returns the number of keys staged in the leaves before leaf
+func (c stagedCounts) before(leaf int) int {
+	sum := int64(0)
+	for i := leaf; i > 0; i -= i & -i {
+		sum += c[i].Load()
+	}
+
+	return int(sum)
+}
+
+/*
+Published state of an updatable index
+base: the model over all compacted keys
+staged: numbers of keys staged in the stripes by leaf of base
+folding: staged keys being folded into the next base (nil if no compaction runs)
+foldingBefore: number of folding keys in the leaves before each leaf
+*/
+type updatableState struct {
+	base          *RMI
+	staged        stagedCounts
+	folding       map[int][]*big.Int
+	foldingBefore []int
+}
+
+/*
+Updatable RMI accepting concurrent inserts
+state: the current base model and keys being compacted
+stripes: staging buffers, the keys of a leaf are staged in the stripe of the leaf
+staged: number of staged keys not yet compacted
+compact: serializes compactions
+heat: correction distances of the leaves of the base model (see hotspot.go)
+*/
+type Updatable struct {
+	state   atomic.Pointer[updatableState]
+	stripes []stagingStripe
+	staged  atomic.Int64
+	compact sync.Mutex
+	heat    *leafHeat
+}
+
+// NewUpdatable returns an updatable index over the keys of rmi (which
+// must retain its keys, see Load) with the given number of lock stripes
+// for the staging buffers
+func NewUpdatable(rmi *RMI, stripes int) *Updatable {
+
+	if stripes < 1 {
+		stripes = 1
+	}
+
+	u := &Updatable{stripes: make([]stagingStripe, stripes), heat: newLeafHeat()}
+	for i := range u.stripes {
+		u.stripes[i].leaves = make(map[int][]*big.Int)
+	}
+	u.state.Store(&updatableState{base: rmi, staged: newStagedCounts(rmi.NumLeaves())})
+
+	return u
+}
+
+// RMI returns the current base model (staged keys are not part of it)
+func (u *Updatable) RMI() *RMI {
+	return u.state.Load().base
+}
+
+// Staged returns the number of inserted keys not yet compacted
+func (u *Updatable) Staged() int {
+	return int(u.staged.Load())
+}
+
+// stripe returns the lock stripe of the staged keys of leaf
+func (u *Updatable) stripe(leaf int) *stagingStripe {
+	return &u.stripes[leaf%len(u.stripes)]
+}
+
+// lockLeaf locks the stripe of the leaf of the current base model value is
+// routed to and returns the stripe, the state and the leaf; compactions
+// publish new states under all stripe locks, so the state is current until
+// the stripe is unlocked
+func (u *Updatable) lockLeaf(value *big.Int) (*stagingStripe, *updatableState, int) {
+	for {
+		base := u.state.Load().base
+		_, leaf := base.leaf(base.current.Load(), value)
+
+		s := u.stripe(leaf)
+		s.mu.Lock()
+		if state := u.state.Load(); state.base == base {
+			return s, state, leaf
+		}
+		s.mu.Unlock()
+	}
+}
+
+// Insert stages value; it is visible to Contains immediately
+// and becomes part of the base model at the next compaction
+func (u *Updatable) Insert(value *big.Int) {
+
+	s, state, leaf := u.lockLeaf(value)
+	s.leaves[leaf] = insertKey(s.leaves[leaf], value)
+	state.staged.add(leaf, 1)
+	s.mu.Unlock()
+
+	u.staged.Add(1)
+}
+
+// InsertBatch stages all values, locking each stripe once
+func (u *Updatable) InsertBatch(values []*big.Int) {
+
+	for len(values) > 0 {
+		base := u.state.Load().base
+		leaves := make([]int, len(values))
+		byStripe := make(map[*stagingStripe][]int)
+		for i, value := range values {
+			_, leaves[i] = base.leaf(base.current.Load(), value)
+			s := u.stripe(leaves[i])
+			byStripe[s] = append(byStripe[s], i)
+		}
+
+		// keys of stripes locked after a compaction are routed again
+		rest := make([]*big.Int, 0)
+		for s, batch := range byStripe {
+			s.mu.Lock()
+			state := u.state.Load()
+			if state.base != base {
+				s.mu.Unlock()
+				for _, i := range batch {
+					rest = append(rest, values[i])
+				}
+				continue
+			}
+
+			for _, i := range batch {
+				s.leaves[leaves[i]] = insertKey(s.leaves[leaves[i]], values[i])
+				state.staged.add(leaves[i], 1)
+			}
+			s.mu.Unlock()
+			u.staged.Add(int64(len(batch)))
+		}
+		values = rest
+	}
+}
+
+// Contains reports whether value is a live key of the base model or staged
+func (u *Updatable) Contains(value *big.Int) bool {
+
+	// the state is loaded under the stripe lock so that keys drained from
+	// the stripe by a compaction are always found in the state's folding keys
+	s, state, leaf := u.lockLeaf(value)
+	_, staged := searchKeys(s.leaves[leaf], value)
+	s.mu.Unlock()
+
+	if _, folding := searchKeys(state.folding[leaf], value); staged || folding {
+		return true
+	}
+
+	_, ok := state.base.Lookup(value)
+	return ok
+}
+
+// Lookup returns the index of the first occurrence of value among the keys
+// of the base model merged with the staged keys in sorted order (or false if
+// value is neither a live key of the base model nor staged); deleted keys of
+// the base model keep their positions until the next compaction drops them
+func (u *Updatable) Lookup(value *big.Int) (int, bool) {
+
+	// only the stripe of the leaf of value is locked, keys staged
+	// concurrently in other leaves may or may not be counted
+	s, state, leaf := u.lockLeaf(value)
+	smaller, staged := searchKeys(s.leaves[leaf], value)
+	smaller += state.staged.before(leaf)
+	s.mu.Unlock()
+
+	if state.folding != nil {
+		n, found := searchKeys(state.folding[leaf], value)
+		smaller += n + state.foldingBefore[leaf]
+		staged = staged || found
+	}
+
+	if !staged {
+		if _, ok := state.base.Lookup(value); !ok {
+			return 0, false
+		}
+	}
+
+	index := state.base.lowerBound(value) + smaller
+	if state.base.conf.splitWindow > 0 {
+		u.observe(state.base, value, index)
+	}
+
+	return index, true
+}
+
+// searchKeys returns the number of sorted keys smaller than value
+// and whether value is one of keys
+func searchKeys(keys []*big.Int, value *big.Int) (int, bool) {
+	i := sort.Search(len(keys), func(i int) bool {
+		return keys[i].Cmp(value) != -1
+	})
+
+	return i, i < len(keys) && keys[i].Cmp(value) == 0
+}
+
+// insertKey inserts value into the sorted keys
+func insertKey(keys []*big.Int, value *big.Int) []*big.Int {
+	i, _ := searchKeys(keys, value)
+	return slices.Insert(keys, i, value)
+}
+
+// Compact folds all staged keys (and the deletions of the base model)
+// into a new base model and publishes it; hot leaves are split (see
+// WithHotSpotSplits)
+func (u *Updatable) Compact() {
+	u.compactWith(func(old *RMI, staged map[int][]*big.Int) *RMI {
+		return old.fold(staged, u.heat.take())
+	})
+}
+
+/*
+CompactionReport describes a full compaction (see CompactAll)
+KeysBefore, KeysAfter: number of keys in the data array before and after
+Inserted: number of staged keys folded into the data array
+Tombstones: number of deleted keys dropped from the data array
+BytesReclaimed: approximate memory of the dropped keys
+*/
+type CompactionReport struct {
+	KeysBefore, KeysAfter int
+	Inserted, Tombstones  int
+	BytesReclaimed        int64
+}
+
+// approximate memory of a key: the *big.Int slot, the big.Int header and its words
+func approxKeyBytes(value *big.Int) int64 {
+	return 8 + 32 + 8*int64(len(value.Bits()))
+}
+
+// CompactAll rewrites the data array with all staged keys and without the
+// deleted keys, reassigns the ranks and retrains the whole model over them
+// (every subtree is affected as ranks shift); if the compacted keys do not
+// fit the shape of the model it falls back to the incremental Compact
+func (u *Updatable) CompactAll() CompactionReport {
+
+	report := CompactionReport{}
+	u.compactWith(func(old *RMI, staged map[int][]*big.Int) *RMI {
+
+		values, inserted := old.merge(staged)
+
+		report.KeysBefore = len(old.values)
+		report.KeysAfter = len(values)
+		report.Inserted = inserted
+		deleted := old.deletions()
+		for i, value := range old.values {
+			if deleted.isDeleted(i) {
+				report.Tombstones++
+				report.BytesReclaimed += approxKeyBytes(value)
+			}
+		}
+
+		hot := u.heat.take()
+		next, err := newRMI(values, old.width, old.depth, old.conf)
+		if err != nil {
+			return old.fold(staged, hot)
+		}
+
+		return next
+	})
+
+	return report
+}
+
+// compactWith drains the staging buffers, builds the next base model with
+// compact and publishes it
+func (u *Updatable) compactWith(compact func(old *RMI, staged map[int][]*big.Int) *RMI) {
+
+	u.compact.Lock()
+	defer u.compact.Unlock()
+
+	// drain the staging buffers
+	old := u.state.Load().base
+	folding := make(map[int][]*big.Int)
+	u.lockStripes()
+	for i := range u.stripes {
+		for leaf, keys := range u.stripes[i].leaves {
+			folding[leaf] = append(folding[leaf], keys...)
+		}
+		u.stripes[i].leaves = make(map[int][]*big.Int)
+	}
+	foldingBefore := make([]int, old.NumLeaves()+1)
+	for leaf, keys := range folding {
+		sortKeys(keys)
+		foldingBefore[leaf+1] = len(keys)
+	}
+	for leaf := 1; leaf < len(foldingBefore); leaf++ {
+		foldingBefore[leaf] += foldingBefore[leaf-1]
+	}
+	u.state.Store(&updatableState{base: old, staged: newStagedCounts(old.NumLeaves()), folding: folding, foldingBefore: foldingBefore})
+	u.unlockStripes()
+
+	drained := 0
+	for _, keys := range folding {
+		drained += len(keys)
+	}
+
+	next := compact(old, folding)
+
+	// keys staged during the compaction were routed with the old base
+	u.lockStripes()
+	keys := make([]*big.Int, 0)
+	for i := range u.stripes {
+		for _, staged := range u.stripes[i].leaves {
+			keys = append(keys, staged...)
+		}
+		u.stripes[i].leaves = make(map[int][]*big.Int)
+	}
+
+	staged := newStagedCounts(next.NumLeaves())
+	for _, key := range keys {
+		_, leaf := next.leaf(next.current.Load(), key)
+		s := u.stripe(leaf)
+		s.leaves[leaf] = insertKey(s.leaves[leaf], key)
+		staged.add(leaf, 1)
+	}
+	u.state.Store(&updatableState{base: next, staged: staged})
+	u.unlockStripes()
+
+	u.staged.Add(-int64(drained))
+}
+
+func (u *Updatable) lockStripes() {
+	for i := range u.stripes {
+		u.stripes[i].mu.Lock()
+	}
+}
+
+func (u *Updatable) unlockStripes() {
+	for i := range u.stripes {
+		u.stripes[i].mu.Unlock()
+	}
+}
+
+// RunCompactor compacts every interval while at least minStaged keys are
+// staged (or any leaf is hot, see WithHotSpotSplits), until ctx is done
+func (u *Updatable) RunCompactor(ctx context.Context, interval time.Duration, minStaged int) {
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if (u.Staged() > 0 && u.Staged() >= minStaged) || len(u.HotLeaves()) > 0 {
+				u.Compact()
+			}
+		}
+	}
+}
+
+// fold returns a new model over the live keys of rmi and the staged keys;
+// the internal layers are rescaled to the new number of keys so routing is
+// preserved, hot leaves are split in two models, leaves whose number of keys
+// changed are retrained and all other leaves are shifted to their keys' new indices
+func (rmi *RMI) fold(staged map[int][]*big.Int, hot map[int]bool) *RMI {
+
+	oldErrs := rmi.errorsOf(rmi.current.Load())
+	values, _ := rmi.merge(staged)
+
+	return rmi.refit(values, func(loc int, leaf *Node, task buildTask) (*Node, bool) {
+		if hot[loc] && len(task.values) > 0 {
+			return trainEnsemble(task), true
+		} else if len(task.values) != oldErrs[loc].count {
+			return rmi.trainLeaf(task), true
+		} else if len(task.values) > 0 {
+			return leaf.shifted(task.offset.Int64() - int64(oldErrs[loc].first)), false
+		}
+
+		return leaf, false
+	})
+}
+
+// refit returns a new model over values with the internal layers of rmi
+// rescaled to the new number of keys; every key is routed to its leaf and
+// each leaf is replaced by fit of its position, old node and new keys
+// (which reports whether it trained a model, to pace the retraining)
+func (rmi *RMI) refit(values []*big.Int, fit func(loc int, leaf *Node, task buildTask) (*Node, bool)) *RMI {
+
+	v := rmi.current.Load()
+
+	next := &RMI{width: rmi.width, depth: rmi.depth, values: values, conf: rmi.conf}
+	if next.conf.routingCapacity > 0 {
+		next.routing = newRoutingCache(next.conf.routingShift, next.conf.routingCapacity)
+	}
+
+	// rescale the internal layers from the old to the new max index
+	scale := big.NewFloat(math.Max(1, float64(len(values)-1)))
+	scale.Quo(scale, big.NewFloat(routingMaxIndex(v.maxIndex)))
+
+	nodes := make([][]*Node, rmi.depth)
+	for layer := 0; layer < rmi.depth-1; layer++ {
+		nodes[layer] = make([]*Node, len(v.nodes[layer]))
+		for i, node := range v.nodes[layer] {
+			nodes[layer][i] = node.scaled(scale)
+		}
+	}
+	nodes[rmi.depth-1] = make([]*Node, len(v.nodes[rmi.depth-1]))
+	copy(nodes[rmi.depth-1], v.nodes[rmi.depth-1])
+
+	// route the new keys and refit each leaf
+	routed := newVersion(v.epoch+1, nodes, len(values)-1).flatten()
+	p := next.conf.pacer()
+	tasks := make([]buildTask, len(nodes[rmi.depth-1]))
+	for i, value := range values {
+		if i%pacingChunk == pacingChunk-1 {
+			p.pause()
+		}
+
+		_, loc := next.leaf(routed, value)
+		if tasks[loc].values == nil {
+			tasks[loc].offset = big.NewInt(int64(i))
+		}
+		tasks[loc].values = append(tasks[loc].values, value)
+		tasks[loc].indices = append(tasks[loc].indices, big.NewInt(int64(i)))
+	}
+
+	// leaves left without keys predict the index following the earlier leaves
+	seen := 0
+	for loc := range tasks {
+		if tasks[loc].offset == nil {
+			tasks[loc].offset = big.NewInt(int64(min(seen, len(values)-1)))
+		}
+		seen += len(tasks[loc].values)
+	}
+
+	leaves := nodes[rmi.depth-1]
+	for loc, task := range tasks {
+		var trained bool
+		if leaves[loc], trained = fit(loc, leaves[loc], task); trained {
+			p.pause()
+		}
+
+		// last-mile tables map keys to their old indices, they are rebuilt below
+		leaves[loc] = leaves[loc].withoutTable()
+	}
+
+	// tables are attached to the packed nodes, which the queries read
+	folded := newVersion(v.epoch+1, nodes, len(values)-1).pack(next.conf.precision)
+	if next.conf.lastMile {
+		next.attachExactTables(folded)
+	}
+	next.current.Store(folded)
+
+	if next.conf.calibrate {
+		next.Calibrate()
+	}
+
+	return next
+}
+
+// withoutTable returns node without its last-mile table (node itself if it has none)
+func (node *Node) withoutTable() *Node {
+
+	if node.exact == nil {
+		return node
+	}
+
+	res := *node
+	res.exact = nil
+	if node.alt != nil {
+		alt := *node.alt
+		alt.exact = nil
+		res.alt = &alt
+	}
+
+	return &res
+}
+
+// merge returns the live keys of rmi merged with the staged keys
+// and the number of staged keys
+func (rmi *RMI) merge(staged map[int][]*big.Int) ([]*big.Int, int) {
+
+	inserted := make([]*big.Int, 0)
+	for _, keys := range staged {
+		inserted = append(inserted, keys...)
+	}
+	sortKeys(inserted)
+
+	deleted := rmi.deletions()
+	values := make([]*big.Int, 0, len(rmi.values)+len(inserted))
+	j := 0
+	for i, value := range rmi.values {
+		for j < len(inserted) && inserted[j].Cmp(value) == -1 {
+			values = append(values, inserted[j])
+			j++
+		}
+		if !deleted.isDeleted(i) {
+			values = append(values, value)
+		}
+	}
+
+	return append(values, inserted[j:]...), len(inserted)
+}
+
+// sortKeys sorts keys in increasing order
+func sortKeys(keys []*big.Int) {
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Cmp(keys[j]) == -1
+	})
+}
+
+// scaled returns a copy of the internal node whose outputs are multiplied
+// by scale (e.g., to route the same keys in a model with another max index)
+func (node *Node) scaled(scale *big.Float) *Node {
+	res := newLinearNode(new(big.Float).Mul(node.m, scale), new(big.Float).Mul(node.b, scale))
+	res.log, res.fit, res.fallback = node.log, node.fit, node.fallback
+	return res
+}
+
+// shifted returns a copy of the leaf (without its last-mile table)
+// whose predictions are moved by shift indices
+func (node *Node) shifted(shift int64) *Node {
+
+	delta := new(big.Float).SetInt64(shift)
+
+	res := newLinearNode(node.m, new(big.Float).Add(node.b, delta))
+	res.log, res.fit, res.fallback = node.log, node.fit, node.fallback
+	res.breakpoint = node.breakpoint
+	if node.alt != nil {
+		res.alt = newLinearNode(node.alt.m, new(big.Float).Add(node.alt.b, delta))
+		res.alt.log, res.alt.fit = node.alt.log, node.alt.fit
+	}
+
+	return res
+}
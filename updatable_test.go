@@ -0,0 +1,169 @@
+package rmi
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestUpdatableConcurrentInsert(t *testing.T) {
+	values := sortedTestData(4000)
+
+	// build over the even positions and insert the odd ones concurrently
+	base := make([]*big.Int, 0)
+	inserts := make([]*big.Int, 0)
+	for i, value := range values {
+		if i%2 == 0 {
+			base = append(base, value)
+		} else {
+			inserts = append(inserts, value)
+		}
+	}
+
+	rmi, _ := NewRMI(base, RMIWidthParameter, 2)
+	u := NewUpdatable(rmi, 8)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		u.RunCompactor(ctx, time.Millisecond, 100)
+		close(done)
+	}()
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i < len(inserts); i += 4 {
+				if i%3 == 0 {
+					u.InsertBatch(inserts[i : i+1])
+				} else {
+					u.Insert(inserts[i])
+				}
+				if !u.Contains(inserts[i]) {
+					t.Errorf("inserted key %v not found", i)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	cancel()
+	<-done
+
+	u.Compact()
+	if u.Staged() != 0 {
+		t.Fatalf("expected no staged keys after compaction but got %v", u.Staged())
+	}
+
+	folded := u.RMI()
+	if len(folded.values) != len(values) {
+		t.Fatalf("expected %v keys after compaction but got %v", len(values), len(folded.values))
+	}
+
+	for i, value := range values {
+		if folded.values[i].Cmp(value) != 0 {
+			t.Fatalf("compacted keys are not sorted at %v", i)
+		}
+		if index, ok := folded.findExact(folded.current.Load(), value); !ok || index != i {
+			t.Fatalf("failed to find key %v after compaction", i)
+		}
+	}
+
+	rebuilt, _ := NewRMI(values, RMIWidthParameter, 2)
+	t.Logf("max error folded %v rebuilt %v", folded.MaxError(), rebuilt.MaxError())
+}
+
+func TestUpdatableCompactsDeletions(t *testing.T) {
+	values := sortedTestData(1000)
+	rmi, _ := NewRMI(values, RMIWidthParameter, 2)
+
+	deleted := NewBitmap(len(values))
+	deleted.Set(10)
+	rmi.SetDeletions(deleted)
+
+	u := NewUpdatable(rmi, 1)
+	if u.Contains(values[10]) {
+		t.Fatalf("deleted key reported as contained")
+	}
+
+	u.Compact()
+	if len(u.RMI().values) != len(values)-1 || u.Contains(values[10]) {
+		t.Fatalf("expected the deleted key to be dropped by the compaction")
+	}
+}
+
+func TestCompactAll(t *testing.T) {
+	values := sortedTestData(2000)
+	rmi, _ := NewRMI(values, RMIWidthParameter, 2)
+
+	deleted := NewBitmap(len(values))
+	for i := 0; i < len(values); i += 4 {
+		deleted.Set(i)
+	}
+	rmi.SetDeletions(deleted)
+
+	u := NewUpdatable(rmi, 4)
+	extra := new(big.Int).Add(values[len(values)-1], big.NewInt(1))
+	u.Insert(extra)
+
+	report := u.CompactAll()
+	if report.KeysBefore != len(values) || report.Tombstones != len(values)/4 || report.Inserted != 1 {
+		t.Fatalf("unexpected compaction report %+v", report)
+	}
+	if report.KeysAfter != len(values)-len(values)/4+1 || report.BytesReclaimed <= 0 {
+		t.Fatalf("unexpected compaction report %+v", report)
+	}
+
+	// ranks are reassigned over the live keys
+	compacted := u.RMI()
+	for i, value := range compacted.values {
+		if index, ok := compacted.findExact(compacted.current.Load(), value); !ok || index != i {
+			t.Fatalf("failed to find compacted key %v", i)
+		}
+	}
+	if !u.Contains(extra) || u.Contains(values[0]) {
+		t.Fatalf("expected the inserted key to be kept and the deleted key to be dropped")
+	}
+}
+
+func TestUpdatableLookup(t *testing.T) {
+	values := sortedTestData(3000)
+
+	base := make([]*big.Int, 0)
+	inserts := make([]*big.Int, 0)
+	for i, value := range values {
+		if i%3 == 0 {
+			inserts = append(inserts, value)
+		} else {
+			base = append(base, value)
+		}
+	}
+
+	rmi, _ := NewRMI(base, RMIWidthParameter, 2)
+	u := NewUpdatable(rmi, 4)
+	for i := len(inserts) - 1; i >= 0; i-- {
+		u.Insert(inserts[i])
+	}
+
+	// staged keys are merged with the base keys at query time
+	check := func() {
+		for i, value := range values {
+			first, _ := searchKeys(values, value)
+			index, ok := u.Lookup(value)
+			if !ok || index != first {
+				t.Fatalf("Lookup of key %v returned %v, %v", i, index, ok)
+			}
+		}
+
+		if _, ok := u.Lookup(big.NewInt(-1)); ok {
+			t.Fatalf("found absent key")
+		}
+	}
+
+	check()
+	u.Compact()
+	check()
+}
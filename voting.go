@@ -0,0 +1,219 @@
+// voting.go: ensembles of independently trained models over the same keys.
+// Every query is answered by all members and the median of their indices is
+// returned, so a member fooled by an adversarial or drifted region of the
+// key space is outvoted as long as most members place the key correctly;
+// a query costs one evaluation per member. (Leaves with two models of one
+// RMI are a different mechanism, see ensemble.go.)
+
+package rmi
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"sync"
+)
+
+// magic bytes at the start of every saved ensemble
+var ensembleMagic = []byte("RMIE")
+
+/*
+Ensemble of models over the same keys answering with the median index
+models: the members, all built over the same number of keys
+values: the keys the members were built over (nil if not retained)
+stats: error statistics of the median over the keys, measured at most once
+*/
+type Ensemble struct {
+	models []*RMI
+	values []*big.Int
+
+	statsOnce sync.Once
+	stats     ErrorStats
+}
+
+// NewEnsemble returns the ensemble of the given models, which must be built
+// over the same keys (in any shape or configuration)
+func NewEnsemble(models ...*RMI) (*Ensemble, error) {
+
+	if len(models) == 0 {
+		return nil, errors.New("an ensemble needs at least one model")
+	}
+
+	e := &Ensemble{models: models}
+	maxIndex := models[0].current.Load().maxIndex
+	for i, model := range models {
+		if model.current.Load().maxIndex != maxIndex {
+			return nil, fmt.Errorf("model %v is built over a different number of keys", i)
+		}
+		if e.values == nil {
+			e.values = model.values
+		}
+	}
+
+	return e, nil
+}
+
+// BuildEnsemble builds one member over the sorted values with each of the
+// option lists (see NewRMIWithOptions), e.g. different shapes or model types
+func BuildEnsemble(values []*big.Int, configs ...[]Option) (*Ensemble, error) {
+
+	models := make([]*RMI, len(configs))
+	for i, opts := range configs {
+		model, err := NewRMIWithOptions(values, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("member %v: %w", i, err)
+		}
+		models[i] = model
+	}
+
+	return NewEnsemble(models...)
+}
+
+// Models returns the members of the ensemble
+func (e *Ensemble) Models() []*RMI {
+	return e.models
+}
+
+// GetIndex returns the median of the indices the members predict for value
+// (the lower median for an even number of members)
+func (e *Ensemble) GetIndex(value *big.Int) int {
+
+	indices := make([]int, len(e.models))
+	for i, model := range e.models {
+		indices[i] = model.GetIndex(value)
+	}
+
+	sort.Ints(indices)
+	return indices[(len(indices)-1)/2]
+}
+
+// ErrorStats returns the error statistics of GetIndex over the keys,
+// measured on first use (or decoded, see UnmarshalEnsemble)
+func (e *Ensemble) ErrorStats() ErrorStats {
+	e.statsOnce.Do(func() {
+		e.stats = e.measure()
+	})
+
+	return e.stats
+}
+
+// MemberStats returns the error statistics of every member
+func (e *Ensemble) MemberStats() []ErrorStats {
+
+	stats := make([]ErrorStats, len(e.models))
+	for i, model := range e.models {
+		stats[i] = model.ErrorStats()
+	}
+
+	return stats
+}
+
+// measure returns the error statistics of GetIndex over the keys
+func (e *Ensemble) measure() ErrorStats {
+
+	hist := errorHistogram{}
+	sum := 0.0
+	stats := ErrorStats{Keys: len(e.values)}
+	for i, value := range e.values {
+		d := int(math.Abs(float64(i - e.GetIndex(value))))
+		stats.Max = max(stats.Max, d)
+		sum += float64(d)
+		hist.add(d)
+	}
+
+	if stats.Keys > 0 {
+		stats.Mean = sum / float64(stats.Keys)
+	}
+	stats.Median, stats.P99 = hist.percentile(0.5), hist.percentile(0.99)
+
+	return stats
+}
+
+// MarshalBinary encodes the members (but not the keys) and the error
+// statistics of the ensemble: magic, number of members, every member as a
+// length prefixed model (see RMI.MarshalBinary) and the statistics
+func (e *Ensemble) MarshalBinary() ([]byte, error) {
+
+	buf := append(make([]byte, 0), ensembleMagic...)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(e.models)))
+	for _, model := range e.models {
+		encoded := model.encode()
+		buf = binary.BigEndian.AppendUint64(buf, uint64(len(encoded)))
+		buf = append(buf, encoded...)
+	}
+
+	stats := e.ErrorStats()
+	for _, field := range []int{stats.Keys, stats.Max, stats.Median, stats.P99} {
+		buf = binary.BigEndian.AppendUint64(buf, uint64(int64(field)))
+	}
+	buf = binary.BigEndian.AppendUint64(buf, math.Float64bits(stats.Mean))
+
+	return buf, nil
+}
+
+// UnmarshalEnsemble decodes an ensemble written by MarshalBinary; values are
+// the keys the members were built over (needed by exact queries of the
+// members) or nil if only GetIndex will be used
+func UnmarshalEnsemble(data []byte, values []*big.Int) (*Ensemble, error) {
+
+	if len(data) < len(ensembleMagic)+4 || string(data[:len(ensembleMagic)]) != string(ensembleMagic) {
+		return nil, errors.New("data is not a saved RMI ensemble")
+	}
+	data = data[len(ensembleMagic):]
+
+	n := binary.BigEndian.Uint32(data)
+	data = data[4:]
+	if uint64(n) > uint64(len(data))/8 {
+		return nil, errShortBuffer
+	}
+
+	models := make([]*RMI, n)
+	for i := range models {
+		if len(data) < 8 {
+			return nil, errShortBuffer
+		}
+
+		size := binary.BigEndian.Uint64(data)
+		data = data[8:]
+		if size > uint64(len(data)) {
+			return nil, errShortBuffer
+		}
+
+		model, err := decode(data[:size], Metadata{})
+		if err != nil {
+			return nil, fmt.Errorf("member %v: %w", i, err)
+		}
+
+		if values != nil && len(values) != model.current.Load().maxIndex+1 {
+			return nil, fmt.Errorf("ensemble was built over %v keys but %v were provided", model.current.Load().maxIndex+1, len(values))
+		}
+		model.values = values
+
+		models[i] = model
+		data = data[size:]
+	}
+
+	if len(data) != 40 {
+		return nil, errors.New("invalid ensemble statistics")
+	}
+
+	e, err := NewEnsemble(models...)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make([]int, 4)
+	for j := range fields {
+		if fields[j], err = platformInt(binary.BigEndian.Uint64(data[8*j:])); err != nil {
+			return nil, err
+		}
+	}
+	stats := ErrorStats{Keys: fields[0], Max: fields[1], Median: fields[2], P99: fields[3]}
+	stats.Mean = math.Float64frombits(binary.BigEndian.Uint64(data[32:]))
+	e.statsOnce.Do(func() { e.stats = stats })
+
+	return e, nil
+}
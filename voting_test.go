@@ -0,0 +1,73 @@
+package rmi
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestEnsembleVoting(t *testing.T) {
+
+	values := sortedTestData(20000)
+
+	// one member is badly wrong everywhere: a single constant model
+	ensemble, err := BuildEnsemble(values, []Option{WithWidth(100), WithDepth(2)},
+		[]Option{WithWidth(20), WithDepth(3)}, []Option{WithWidth(1), WithDepth(1), WithModelType(ModelConstant)})
+	if err != nil {
+		t.Fatalf("Failed to build ensemble %v\n", err)
+	}
+
+	members := ensemble.MemberStats()
+	for i, value := range values {
+		errs := make([]int, len(members))
+		for m, model := range ensemble.Models() {
+			errs[m] = abs(model.GetIndex(value) - i)
+		}
+		sort.Ints(errs)
+
+		// a majority of the members is within the median error of the key
+		if e := abs(ensemble.GetIndex(value) - i); e > errs[1] {
+			t.Fatalf("ensemble error %v of key %v exceeds the median member error %v", e, i, errs[1])
+		}
+	}
+
+	stats := ensemble.ErrorStats()
+	if stats.Keys != len(values) || stats.Max > max(members[0].Max, members[1].Max) {
+		t.Fatalf("ensemble stats %+v are worse than the accurate members %+v", stats, members[:2])
+	}
+
+	data, _ := ensemble.MarshalBinary()
+	decoded, err := UnmarshalEnsemble(data, nil)
+	if err != nil {
+		t.Fatalf("failed to decode ensemble %v", err)
+	}
+
+	if decoded.ErrorStats() != stats || len(decoded.Models()) != 3 {
+		t.Fatalf("decoded ensemble stats %+v differ from %+v", decoded.ErrorStats(), stats)
+	}
+
+	for i, value := range values {
+		if decoded.GetIndex(value) != ensemble.GetIndex(value) {
+			t.Fatalf("decoded ensemble predicts key %v differently", i)
+		}
+	}
+
+	if _, err := UnmarshalEnsemble(data[:len(data)-1], nil); err == nil {
+		t.Fatalf("expected an error decoding a truncated ensemble")
+	}
+	if _, err := UnmarshalEnsemble(data, values[1:]); err == nil {
+		t.Fatalf("expected an error decoding with the wrong keys")
+	}
+}
+
+func TestNewEnsembleErrors(t *testing.T) {
+
+	if _, err := NewEnsemble(); err == nil {
+		t.Fatalf("expected an error for an empty ensemble")
+	}
+
+	a, _ := NewRMI(sortedTestData(1000), 10, 2)
+	b, _ := NewRMI(sortedTestData(2000), 10, 2)
+	if _, err := NewEnsemble(a, b); err == nil {
+		t.Fatalf("expected an error for members over different keys")
+	}
+}
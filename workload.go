@@ -0,0 +1,139 @@
+// workload.go: simulated query workloads for capacity planning.
+// Queries arrive either as fast as possible (closed loop) or following
+// a Poisson process at a target rate (open loop); in the open loop case
+// latency is measured from the scheduled arrival so that queueing delay
+// is not hidden when the index cannot keep up.
+
+package rmi
+
+import (
+	"math"
+	"math/big"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+/*
+Workload describes a simulated query mix
+PointFraction: fraction of point queries, the rest are range queries
+RangeWidth: number of keys spanned by each range query
+Skew: Zipf exponent of the key popularity (values <= 1 mean uniform)
+Rate: target arrival rate in queries per second (0 runs a closed loop)
+Duration: how long to run the workload
+Workers: number of concurrent clients
+Seed: seed of the random key and arrival generators
+*/
+type Workload struct {
+	PointFraction float64
+	RangeWidth    int
+	Skew          float64
+	Rate          float64
+	Duration      time.Duration
+	Workers       int
+	Seed          int64
+}
+
+/*
+WorkloadReport summarises a workload run
+Queries, PointQueries, RangeQueries: number of queries completed
+QPS: sustained throughput over the run
+P50, P99, P999, Max: latency percentiles
+*/
+type WorkloadReport struct {
+	Queries, PointQueries, RangeQueries int
+	QPS                                 float64
+	P50, P99, P999, Max                 time.Duration
+}
+
+// RunWorkload runs the simulated workload against the index and reports
+// throughput and tail latency; point queries are Nearest lookups and range
+// queries locate both range ends in the retained keys, so a model without
+// keys (see Load) runs an empty workload
+func (rmi *RMI) RunWorkload(w Workload) WorkloadReport {
+
+	if len(rmi.values) == 0 {
+		return WorkloadReport{}
+	}
+
+	workers := int(math.Max(1, float64(w.Workers)))
+	latencies := make([][]time.Duration, workers)
+	points := make([]int, workers)
+
+	start := time.Now()
+	deadline := start.Add(w.Duration)
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < workers; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(w.Seed + int64(worker)))
+			keys := rmi.keyGenerator(r, w.Skew)
+
+			arrival := start
+			for {
+				scheduled := time.Now()
+				if w.Rate > 0 {
+					// Poisson arrivals split evenly among the workers
+					arrival = arrival.Add(time.Duration(r.ExpFloat64() / (w.Rate / float64(workers)) * float64(time.Second)))
+					if wait := time.Until(arrival); wait > 0 {
+						time.Sleep(wait)
+					}
+					scheduled = arrival
+				}
+
+				if scheduled.After(deadline) {
+					return
+				}
+
+				if r.Float64() < w.PointFraction {
+					rmi.Nearest(rmi.values[keys()])
+					points[worker]++
+				} else {
+					lo := keys()
+					hi := int(math.Min(float64(lo+w.RangeWidth), float64(len(rmi.values)-1)))
+					rmi.lowerBound(rmi.values[lo])
+					rmi.lowerBound(new(big.Int).Add(rmi.values[hi], big.NewInt(1)))
+				}
+
+				latencies[worker] = append(latencies[worker], time.Since(scheduled))
+			}
+		}(worker)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	all := make([]time.Duration, 0)
+	report := WorkloadReport{}
+	for worker := range latencies {
+		all = append(all, latencies[worker]...)
+		report.PointQueries += points[worker]
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i] < all[j] })
+
+	report.Queries = len(all)
+	report.RangeQueries = report.Queries - report.PointQueries
+	report.QPS = float64(report.Queries) / elapsed.Seconds()
+	if len(all) > 0 {
+		report.P50 = all[len(all)*50/100]
+		report.P99 = all[len(all)*99/100]
+		report.P999 = all[len(all)*999/1000]
+		report.Max = all[len(all)-1]
+	}
+
+	return report
+}
+
+// keyGenerator returns a generator of key positions with the given skew;
+// the model must retain at least one key
+func (rmi *RMI) keyGenerator(r *rand.Rand, skew float64) func() int {
+	n := len(rmi.values)
+	if skew <= 1 || n < 2 {
+		return func() int { return r.Intn(n) }
+	}
+
+	zipf := rand.NewZipf(r, skew, 1, uint64(n-1))
+	return func() int { return int(zipf.Uint64()) }
+}
@@ -0,0 +1,41 @@
+package rmi
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRunWorkload(t *testing.T) {
+	rmi, _, _ := generateTestRMI()
+
+	for _, rate := range []float64{0, 2000} {
+		report := rmi.RunWorkload(Workload{
+			PointFraction: 0.8,
+			RangeWidth:    100,
+			Skew:          1.2,
+			Rate:          rate,
+			Duration:      50 * time.Millisecond,
+			Workers:       2,
+			Seed:          1,
+		})
+
+		if report.Queries == 0 || report.PointQueries == 0 || report.RangeQueries == 0 {
+			t.Fatalf("expected a point/range mix of queries, got %+v", report)
+		}
+
+		if report.P50 > report.P99 || report.P99 > report.P999 || report.P999 > report.Max {
+			t.Fatalf("latency percentiles are not ordered %+v", report)
+		}
+
+		// an open loop must not exceed its target rate by much
+		if rate > 0 && report.QPS > 2*rate {
+			t.Fatalf("throughput %v exceeds the target rate %v", report.QPS, rate)
+		}
+	}
+
+	// a model without keys has nothing to query
+	empty, _ := NewRMI(nil, 1, 1)
+	if report := empty.RunWorkload(Workload{Duration: time.Millisecond}); report.Queries != 0 {
+		t.Fatalf("expected an empty workload, got %+v", report)
+	}
+}